@@ -12,6 +12,94 @@ type Config struct {
 	MaxEmails    int    `json:"max_emails"`
 	DefaultLabel string `json:"default_label"`
 	Theme        string `json:"theme"`
+
+	// SecondaryTimeZone is shown next to local times in the calendar, e.g.
+	// "Europe/Berlin", "PT" or "Tokyo"
+	SecondaryTimeZone string `json:"secondary_time_zone,omitempty"`
+
+	AI AIConfig `json:"ai,omitempty"`
+
+	Digest DigestConfig `json:"digest,omitempty"`
+
+	Notifications NotificationConfig `json:"notifications,omitempty"`
+
+	Daemon DaemonConfig `json:"daemon,omitempty"`
+}
+
+// DaemonConfig is how often the daemon syncs each account. Intervals are
+// durations such as "10m" or "1h".
+type DaemonConfig struct {
+	Interval      string                         `json:"interval,omitempty"`       // default 30m
+	MaxConcurrent int                            `json:"max_concurrent,omitempty"` // accounts syncing at once (default 4)
+	Accounts      map[string]DaemonAccountConfig `json:"accounts,omitempty"`       // by account email
+}
+
+// DaemonAccountConfig overrides the sync interval of an account
+type DaemonAccountConfig struct {
+	Interval string `json:"interval,omitempty"`
+}
+
+// DigestConfig schedules a daily digest of unread mail in the daemon
+type DigestConfig struct {
+	Time    string `json:"time,omitempty"`     // e.g. "08:00"; no time disables the scheduled digest
+	GroupBy string `json:"group_by,omitempty"` // "thread" (default) or "sender"
+	Output  string `json:"output,omitempty"`   // file to write the digest to
+	Send    bool   `json:"send,omitempty"`     // email the digest to the first account's own address
+}
+
+// NotificationConfig is how the daemon shows desktop notifications for new
+// mail. Senders are matched by address ("boss@example.com") or domain
+// ("example.com").
+type NotificationConfig struct {
+	Off        bool                                 `json:"off,omitempty"`
+	QuietHours string                               `json:"quiet_hours,omitempty"` // e.g. "22:00-07:00"; no notifications then
+	VIPs       []string                             `json:"vips,omitempty"`
+	VIPOnly    bool                                 `json:"vip_only,omitempty"` // only notify for mail from VIPs
+	Batch      int                                  `json:"batch,omitempty"`    // more new messages than this at once make one notification (default 3)
+	Accounts   map[string]NotificationAccountConfig `json:"accounts,omitempty"` // by account email
+}
+
+// NotificationAccountConfig turns notifications off for an account, or
+// picks its mailboxes
+type NotificationAccountConfig struct {
+	Off       bool     `json:"off,omitempty"`
+	Mailboxes []string `json:"mailboxes,omitempty"` // default INBOX
+}
+
+// AIConfig selects the AI backend. With no provider, maily uses the first
+// AI CLI it finds (claude, codex, gemini, vibe, ollama).
+type AIConfig struct {
+	Provider       string `json:"provider,omitempty"`         // "openai" (any OpenAI-compatible server), "ollama" (REST API) or a CLI: "claude", "codex", "gemini", "vibe"
+	BaseURL        string `json:"base_url,omitempty"`         // e.g. "https://api.openai.com/v1" or "http://localhost:11434"
+	Model          string `json:"model,omitempty"`            // e.g. "gpt-4o-mini" or "llama3.2:3b"
+	APIKey         string `json:"api_key,omitempty"`          // Bearer token; $OPENAI_API_KEY if empty
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`  // per request (default 120)
+	MaxInputTokens int    `json:"max_input_tokens,omitempty"` // longer prompts are cut in the middle (default 8000)
+	DraftTone      string `json:"draft_tone,omitempty"`       // default tone of drafted replies: "neutral", "friendly", "formal" or "brief"
+
+	// Tasks overrides the provider or model per task: "summarize",
+	// "calendar" (parsing events), "draft" (replies), "chat" (questions
+	// about the mailbox) or "triage" (classifying new mail)
+	Tasks map[string]AITaskConfig `json:"tasks,omitempty"`
+
+	// Privacy applies to prompts sent to providers that are not on this
+	// machine
+	Privacy PrivacyConfig `json:"privacy,omitempty"`
+}
+
+// PrivacyConfig masks personal data in prompts before they leave the
+// machine, restoring it in the responses, and keeps a log of what was sent
+type PrivacyConfig struct {
+	Redact   bool     `json:"redact,omitempty"`    // mask email addresses, phone numbers, IBANs and card numbers
+	Patterns []string `json:"patterns,omitempty"`  // more regular expressions to mask, e.g. "ACME-[0-9]{6}"
+	AuditLog bool     `json:"audit_log,omitempty"` // append every prompt sent to ~/.config/maily/ai-audit.log
+}
+
+// AITaskConfig is the provider and model for one AI task. A different
+// provider uses its default URL.
+type AITaskConfig struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
 }
 
 func DefaultConfig() Config {
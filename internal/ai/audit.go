@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"maily/config"
+)
+
+const auditLogFileName = "ai-audit.log"
+
+// auditEntry is a line of the audit log: a prompt as it was sent, after
+// redaction
+type auditEntry struct {
+	Time     time.Time      `json:"time"`
+	Task     string         `json:"task,omitempty"`
+	Provider string         `json:"provider"`
+	Redacted map[string]int `json:"redacted,omitempty"` // masked values per kind
+	Prompt   string         `json:"prompt"`
+}
+
+// AuditLogPath returns the file prompts are logged to when audit_log is set
+func AuditLogPath() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, auditLogFileName), nil
+}
+
+// audit appends prompt, as it is about to be sent, to the audit log
+func (c *Client) audit(prompt string, r *redactor) error {
+	path, err := AuditLogPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	entry := auditEntry{Time: time.Now(), Task: string(c.task), Provider: c.Provider(), Prompt: prompt}
+	if r != nil {
+		entry.Redacted = r.Counts()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os/exec"
 	"strings"
+
+	"maily/config"
 )
 
-// Provider represents an AI CLI provider
+// Provider represents an AI provider: a CLI, or an HTTP API configured in
+// config.json
 type Provider string
 
 const (
@@ -16,50 +20,159 @@ const (
 	ProviderCodex  Provider = "codex"
 	ProviderGemini Provider = "gemini"
 	ProviderVibe   Provider = "vibe"
-	ProviderOllama Provider = "ollama"
+	ProviderOllama Provider = "ollama" // REST API if configured, else the CLI
+	ProviderOpenAI Provider = "openai" // Any OpenAI-compatible chat completions API
 	ProviderNone   Provider = ""
 )
 
-// Client handles AI operations using available CLI tools
+// Task is a kind of AI work that can use its own provider and model
+type Task string
+
+const (
+	TaskDefault   Task = ""
+	TaskSummarize Task = "summarize" // Email and thread summaries
+	TaskCalendar  Task = "calendar"  // Parsing events from text
+	TaskDraft     Task = "draft"     // Drafting replies
+	TaskChat      Task = "chat"      // Answering questions about the mailbox
+	TaskTriage    Task = "triage"    // Classifying new mail
+)
+
+// Tasks lists the tasks that can be configured under "tasks" in config.json
+var Tasks = []Task{TaskSummarize, TaskCalendar, TaskDraft, TaskChat, TaskTriage}
+
+// defaultModels are used for CLIs when config.json names no model; an
+// empty model leaves the choice to the CLI
+var defaultModels = map[Provider]string{
+	ProviderClaude: "haiku",
+	ProviderGemini: "gemini-2.5-flash",
+	ProviderOllama: defaultOllamaModel,
+}
+
+// Client handles AI operations using the configured HTTP provider or an
+// available CLI tool
 type Client struct {
 	provider Provider
+	model    string
+	http     *httpBackend // nil for CLIs
+	task     Task
+	privacy  config.PrivacyConfig
 }
 
-// NewClient creates a new AI client, auto-detecting available CLI
+// NewClient creates a new AI client for the provider configured in
+// config.json, auto-detecting an available CLI if there is none
 func NewClient() *Client {
-	return &Client{
-		provider: detectProvider(),
+	return NewTaskClient(TaskDefault)
+}
+
+// NewTaskClient creates a new AI client for task, using the provider and
+// model configured for it in config.json
+func NewTaskClient(task Task) *Client {
+	cfg, _ := config.Load()
+	c := NewClientWithConfig(TaskConfig(cfg.AI, task))
+	c.task = task
+	return c
+}
+
+// TaskConfig returns cfg with the overrides configured for task applied
+func TaskConfig(cfg config.AIConfig, task Task) config.AIConfig {
+	override, ok := cfg.Tasks[string(task)]
+	if !ok {
+		return cfg
+	}
+	if override.Provider != "" && !strings.EqualFold(override.Provider, cfg.Provider) {
+		// Another provider's model and URL don't apply
+		cfg.Provider, cfg.Model, cfg.BaseURL = override.Provider, "", ""
+	}
+	if override.Model != "" {
+		cfg.Model = override.Model
+	}
+	return cfg
+}
+
+// NewClientWithConfig creates a new AI client for cfg
+func NewClientWithConfig(cfg config.AIConfig) *Client {
+	provider := Provider(strings.ToLower(cfg.Provider))
+	switch {
+	case provider == ProviderOpenAI || provider == ProviderOllama:
+		// A configured Ollama is reached over its REST API; only a
+		// detected one runs as a CLI
+		http := newHTTPBackend(provider, cfg)
+		return &Client{provider: provider, model: http.model, http: http, privacy: cfg.Privacy}
+	case provider == ProviderNone:
+		provider = detectProvider()
+	case !commandExists(string(provider)):
+		// A configured CLI that is not installed
+		provider = ProviderNone
+	}
+
+	model := cfg.Model
+	if model == "" {
+		model = defaultModels[provider]
 	}
+	return &Client{provider: provider, model: model, privacy: cfg.Privacy}
 }
 
-// Available returns true if an AI CLI is available
+// Available returns true if an AI provider is configured or a CLI is found
 func (c *Client) Available() bool {
 	return c.provider != ProviderNone
 }
 
-// Provider returns the detected provider name with model info
+// Provider returns the provider with its model, e.g. "claude (haiku)"
 func (c *Client) Provider() string {
-	switch c.provider {
-	case ProviderClaude:
-		return "claude-haiku"
-	case ProviderCodex:
-		return "codex"
-	case ProviderGemini:
-		return "gemini-2.5-flash"
-	case ProviderVibe:
-		return "vibe"
-	case ProviderOllama:
-		return "llama3.2:3b"
-	default:
+	if c.model == "" {
 		return string(c.provider)
 	}
+	return string(c.provider) + " (" + c.model + ")"
 }
 
-// Call executes a prompt using the detected AI CLI and returns the response
+// Local reports whether prompts stay on this machine: an Ollama model, or
+// an OpenAI-compatible server on localhost
+func (c *Client) Local() bool {
+	if c.provider == ProviderOllama {
+		return c.http == nil || isLocalURL(c.http.baseURL)
+	}
+	return c.http != nil && isLocalURL(c.http.baseURL)
+}
+
+// Call executes a prompt using the configured provider and returns the
+// response. Prompts for providers that are not on this machine are
+// redacted and logged as set under "privacy" in config.json; when the
+// audit log cannot be written, nothing is sent.
 func (c *Client) Call(prompt string) (string, error) {
 	if c.provider == ProviderNone {
 		return "", errors.New("no AI CLI found - install claude, codex, gemini, vibe, or ollama")
 	}
+	if c.Local() {
+		return c.call(prompt)
+	}
+
+	var r *redactor
+	if c.privacy.Redact {
+		var err error
+		if r, err = newRedactor(c.privacy.Patterns); err != nil {
+			return "", err
+		}
+		prompt = r.Redact(prompt)
+	}
+	if c.privacy.AuditLog {
+		if err := c.audit(prompt, r); err != nil {
+			return "", fmt.Errorf("failed to write AI audit log: %w", err)
+		}
+	}
+
+	response, err := c.call(prompt)
+	if err != nil || r == nil {
+		return response, err
+	}
+	return r.Restore(response), nil
+}
+
+// call sends prompt to the provider as it is
+func (c *Client) call(prompt string) (string, error) {
+	if c.http != nil {
+		output, err := c.http.call(prompt)
+		return strings.TrimSpace(output), err
+	}
 
 	var cmd *exec.Cmd
 	var parseFunc func(string) string
@@ -67,20 +180,22 @@ func (c *Client) Call(prompt string) (string, error) {
 	switch c.provider {
 	case ProviderClaude:
 		// claude -p "prompt" --model haiku --output-format json
-		// Use haiku for fast, cheap summarization
-		cmd = exec.Command("claude", "-p", prompt, "--model", "haiku", "--output-format", "json")
+		cmd = exec.Command("claude", "-p", prompt, "--model", c.model, "--output-format", "json")
 		parseFunc = parseClaudeOutput
 
 	case ProviderCodex:
 		// codex exec "prompt" --json
-		// Use default model (mini models require reasoning_effort config change)
-		cmd = exec.Command("codex", "exec", prompt, "--json")
+		// Default model unless configured (mini models require reasoning_effort config change)
+		args := []string{"exec", prompt, "--json"}
+		if c.model != "" {
+			args = append(args, "--model", c.model)
+		}
+		cmd = exec.Command("codex", args...)
 		parseFunc = parseCodexOutput
 
 	case ProviderGemini:
 		// gemini -p "prompt" -m gemini-2.5-flash --output-format json
-		// Use flash model for fast, cheap summarization
-		cmd = exec.Command("gemini", "-p", prompt, "-m", "gemini-2.5-flash", "--output-format", "json")
+		cmd = exec.Command("gemini", "-p", prompt, "-m", c.model, "--output-format", "json")
 		parseFunc = parseGeminiOutput
 
 	case ProviderVibe:
@@ -91,8 +206,7 @@ func (c *Client) Call(prompt string) (string, error) {
 
 	case ProviderOllama:
 		// ollama run model "prompt"
-		// Use smaller model for summarization
-		cmd = exec.Command("ollama", "run", "llama3.2:3b", prompt)
+		cmd = exec.Command("ollama", "run", c.model, prompt)
 		parseFunc = func(s string) string { return s }
 
 	default:
@@ -0,0 +1,78 @@
+package ai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"maily/config"
+)
+
+func TestHTTPProviders(t *testing.T) {
+	var got struct {
+		Model    string        `json:"model"`
+		Messages []chatMessage `json:"messages"`
+		Stream   *bool         `json:"stream"`
+	}
+	var path, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":" from openai \n"}}]}`))
+		case "/api/chat":
+			w.Write([]byte(`{"message":{"role":"assistant","content":"from ollama"},"done":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"message":"model not found"}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClientWithConfig(config.AIConfig{Provider: "openai", BaseURL: server.URL + "/v1/", Model: "test-model", APIKey: "secret"})
+	if !client.Available() || client.Provider() != "openai (test-model)" {
+		t.Fatalf("openai client: available %v, provider %q", client.Available(), client.Provider())
+	}
+	reply, err := client.Call("Summarize this")
+	if err != nil || reply != "from openai" {
+		t.Fatalf("openai Call = %q, %v", reply, err)
+	}
+	if path != "/v1/chat/completions" || auth != "Bearer secret" || got.Model != "test-model" ||
+		len(got.Messages) != 1 || got.Messages[0].Role != "user" || got.Messages[0].Content != "Summarize this" {
+		t.Errorf("openai request: %s %q %+v", path, auth, got)
+	}
+
+	// Prompts over max_input_tokens lose their middle, not their instructions.
+	// The draft task goes to Ollama instead of OpenAI.
+	cfg := config.AIConfig{
+		Provider: "openai", BaseURL: server.URL + "/v1", Model: "test-model", MaxInputTokens: 50,
+		Tasks: map[string]config.AITaskConfig{"draft": {Provider: "ollama"}, "summarize": {Model: "small-model"}},
+	}
+	if c := NewClientWithConfig(TaskConfig(cfg, TaskSummarize)); c.Provider() != "openai (small-model)" {
+		t.Errorf("summarize client = %s", c.Provider())
+	}
+	draft := TaskConfig(cfg, TaskDraft)
+	draft.BaseURL = server.URL
+	client = NewClientWithConfig(draft)
+	prompt := "Summarize this email:\n" + strings.Repeat("blah ", 200) + "\nRespond with bullet points."
+	if reply, err := client.Call(prompt); err != nil || reply != "from ollama" {
+		t.Fatalf("ollama Call = %q, %v", reply, err)
+	}
+	sent := got.Messages[0].Content
+	if path != "/api/chat" || auth != "" || got.Model != defaultOllamaModel || got.Stream == nil || *got.Stream {
+		t.Errorf("ollama request: %s %q %+v", path, auth, got)
+	}
+	if len(sent) > 50*charsPerToken || !strings.HasPrefix(sent, "Summarize this email:") || !strings.HasSuffix(sent, "Respond with bullet points.") {
+		t.Errorf("truncated prompt = %q", sent)
+	}
+
+	client = NewClientWithConfig(config.AIConfig{Provider: "openai", BaseURL: server.URL + "/missing"})
+	if _, err := client.Call("hi"); err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("error response: %v", err)
+	}
+}
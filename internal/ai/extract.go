@@ -0,0 +1,135 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// extractAttempts is how many times ExtractEvents asks for events before
+// giving up on malformed JSON
+const extractAttempts = 3
+
+// ExtractEventsPrompt builds a prompt for extracting calendar events from
+// an email sent at sent as a JSON array of ParsedEvent objects
+func ExtractEventsPrompt(from, subject, body string, sent, now time.Time) string {
+	return fmt.Sprintf(`Extract the calendar events, meetings, and deadlines from this email.
+
+Current date/time: %s
+Current time zone: %s
+
+From: %s
+Subject: %s
+Sent: %s
+
+%s
+
+Respond with ONLY a JSON array (no markdown, no explanation), one object per event:
+[
+  {
+    "title": "event title",
+    "start_time": "2024-12-25T10:00:00-08:00",
+    "end_time": "2024-12-25T11:00:00-08:00",
+    "location": "room, address or meeting link, otherwise empty string",
+    "attendees": ["alice@example.com"],
+    "recurrence": "",
+    "time_zone": ""
+  }
+]
+
+Rules:
+- start_time and end_time must be in RFC3339 format with timezone
+- If no duration is given, the event lasts 1 hour; a deadline lasts 30 minutes and starts at 09:00 if no time is given
+- Interpret relative dates like "tomorrow" or "next Monday" from when the email was sent
+- attendees are the email addresses of the people taking part, as written in the email; leave the list empty if none are given
+- If the event repeats, set recurrence to an iCalendar RRULE value without the "RRULE:" prefix, e.g. "FREQ=WEEKLY;BYDAY=MO"; otherwise an empty string
+- If the email names a time zone for the times ("3pm PT", "10:00 Berlin time"), give the times with that zone's UTC offset and set time_zone to its IANA name; otherwise use the current time zone and an empty time_zone
+- Skip events that are already over, and dates that are not events (e.g. when the email was sent)
+- If there are no events, respond with []
+
+Respond with ONLY the JSON array, no other text.`, now.Format(time.RFC3339), localZoneName(now), from, subject, sent.Format(time.RFC3339), body)
+}
+
+// repairEventsPrompt asks again for the events after response could not be
+// used, quoting the error
+func repairEventsPrompt(prompt, response string, err error) string {
+	return fmt.Sprintf(`%s
+
+Your previous response could not be used:
+
+%s
+
+Error: %v
+
+Respond again with ONLY a valid JSON array of event objects, as described above.`, prompt, response, err)
+}
+
+// ParseEventsResponse parses and validates the AI JSON array of events.
+// Every event needs a title and an RFC3339 start time; a missing end time
+// means one hour. Attendees that are not email addresses are dropped.
+func ParseEventsResponse(response string) ([]ParsedEvent, error) {
+	response = stripMarkdownCodeFences(response)
+	if strings.HasPrefix(response, "{") {
+		response = "[" + response + "]"
+	}
+
+	var events []ParsedEvent
+	if err := json.Unmarshal([]byte(response), &events); err != nil {
+		return nil, fmt.Errorf("failed to parse AI response: %w", err)
+	}
+
+	for i := range events {
+		e := &events[i]
+		e.Title = strings.TrimSpace(e.Title)
+		if e.Title == "" {
+			return nil, fmt.Errorf("event %d has no title", i+1)
+		}
+		start, err := e.GetStartTime()
+		if err != nil {
+			return nil, fmt.Errorf("event %d has an invalid start_time: %w", i+1, err)
+		}
+		if e.EndTime == "" {
+			e.EndTime = start.Add(time.Hour).Format(time.RFC3339)
+		}
+		end, err := e.GetEndTime()
+		if err != nil {
+			return nil, fmt.Errorf("event %d has an invalid end_time: %w", i+1, err)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("event %d ends before it starts", i+1)
+		}
+
+		var attendees []string
+		for _, a := range e.Attendees {
+			if addr, err := mail.ParseAddress(a); err == nil {
+				attendees = append(attendees, addr.Address)
+			}
+		}
+		e.Attendees = attendees
+	}
+	return events, nil
+}
+
+// ExtractEvents asks the AI for the events in an email. Responses that are
+// not a valid JSON array of events are sent back with the error, up to
+// extractAttempts times in all.
+func (c *Client) ExtractEvents(from, subject, body string, sent, now time.Time) ([]ParsedEvent, error) {
+	prompt := ExtractEventsPrompt(from, subject, body, sent, now)
+	request := prompt
+	var lastErr error
+	for attempt := 0; attempt < extractAttempts; attempt++ {
+		response, err := c.Call(request)
+		if err != nil {
+			return nil, err
+		}
+		events, err := ParseEventsResponse(response)
+		if err == nil {
+			return events, nil
+		}
+		lastErr = err
+		request = repairEventsPrompt(prompt, response, err)
+	}
+	return nil, fmt.Errorf("no valid events after %d attempts: %w", extractAttempts, lastErr)
+}
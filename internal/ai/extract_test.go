@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"maily/config"
+)
+
+func TestExtractEventsRetries(t *testing.T) {
+	responses := []string{
+		"Sure! Here are the events: Title: Kickoff",
+		`[{"title": "Kickoff", "start_time": "2025-03-04T10:00:00Z"}]`,
+	}
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []chatMessage `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		prompts = append(prompts, req.Messages[0].Content)
+		content, _ := json.Marshal(responses[len(prompts)-1])
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":` + string(content) + `}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClientWithConfig(config.AIConfig{Provider: "openai", BaseURL: server.URL + "/v1", Model: "test-model"})
+	events, err := client.ExtractEvents("bob@example.com", "Kickoff", "See you Tuesday at 10", time.Now(), time.Now())
+	if err != nil {
+		t.Fatalf("ExtractEvents: %v", err)
+	}
+	if len(prompts) != 2 || !strings.Contains(prompts[1], "failed to parse AI response") {
+		t.Errorf("expected one retry quoting the error, got prompts %q", prompts)
+	}
+	if len(events) != 1 || events[0].Title != "Kickoff" || events[0].EndTime != "2025-03-04T11:00:00Z" {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func TestParseEventsResponse(t *testing.T) {
+	events, err := ParseEventsResponse("```json\n" + `[{"title": "Review", "start_time": "2025-03-04T10:00:00+01:00",
+		"end_time": "2025-03-04T10:30:00+01:00", "location": "Room 4",
+		"attendees": ["Alice <alice@example.com>", "the whole team", "bob@example.com"]}]` + "\n```")
+	if err != nil {
+		t.Fatalf("ParseEventsResponse: %v", err)
+	}
+	if len(events) != 1 || events[0].Location != "Room 4" ||
+		strings.Join(events[0].Attendees, ",") != "alice@example.com,bob@example.com" {
+		t.Errorf("events = %+v", events)
+	}
+
+	if events, err := ParseEventsResponse("[]"); err != nil || len(events) != 0 {
+		t.Errorf("empty array = %v, %v", events, err)
+	}
+	for _, bad := range []string{
+		`[{"title": "", "start_time": "2025-03-04T10:00:00Z"}]`,
+		`[{"title": "Review", "start_time": "tomorrow at 10"}]`,
+		`[{"title": "Review", "start_time": "2025-03-04T10:00:00Z", "end_time": "2025-03-04T09:00:00Z"}]`,
+	} {
+		if _, err := ParseEventsResponse(bad); err == nil {
+			t.Errorf("ParseEventsResponse(%s) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"maily/config"
+)
+
+// Defaults for the HTTP providers
+const (
+	defaultOpenAIURL      = "https://api.openai.com/v1"
+	defaultOpenAIModel    = "gpt-4o-mini"
+	defaultOllamaURL      = "http://localhost:11434"
+	defaultOllamaModel    = "llama3.2:3b"
+	defaultTimeout        = 120 * time.Second
+	defaultMaxInputTokens = 8000
+)
+
+// charsPerToken is a rough estimate for English text, used to keep prompts
+// within max_input_tokens without a tokenizer
+const charsPerToken = 4
+
+// chatMessage is a message of the OpenAI and Ollama chat APIs
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// httpBackend calls an OpenAI-compatible chat completions endpoint or
+// Ollama's REST API. The prompt goes in the request body, so it is neither
+// limited by argument length nor visible in ps.
+type httpBackend struct {
+	provider  Provider
+	baseURL   string
+	model     string
+	apiKey    string
+	maxTokens int
+	http      *http.Client
+}
+
+func newHTTPBackend(provider Provider, cfg config.AIConfig) *httpBackend {
+	b := &httpBackend{
+		provider:  provider,
+		baseURL:   strings.TrimSuffix(cfg.BaseURL, "/"),
+		model:     cfg.Model,
+		apiKey:    cfg.APIKey,
+		maxTokens: cfg.MaxInputTokens,
+		http:      &http.Client{Timeout: defaultTimeout},
+	}
+	if cfg.TimeoutSeconds > 0 {
+		b.http.Timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	if b.maxTokens <= 0 {
+		b.maxTokens = defaultMaxInputTokens
+	}
+
+	switch provider {
+	case ProviderOpenAI:
+		if b.baseURL == "" {
+			b.baseURL = defaultOpenAIURL
+		}
+		if b.model == "" {
+			b.model = defaultOpenAIModel
+		}
+		if b.apiKey == "" {
+			b.apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+	case ProviderOllama:
+		if b.baseURL == "" {
+			b.baseURL = defaultOllamaURL
+		}
+		if b.model == "" {
+			b.model = defaultOllamaModel
+		}
+	}
+	return b
+}
+
+func (b *httpBackend) call(prompt string) (string, error) {
+	messages := []chatMessage{{Role: "user", Content: truncateMiddle(prompt, b.maxTokens*charsPerToken)}}
+
+	if b.provider == ProviderOllama {
+		var resp struct {
+			Message chatMessage `json:"message"`
+		}
+		req := map[string]any{"model": b.model, "messages": messages, "stream": false}
+		if err := b.post("/api/chat", req, &resp); err != nil {
+			return "", err
+		}
+		return resp.Message.Content, nil
+	}
+
+	var resp struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	req := map[string]any{"model": b.model, "messages": messages}
+	if err := b.post("/chat/completions", req, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("AI call failed: %s returned no choices", b.model)
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// post sends body as JSON and decodes the JSON response into out
+func (b *httpBackend) post(path string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, b.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("AI call failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("AI call failed: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("AI call failed: %s", apiError(resp.Status, data))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("AI call failed: invalid response: %w", err)
+	}
+	return nil
+}
+
+// apiError extracts the message of an OpenAI ({"error":{"message":...}}) or
+// Ollama ({"error":"..."}) error response
+func apiError(status string, data []byte) string {
+	var openAI struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &openAI) == nil && openAI.Error.Message != "" {
+		return openAI.Error.Message
+	}
+	var ollama struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &ollama) == nil && ollama.Error != "" {
+		return ollama.Error
+	}
+	return status
+}
+
+// isLocalURL reports whether rawURL points at this machine
+func isLocalURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	switch host := u.Hostname(); host {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	default:
+		return strings.HasPrefix(host, "127.")
+	}
+}
+
+// truncateMiddle shortens s to about max bytes by cutting out its middle.
+// Prompts carry their instructions around the email text, so both ends
+// are kept.
+func truncateMiddle(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	const marker = "\n\n[... truncated ...]\n\n"
+	head := (max - len(marker)) * 2 / 3
+	tail := max - len(marker) - head
+	if head < 0 || tail < 0 {
+		return s[:max]
+	}
+	// Don't split UTF-8 sequences
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	start := len(s) - tail
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return s[:head] + marker + s[start:]
+}
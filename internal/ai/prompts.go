@@ -3,18 +3,24 @@ package ai
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // ParsedEvent represents a calendar event parsed from natural language
 type ParsedEvent struct {
-	Title              string `json:"title"`
-	StartTime          string `json:"start_time"`           // ISO 8601 format
-	EndTime            string `json:"end_time"`             // ISO 8601 format
-	Location           string `json:"location,omitempty"`
-	AlarmMinutesBefore int    `json:"alarm_minutes_before"` // 0 means not specified
-	AlarmSpecified     bool   `json:"alarm_specified"`      // true if user explicitly mentioned reminder
+	Title              string   `json:"title"`
+	StartTime          string   `json:"start_time"` // ISO 8601 format
+	EndTime            string   `json:"end_time"`   // ISO 8601 format
+	Location           string   `json:"location,omitempty"`
+	AlarmMinutesBefore int      `json:"alarm_minutes_before"` // 0 means not specified
+	AlarmSpecified     bool     `json:"alarm_specified"`      // true if user explicitly mentioned reminder
+	Recurrence         string   `json:"recurrence,omitempty"` // RRULE value, e.g. "FREQ=WEEKLY;BYDAY=MO"; empty if one-off
+	TimeZone           string   `json:"time_zone,omitempty"`  // IANA zone named in the input ("3pm PT"); empty for local time
+	Attendees          []string `json:"attendees,omitempty"`  // Email addresses of people taking part
 }
 
 // ParseEventResponse parses the AI JSON response into a ParsedEvent
@@ -62,6 +68,7 @@ func ParseCalendarEventPrompt(input string, now time.Time) string {
 	return fmt.Sprintf(`Parse this natural language into a calendar event.
 
 Current date/time: %s
+Current time zone: %s
 
 Input: "%s"
 
@@ -72,7 +79,9 @@ Respond with ONLY a JSON object (no markdown, no explanation):
   "end_time": "2024-12-25T11:00:00-08:00",
   "location": "location if mentioned, otherwise empty string",
   "alarm_minutes_before": 5,
-  "alarm_specified": true
+  "alarm_specified": true,
+  "recurrence": "FREQ=WEEKLY;BYDAY=MO",
+  "time_zone": "America/Los_Angeles"
 }
 
 Rules:
@@ -81,9 +90,32 @@ Rules:
 - If user says "remind me X minutes before" or similar, set alarm_minutes_before and alarm_specified=true
 - If no reminder mentioned, set alarm_minutes_before=0 and alarm_specified=false
 - Extract location if mentioned (e.g., "at the coffee shop")
+- If the event repeats ("every Monday", "daily", "every other week", "monthly on the 15th"), set recurrence to an iCalendar RRULE value without the "RRULE:" prefix, e.g. "FREQ=DAILY", "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH", "FREQ=MONTHLY;BYMONTHDAY=15", "FREQ=WEEKLY;BYDAY=MO;COUNT=10"; start_time is then the first occurrence
+- If the event does not repeat, set recurrence to an empty string
+- If the input names a time zone for its times ("3pm PT", "10:00 Berlin time", "noon CET"), the times are in that zone: give start_time and end_time with that zone's UTC offset on that date, and set time_zone to its IANA name, e.g. "America/Los_Angeles" or "Europe/Berlin"
+- If no time zone is named, use the current time zone and set time_zone to an empty string
 - Use the current date/time to interpret relative dates like "tomorrow", "next Monday"
 
-Respond with ONLY the JSON, no other text.`, now.Format(time.RFC3339), input)
+Respond with ONLY the JSON, no other text.`, now.Format(time.RFC3339), localZoneName(now), input)
+}
+
+// localZoneName returns the IANA name of t's zone. time.Local is named
+// "Local", so the name comes from $TZ or the /etc/localtime link instead,
+// falling back to the zone's abbreviation.
+func localZoneName(t time.Time) string {
+	if name := t.Location().String(); name != "Local" {
+		return name
+	}
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" {
+		return tz
+	}
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if i := strings.Index(target, "zoneinfo/"); i >= 0 {
+			return target[i+len("zoneinfo/"):]
+		}
+	}
+	abbrev, _ := t.Zone()
+	return abbrev
 }
 
 // SummarizePrompt builds a prompt for email summarization
@@ -110,22 +142,233 @@ Format your response exactly like this (skip sections if not applicable):
 Keep it brief. No preamble, just the bullet points.`, from, subject, body)
 }
 
-// ExtractEventsPrompt builds a prompt for extracting calendar events from email
-func ExtractEventsPrompt(from, subject, body string) string {
-	return fmt.Sprintf(`Extract any calendar events, meetings, or deadlines from this email.
+// ThreadMessage is one email of a conversation or digest group
+type ThreadMessage struct {
+	From    string
+	Subject string
+	Date    time.Time
+	Body    string
+}
+
+// SummarizeThreadPrompt builds a prompt for summarizing a whole
+// conversation, messages oldest first
+func SummarizeThreadPrompt(subject string, messages []ThreadMessage) string {
+	return fmt.Sprintf(`Summarize this email conversation as bullet points.
+
+Subject: %s
+Messages: %d (oldest first)
+
+%s
+Format your response exactly like this (skip sections if not applicable):
+
+• Summary: <one or two sentences on what the conversation is about and where it stands>
+• Key points:
+  - <point, with who said it>
+• Decisions:
+  - <what was agreed>
+• Open questions:
+  - <what is still unanswered, and who is expected to answer>
+• Action items:
+  - <action, with owner and deadline if mentioned>
+
+Keep it brief. No preamble, just the bullet points.`, subject, len(messages), formatMessages(messages))
+}
+
+// Tones are the tones a drafted reply can take; the first is the default
+var Tones = []string{"neutral", "friendly", "formal", "brief"}
+
+// DraftReplyPrompt builds a prompt for drafting a reply to an email. intent
+// is what the reply should say ("decline politely, propose next week") and
+// may be empty; me is the address the reply is sent from.
+func DraftReplyPrompt(from, subject, body, intent, tone, me string) string {
+	if intent == "" {
+		intent = "(none given: reply appropriately to what the email asks)"
+	}
+	return fmt.Sprintf(`Draft a reply to this email. I am %s.
 
 From: %s
 Subject: %s
 
 %s
 
-If found, respond in this format:
-Title: <event title>
-Date: <date in YYYY-MM-DD format>
-Time: <time in HH:MM format, 24h>
-Duration: <duration like "1h" or "30m">
+What my reply should say: %s
+Tone: %s
 
-If no events found, respond with: No events found.
+Rules:
+- Write only the body of the reply: no subject line, no quoted original, no placeholders like [Name] unless a detail is truly unknown
+- Answer in the language of the email
+- Do not invent facts, dates or commitments beyond what the intent says
+- Keep it short unless the email needs a long answer
+- End with a sign-off but no name
+
+Respond with only the reply body, no preamble.`, me, from, subject, stripQuoted(body), intent, tone)
+}
+
+// AskPrompt builds a prompt for answering a question about the mailbox
+// from sources, the emails retrieved for it. The answer cites sources as
+// [1], [2], ... in the order given.
+func AskPrompt(question string, sources []ThreadMessage, now time.Time) string {
+	var b strings.Builder
+	for i, m := range sources {
+		fmt.Fprintf(&b, "[%d]\n%s", i+1, formatMessages([]ThreadMessage{m}))
+	}
+
+	return fmt.Sprintf(`Answer a question about my email using only the emails below.
+
+Current date: %s
+
+%s
+Question: %s
+
+Rules:
+- Answer in one to three sentences, then stop
+- Cite the emails you used as [1], [2], ... right after the facts they support
+- Use only facts from these emails; if they don't answer the question, say that you could not find it in the emails
+- Give dates as written in the email and, if useful, relative to the current date
+
+No preamble.`, now.Format("Monday, January 2, 2006"), b.String(), question)
+}
+
+// citationPattern matches citations like [2] or [1, 3]
+var citationPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// Citations returns the source numbers cited in answer (1-based, at most
+// sources), in order of first citation
+func Citations(answer string, sources int) []int {
+	var cited []int
+	seen := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		for _, field := range strings.Split(match[1], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || n < 1 || n > sources || seen[n] {
+				continue
+			}
+			seen[n] = true
+			cited = append(cited, n)
+		}
+	}
+	return cited
+}
+
+// DigestGroup is a thread or a sender's emails in a digest
+type DigestGroup struct {
+	Title    string // thread subject or sender
+	Messages []ThreadMessage
+}
 
-Respond with only the event details or "No events found", no preamble.`, from, subject, body)
+// DigestPrompt builds a prompt for a "catch me up" digest of unread mail
+func DigestPrompt(groups []DigestGroup, since time.Time) string {
+	var b strings.Builder
+	for i, g := range groups {
+		fmt.Fprintf(&b, "=== %d. %s ===\n\n%s\n", i+1, g.Title, formatMessages(g.Messages))
+	}
+
+	return fmt.Sprintf(`Write a digest of my unread email since %s, so I can catch up without reading it.
+
+The emails are grouped; each group starts with "=== N. title ===".
+
+%s
+Format your response exactly like this:
+
+ACTION ITEMS
+- [ ] <what I need to do, for whom, and by when if mentioned> (group N)
+
+DIGEST
+N. <group title>
+   <one or two sentences summarizing the group>
+
+List action items first, most urgent first; write "- none" if there are none. Summarize every group, in the given order. Skip newsletters and notifications in one line each. No preamble.`, since.Format("Mon Jan 2 15:04"), b.String())
+}
+
+// formatMessages renders messages as headers and bodies for a prompt
+func formatMessages(messages []ThreadMessage) string {
+	var b strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&b, "From: %s\n", m.From)
+		if m.Subject != "" {
+			fmt.Fprintf(&b, "Subject: %s\n", m.Subject)
+		}
+		if !m.Date.IsZero() {
+			fmt.Fprintf(&b, "Date: %s\n", m.Date.Format("Mon Jan 2 15:04"))
+		}
+		fmt.Fprintf(&b, "\n%s\n\n---\n\n", stripQuoted(m.Body))
+	}
+	return b.String()
+}
+
+// stripQuoted removes quoted replies ("> ..." lines and the "On ... wrote:"
+// line above them), which repeat earlier messages of the thread
+func stripQuoted(body string) string {
+	lines := strings.Split(body, "\n")
+	var kept []string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		if strings.HasSuffix(trimmed, "wrote:") && i+1 < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i+1]), ">") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+// Triage categories
+const (
+	CategoryAction       = "action"       // needs a reply or something done
+	CategoryFYI          = "fyi"          // worth reading, nothing to do
+	CategoryNewsletter   = "newsletter"   // newsletters and marketing
+	CategoryNotification = "notification" // automated messages from services
+	CategoryPersonal     = "personal"     // from friends and family
+)
+
+// Categories lists the triage categories in the order they are shown
+var Categories = []string{CategoryAction, CategoryFYI, CategoryPersonal, CategoryNotification, CategoryNewsletter}
+
+// Triage is the classification of an email
+type Triage struct {
+	Category string `json:"category"`
+	Priority int    `json:"priority"` // 1 (high) to 3 (low)
+}
+
+// TriagePrompt builds a prompt for classifying an email
+func TriagePrompt(from, subject, body string) string {
+	return fmt.Sprintf(`Classify this email.
+
+From: %s
+Subject: %s
+
+%s
+
+Respond with ONLY a JSON object (no markdown, no explanation):
+{"category": "action", "priority": 1}
+
+category is one of:
+- "action": I need to reply, decide, pay, attend or do something
+- "fyi": worth reading, but nothing to do
+- "newsletter": newsletters, marketing and promotions
+- "notification": automated messages from services (receipts, alerts, shipping, social networks)
+- "personal": from friends or family, not about work
+
+priority is 1 (high: urgent or important, from a real person expecting me), 2 (normal) or 3 (low: can be ignored).`, from, subject, body)
+}
+
+// ParseTriageResponse parses the AI JSON response into a Triage, clamping
+// unknown categories to "fyi" and priorities to 1-3
+func ParseTriageResponse(response string) (*Triage, error) {
+	var t Triage
+	if err := json.Unmarshal([]byte(stripMarkdownCodeFences(response)), &t); err != nil {
+		return nil, fmt.Errorf("failed to parse AI response: %w", err)
+	}
+	t.Category = strings.ToLower(strings.TrimSpace(t.Category))
+	known := false
+	for _, c := range Categories {
+		known = known || c == t.Category
+	}
+	if !known {
+		t.Category = CategoryFYI
+	}
+	t.Priority = min(max(t.Priority, 1), 3)
+	return &t, nil
 }
@@ -0,0 +1,13 @@
+package ai
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCitations(t *testing.T) {
+	answer := "The invoice is due March 15 [2]. Bob sent it twice [2, 4]; see also [9] and [0]."
+	if got, want := Citations(answer, 5), []int{2, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Citations = %v, want %v", got, want)
+	}
+}
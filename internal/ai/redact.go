@@ -0,0 +1,173 @@
+package ai
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kinds of data the redactor masks; a masked value becomes "[KIND_n]"
+const (
+	RedactEmail  = "EMAIL"
+	RedactPhone  = "PHONE"
+	RedactIBAN   = "IBAN"
+	RedactCard   = "CARD"
+	RedactCustom = "REDACTED"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	ibanPattern  = regexp.MustCompile(`\b[A-Z]{2}[0-9]{2}(?: ?[A-Z0-9]){11,30}\b`)
+	cardPattern  = regexp.MustCompile(`\b[0-9](?:[ -]?[0-9]){12,18}\b`)
+	phonePattern = regexp.MustCompile(`(?:\+|\(|\b)[0-9][0-9 ().-]{5,}[0-9]`)
+
+	// datePattern keeps dates and times out of the phone numbers, so
+	// prompts about events still make sense
+	datePattern = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}|^[0-9]{1,2}[./-][0-9]{1,2}[./-][0-9]{2,4}$`)
+)
+
+// redactor masks personal data in prompts and restores it in responses.
+// The same value always gets the same token, so the AI can still tell
+// two people apart.
+type redactor struct {
+	custom  []*regexp.Regexp
+	tokens  map[string]string // value -> token
+	values  map[string]string // token -> value
+	counts  map[string]int    // tokens per kind
+	ordered []string          // tokens in the order they were made
+}
+
+// newRedactor returns a redactor that also masks the regular expressions
+// of patterns
+func newRedactor(patterns []string) (*redactor, error) {
+	r := &redactor{
+		tokens: make(map[string]string),
+		values: make(map[string]string),
+		counts: make(map[string]int),
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid privacy pattern %q: %w", p, err)
+		}
+		r.custom = append(r.custom, re)
+	}
+	return r, nil
+}
+
+// Redact returns text with the personal data it finds masked. Custom
+// patterns go first, then IBANs and card numbers (whose checksums keep
+// other numbers out), email addresses and phone numbers.
+func (r *redactor) Redact(text string) string {
+	for _, re := range r.custom {
+		text = r.mask(text, re, RedactCustom, nil)
+	}
+	text = r.mask(text, ibanPattern, RedactIBAN, validIBAN)
+	text = r.mask(text, cardPattern, RedactCard, validCard)
+	text = r.mask(text, emailPattern, RedactEmail, nil)
+	return r.mask(text, phonePattern, RedactPhone, validPhone)
+}
+
+// mask replaces the matches of re in text that valid accepts with tokens
+// of kind
+func (r *redactor) mask(text string, re *regexp.Regexp, kind string, valid func(string) bool) string {
+	return re.ReplaceAllStringFunc(text, func(match string) string {
+		if valid != nil && !valid(match) {
+			return match
+		}
+		return r.token(match, kind)
+	})
+}
+
+func (r *redactor) token(value, kind string) string {
+	if token, ok := r.tokens[value]; ok {
+		return token
+	}
+	r.counts[kind]++
+	token := fmt.Sprintf("[%s_%d]", kind, r.counts[kind])
+	r.tokens[value] = token
+	r.values[token] = value
+	r.ordered = append(r.ordered, token)
+	return token
+}
+
+// Restore puts the masked values back into a response
+func (r *redactor) Restore(text string) string {
+	if len(r.values) == 0 {
+		return text
+	}
+	pairs := make([]string, 0, 2*len(r.values))
+	for _, token := range r.ordered {
+		pairs = append(pairs, token, r.values[token])
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// Counts returns how many values of each kind were masked
+func (r *redactor) Counts() map[string]int {
+	return r.counts
+}
+
+// validIBAN checks the ISO 13616 mod-97 checksum
+func validIBAN(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	rearranged := s[4:] + s[:4]
+	remainder := 0
+	for _, c := range rearranged {
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			remainder = (remainder*100 + int(c-'A'+10)) % 97
+		default:
+			return false
+		}
+	}
+	return remainder == 1
+}
+
+// validCard checks the Luhn checksum of a card number
+func validCard(s string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range digits {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validPhone accepts 7 to 15 digits that are not a date, and that either
+// start with + or are grouped like a phone number
+func validPhone(s string) bool {
+	if datePattern.MatchString(s) {
+		return false
+	}
+	digits := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	if digits < 7 || digits > 15 {
+		return false
+	}
+	return strings.HasPrefix(s, "+") || strings.ContainsAny(s, " ()-.")
+}
@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	r, err := newRedactor([]string{`ACME-[0-9]{6}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := `From: Bob <bob@example.com>
+Call me on +1 (555) 123-4567 or 030 1234567 about case ACME-123456.
+Pay to DE89 3704 0044 0532 0130 00 with card 4111 1111 1111 1111, or tell bob@example.com.
+Meeting on 2025-03-04T10:00:00-08:00, order 12345678, from 9:30-10:30.`
+
+	got := r.Redact(text)
+	for _, secret := range []string{"bob@example.com", "555", "030 1", "ACME-123456", "DE89", "4111"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("redacted text still contains %q:\n%s", secret, got)
+		}
+	}
+	for _, kept := range []string{"[EMAIL_1]", "[PHONE_2]", "[REDACTED_1]", "[IBAN_1]", "[CARD_1]", "2025-03-04T10:00:00-08:00", "order 12345678", "9:30-10:30"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("redacted text lacks %q:\n%s", kept, got)
+		}
+	}
+	if strings.Count(got, "[EMAIL_1]") != 2 || strings.Contains(got, "[EMAIL_2]") {
+		t.Errorf("one address should get one token:\n%s", got)
+	}
+
+	if restored := r.Restore("Reply to [EMAIL_1] about [REDACTED_1]."); restored != "Reply to bob@example.com about ACME-123456." {
+		t.Errorf("Restore = %q", restored)
+	}
+	if r.Counts()[RedactPhone] != 2 {
+		t.Errorf("Counts = %v", r.Counts())
+	}
+
+	if _, err := newRedactor([]string{"("}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
@@ -3,6 +3,8 @@ package auth
 import (
 	"bufio"
 	"fmt"
+	netmail "net/mail"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,8 +18,10 @@ const accountsFileName = "accounts.yml"
 
 // Provider identifiers
 const (
-	ProviderGmail = "gmail"
-	ProviderYahoo = "yahoo"
+	ProviderGmail   = "gmail"
+	ProviderYahoo   = "yahoo"
+	ProviderJMAP    = "jmap"
+	ProviderOutlook = "outlook"
 )
 
 // Gmail IMAP/SMTP hosts
@@ -32,10 +36,14 @@ const (
 	YahooSMTPHost = "smtp.mail.yahoo.com"
 )
 
+// FastmailJMAPURL is the JMAP session resource used when no server is given
+const FastmailJMAPURL = "https://api.fastmail.com/jmap/session"
+
 // Standard ports
 const (
-	IMAPPort = 993
-	SMTPPort = 587
+	IMAPPort  = 993
+	SMTPPort  = 587
+	SievePort = 4190 // ManageSieve
 )
 
 type Credentials struct {
@@ -46,12 +54,76 @@ type Credentials struct {
 	SMTPHost string `yaml:"smtp_host"`
 	SMTPPort int    `yaml:"smtp_port"`
 	Provider string `yaml:"provider"`
+	JMAPURL  string `yaml:"jmap_url,omitempty"` // JMAP session resource
+
+	// ManageSieve server, if not the IMAP host on SievePort
+	SieveHost string `yaml:"sieve_host,omitempty"`
+	SievePort int    `yaml:"sieve_port,omitempty"`
+
+	// OAuth accounts (Outlook) authenticate with a refresh token instead of a password
+	OAuthClientID string `yaml:"oauth_client_id,omitempty"`
+	RefreshToken  string `yaml:"refresh_token,omitempty"`
 }
 
 type Account struct {
-	Name        string      `yaml:"name"`
-	Provider    string      `yaml:"provider"`
-	Credentials Credentials `yaml:"credentials"`
+	Name        string          `yaml:"name"`
+	Provider    string          `yaml:"provider"`
+	Credentials Credentials     `yaml:"credentials"`
+	Calendar    *CalendarConfig `yaml:"calendar,omitempty"`
+	Triage      *TriageConfig   `yaml:"triage,omitempty"`
+}
+
+// TriageConfig opts an account in to AI triage of new mail. Senders are
+// matched by address ("boss@example.com") or domain ("example.com").
+type TriageConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Allow   []string `yaml:"allow,omitempty"` // if set, only these senders are classified
+	Deny    []string `yaml:"deny,omitempty"`  // never classified, even if allowed
+}
+
+// Allows reports whether mail from the address in from may be sent to the
+// AI for triage
+func (t *TriageConfig) Allows(from string) bool {
+	if t == nil || !t.Enabled {
+		return false
+	}
+	if SenderMatches(from, t.Deny) {
+		return false
+	}
+	return len(t.Allow) == 0 || SenderMatches(from, t.Allow)
+}
+
+// SenderMatches reports whether the address in from is one of patterns:
+// addresses ("boss@example.com") or domains ("example.com"), which also
+// cover their subdomains
+func SenderMatches(from string, patterns []string) bool {
+	addr := strings.ToLower(from)
+	if parsed, err := netmail.ParseAddress(from); err == nil {
+		addr = strings.ToLower(parsed.Address)
+	}
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(p), "@"))
+		if p == addr || strings.HasSuffix(addr, "@"+p) || strings.HasSuffix(addr, "."+p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Calendar backend types
+const (
+	CalendarCalDAV = "caldav"
+	CalendarLocal  = "local" // directory of .ics files
+)
+
+// CalendarConfig selects the calendar backend of an account. Username and
+// password default to the account's mail credentials.
+type CalendarConfig struct {
+	Type     string `yaml:"type"`
+	URL      string `yaml:"url,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	Path     string `yaml:"path,omitempty"` // local calendars
 }
 
 type AccountStore struct {
@@ -82,6 +154,37 @@ func YahooCredentials(email, password string) Credentials {
 	}
 }
 
+// JMAPCredentials builds credentials for a JMAP account. The password may be
+// an app password or an API token. server may be a full session URL or a bare
+// host, in which case the well-known session path is used.
+func JMAPCredentials(email, password, server string) Credentials {
+	return Credentials{
+		Email:    email,
+		Password: password,
+		Provider: ProviderJMAP,
+		JMAPURL:  JMAPSessionURL(server),
+	}
+}
+
+// JMAPSessionURL normalizes a user-supplied JMAP server into a session URL
+func JMAPSessionURL(server string) string {
+	server = strings.TrimSpace(server)
+	if server == "" {
+		return FastmailJMAPURL
+	}
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return server
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/.well-known/jmap"
+	}
+	return u.String()
+}
+
 func LoadAccountStore() (*AccountStore, error) {
 	configDir, err := getConfigDir()
 	if err != nil {
@@ -121,7 +224,21 @@ func (s *AccountStore) Save() error {
 		return err
 	}
 
-	return os.WriteFile(storePath, data, 0600)
+	// Write a temp file and rename it, so readers never see half a file
+	tmp, err := os.CreateTemp(configDir, ".accounts-*.yml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), storePath)
 }
 
 func (s *AccountStore) AddAccount(account Account) {
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/microsoft"
+)
+
+// OutlookClientID is the Azure app registration used for Microsoft 365
+// device-code login. Set at build time with
+// -ldflags "-X maily/internal/auth.OutlookClientID=..." or at runtime with
+// MAILY_OUTLOOK_CLIENT_ID.
+var OutlookClientID = ""
+
+// Microsoft Graph scopes requested at login
+var outlookScopes = []string{
+	"offline_access",
+	"https://graph.microsoft.com/User.Read",
+	"https://graph.microsoft.com/Mail.ReadWrite",
+	"https://graph.microsoft.com/Mail.Send",
+	"https://graph.microsoft.com/Calendars.ReadWrite",
+}
+
+// OutlookOAuthConfig returns the OAuth2 config for Microsoft 365. clientID
+// may be empty to use the built-in or environment client ID. The tenant
+// defaults to "common" and can be pinned with MAILY_OUTLOOK_TENANT.
+func OutlookOAuthConfig(clientID string) (*oauth2.Config, error) {
+	if clientID == "" {
+		clientID = os.Getenv("MAILY_OUTLOOK_CLIENT_ID")
+	}
+	if clientID == "" {
+		clientID = OutlookClientID
+	}
+	if clientID == "" {
+		return nil, fmt.Errorf("no Outlook client ID: set MAILY_OUTLOOK_CLIENT_ID to your Azure app's ID")
+	}
+
+	return &oauth2.Config{
+		ClientID: clientID,
+		Endpoint: microsoft.AzureADEndpoint(os.Getenv("MAILY_OUTLOOK_TENANT")),
+		Scopes:   outlookScopes,
+	}, nil
+}
+
+// OutlookCredentials builds credentials for a Microsoft 365 account that
+// logged in with the device-code flow
+func OutlookCredentials(email, clientID string, token *oauth2.Token) Credentials {
+	return Credentials{
+		Email:         email,
+		Provider:      ProviderOutlook,
+		OAuthClientID: clientID,
+		RefreshToken:  token.RefreshToken,
+	}
+}
+
+// TokenSource returns an auto-refreshing token source for an OAuth account.
+// Microsoft rotates refresh tokens, so new ones are written back to
+// accounts.yml as they are issued.
+func TokenSource(creds *Credentials) (oauth2.TokenSource, error) {
+	if creds.RefreshToken == "" {
+		return nil, fmt.Errorf("%s has no OAuth token, run 'maily login outlook' again", creds.Email)
+	}
+
+	cfg, err := OutlookOAuthConfig(creds.OAuthClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	base := cfg.TokenSource(context.Background(), &oauth2.Token{RefreshToken: creds.RefreshToken})
+	return &persistingTokenSource{base: base, creds: creds}, nil
+}
+
+type persistingTokenSource struct {
+	mu    sync.Mutex
+	base  oauth2.TokenSource
+	creds *Credentials
+}
+
+func (s *persistingTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := s.base.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tok.RefreshToken != "" && tok.RefreshToken != s.creds.RefreshToken {
+		s.creds.RefreshToken = tok.RefreshToken
+		// Best effort: the old token keeps working for a while
+		SaveRefreshToken(s.creds.Email, tok.RefreshToken)
+	}
+	return tok, nil
+}
+
+// refreshTokenMu serializes SaveRefreshToken, so accounts whose tokens are
+// rotated at once do not undo each other's update of the store
+var refreshTokenMu sync.Mutex
+
+// SaveRefreshToken updates the stored refresh token of an account
+func SaveRefreshToken(email, refreshToken string) error {
+	refreshTokenMu.Lock()
+	defer refreshTokenMu.Unlock()
+
+	store, err := LoadAccountStore()
+	if err != nil {
+		return err
+	}
+	for i := range store.Accounts {
+		if store.Accounts[i].Credentials.Email == email {
+			store.Accounts[i].Credentials.RefreshToken = refreshToken
+			return store.Save()
+		}
+	}
+	return nil
+}
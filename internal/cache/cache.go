@@ -25,25 +25,36 @@ type Attachment struct {
 
 // CachedEmail represents an email stored in the cache
 type CachedEmail struct {
-	UID          imap.UID     `json:"uid"`
-	MessageID    string       `json:"message_id"`
-	InternalDate time.Time    `json:"internal_date"`
-	From         string       `json:"from"`
-	ReplyTo      string       `json:"reply_to,omitempty"`
-	To           string       `json:"to"`
-	Subject      string       `json:"subject"`
-	Date         time.Time    `json:"date"`
-	Snippet      string       `json:"snippet"`
-	Body         string       `json:"body"`
-	Unread       bool         `json:"unread"`
-	References   string       `json:"references,omitempty"`
-	Attachments  []Attachment `json:"attachments,omitempty"`
+	UID          imap.UID          `json:"uid"`
+	MessageID    string            `json:"message_id"`
+	InternalDate time.Time         `json:"internal_date"`
+	From         string            `json:"from"`
+	ReplyTo      string            `json:"reply_to,omitempty"`
+	To           string            `json:"to"`
+	Subject      string            `json:"subject"`
+	Date         time.Time         `json:"date"`
+	Snippet      string            `json:"snippet"`
+	Body         string            `json:"body"`
+	Unread       bool              `json:"unread"`
+	References   string            `json:"references,omitempty"`
+	Attachments  []Attachment      `json:"attachments,omitempty"`
+	Calendar     string            `json:"calendar,omitempty"` // text/calendar part (invitation)
+	Triage       *Triage           `json:"triage,omitempty"`   // AI classification, set once
+	Headers      map[string]string `json:"headers,omitempty"`
+}
+
+// Triage is the AI classification of an email
+type Triage struct {
+	Category string `json:"category"` // "action", "fyi", "newsletter", "notification" or "personal"
+	Priority int    `json:"priority"` // 1 (high) to 3 (low)
 }
 
 // Metadata tracks mailbox sync state
 type Metadata struct {
-	UIDValidity uint32    `json:"uidvalidity"`
-	LastSync    time.Time `json:"last_sync"`
+	UIDValidity  uint32    `json:"uidvalidity"`
+	LastSync     time.Time `json:"last_sync"`
+	EmailState   string    `json:"email_state,omitempty"`   // JMAP Email state
+	MailboxState string    `json:"mailbox_state,omitempty"` // JMAP Mailbox state
 }
 
 // Cache manages persistent email storage
@@ -61,6 +72,11 @@ func New() (*Cache, error) {
 	return &Cache{baseDir: baseDir}, nil
 }
 
+// NewWithBaseDir creates a cache rooted at the given directory
+func NewWithBaseDir(baseDir string) *Cache {
+	return &Cache{baseDir: baseDir}
+}
+
 // encodeMailbox URL-encodes a mailbox name for filesystem safety
 func encodeMailbox(mailbox string) string {
 	return url.PathEscape(mailbox)
@@ -209,8 +225,16 @@ func (c *Cache) LoadEmailsLimit(account, mailbox string, limit int) ([]CachedEma
 	return emails, nil
 }
 
-// SaveEmail saves a single email to cache (atomic write)
+// SaveEmail saves a single email to cache (atomic write). An email saved
+// again without a triage keeps the one already cached, so syncs don't make
+// messages be classified twice.
 func (c *Cache) SaveEmail(account, mailbox string, email CachedEmail) error {
+	if email.Triage == nil {
+		if old, err := c.GetEmail(account, mailbox, email.UID); err == nil && old != nil && old.MessageID == email.MessageID {
+			email.Triage = old.Triage
+		}
+	}
+
 	dir := c.getMailboxPath(account, mailbox)
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return err
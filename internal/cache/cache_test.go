@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+)
+
+const testAccount = "me@example.com"
+
+func TestSaveAndLoadEmails(t *testing.T) {
+	c := NewWithBaseDir(t.TempDir())
+	now := time.Now()
+
+	for i, subject := range []string{"oldest", "middle", "newest"} {
+		email := CachedEmail{
+			UID:          imap.UID(i + 1),
+			Subject:      subject,
+			InternalDate: now.Add(time.Duration(i) * time.Hour),
+		}
+		if err := c.SaveEmail(testAccount, "INBOX", email); err != nil {
+			t.Fatalf("SaveEmail: %v", err)
+		}
+	}
+
+	emails, err := c.LoadEmails(testAccount, "INBOX")
+	if err != nil {
+		t.Fatalf("LoadEmails: %v", err)
+	}
+	if len(emails) != 3 {
+		t.Fatalf("got %d emails, want 3", len(emails))
+	}
+	if emails[0].Subject != "newest" || emails[2].Subject != "oldest" {
+		t.Errorf("emails not sorted newest first: %q, %q, %q", emails[0].Subject, emails[1].Subject, emails[2].Subject)
+	}
+
+	limited, err := c.LoadEmailsLimit(testAccount, "INBOX", 2)
+	if err != nil {
+		t.Fatalf("LoadEmailsLimit: %v", err)
+	}
+	if len(limited) != 2 {
+		t.Errorf("got %d emails, want 2", len(limited))
+	}
+
+	uids, err := c.GetCachedUIDs(testAccount, "INBOX")
+	if err != nil {
+		t.Fatalf("GetCachedUIDs: %v", err)
+	}
+	for uid := imap.UID(1); uid <= 3; uid++ {
+		if !uids[uid] {
+			t.Errorf("UID %d missing from cached UIDs", uid)
+		}
+	}
+}
+
+func TestMetadataIsNotAnEmail(t *testing.T) {
+	c := NewWithBaseDir(t.TempDir())
+
+	if meta, err := c.LoadMetadata(testAccount, "INBOX"); err != nil || meta != nil {
+		t.Fatalf("LoadMetadata on empty cache = %v, %v; want nil, nil", meta, err)
+	}
+
+	if err := c.SaveMetadata(testAccount, "INBOX", &Metadata{UIDValidity: 42, LastSync: time.Now()}); err != nil {
+		t.Fatalf("SaveMetadata: %v", err)
+	}
+	if err := c.SaveEmail(testAccount, "INBOX", CachedEmail{UID: 7}); err != nil {
+		t.Fatalf("SaveEmail: %v", err)
+	}
+
+	meta, err := c.LoadMetadata(testAccount, "INBOX")
+	if err != nil {
+		t.Fatalf("LoadMetadata: %v", err)
+	}
+	if meta == nil || meta.UIDValidity != 42 {
+		t.Errorf("UIDValidity = %v, want 42", meta)
+	}
+
+	uids, err := c.GetCachedUIDs(testAccount, "INBOX")
+	if err != nil {
+		t.Fatalf("GetCachedUIDs: %v", err)
+	}
+	if len(uids) != 1 || !uids[7] {
+		t.Errorf("cached UIDs = %v, want only 7", uids)
+	}
+}
+
+func TestUpdateFlagsDeleteAndCleanup(t *testing.T) {
+	c := NewWithBaseDir(t.TempDir())
+	now := time.Now()
+
+	c.SaveEmail(testAccount, "INBOX", CachedEmail{UID: 1, Unread: true, InternalDate: now})
+	c.SaveEmail(testAccount, "INBOX", CachedEmail{UID: 2, InternalDate: now})
+	c.SaveEmail(testAccount, "INBOX", CachedEmail{UID: 3, InternalDate: now.AddDate(0, 0, -30)})
+
+	if err := c.UpdateEmailFlags(testAccount, "INBOX", 1, false); err != nil {
+		t.Fatalf("UpdateEmailFlags: %v", err)
+	}
+	email, err := c.GetEmail(testAccount, "INBOX", 1)
+	if err != nil || email == nil {
+		t.Fatalf("GetEmail: %v, %v", email, err)
+	}
+	if email.Unread {
+		t.Error("UID 1 still unread after UpdateEmailFlags")
+	}
+
+	// Updating a message that is not cached is a no-op
+	if err := c.UpdateEmailFlags(testAccount, "INBOX", 99, true); err != nil {
+		t.Errorf("UpdateEmailFlags on missing UID: %v", err)
+	}
+
+	if err := c.DeleteEmail(testAccount, "INBOX", 2); err != nil {
+		t.Fatalf("DeleteEmail: %v", err)
+	}
+	if err := c.DeleteEmail(testAccount, "INBOX", 2); err != nil {
+		t.Errorf("DeleteEmail twice: %v", err)
+	}
+
+	deleted, err := c.Cleanup(testAccount, "INBOX", now.AddDate(0, 0, -14))
+	if err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Cleanup deleted %d, want 1", deleted)
+	}
+
+	uids, _ := c.GetCachedUIDs(testAccount, "INBOX")
+	if len(uids) != 1 || !uids[1] {
+		t.Errorf("cached UIDs = %v, want only 1", uids)
+	}
+
+	if err := c.InvalidateMailbox(testAccount, "INBOX"); err != nil {
+		t.Fatalf("InvalidateMailbox: %v", err)
+	}
+	emails, err := c.LoadEmails(testAccount, "INBOX")
+	if err != nil || len(emails) != 0 {
+		t.Errorf("LoadEmails after invalidate = %d emails, %v", len(emails), err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	c := NewWithBaseDir(t.TempDir())
+
+	acquired, err := c.AcquireLock(testAccount)
+	if err != nil || !acquired {
+		t.Fatalf("AcquireLock = %v, %v; want true", acquired, err)
+	}
+
+	// Our own PID is alive, so a second attempt must fail
+	acquired, err = c.AcquireLock(testAccount)
+	if err != nil || acquired {
+		t.Fatalf("second AcquireLock = %v, %v; want false", acquired, err)
+	}
+
+	if err := c.ReleaseLock(testAccount); err != nil {
+		t.Fatalf("ReleaseLock: %v", err)
+	}
+	acquired, err = c.AcquireLock(testAccount)
+	if err != nil || !acquired {
+		t.Errorf("AcquireLock after release = %v, %v; want true", acquired, err)
+	}
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Mailboxes returns the names of the cached mailboxes of account
+func (c *Cache) Mailboxes(account string) ([]string, error) {
+	entries, err := os.ReadDir(c.getMailboxPath(account, ""))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var mailboxes []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if name, err := url.PathUnescape(entry.Name()); err == nil {
+			mailboxes = append(mailboxes, name)
+		}
+	}
+	return mailboxes, nil
+}
+
+// searchStopwords are left out of queries; they match nearly every email
+var searchStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "at": true, "be": true,
+	"by": true, "can": true, "did": true, "do": true, "does": true, "for": true,
+	"from": true, "has": true, "have": true, "how": true, "i": true, "in": true,
+	"is": true, "it": true, "me": true, "my": true, "of": true, "on": true,
+	"or": true, "our": true, "said": true, "say": true, "the": true, "to": true,
+	"was": true, "we": true, "what": true, "when": true, "where": true,
+	"which": true, "who": true, "why": true, "will": true, "with": true,
+	"you": true, "your": true, "about": true, "any": true, "there": true,
+}
+
+// Search ranks emails by how well they match the words of query and
+// returns the indexes of at most limit matches, best first. Words in the subject and
+// sender count more than words in the body, and words that are rare among
+// emails count more than common ones.
+func Search(emails []CachedEmail, query string, limit int) []int {
+	var terms []string
+	for _, term := range searchTerms(query) {
+		if len(term) > 1 && !searchStopwords[term] {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 || len(emails) == 0 {
+		return nil
+	}
+
+	type doc struct {
+		subject, from, body map[string]int
+	}
+	docs := make([]doc, len(emails))
+	df := make(map[string]int) // emails containing each term
+	for i, e := range emails {
+		body := e.Body
+		if body == "" {
+			body = e.Snippet
+		}
+		docs[i] = doc{
+			subject: termCounts(e.Subject),
+			from:    termCounts(e.From),
+			body:    termCounts(body),
+		}
+		for _, t := range terms {
+			if docs[i].subject[t]+docs[i].from[t]+docs[i].body[t] > 0 {
+				df[t]++
+			}
+		}
+	}
+
+	type result struct {
+		index int
+		score float64
+	}
+	var results []result
+	for i, d := range docs {
+		var score float64
+		for _, t := range terms {
+			tf := float64(3*d.subject[t] + 2*d.from[t] + d.body[t])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + float64(len(emails))/float64(df[t]))
+			score += idf * tf / (tf + 1.2)
+		}
+		if score > 0 {
+			results = append(results, result{i, score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return emails[results[i].index].Date.After(emails[results[j].index].Date)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	matches := make([]int, len(results))
+	for i, r := range results {
+		matches[i] = r.index
+	}
+	return matches
+}
+
+// searchTerms splits s into lowercased, stemmed words
+func searchTerms(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = stem(w)
+	}
+	return words
+}
+
+func termCounts(s string) map[string]int {
+	counts := make(map[string]int)
+	for _, term := range searchTerms(s) {
+		counts[term]++
+	}
+	return counts
+}
+
+// stem strips common English endings so "invoices" matches "invoice" and
+// "meeting" matches "meet"
+func stem(w string) string {
+	for _, suffix := range []string{"ing", "ed", "s"} {
+		if len(w) > len(suffix)+3 && strings.HasSuffix(w, suffix) {
+			return strings.TrimSuffix(w, suffix)
+		}
+	}
+	return w
+}
@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	day := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	emails := []CachedEmail{
+		{Subject: "Lunch on Friday?", From: "Sam <sam@example.com>", Body: "Are you free for lunch?", Date: day},
+		{Subject: "Invoice #42", From: "Bob's Renovations <bob@contractor.com>", Body: "Please find the invoice attached. Payment is due March 15.", Date: day.Add(time.Hour)},
+		{Subject: "Newsletter", From: "news@example.com", Body: "Our invoices feature got better.", Date: day.Add(2 * time.Hour)},
+	}
+
+	got := Search(emails, "When is the contractor's invoice due?", 2)
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("Search = %v, want [1 2]", got)
+	}
+	if got := Search(emails, "what is the", 5); got != nil {
+		t.Errorf("Search with only stopwords = %v, want none", got)
+	}
+	if got := Search(emails, "dentist", 5); len(got) != 0 {
+		t.Errorf("Search(dentist) = %v, want none", got)
+	}
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"sort"
+	"strings"
+)
+
+// Threads groups emails into conversations. Emails are in the same thread
+// when one's Message-ID appears in another's References, or when they
+// reference a common message that may not be cached itself. Copies of the
+// same message are kept once. Each thread is sorted oldest first; threads
+// are sorted by their newest email, newest first.
+func Threads(emails []CachedEmail) [][]CachedEmail {
+	// Union-find over email indexes, joined through shared message IDs
+	parent := make([]int, len(emails))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	owner := make(map[string]int) // message ID -> first email with it
+	seen := make(map[string]bool) // own Message-IDs, to drop copies
+	skip := make([]bool, len(emails))
+	for i, e := range emails {
+		if id := normalizeMessageID(e.MessageID); id != "" {
+			if seen[id] {
+				skip[i] = true
+			}
+			seen[id] = true
+		}
+		for _, id := range threadIDs(e) {
+			if j, ok := owner[id]; ok {
+				parent[find(i)] = find(j)
+			} else {
+				owner[id] = i
+			}
+		}
+	}
+
+	byRoot := make(map[int][]CachedEmail)
+	var roots []int
+	for i, e := range emails {
+		if skip[i] {
+			continue
+		}
+		root := find(i)
+		if _, ok := byRoot[root]; !ok {
+			roots = append(roots, root)
+		}
+		byRoot[root] = append(byRoot[root], e)
+	}
+
+	threads := make([][]CachedEmail, 0, len(roots))
+	for _, root := range roots {
+		thread := byRoot[root]
+		sort.SliceStable(thread, func(i, j int) bool {
+			return thread[i].Date.Before(thread[j].Date)
+		})
+		threads = append(threads, thread)
+	}
+	sort.SliceStable(threads, func(i, j int) bool {
+		return threads[i][len(threads[i])-1].Date.After(threads[j][len(threads[j])-1].Date)
+	})
+	return threads
+}
+
+// Thread returns the conversation email belongs to among emails, oldest
+// first. The result always includes email.
+func Thread(emails []CachedEmail, email CachedEmail) []CachedEmail {
+	for _, thread := range Threads(append([]CachedEmail{email}, emails...)) {
+		for _, e := range thread {
+			if e.UID == email.UID && e.MessageID == email.MessageID {
+				return thread
+			}
+		}
+	}
+	return []CachedEmail{email}
+}
+
+// threadIDs returns the email's own Message-ID followed by the IDs in its
+// References header
+func threadIDs(e CachedEmail) []string {
+	var ids []string
+	if id := normalizeMessageID(e.MessageID); id != "" {
+		ids = append(ids, id)
+	}
+	for _, ref := range strings.Fields(e.References) {
+		if id := normalizeMessageID(ref); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func normalizeMessageID(id string) string {
+	return strings.Trim(strings.TrimSpace(id), "<>")
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+)
+
+func TestThreads(t *testing.T) {
+	day := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	emails := []CachedEmail{
+		{UID: 1, MessageID: "<a@x>", Subject: "Plan", Date: day},
+		{UID: 2, MessageID: "<b@x>", Subject: "Re: Plan", References: "<a@x>", Date: day.Add(time.Hour)},
+		{UID: 3, MessageID: "<c@x>", Subject: "Lunch", Date: day.Add(2 * time.Hour)},
+		// Replies to a message that is not cached still join the thread
+		{UID: 4, MessageID: "<d@x>", Subject: "Re: Plan", References: "<a@x> <missing@x>", Date: day.Add(3 * time.Hour)},
+		{UID: 5, MessageID: "<e@x>", Subject: "Re: Plan", References: "<missing@x>", Date: day.Add(30 * time.Minute)},
+		// A copy of the same message (e.g. in two mailboxes) counts once
+		{UID: 6, MessageID: "<b@x>", Subject: "Re: Plan", References: "<a@x>", Date: day.Add(time.Hour)},
+	}
+
+	threads := Threads(emails)
+	if len(threads) != 2 {
+		t.Fatalf("got %d threads, want 2", len(threads))
+	}
+	// Newest thread first, oldest email first within it
+	var uids []imap.UID
+	for _, e := range threads[0] {
+		uids = append(uids, e.UID)
+	}
+	if want := []imap.UID{1, 5, 2, 4}; len(uids) != len(want) || uids[0] != 1 || uids[1] != 5 || uids[2] != 2 || uids[3] != 4 {
+		t.Errorf("first thread = %v, want %v", uids, want)
+	}
+	if len(threads[1]) != 1 || threads[1][0].Subject != "Lunch" {
+		t.Errorf("second thread = %v, want Lunch alone", threads[1])
+	}
+
+	if thread := Thread(emails, emails[3]); len(thread) != 4 {
+		t.Errorf("Thread(d) has %d emails, want 4", len(thread))
+	}
+	lone := CachedEmail{UID: 9, MessageID: "<z@x>"}
+	if thread := Thread(emails, lone); len(thread) != 1 || thread[0].UID != 9 {
+		t.Errorf("Thread(lone) = %v, want only itself", thread)
+	}
+}
@@ -0,0 +1,61 @@
+package calendar
+
+import (
+	"maily/internal/auth"
+)
+
+// NewAccountClient returns the calendar configured for an account: its
+// CalDAV server if one is set up, otherwise Microsoft Graph for Outlook
+// accounts. It returns ErrNotSupported if the account has no calendar.
+func NewAccountClient(account *auth.Account) (Client, error) {
+	creds := &account.Credentials
+
+	if cfg := account.Calendar; cfg != nil {
+		switch cfg.Type {
+		case auth.CalendarCalDAV:
+			username, password := cfg.Username, cfg.Password
+			if username == "" {
+				username = creds.Email
+			}
+			if password == "" {
+				password = creds.Password
+			}
+			return NewCalDAVClient(cfg.URL, username, password)
+		case auth.CalendarLocal:
+			path := cfg.Path
+			if path == "" {
+				var err error
+				if path, err = DefaultVdirPath(); err != nil {
+					return nil, err
+				}
+			}
+			return NewVdirClient(path)
+		}
+	}
+
+	if creds.Provider == auth.ProviderOutlook {
+		return NewGraphClient(creds)
+	}
+	return nil, ErrNotSupported
+}
+
+// newConfiguredClient returns the calendar of the first account that has one,
+// falling back to local calendars in the default directory
+func newConfiguredClient() (Client, error) {
+	store, err := auth.LoadAccountStore()
+	if err != nil {
+		return nil, err
+	}
+	for i := range store.Accounts {
+		client, err := NewAccountClient(&store.Accounts[i])
+		if err != ErrNotSupported {
+			return client, err
+		}
+	}
+
+	path, err := DefaultVdirPath()
+	if err != nil {
+		return nil, err
+	}
+	return NewVdirClient(path)
+}
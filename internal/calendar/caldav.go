@@ -0,0 +1,400 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CalDAV presets. Nextcloud needs the server host, the others derive the URL
+// from the account email.
+var CalDAVPresets = map[string]string{
+	"google":    "https://www.google.com/calendar/dav/%s/user/",
+	"fastmail":  "https://caldav.fastmail.com/",
+	"icloud":    "https://caldav.icloud.com/",
+	"nextcloud": "https://%s/remote.php/dav/",
+}
+
+// CalDAVPresetURL returns the server URL for a preset. server is the host for
+// Nextcloud; email fills in the Google URL.
+func CalDAVPresetURL(preset, email, server string) (string, error) {
+	pattern, ok := CalDAVPresets[preset]
+	if !ok {
+		return "", fmt.Errorf("unknown CalDAV preset: %s", preset)
+	}
+	switch preset {
+	case "google":
+		return fmt.Sprintf(pattern, url.PathEscape(email)), nil
+	case "nextcloud":
+		if server == "" {
+			return "", fmt.Errorf("nextcloud needs a server host")
+		}
+		server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+		return fmt.Sprintf(pattern, strings.TrimSuffix(server, "/")), nil
+	}
+	return pattern, nil
+}
+
+// icalTimeFormat is the UTC DATE-TIME format used in CalDAV time ranges
+const icalTimeFormat = "20060102T150405Z"
+
+// caldavClient talks to a CalDAV server (RFC 4791), e.g. Radicale, Nextcloud,
+// Fastmail or iCloud. Event IDs are the hrefs of the event resources.
+type caldavClient struct {
+	baseURL  *url.URL
+	username string
+	password string
+	http     *http.Client
+
+	mu        sync.Mutex
+	calendars []caldavCalendar
+}
+
+type caldavCalendar struct {
+	Calendar
+	href string
+}
+
+// DAV multistatus responses
+type davMultistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"DAV: href"`
+	Propstats []davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"DAV: prop"`
+	Status string  `xml:"DAV: status"`
+}
+
+type davHref struct {
+	Href string `xml:"DAV: href"`
+}
+
+type davProp struct {
+	CurrentUserPrincipal davHref `xml:"DAV: current-user-principal"`
+	CalendarHomeSet      davHref `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+	DisplayName          string  `xml:"DAV: displayname"`
+	ResourceType         struct {
+		Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+	} `xml:"DAV: resourcetype"`
+	Components struct {
+		Comps []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"urn:ietf:params:xml:ns:caldav comp"`
+	} `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set"`
+	Color        string `xml:"http://apple.com/ns/ical/ calendar-color"`
+	CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+}
+
+// prop returns the properties of the successful propstat
+func (r davResponse) prop() davProp {
+	for _, ps := range r.Propstats {
+		if ps.Status == "" || strings.Contains(ps.Status, " 200 ") {
+			return ps.Prop
+		}
+	}
+	return davProp{}
+}
+
+// NewCalDAVClient returns a client for the CalDAV server at serverURL, which
+// may be the server root, a principal or a calendar home
+func NewCalDAVClient(serverURL, username, password string) (Client, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CalDAV URL: %w", err)
+	}
+	if u.Scheme == "" {
+		u, err = url.Parse("https://" + serverURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CalDAV URL: %w", err)
+		}
+	}
+	return &caldavClient{
+		baseURL:  u,
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (c *caldavClient) resolve(href string) string {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return c.baseURL.ResolveReference(ref).String()
+}
+
+// request sends a WebDAV request and returns the response body, failing on
+// non-2xx statuses. The response is returned for its status and headers.
+func (c *caldavClient) request(method, href, depth string, body []byte, headers map[string]string) ([]byte, *http.Response, error) {
+	req, err := http.NewRequest(method, c.resolve(href), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	if depth != "" {
+		req.Header.Set("Depth", depth)
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, resp, ErrAccessDenied
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, resp, fmt.Errorf("caldav: %s %s: %s", method, href, resp.Status)
+	}
+	return data, resp, nil
+}
+
+func (c *caldavClient) multistatus(method, href, depth, body string) ([]davResponse, error) {
+	data, _, err := c.request(method, href, depth, []byte(body), nil)
+	if err != nil {
+		return nil, err
+	}
+	var ms davMultistatus
+	if err := xml.Unmarshal(data, &ms); err != nil {
+		return nil, fmt.Errorf("caldav: invalid multistatus: %w", err)
+	}
+	return ms.Responses, nil
+}
+
+// findProp runs a Depth 0 PROPFIND for a single href property
+func (c *caldavClient) findProp(href, propXML string, get func(davProp) string) string {
+	body := `<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop>` + propXML + `</d:prop></d:propfind>`
+	responses, err := c.multistatus("PROPFIND", href, "0", body)
+	if err != nil || len(responses) == 0 {
+		return ""
+	}
+	return get(responses[0].prop())
+}
+
+// calendarHome discovers the calendar home set: server -> principal -> home.
+// Servers that answer neither are assumed to be pointed at the home already.
+func (c *caldavClient) calendarHome() string {
+	start := c.baseURL.String()
+
+	principal := c.findProp(start, `<d:current-user-principal/>`, func(p davProp) string {
+		return p.CurrentUserPrincipal.Href
+	})
+	if principal == "" {
+		principal = start
+	}
+
+	home := c.findProp(principal, `<c:calendar-home-set/>`, func(p davProp) string {
+		return p.CalendarHomeSet.Href
+	})
+	if home == "" {
+		return start
+	}
+	return home
+}
+
+func (c *caldavClient) loadCalendars() ([]caldavCalendar, error) {
+	c.mu.Lock()
+	cached := c.calendars
+	c.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	body := `<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:a="http://apple.com/ns/ical/">` +
+		`<d:prop><d:resourcetype/><d:displayname/><a:calendar-color/><c:supported-calendar-component-set/></d:prop></d:propfind>`
+	responses, err := c.multistatus("PROPFIND", c.calendarHome(), "1", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var calendars []caldavCalendar
+	for _, r := range responses {
+		prop := r.prop()
+		if prop.ResourceType.Calendar == nil || !supportsEvents(prop) {
+			continue
+		}
+		title := prop.DisplayName
+		if title == "" {
+			title = strings.Trim(r.Href, "/")
+			title = title[strings.LastIndex(title, "/")+1:]
+		}
+		calendars = append(calendars, caldavCalendar{
+			Calendar: Calendar{ID: r.Href, Title: title, Color: prop.Color},
+			href:     r.Href,
+		})
+	}
+
+	c.mu.Lock()
+	c.calendars = calendars
+	c.mu.Unlock()
+	return calendars, nil
+}
+
+// supportsEvents reports whether a collection accepts VEVENTs. Servers that
+// do not advertise components accept everything.
+func supportsEvents(prop davProp) bool {
+	if len(prop.Components.Comps) == 0 {
+		return true
+	}
+	for _, comp := range prop.Components.Comps {
+		if strings.EqualFold(comp.Name, "VEVENT") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *caldavClient) ListCalendars() ([]Calendar, error) {
+	calendars, err := c.loadCalendars()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Calendar, len(calendars))
+	for i, cal := range calendars {
+		result[i] = cal.Calendar
+	}
+	return result, nil
+}
+
+func (c *caldavClient) ListEvents(start, end time.Time) ([]Event, error) {
+	calendars, err := c.loadCalendars()
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`+
+		`<d:prop><d:getetag/><c:calendar-data/></d:prop>`+
+		`<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT">`+
+		`<c:time-range start="%s" end="%s"/>`+
+		`</c:comp-filter></c:comp-filter></c:filter></c:calendar-query>`,
+		start.UTC().Format(icalTimeFormat), end.UTC().Format(icalTimeFormat))
+
+	var events []Event
+	for _, cal := range calendars {
+		responses, err := c.multistatus("REPORT", cal.href, "1", query)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range responses {
+			data := r.prop().CalendarData
+			if data == "" {
+				continue
+			}
+			parsed, err := expandICS([]byte(data), r.Href, cal.Title, start, end)
+			if err != nil {
+				// Skip objects we cannot parse rather than hiding the whole calendar
+				continue
+			}
+			events = append(events, parsed...)
+		}
+	}
+	return events, nil
+}
+
+// CreateEvent stores the event in event.Calendar (a calendar ID), or the
+// first calendar if it is empty
+func (c *caldavClient) CreateEvent(event Event) (string, error) {
+	calendars, err := c.loadCalendars()
+	if err != nil {
+		return "", err
+	}
+	if len(calendars) == 0 {
+		return "", fmt.Errorf("%w: no calendars found", ErrFailed)
+	}
+
+	collection := calendars[0].href
+	for _, cal := range calendars {
+		if cal.ID == event.Calendar || cal.Title == event.Calendar {
+			collection = cal.href
+		}
+	}
+
+	uid, name := eventUID(event)
+	data, err := encodeICS(event, uid)
+	if err != nil {
+		return "", err
+	}
+
+	href := strings.TrimSuffix(collection, "/") + "/" + url.PathEscape(name)
+	_, _, err = c.request(http.MethodPut, href, "", data, map[string]string{
+		"Content-Type":  "text/calendar; charset=utf-8",
+		"If-None-Match": "*",
+	})
+	if err != nil {
+		return "", err
+	}
+	return href, nil
+}
+
+func (c *caldavClient) UpdateEvent(event Event) error {
+	return c.modify(event.ID, func(data []byte) ([]byte, error) {
+		return updateICS(data, event)
+	})
+}
+
+func (c *caldavClient) DeleteOccurrence(id string, occurrence time.Time, span Span) error {
+	return c.modify(id, func(data []byte) ([]byte, error) {
+		return deleteOccurrenceICS(data, occurrence, span)
+	})
+}
+
+// modify rewrites the event resource at href with change, or deletes it if
+// change returns no data. Writes are guarded by the ETag so concurrent
+// changes on the server are not overwritten.
+func (c *caldavClient) modify(href string, change func([]byte) ([]byte, error)) error {
+	data, resp, err := c.request(http.MethodGet, href, "", nil, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	updated, err := change(data)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		headers["If-Match"] = etag
+	}
+	if updated == nil {
+		_, _, err = c.request(http.MethodDelete, href, "", nil, headers)
+		return err
+	}
+	headers["Content-Type"] = "text/calendar; charset=utf-8"
+	_, _, err = c.request(http.MethodPut, href, "", updated, headers)
+	return err
+}
+
+func (c *caldavClient) DeleteEvent(id string) error {
+	_, resp, err := c.request(http.MethodDelete, id, "", nil, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return err
+}
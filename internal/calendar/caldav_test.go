@@ -0,0 +1,148 @@
+package calendar
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCalDAV is a minimal CalDAV server with one principal and one calendar
+// at /dav/cal/work/, answering the requests caldavClient sends (no filtering)
+type fakeCalDAV struct {
+	mu      sync.Mutex
+	objects map[string]string // href -> iCalendar data
+}
+
+func (f *fakeCalDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, _ := r.BasicAuth(); user != "me" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	multistatus := func(responses string) {
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">%s</d:multistatus>`, responses)
+	}
+	response := func(href, props string) string {
+		return fmt.Sprintf(`<d:response><d:href>%s</d:href><d:propstat><d:prop>%s</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, href, props)
+	}
+
+	switch {
+	case r.Method == "PROPFIND" && r.URL.Path == "/":
+		multistatus(response("/", `<d:current-user-principal><d:href>/dav/me/</d:href></d:current-user-principal>`))
+	case r.Method == "PROPFIND" && r.URL.Path == "/dav/me/":
+		multistatus(response("/dav/me/", `<c:calendar-home-set><d:href>/dav/cal/</d:href></c:calendar-home-set>`))
+	case r.Method == "PROPFIND" && r.URL.Path == "/dav/cal/":
+		multistatus(response("/dav/cal/", `<d:resourcetype><d:collection/></d:resourcetype>`) +
+			response("/dav/cal/work/", `<d:resourcetype><d:collection/><c:calendar/></d:resourcetype><d:displayname>Work</d:displayname>`+
+				`<c:supported-calendar-component-set><c:comp name="VEVENT"/></c:supported-calendar-component-set>`) +
+			response("/dav/cal/tasks/", `<d:resourcetype><d:collection/><c:calendar/></d:resourcetype><d:displayname>Tasks</d:displayname>`+
+				`<c:supported-calendar-component-set><c:comp name="VTODO"/></c:supported-calendar-component-set>`))
+	case r.Method == "REPORT" && r.URL.Path == "/dav/cal/work/":
+		var out strings.Builder
+		for href, data := range f.objects {
+			out.WriteString(response(href, "<c:calendar-data>"+xmlEscape(data)+"</c:calendar-data>"))
+		}
+		multistatus(out.String())
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = string(data)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodDelete:
+		if _, ok := f.objects[r.URL.Path]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func TestCalDAVClient(t *testing.T) {
+	fake := &fakeCalDAV{objects: map[string]string{
+		"/dav/cal/work/allday.ics": "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:test\r\nBEGIN:VEVENT\r\n" +
+			"UID:allday\r\nDTSTAMP:20240101T000000Z\r\nDTSTART;VALUE=DATE:20240305\r\nSUMMARY:Offsite\r\n" +
+			"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := NewCalDAVClient(server.URL+"/", "me", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calendars, err := client.ListCalendars()
+	if err != nil {
+		t.Fatalf("ListCalendars: %v", err)
+	}
+	if len(calendars) != 1 || calendars[0].Title != "Work" || calendars[0].ID != "/dav/cal/work/" {
+		t.Fatalf("calendars = %+v, want only Work", calendars)
+	}
+
+	start := time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)
+	id, err := client.CreateEvent(Event{
+		Title:              "Review",
+		StartTime:          start,
+		EndTime:            start.Add(time.Hour),
+		Location:           "Room 1",
+		Calendar:           calendars[0].ID,
+		AlarmMinutesBefore: 15,
+	})
+	if err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+	if !strings.HasPrefix(id, "/dav/cal/work/") {
+		t.Fatalf("event id = %q, want a href in the Work calendar", id)
+	}
+
+	events, err := client.ListEvents(start.Add(-24*time.Hour), start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	byTitle := make(map[string]Event)
+	for _, e := range events {
+		byTitle[e.Title] = e
+	}
+
+	review := byTitle["Review"]
+	if review.ID != id || !review.StartTime.Equal(start) || !review.EndTime.Equal(start.Add(time.Hour)) ||
+		review.Location != "Room 1" || review.AlarmMinutesBefore != 15 || review.Calendar != "Work" {
+		t.Fatalf("unexpected created event: %+v", review)
+	}
+	if offsite := byTitle["Offsite"]; !offsite.AllDay || offsite.EndTime.Sub(offsite.StartTime) != 24*time.Hour {
+		t.Fatalf("unexpected all-day event: %+v", offsite)
+	}
+
+	if err := client.DeleteEvent(id); err != nil {
+		t.Fatalf("DeleteEvent: %v", err)
+	}
+	if err := client.DeleteEvent(id); err != ErrNotFound {
+		t.Fatalf("second DeleteEvent = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCalDAVPresetURL(t *testing.T) {
+	if u, _ := CalDAVPresetURL("nextcloud", "me@example.com", "https://cloud.example.com/"); u != "https://cloud.example.com/remote.php/dav/" {
+		t.Errorf("nextcloud = %q", u)
+	}
+	if _, err := CalDAVPresetURL("nextcloud", "me@example.com", ""); err == nil {
+		t.Error("nextcloud without server should fail")
+	}
+	if u, _ := CalDAVPresetURL("google", "me@gmail.com", ""); u != "https://www.google.com/calendar/dav/me@gmail.com/user/" {
+		t.Errorf("google = %q", u)
+	}
+}
@@ -9,20 +9,27 @@ var (
 	ErrAccessDenied = errors.New("calendar access denied")
 	ErrNotFound     = errors.New("event not found")
 	ErrFailed       = errors.New("operation failed")
-	ErrNotSupported = errors.New("calendar integration only supported on macOS")
+	ErrNotSupported = errors.New("account has no calendar: run 'maily calendar setup'")
 )
 
 // Event represents a calendar event
 type Event struct {
 	ID                 string
+	UID                string // iCalendar UID, shared with invitations for this event
 	Title              string
-	StartTime          time.Time
+	StartTime          time.Time // In local time, like EndTime
 	EndTime            time.Time
+	TimeZone           string // IANA zone the event was scheduled in, e.g. "America/Los_Angeles" (empty = local)
 	Location           string
 	Notes              string
 	Calendar           string
 	AllDay             bool
-	AlarmMinutesBefore int // Minutes before event to trigger alarm (0 = no alarm)
+	AlarmMinutesBefore int        // Minutes before event to trigger alarm (0 = no alarm)
+	Recurrence         string     // RRULE value, e.g. "FREQ=WEEKLY;BYDAY=MO" (empty = does not repeat)
+	OccurrenceDate     time.Time  // Original start of this occurrence of a recurring event
+	Organizer          Attendee   // Who sends invitations for the event (zero if it has no attendees)
+	Attendees          []Attendee // People invited to the event
+	Sequence           int        // iCalendar SEQUENCE, bumped by every update
 }
 
 // Calendar represents a calendar source
@@ -43,6 +50,17 @@ type Client interface {
 	// CreateEvent creates a new event and returns its ID
 	CreateEvent(event Event) (string, error)
 
-	// DeleteEvent removes an event by its ID
+	// UpdateEvent saves changes to the event with event.ID in place. Fields
+	// Event does not model (other alarms, ...) are kept; event.Calendar is
+	// ignored. For an occurrence of a recurring event only that occurrence
+	// changes.
+	UpdateEvent(event Event) error
+
+	// DeleteEvent removes an event by its ID, with all its occurrences
 	DeleteEvent(id string) error
+
+	// DeleteOccurrence removes the occurrence of a recurring event that
+	// originally started at occurrence, and with SpanFutureEvents every
+	// later one too
+	DeleteOccurrence(id string, occurrence time.Time, span Span) error
 }
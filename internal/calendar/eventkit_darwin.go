@@ -12,8 +12,11 @@ import "C"
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 	"unsafe"
+
+	"github.com/teambition/rrule-go"
 )
 
 type eventKitClient struct{}
@@ -98,15 +101,22 @@ func (c *eventKitClient) ListEvents(start, end time.Time) ([]Event, error) {
 	jsonStr := C.GoString(cStr)
 
 	var rawEvents []struct {
-		ID         string `json:"id"`
-		Title      string `json:"title"`
-		StartTime  int64  `json:"startTime"`
-		EndTime    int64  `json:"endTime"`
-		Location   string `json:"location"`
-		Notes      string `json:"notes"`
-		Calendar   string `json:"calendar"`
-		CalendarID string `json:"calendarID"`
-		AllDay     bool   `json:"allDay"`
+		ID         string          `json:"id"`
+		UID        string          `json:"uid"`
+		Title      string          `json:"title"`
+		StartTime  int64           `json:"startTime"`
+		EndTime    int64           `json:"endTime"`
+		Location   string          `json:"location"`
+		Notes      string          `json:"notes"`
+		Calendar   string          `json:"calendar"`
+		CalendarID string          `json:"calendarID"`
+		AllDay     bool            `json:"allDay"`
+		TimeZone   string          `json:"timeZone"`
+		Alarm      int             `json:"alarmMinutesBefore"`
+		Recurrence *ekRecurrence   `json:"recurrence"`
+		Occurrence int64           `json:"occurrenceDate"`
+		Organizer  *ekParticipant  `json:"organizer"`
+		Attendees  []ekParticipant `json:"attendees"`
 	}
 
 	if err := json.Unmarshal([]byte(jsonStr), &rawEvents); err != nil {
@@ -116,14 +126,31 @@ func (c *eventKitClient) ListEvents(start, end time.Time) ([]Event, error) {
 	events := make([]Event, len(rawEvents))
 	for i, re := range rawEvents {
 		events[i] = Event{
-			ID:        re.ID,
-			Title:     re.Title,
-			StartTime: time.Unix(re.StartTime, 0),
-			EndTime:   time.Unix(re.EndTime, 0),
-			Location:  re.Location,
-			Notes:     re.Notes,
-			Calendar:  re.Calendar,
-			AllDay:    re.AllDay,
+			ID:                 re.ID,
+			UID:                re.UID,
+			Title:              re.Title,
+			StartTime:          time.Unix(re.StartTime, 0),
+			EndTime:            time.Unix(re.EndTime, 0),
+			Location:           re.Location,
+			Notes:              re.Notes,
+			Calendar:           re.Calendar,
+			AllDay:             re.AllDay,
+			AlarmMinutesBefore: re.Alarm,
+		}
+		if loc, err := time.LoadLocation(re.TimeZone); err == nil && re.TimeZone != "" && !re.AllDay {
+			events[i].TimeZone = loc.String()
+		}
+		if re.Recurrence != nil {
+			events[i].Recurrence = re.Recurrence.rule()
+		}
+		if re.Occurrence != 0 {
+			events[i].OccurrenceDate = time.Unix(re.Occurrence, 0)
+		}
+		if re.Organizer != nil {
+			events[i].Organizer = re.Organizer.attendee()
+		}
+		for _, p := range re.Attendees {
+			events[i].Attendees = append(events[i].Attendees, p.attendee())
 		}
 	}
 
@@ -148,6 +175,21 @@ func (c *eventKitClient) CreateEvent(event Event) (string, error) {
 		allDay = C.int(1)
 	}
 
+	recurrence := ""
+	if event.Recurrence != "" {
+		rec, err := newEKRecurrence(event.Recurrence)
+		if err != nil {
+			return "", err
+		}
+		data, _ := json.Marshal(rec)
+		recurrence = string(data)
+	}
+	cRecurrence := C.CString(recurrence)
+	defer C.free(unsafe.Pointer(cRecurrence))
+
+	cTimeZone := C.CString(event.TimeZone)
+	defer C.free(unsafe.Pointer(cTimeZone))
+
 	cEventID := C.CreateEvent(
 		cTitle,
 		C.longlong(event.StartTime.Unix()),
@@ -157,6 +199,8 @@ func (c *eventKitClient) CreateEvent(event Event) (string, error) {
 		cNotes,
 		allDay,
 		C.int(event.AlarmMinutesBefore),
+		cRecurrence,
+		cTimeZone,
 	)
 
 	if cEventID == nil {
@@ -167,12 +211,71 @@ func (c *eventKitClient) CreateEvent(event Event) (string, error) {
 	return C.GoString(cEventID), nil
 }
 
+func (c *eventKitClient) UpdateEvent(event Event) error {
+	cID := C.CString(event.ID)
+	defer C.free(unsafe.Pointer(cID))
+
+	cTitle := C.CString(event.Title)
+	defer C.free(unsafe.Pointer(cTitle))
+
+	cLocation := C.CString(event.Location)
+	defer C.free(unsafe.Pointer(cLocation))
+
+	cNotes := C.CString(event.Notes)
+	defer C.free(unsafe.Pointer(cNotes))
+
+	allDay := C.int(0)
+	if event.AllDay {
+		allDay = C.int(1)
+	}
+
+	cTimeZone := C.CString(event.TimeZone)
+	defer C.free(unsafe.Pointer(cTimeZone))
+
+	result := C.UpdateEvent(
+		cID,
+		occurrenceTimestamp(event.OccurrenceDate),
+		cTitle,
+		C.longlong(event.StartTime.Unix()),
+		C.longlong(event.EndTime.Unix()),
+		cLocation,
+		cNotes,
+		allDay,
+		C.int(event.AlarmMinutesBefore),
+		cTimeZone,
+	)
+
+	return resultError(result)
+}
+
 func (c *eventKitClient) DeleteEvent(id string) error {
 	cID := C.CString(id)
 	defer C.free(unsafe.Pointer(cID))
 
-	result := C.DeleteEvent(cID)
+	// The first occurrence and all future ones is the whole series
+	return resultError(C.DeleteEvent(cID, 0, 1))
+}
+
+func (c *eventKitClient) DeleteOccurrence(id string, occurrence time.Time, span Span) error {
+	cID := C.CString(id)
+	defer C.free(unsafe.Pointer(cID))
+
+	future := C.int(0)
+	if span == SpanFutureEvents {
+		future = C.int(1)
+	}
+	return resultError(C.DeleteEvent(cID, occurrenceTimestamp(occurrence), future))
+}
+
+func occurrenceTimestamp(occurrence time.Time) C.longlong {
+	if occurrence.IsZero() {
+		return 0
+	}
+	return C.longlong(occurrence.Unix())
+}
 
+// resultError maps an EK_* result code to an error
+func resultError(result C.int) error {
 	switch result {
 	case C.EK_SUCCESS:
 		return nil
@@ -184,3 +287,101 @@ func (c *eventKitClient) DeleteEvent(id string) error {
 		return ErrFailed
 	}
 }
+
+// ekRecurrence is the JSON form of an EKRecurrenceRule exchanged with the
+// Objective-C side
+type ekRecurrence struct {
+	Frequency   int           `json:"frequency"` // EKRecurrenceFrequency
+	Interval    int           `json:"interval"`
+	DaysOfWeek  []ekDayOfWeek `json:"daysOfWeek"`
+	DaysOfMonth []int         `json:"daysOfMonth"`
+	Months      []int         `json:"monthsOfYear"`
+	Count       int           `json:"count"`
+	Until       int64         `json:"until"`
+}
+
+type ekDayOfWeek struct {
+	Day  int `json:"day"`  // EKWeekday: 1 = Sunday ... 7 = Saturday
+	Week int `json:"week"` // 0 = every week, -1 = last
+}
+
+var ekFrequencies = []rrule.Frequency{rrule.DAILY, rrule.WEEKLY, rrule.MONTHLY, rrule.YEARLY}
+
+func newEKRecurrence(rule string) (*ekRecurrence, error) {
+	opt, err := rrule.StrToROptionInLocation(rule, time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &ekRecurrence{
+		Frequency:   -1,
+		Interval:    max(opt.Interval, 1),
+		DaysOfMonth: opt.Bymonthday,
+		Months:      opt.Bymonth,
+		Count:       opt.Count,
+	}
+	for i, freq := range ekFrequencies {
+		if freq == opt.Freq {
+			rec.Frequency = i
+		}
+	}
+	if rec.Frequency < 0 {
+		return nil, fmt.Errorf("%w: unsupported recurrence frequency %v", ErrFailed, opt.Freq)
+	}
+	for _, wd := range opt.Byweekday {
+		// rrule weekdays count from Monday = 0, EKWeekday from Sunday = 1
+		rec.DaysOfWeek = append(rec.DaysOfWeek, ekDayOfWeek{Day: (wd.Day()+1)%7 + 1, Week: wd.N()})
+	}
+	if !opt.Until.IsZero() {
+		rec.Until = opt.Until.Unix()
+	}
+	return rec, nil
+}
+
+// rule converts the recurrence back to an RRULE value
+func (rec *ekRecurrence) rule() string {
+	if rec.Frequency < 0 || rec.Frequency >= len(ekFrequencies) {
+		return ""
+	}
+	opt := rrule.ROption{
+		Freq:       ekFrequencies[rec.Frequency],
+		Bymonthday: rec.DaysOfMonth,
+		Bymonth:    rec.Months,
+		Count:      rec.Count,
+	}
+	if rec.Interval > 1 {
+		opt.Interval = rec.Interval
+	}
+	weekdays := []rrule.Weekday{rrule.MO, rrule.TU, rrule.WE, rrule.TH, rrule.FR, rrule.SA, rrule.SU}
+	for _, d := range rec.DaysOfWeek {
+		wd := weekdays[(d.Day+5)%7]
+		opt.Byweekday = append(opt.Byweekday, wd.Nth(d.Week))
+	}
+	if rec.Until > 0 {
+		opt.Until = time.Unix(rec.Until, 0)
+	}
+	return opt.RRuleString()
+}
+
+// ekParticipant is an EKParticipant as sent by ListEvents. EventKit cannot
+// invite attendees, so they are only read.
+type ekParticipant struct {
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Status int    `json:"status"` // EKParticipantStatus
+}
+
+// ekStatuses maps EKParticipantStatus values to PARTSTAT
+var ekStatuses = map[int]string{
+	2: StatusAccepted,
+	3: StatusDeclined,
+	4: StatusTentative,
+}
+
+func (p ekParticipant) attendee() Attendee {
+	status, ok := ekStatuses[p.Status]
+	if !ok {
+		status = StatusNeedsAction
+	}
+	return Attendee{Email: p.Email, Name: p.Name, Status: status}
+}
@@ -27,21 +27,38 @@ int RequestCalendarAccess(void);
 char* ListCalendars(void);
 
 // List events between start and end dates (Unix timestamps)
-// Returns JSON array of events
+// Returns JSON array of events, with recurring events expanded into their
+// occurrences. Recurrence rules use the JSON form described at CreateEvent.
 // Caller must free the returned string
 char* ListEvents(long long startTimestamp, long long endTimestamp);
 
 // Create a new event
 // Returns the event ID on success, NULL on failure
 // alarmMinutesBefore: minutes before event to trigger alarm (0 = no alarm)
+// recurrence: JSON recurrence rule, or empty for a single event:
+//   {"frequency":1, "interval":1, "daysOfWeek":[{"day":2,"week":0}],
+//    "daysOfMonth":[], "monthsOfYear":[], "count":0, "until":0}
+// with EKRecurrenceFrequency and EKWeekday values
+// timeZone: IANA zone the event is scheduled in, or empty for the system zone
 // Caller must free the returned string
 char* CreateEvent(const char* title, long long startTimestamp, long long endTimestamp,
                   const char* calendarID, const char* location, const char* notes, int allDay,
-                  int alarmMinutesBefore);
+                  int alarmMinutesBefore, const char* recurrence, const char* timeZone);
 
-// Delete an event by ID
+// Update an existing event in place, keeping its ID, attendees and recurrence.
+// Alarms are only replaced if alarmMinutesBefore differs from the first alarm.
+// occurrenceTimestamp selects one occurrence of a recurring event (0 = the event)
+// timeZone: IANA zone to move the event to, or empty to keep its zone
 // Returns EK_SUCCESS on success, error code on failure
-int DeleteEvent(const char* eventID);
+int UpdateEvent(const char* eventID, long long occurrenceTimestamp, const char* title,
+                long long startTimestamp, long long endTimestamp,
+                const char* location, const char* notes, int allDay, int alarmMinutesBefore,
+                const char* timeZone);
+
+// Delete an event by ID, or the occurrence of a recurring event that started
+// at occurrenceTimestamp (0 = the first); futureEvents also deletes later ones
+// Returns EK_SUCCESS on success, error code on failure
+int DeleteEvent(const char* eventID, long long occurrenceTimestamp, int futureEvents);
 
 // Free a string returned by the EventKit functions
 void FreeString(char* str);
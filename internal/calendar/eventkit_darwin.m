@@ -41,6 +41,124 @@ static NSString* colorToHex(CGColorRef cgColor) {
     return [NSString stringWithFormat:@"#%02X%02X%02X", r, g, b];
 }
 
+// Minutes before the event of its first relative alarm, 0 if none
+static int firstAlarmMinutes(EKEvent *event) {
+    for (EKAlarm *alarm in event.alarms) {
+        if (alarm.absoluteDate == nil && alarm.relativeOffset <= 0) {
+            return (int)(-alarm.relativeOffset / 60);
+        }
+    }
+    return 0;
+}
+
+// Finds an event by ID. With an occurrence timestamp, finds the occurrence of
+// a recurring event that originally started then; detached occurrences keep
+// their occurrenceDate, so the search window covers moved ones too.
+static EKEvent* findEvent(EKEventStore *store, const char* eventID, long long occurrenceTimestamp) {
+    NSString *eventIDStr = [NSString stringWithUTF8String:eventID];
+    EKEvent *event = [store eventWithIdentifier:eventIDStr];
+    if (event == nil || occurrenceTimestamp == 0 || !event.hasRecurrenceRules) {
+        return event;
+    }
+
+    NSDate *occurrence = [NSDate dateWithTimeIntervalSince1970:(NSTimeInterval)occurrenceTimestamp];
+    NSTimeInterval week = 7 * 24 * 60 * 60;
+    NSPredicate *predicate = [store predicateForEventsWithStartDate:[occurrence dateByAddingTimeInterval:-week]
+                                                            endDate:[occurrence dateByAddingTimeInterval:week]
+                                                          calendars:@[event.calendar]];
+    for (EKEvent *candidate in [store eventsMatchingPredicate:predicate]) {
+        if ([candidate.eventIdentifier isEqualToString:eventIDStr] &&
+            [candidate.occurrenceDate isEqualToDate:occurrence]) {
+            return candidate;
+        }
+    }
+    return nil;
+}
+
+// JSON form of a participant: email (from its mailto: URL), name and
+// EKParticipantStatus
+static NSDictionary *participantToJSON(EKParticipant *participant) {
+    NSString *email = participant.URL.resourceSpecifier ?: @"";
+    return @{
+        @"email": email,
+        @"name": participant.name ?: @"",
+        @"status": @(participant.participantStatus)
+    };
+}
+
+static NSArray *attendeesToJSON(EKEvent *event) {
+    NSMutableArray *attendees = [NSMutableArray array];
+    for (EKParticipant *participant in event.attendees) {
+        [attendees addObject:participantToJSON(participant)];
+    }
+    return attendees;
+}
+
+// JSON form of the event's first recurrence rule (see CreateEvent), or NSNull
+static id recurrenceToJSON(EKEvent *event) {
+    EKRecurrenceRule *rule = event.recurrenceRules.firstObject;
+    if (rule == nil) {
+        return [NSNull null];
+    }
+
+    NSMutableArray *days = [NSMutableArray array];
+    for (EKRecurrenceDayOfWeek *day in rule.daysOfTheWeek) {
+        [days addObject:@{@"day": @(day.dayOfTheWeek), @"week": @(day.weekNumber)}];
+    }
+
+    return @{
+        @"frequency": @(rule.frequency),
+        @"interval": @(rule.interval),
+        @"daysOfWeek": days,
+        @"daysOfMonth": rule.daysOfTheMonth ?: @[],
+        @"monthsOfYear": rule.monthsOfTheYear ?: @[],
+        @"count": @(rule.recurrenceEnd.occurrenceCount),
+        @"until": @((long long)[rule.recurrenceEnd.endDate timeIntervalSince1970])
+    };
+}
+
+// Builds a recurrence rule from its JSON form, nil for an empty string
+static EKRecurrenceRule* recurrenceFromJSON(const char* json) {
+    if (json == NULL || strlen(json) == 0) {
+        return nil;
+    }
+    NSData *data = [NSData dataWithBytes:json length:strlen(json)];
+    NSDictionary *dict = [NSJSONSerialization JSONObjectWithData:data options:0 error:nil];
+    if (![dict isKindOfClass:[NSDictionary class]]) {
+        return nil;
+    }
+
+    NSMutableArray *days = nil;
+    for (NSDictionary *day in dict[@"daysOfWeek"]) {
+        if (days == nil) {
+            days = [NSMutableArray array];
+        }
+        [days addObject:[EKRecurrenceDayOfWeek dayOfWeek:[day[@"day"] integerValue]
+                                              weekNumber:[day[@"week"] integerValue]]];
+    }
+    NSArray *monthDays = [dict[@"daysOfMonth"] count] > 0 ? dict[@"daysOfMonth"] : nil;
+    NSArray *months = [dict[@"monthsOfYear"] count] > 0 ? dict[@"monthsOfYear"] : nil;
+
+    EKRecurrenceEnd *end = nil;
+    if ([dict[@"count"] integerValue] > 0) {
+        end = [EKRecurrenceEnd recurrenceEndWithOccurrenceCount:[dict[@"count"] integerValue]];
+    } else if ([dict[@"until"] longLongValue] > 0) {
+        end = [EKRecurrenceEnd recurrenceEndWithEndDate:
+               [NSDate dateWithTimeIntervalSince1970:(NSTimeInterval)[dict[@"until"] longLongValue]]];
+    }
+
+    NSInteger interval = [dict[@"interval"] integerValue];
+    return [[EKRecurrenceRule alloc] initRecurrenceWithFrequency:(EKRecurrenceFrequency)[dict[@"frequency"] integerValue]
+                                                        interval:(interval > 0 ? interval : 1)
+                                                   daysOfTheWeek:days
+                                                  daysOfTheMonth:monthDays
+                                                 monthsOfTheYear:months
+                                                  weeksOfTheYear:nil
+                                                   daysOfTheYear:nil
+                                                    setPositions:nil
+                                                             end:end];
+}
+
 int GetAuthorizationStatus(void) {
     @autoreleasepool {
         EKAuthorizationStatus status;
@@ -167,6 +285,7 @@ char* ListEvents(long long startTimestamp, long long endTimestamp) {
         for (EKEvent *event in events) {
             NSDictionary *dict = @{
                 @"id": event.eventIdentifier ?: @"",
+                @"uid": event.calendarItemExternalIdentifier ?: @"",
                 @"title": event.title ?: @"",
                 @"startTime": @((long long)[event.startDate timeIntervalSince1970]),
                 @"endTime": @((long long)[event.endDate timeIntervalSince1970]),
@@ -174,7 +293,13 @@ char* ListEvents(long long startTimestamp, long long endTimestamp) {
                 @"notes": event.notes ?: @"",
                 @"calendar": event.calendar.title ?: @"",
                 @"calendarID": event.calendar.calendarIdentifier ?: @"",
-                @"allDay": @(event.allDay)
+                @"allDay": @(event.allDay),
+                @"timeZone": event.timeZone.name ?: @"",
+                @"alarmMinutesBefore": @(firstAlarmMinutes(event)),
+                @"recurrence": recurrenceToJSON(event),
+                @"organizer": event.organizer ? participantToJSON(event.organizer) : [NSNull null],
+                @"attendees": attendeesToJSON(event),
+                @"occurrenceDate": @(event.hasRecurrenceRules ? (long long)[event.occurrenceDate timeIntervalSince1970] : 0)
             };
             [eventDicts addObject:dict];
         }
@@ -195,9 +320,20 @@ char* ListEvents(long long startTimestamp, long long endTimestamp) {
     }
 }
 
+// setTimeZone schedules event in the named IANA zone, if there is one
+static void setTimeZone(EKEvent *event, const char* timeZone) {
+    if (timeZone == NULL || strlen(timeZone) == 0 || event.allDay) {
+        return;
+    }
+    NSTimeZone *zone = [NSTimeZone timeZoneWithName:[NSString stringWithUTF8String:timeZone]];
+    if (zone != nil) {
+        event.timeZone = zone;
+    }
+}
+
 char* CreateEvent(const char* title, long long startTimestamp, long long endTimestamp,
                   const char* calendarID, const char* location, const char* notes, int allDay,
-                  int alarmMinutesBefore) {
+                  int alarmMinutesBefore, const char* recurrence, const char* timeZone) {
     @autoreleasepool {
         if (!accessGranted) {
             if (RequestCalendarAccess() != EK_SUCCESS) {
@@ -212,6 +348,7 @@ char* CreateEvent(const char* title, long long startTimestamp, long long endTime
         event.startDate = [NSDate dateWithTimeIntervalSince1970:(NSTimeInterval)startTimestamp];
         event.endDate = [NSDate dateWithTimeIntervalSince1970:(NSTimeInterval)endTimestamp];
         event.allDay = (allDay != 0);
+        setTimeZone(event, timeZone);
 
         if (location != NULL && strlen(location) > 0) {
             event.location = [NSString stringWithUTF8String:location];
@@ -227,6 +364,11 @@ char* CreateEvent(const char* title, long long startTimestamp, long long endTime
             [event addAlarm:alarm];
         }
 
+        EKRecurrenceRule *rule = recurrenceFromJSON(recurrence);
+        if (rule != nil) {
+            [event addRecurrenceRule:rule];
+        }
+
         // Find the calendar by ID, or use default
         EKCalendar *calendar = nil;
         if (calendarID != NULL && strlen(calendarID) > 0) {
@@ -255,7 +397,59 @@ char* CreateEvent(const char* title, long long startTimestamp, long long endTime
     }
 }
 
-int DeleteEvent(const char* eventID) {
+int UpdateEvent(const char* eventID, long long occurrenceTimestamp, const char* title,
+                long long startTimestamp, long long endTimestamp,
+                const char* location, const char* notes, int allDay, int alarmMinutesBefore,
+                const char* timeZone) {
+    @autoreleasepool {
+        if (!accessGranted) {
+            if (RequestCalendarAccess() != EK_SUCCESS) {
+                return EK_ERROR_ACCESS_DENIED;
+            }
+        }
+
+        if (eventID == NULL) {
+            return EK_ERROR_NOT_FOUND;
+        }
+
+        EKEventStore *store = getEventStore();
+        EKEvent *event = findEvent(store, eventID, occurrenceTimestamp);
+
+        if (event == nil) {
+            return EK_ERROR_NOT_FOUND;
+        }
+
+        event.title = title ? [NSString stringWithUTF8String:title] : @"";
+        event.startDate = [NSDate dateWithTimeIntervalSince1970:(NSTimeInterval)startTimestamp];
+        event.endDate = [NSDate dateWithTimeIntervalSince1970:(NSTimeInterval)endTimestamp];
+        event.allDay = (allDay != 0);
+        setTimeZone(event, timeZone);
+        event.location = (location != NULL && strlen(location) > 0) ? [NSString stringWithUTF8String:location] : nil;
+        event.notes = (notes != NULL && strlen(notes) > 0) ? [NSString stringWithUTF8String:notes] : nil;
+
+        // Only touch alarms when the reminder changed, so alarms maily does
+        // not model (absolute, multiple) survive an edit
+        if (firstAlarmMinutes(event) != alarmMinutesBefore) {
+            for (EKAlarm *alarm in [event.alarms copy]) {
+                [event removeAlarm:alarm];
+            }
+            if (alarmMinutesBefore > 0) {
+                [event addAlarm:[EKAlarm alarmWithRelativeOffset:-alarmMinutesBefore * 60]];
+            }
+        }
+
+        NSError *error = nil;
+        BOOL success = [store saveEvent:event span:EKSpanThisEvent commit:YES error:&error];
+
+        if (!success || error != nil) {
+            return EK_ERROR_FAILED;
+        }
+
+        return EK_SUCCESS;
+    }
+}
+
+int DeleteEvent(const char* eventID, long long occurrenceTimestamp, int futureEvents) {
     @autoreleasepool {
         if (!accessGranted) {
             if (RequestCalendarAccess() != EK_SUCCESS) {
@@ -268,15 +462,15 @@ int DeleteEvent(const char* eventID) {
         }
 
         EKEventStore *store = getEventStore();
-        NSString *eventIDStr = [NSString stringWithUTF8String:eventID];
-        EKEvent *event = [store eventWithIdentifier:eventIDStr];
+        EKEvent *event = findEvent(store, eventID, occurrenceTimestamp);
 
         if (event == nil) {
             return EK_ERROR_NOT_FOUND;
         }
 
         NSError *error = nil;
-        BOOL success = [store removeEvent:event span:EKSpanThisEvent commit:YES error:&error];
+        EKSpan span = futureEvents ? EKSpanFutureEvents : EKSpanThisEvent;
+        BOOL success = [store removeEvent:event span:span commit:YES error:&error];
 
         if (!success || error != nil) {
             return EK_ERROR_FAILED;
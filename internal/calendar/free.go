@@ -0,0 +1,171 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Slot is a period without events
+type Slot struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Duration returns the length of the slot
+func (s Slot) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// WorkingHours is the part of each day FreeSlots looks at, as minutes
+// after midnight
+type WorkingHours struct {
+	Start    int
+	End      int
+	Weekends bool // include Saturdays and Sundays
+}
+
+// DefaultWorkingHours is 9:00 to 17:00 on weekdays
+var DefaultWorkingHours = WorkingHours{Start: 9 * 60, End: 17 * 60}
+
+// ParseWorkingHours parses a range of hours such as "9-17" or "8:30-16:30"
+func ParseWorkingHours(s string) (WorkingHours, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return WorkingHours{}, fmt.Errorf("invalid working hours %q: use e.g. 9-17", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return WorkingHours{}, fmt.Errorf("invalid working hours %q: %w", s, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return WorkingHours{}, fmt.Errorf("invalid working hours %q: %w", s, err)
+	}
+	if end <= start {
+		return WorkingHours{}, fmt.Errorf("invalid working hours %q: end must be after start", s)
+	}
+	return WorkingHours{Start: start, End: end}, nil
+}
+
+// parseClock parses "9", "09:30" or "17" into minutes after midnight
+func parseClock(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	hour, minute, _ := strings.Cut(s, ":")
+	h, err := strconv.Atoi(hour)
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid hour %q", s)
+	}
+	m := 0
+	if minute != "" {
+		if m, err = strconv.Atoi(minute); err != nil || m < 0 || m > 59 {
+			return 0, fmt.Errorf("invalid minute %q", s)
+		}
+	}
+	if h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// ParseWithin turns a period such as "today", "tomorrow", "this week",
+// "next week" or "3d" into the time range from now it covers
+func ParseWithin(s string, now time.Time) (start, end time.Time, err error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// Days since Monday
+	weekday := (int(today.Weekday()) + 6) % 7
+
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "today":
+		return now, today.AddDate(0, 0, 1), nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), today.AddDate(0, 0, 2), nil
+	case "this week", "week":
+		return now, today.AddDate(0, 0, 7-weekday), nil
+	case "next week":
+		monday := today.AddDate(0, 0, 7-weekday)
+		return monday, monday.AddDate(0, 0, 7), nil
+	}
+
+	days := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(s), "days"), "day"), "d")
+	if n, err := strconv.Atoi(strings.TrimSpace(days)); err == nil && n > 0 {
+		return now, today.AddDate(0, 0, n), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid period %q: use today, tomorrow, this week, next week or a number of days like 3d", s)
+}
+
+// slotStep is what free slots are aligned to, so proposals start at
+// times like 9:00 or 10:15 rather than 10:07
+const slotStep = 15 * time.Minute
+
+// FreeSlots returns the periods between start and end, within working
+// hours, that are at least duration long and have no events. All-day events
+// (holidays, birthdays) don't count as busy.
+func FreeSlots(events []Event, start, end time.Time, duration time.Duration, hours WorkingHours) []Slot {
+	var busy []Slot
+	for _, e := range events {
+		if e.AllDay || !e.EndTime.After(start) || !e.StartTime.Before(end) {
+			continue
+		}
+		busy = append(busy, Slot{Start: e.StartTime, End: e.EndTime})
+	}
+	sort.Slice(busy, func(i, j int) bool { return busy[i].Start.Before(busy[j].Start) })
+
+	var slots []Slot
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	for ; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !hours.Weekends && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+			continue
+		}
+		// Wall clock times, which stay put on days that change to or from
+		// daylight saving time
+		from := time.Date(day.Year(), day.Month(), day.Day(), hours.Start/60, hours.Start%60, 0, 0, day.Location())
+		to := time.Date(day.Year(), day.Month(), day.Day(), hours.End/60, hours.End%60, 0, 0, day.Location())
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+
+		for _, b := range busy {
+			if !b.End.After(from) || !b.Start.Before(to) {
+				continue
+			}
+			slots = appendSlot(slots, from, b.Start, duration)
+			if b.End.After(from) {
+				from = b.End
+			}
+		}
+		slots = appendSlot(slots, from, to, duration)
+	}
+	return slots
+}
+
+// appendSlot adds the free period from-to, aligned to slotStep, if it is
+// long enough
+func appendSlot(slots []Slot, from, to time.Time, duration time.Duration) []Slot {
+	if rounded := from.Truncate(slotStep); rounded.Before(from) {
+		from = rounded.Add(slotStep)
+	}
+	if to.Sub(from) < duration {
+		return slots
+	}
+	return append(slots, Slot{Start: from, End: to})
+}
+
+// FormatSlots lists slots one per line, e.g. "Tue, Mar 5  9:00 AM - 11:30 AM",
+// ending with the time zone they are in
+func FormatSlots(slots []Slot) string {
+	var b strings.Builder
+	for _, s := range slots {
+		fmt.Fprintf(&b, "- %s  %s - %s\n", s.Start.Format("Mon, Jan 2"), s.Start.Format("3:04 PM"), s.End.Format("3:04 PM"))
+	}
+	if len(slots) > 0 {
+		zone, _ := slots[0].Start.Zone()
+		fmt.Fprintf(&b, "(times in %s)\n", zone)
+	}
+	return b.String()
+}
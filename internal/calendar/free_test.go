@@ -0,0 +1,70 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFreeSlots(t *testing.T) {
+	// Monday 4 March 2024, from 10:07
+	now := time.Date(2024, 3, 4, 10, 7, 0, 0, time.Local)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 3, day, hour, minute, 0, 0, time.Local)
+	}
+
+	start, end, err := ParseWithin("this week", now)
+	if err != nil || !start.Equal(now) || !end.Equal(at(11, 0, 0)) {
+		t.Fatalf("ParseWithin(this week) = %v, %v, %v", start, end, err)
+	}
+	hours, err := ParseWorkingHours("9-17")
+	if err != nil || hours != DefaultWorkingHours {
+		t.Fatalf("ParseWorkingHours = %+v, %v", hours, err)
+	}
+
+	events := []Event{
+		{Title: "Standup", StartTime: at(4, 11, 0), EndTime: at(4, 11, 15)},
+		{Title: "Lunch", StartTime: at(4, 12, 0), EndTime: at(4, 13, 0)},
+		{Title: "Review", StartTime: at(4, 12, 30), EndTime: at(4, 16, 40)},
+		{Title: "Holiday", StartTime: at(5, 0, 0), EndTime: at(6, 0, 0), AllDay: true},
+		{Title: "Offsite", StartTime: at(6, 8, 0), EndTime: at(8, 18, 0)},
+	}
+	slots := FreeSlots(events, start, end, 30*time.Minute, hours)
+
+	want := []Slot{
+		{at(4, 10, 15), at(4, 11, 0)},
+		{at(4, 11, 15), at(4, 12, 0)},
+		{at(5, 9, 0), at(5, 17, 0)},
+	}
+	if len(slots) != len(want) {
+		t.Fatalf("got %d slots, want %d: %+v", len(slots), len(want), slots)
+	}
+	for i := range want {
+		if !slots[i].Start.Equal(want[i].Start) || !slots[i].End.Equal(want[i].End) {
+			t.Errorf("slot %d = %v - %v, want %v - %v", i, slots[i].Start, slots[i].End, want[i].Start, want[i].End)
+		}
+	}
+
+	if _, err := ParseWorkingHours("17-9"); err == nil {
+		t.Error("ParseWorkingHours accepted hours that end before they start")
+	}
+}
+
+func TestFreeSlotsDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	// Clocks go forward at 2:00 on Sunday 8 March 2026, so the day is 23
+	// hours long
+	start := time.Date(2026, 3, 8, 0, 0, 0, 0, loc)
+	hours := WorkingHours{Start: 9 * 60, End: 17 * 60, Weekends: true}
+	slots := FreeSlots(nil, start, start.AddDate(0, 0, 1), time.Hour, hours)
+
+	if len(slots) != 1 {
+		t.Fatalf("got %d slots, want 1: %+v", len(slots), slots)
+	}
+	want := Slot{time.Date(2026, 3, 8, 9, 0, 0, 0, loc), time.Date(2026, 3, 8, 17, 0, 0, 0, loc)}
+	if !slots[0].Start.Equal(want.Start) || !slots[0].End.Equal(want.End) {
+		t.Errorf("slot = %v - %v, want %v - %v", slots[0].Start, slots[0].End, want.Start, want.End)
+	}
+}
@@ -0,0 +1,313 @@
+package calendar
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"maily/internal/auth"
+	"maily/internal/graph"
+)
+
+// graphDateTime is the layout of Graph dateTimeTimeZone values
+const graphDateTime = "2006-01-02T15:04:05.9999999"
+
+// graphClient reads and writes Outlook calendars through Microsoft Graph
+type graphClient struct {
+	api *graph.Client
+}
+
+type graphCalendar struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"hexColor"`
+}
+
+type graphDateTimeZone struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type graphEvent struct {
+	ID       string            `json:"id,omitempty"`
+	ICalUID  string            `json:"iCalUId,omitempty"`
+	Subject  string            `json:"subject"`
+	Start    graphDateTimeZone `json:"start"`
+	End      graphDateTimeZone `json:"end"`
+	IsAllDay bool              `json:"isAllDay"`
+	Location struct {
+		DisplayName string `json:"displayName"`
+	} `json:"location"`
+	Body struct {
+		ContentType string `json:"contentType"`
+		Content     string `json:"content"`
+	} `json:"body"`
+	IsReminderOn               bool             `json:"isReminderOn"`
+	ReminderMinutesBeforeStart int              `json:"reminderMinutesBeforeStart"`
+	Recurrence                 *graphRecurrence `json:"recurrence,omitempty"`
+	Type                       string           `json:"type,omitempty"`
+	SeriesMasterID             string           `json:"seriesMasterId,omitempty"`
+	OriginalStart              string           `json:"originalStart,omitempty"`
+	OriginalStartTimeZone      string           `json:"originalStartTimeZone,omitempty"`
+	Attendees                  []graphAttendee  `json:"attendees"`
+	Organizer                  *graphRecipient  `json:"organizer,omitempty"`
+}
+
+type graphRecipient struct {
+	EmailAddress struct {
+		Address string `json:"address"`
+		Name    string `json:"name,omitempty"`
+	} `json:"emailAddress"`
+}
+
+type graphAttendee struct {
+	graphRecipient
+	Type   string `json:"type"`
+	Status *struct {
+		Response string `json:"response"`
+	} `json:"status,omitempty"`
+}
+
+// graphResponses maps Graph response statuses to PARTSTAT
+var graphResponses = map[string]string{
+	"accepted":            StatusAccepted,
+	"tentativelyAccepted": StatusTentative,
+	"declined":            StatusDeclined,
+}
+
+// NewGraphClient returns a calendar client for an Outlook account
+func NewGraphClient(creds *auth.Credentials) (Client, error) {
+	api, err := graph.New(creds)
+	if err != nil {
+		return nil, err
+	}
+	return NewGraphClientWithAPI(api), nil
+}
+
+// NewGraphClientWithAPI returns a calendar client that talks through an
+// existing Graph client, e.g. one pointed at a test server
+func NewGraphClientWithAPI(api *graph.Client) Client {
+	return &graphClient{api: api}
+}
+
+func (c *graphClient) ListCalendars() ([]Calendar, error) {
+	raw, err := graph.List[graphCalendar](c.api, "/me/calendars?$select=id,name,hexColor", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	calendars := make([]Calendar, len(raw))
+	for i, cal := range raw {
+		calendars[i] = Calendar{ID: cal.ID, Title: cal.Name, Color: cal.Color}
+	}
+	return calendars, nil
+}
+
+func (c *graphClient) ListEvents(start, end time.Time) ([]Event, error) {
+	calendars, err := c.ListCalendars()
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{
+		"startDateTime": {start.UTC().Format(time.RFC3339)},
+		"endDateTime":   {end.UTC().Format(time.RFC3339)},
+		"$top":          {"100"},
+	}
+
+	var events []Event
+	rules := make(map[string]string) // series master ID -> RRULE
+	for _, cal := range calendars {
+		path := "/me/calendars/" + url.PathEscape(cal.ID) + "/calendarView?" + query.Encode()
+		raw, err := graph.List[graphEvent](c.api, path, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range raw {
+			event := e.toEvent(cal.Title)
+			if e.SeriesMasterID != "" {
+				// calendarView only carries the pattern on the series master
+				rule, ok := rules[e.SeriesMasterID]
+				if !ok {
+					var master graphEvent
+					path := "/me/events/" + url.PathEscape(e.SeriesMasterID) + "?$select=recurrence"
+					if err := c.api.Get(path, &master); err == nil && master.Recurrence != nil {
+						rule = master.Recurrence.rule()
+					}
+					rules[e.SeriesMasterID] = rule
+				}
+				event.Recurrence = rule
+			}
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (e graphEvent) toEvent(calendar string) Event {
+	event := Event{
+		ID:        e.ID,
+		UID:       e.ICalUID,
+		Title:     e.Subject,
+		StartTime: parseGraphTime(e.Start),
+		EndTime:   parseGraphTime(e.End),
+		Location:  e.Location.DisplayName,
+		Notes:     e.Body.Content,
+		Calendar:  calendar,
+		AllDay:    e.IsAllDay,
+	}
+	if e.IsReminderOn {
+		event.AlarmMinutesBefore = e.ReminderMinutesBeforeStart
+	}
+	if loc, err := ResolveTimeZone(e.OriginalStartTimeZone); err == nil && !e.IsAllDay && loc != time.UTC {
+		event.TimeZone = loc.String()
+	}
+	if e.Organizer != nil && len(e.Attendees) > 0 {
+		event.Organizer = Attendee{Email: e.Organizer.EmailAddress.Address, Name: e.Organizer.EmailAddress.Name}
+	}
+	for _, a := range e.Attendees {
+		status := StatusNeedsAction
+		if a.Status != nil {
+			if s, ok := graphResponses[a.Status.Response]; ok {
+				status = s
+			}
+		}
+		event.Attendees = append(event.Attendees, Attendee{
+			Email:  a.EmailAddress.Address,
+			Name:   a.EmailAddress.Name,
+			Status: status,
+		})
+	}
+	if e.SeriesMasterID != "" {
+		event.OccurrenceDate = event.StartTime
+		if t, err := time.Parse(time.RFC3339, e.OriginalStart); err == nil {
+			event.OccurrenceDate = t.Local()
+		}
+	}
+	return event
+}
+
+// parseGraphTime parses a dateTimeTimeZone. Requests ask for UTC, but other
+// IANA zones are honoured if the server sends them.
+func parseGraphTime(dt graphDateTimeZone) time.Time {
+	loc := time.UTC
+	if dt.TimeZone != "" && dt.TimeZone != "UTC" {
+		if l, err := time.LoadLocation(dt.TimeZone); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(graphDateTime, dt.DateTime, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.Local()
+}
+
+// CreateEvent creates the event in event.Calendar (a calendar ID), or the
+// default calendar if it is empty
+func (c *graphClient) CreateEvent(event Event) (string, error) {
+	path := "/me/events"
+	if event.Calendar != "" {
+		path = "/me/calendars/" + url.PathEscape(event.Calendar) + "/events"
+	}
+
+	var created graphEvent
+	if err := c.api.Post(path, toGraphEvent(event), &created); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrFailed, err)
+	}
+	return created.ID, nil
+}
+
+// UpdateEvent patches the event; Graph keeps attendees, and patching an
+// occurrence turns it into an exception of its series
+func (c *graphClient) UpdateEvent(event Event) error {
+	err := c.api.Patch("/me/events/"+url.PathEscape(event.ID), toGraphEvent(event), nil)
+	if gerr, ok := err.(*graph.Error); ok && gerr.Status == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+func toGraphEvent(event Event) graphEvent {
+	body := graphEvent{
+		Subject:  event.Title,
+		Start:    graphDateTimeZone{DateTime: event.StartTime.UTC().Format(graphDateTime), TimeZone: "UTC"},
+		End:      graphDateTimeZone{DateTime: event.EndTime.UTC().Format(graphDateTime), TimeZone: "UTC"},
+		IsAllDay: event.AllDay,
+		// Graph sends the invitations itself; the organizer is the mailbox
+		Attendees: []graphAttendee{},
+	}
+	for _, a := range event.Attendees {
+		attendee := graphAttendee{Type: "required"}
+		attendee.EmailAddress.Address = a.Email
+		attendee.EmailAddress.Name = a.Name
+		body.Attendees = append(body.Attendees, attendee)
+	}
+	if loc := event.Zone(); event.TimeZone != "" && !event.AllDay {
+		body.Start = graphDateTimeZone{DateTime: event.StartTime.In(loc).Format(graphDateTime), TimeZone: loc.String()}
+		body.End = graphDateTimeZone{DateTime: event.EndTime.In(loc).Format(graphDateTime), TimeZone: loc.String()}
+	}
+	if event.AllDay {
+		// All-day events must start and end at midnight
+		body.Start.DateTime = event.StartTime.Format("2006-01-02") + "T00:00:00"
+		body.End.DateTime = event.EndTime.Format("2006-01-02") + "T00:00:00"
+		if body.End.DateTime <= body.Start.DateTime {
+			body.End.DateTime = event.StartTime.AddDate(0, 0, 1).Format("2006-01-02") + "T00:00:00"
+		}
+	}
+	body.Location.DisplayName = event.Location
+	body.Body.ContentType = "text"
+	body.Body.Content = event.Notes
+	if event.AlarmMinutesBefore > 0 {
+		body.IsReminderOn = true
+		body.ReminderMinutesBeforeStart = event.AlarmMinutesBefore
+	}
+	if event.Recurrence != "" && event.OccurrenceDate.IsZero() {
+		body.Recurrence, _ = newGraphRecurrence(event.Recurrence, event.StartTime)
+	}
+	return body
+}
+
+func (c *graphClient) DeleteEvent(id string) error {
+	err := c.api.Delete("/me/events/" + url.PathEscape(id))
+	if gerr, ok := err.(*graph.Error); ok && gerr.Status == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+// DeleteOccurrence deletes one occurrence directly. Deleting the following
+// ones ends the series master's recurrence range the day before.
+func (c *graphClient) DeleteOccurrence(id string, occurrence time.Time, span Span) error {
+	if span == SpanThisEvent {
+		return c.DeleteEvent(id)
+	}
+
+	var instance graphEvent
+	err := c.api.Get("/me/events/"+url.PathEscape(id)+"?$select=seriesMasterId", &instance)
+	if gerr, ok := err.(*graph.Error); ok && gerr.Status == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if instance.SeriesMasterID == "" {
+		return c.DeleteEvent(id)
+	}
+
+	var master graphEvent
+	masterPath := "/me/events/" + url.PathEscape(instance.SeriesMasterID)
+	if err := c.api.Get(masterPath+"?$select=recurrence", &master); err != nil {
+		return err
+	}
+	rec := master.Recurrence
+	if rec == nil || occurrence.Format("2006-01-02") <= rec.Range.StartDate {
+		return c.DeleteEvent(instance.SeriesMasterID)
+	}
+
+	rec.Range.Type = "endDate"
+	rec.Range.EndDate = occurrence.AddDate(0, 0, -1).Format("2006-01-02")
+	rec.Range.NumberOfOccurrences = 0
+	return c.api.Patch(masterPath, map[string]any{"recurrence": rec}, nil)
+}
@@ -0,0 +1,149 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// graphRecurrence is a Graph patternedRecurrence
+type graphRecurrence struct {
+	Pattern struct {
+		Type       string   `json:"type"`
+		Interval   int      `json:"interval"`
+		DaysOfWeek []string `json:"daysOfWeek,omitempty"`
+		DayOfMonth int      `json:"dayOfMonth,omitempty"`
+		Month      int      `json:"month,omitempty"`
+		Index      string   `json:"index,omitempty"`
+	} `json:"pattern"`
+	Range struct {
+		Type                string `json:"type"`
+		StartDate           string `json:"startDate"`
+		EndDate             string `json:"endDate,omitempty"`
+		NumberOfOccurrences int    `json:"numberOfOccurrences,omitempty"`
+	} `json:"range"`
+}
+
+// graphWeekdays maps rrule weekdays (Monday first) to Graph day names
+var graphWeekdays = []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+var graphIndexes = map[int]string{1: "first", 2: "second", 3: "third", 4: "fourth", -1: "last"}
+
+// newGraphRecurrence converts an RRULE value for a series starting at start.
+// Graph patterns are less expressive than RRULE: only the first BYMONTHDAY
+// and BYMONTH and a single weekday index are kept.
+func newGraphRecurrence(rule string, start time.Time) (*graphRecurrence, error) {
+	opt, err := rrule.StrToROptionInLocation(rule, time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &graphRecurrence{}
+	rec.Pattern.Interval = max(opt.Interval, 1)
+	for _, wd := range opt.Byweekday {
+		rec.Pattern.DaysOfWeek = append(rec.Pattern.DaysOfWeek, graphWeekdays[wd.Day()])
+		if wd.N() != 0 {
+			rec.Pattern.Index = graphIndexes[wd.N()]
+		}
+	}
+	dayOfMonth := start.Day()
+	if len(opt.Bymonthday) > 0 {
+		dayOfMonth = opt.Bymonthday[0]
+	}
+	month := int(start.Month())
+	if len(opt.Bymonth) > 0 {
+		month = opt.Bymonth[0]
+	}
+
+	switch opt.Freq {
+	case rrule.DAILY:
+		rec.Pattern.Type = "daily"
+	case rrule.WEEKLY:
+		rec.Pattern.Type = "weekly"
+		if len(rec.Pattern.DaysOfWeek) == 0 {
+			rec.Pattern.DaysOfWeek = []string{strings.ToLower(start.Weekday().String())}
+		}
+	case rrule.MONTHLY, rrule.YEARLY:
+		kind := "Monthly"
+		if opt.Freq == rrule.YEARLY {
+			kind = "Yearly"
+			rec.Pattern.Month = month
+		}
+		if rec.Pattern.Index != "" {
+			rec.Pattern.Type = "relative" + kind
+		} else {
+			rec.Pattern.Type = "absolute" + kind
+			rec.Pattern.DaysOfWeek = nil
+			rec.Pattern.DayOfMonth = dayOfMonth
+		}
+	default:
+		return nil, fmt.Errorf("unsupported recurrence frequency %v", opt.Freq)
+	}
+
+	rec.Range.StartDate = start.Format("2006-01-02")
+	switch {
+	case opt.Count > 0:
+		rec.Range.Type = "numbered"
+		rec.Range.NumberOfOccurrences = opt.Count
+	case !opt.Until.IsZero():
+		rec.Range.Type = "endDate"
+		rec.Range.EndDate = opt.Until.Local().Format("2006-01-02")
+	default:
+		rec.Range.Type = "noEnd"
+	}
+	return rec, nil
+}
+
+// rule converts the pattern back to an RRULE value
+func (rec *graphRecurrence) rule() string {
+	var parts []string
+	switch rec.Pattern.Type {
+	case "daily":
+		parts = append(parts, "FREQ=DAILY")
+	case "weekly":
+		parts = append(parts, "FREQ=WEEKLY")
+	case "absoluteMonthly", "relativeMonthly":
+		parts = append(parts, "FREQ=MONTHLY")
+	case "absoluteYearly", "relativeYearly":
+		parts = append(parts, "FREQ=YEARLY")
+	default:
+		return ""
+	}
+	if rec.Pattern.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", rec.Pattern.Interval))
+	}
+
+	switch rec.Range.Type {
+	case "numbered":
+		parts = append(parts, fmt.Sprintf("COUNT=%d", rec.Range.NumberOfOccurrences))
+	case "endDate":
+		if t, err := time.Parse("2006-01-02", rec.Range.EndDate); err == nil {
+			parts = append(parts, "UNTIL="+t.Format("20060102"))
+		}
+	}
+
+	if strings.HasSuffix(rec.Pattern.Type, "Yearly") && rec.Pattern.Month > 0 {
+		parts = append(parts, fmt.Sprintf("BYMONTH=%d", rec.Pattern.Month))
+	}
+	if strings.HasPrefix(rec.Pattern.Type, "absolute") && rec.Pattern.DayOfMonth > 0 {
+		parts = append(parts, fmt.Sprintf("BYMONTHDAY=%d", rec.Pattern.DayOfMonth))
+	}
+	if len(rec.Pattern.DaysOfWeek) > 0 && !strings.HasPrefix(rec.Pattern.Type, "absolute") {
+		prefix := ""
+		if strings.HasPrefix(rec.Pattern.Type, "relative") {
+			for n, index := range graphIndexes {
+				if index == rec.Pattern.Index {
+					prefix = fmt.Sprint(n)
+				}
+			}
+		}
+		days := make([]string, len(rec.Pattern.DaysOfWeek))
+		for i, day := range rec.Pattern.DaysOfWeek {
+			days[i] = prefix + strings.ToUpper(day[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
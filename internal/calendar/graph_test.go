@@ -0,0 +1,251 @@
+package calendar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"maily/internal/graph"
+)
+
+// fakeGraphCalendar serves the Graph calendar endpoints graphClient uses:
+// a "Work" calendar with a weekly series and a "Home" one on a second page
+type fakeGraphCalendar struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	events   map[string]map[string]any // id -> event
+	requests []string                  // "METHOD path" of every write
+	bodies   []map[string]any
+	masters  int // series masters fetched
+}
+
+func newFakeGraphCalendar(t *testing.T) *fakeGraphCalendar {
+	rec, err := newGraphRecurrence("FREQ=WEEKLY;BYDAY=MO", time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	occurrence := func(id, day string) map[string]any {
+		return map[string]any{
+			"id":             id,
+			"subject":        "Standup",
+			"start":          map[string]string{"dateTime": day + "T09:00:00.0000000", "timeZone": "UTC"},
+			"end":            map[string]string{"dateTime": day + "T09:15:00.0000000", "timeZone": "UTC"},
+			"type":           "occurrence",
+			"seriesMasterId": "series",
+			"originalStart":  day + "T09:00:00Z",
+		}
+	}
+	f := &fakeGraphCalendar{events: map[string]map[string]any{
+		"series": {"id": "series", "subject": "Standup", "recurrence": rec},
+		"occ-1":  occurrence("occ-1", "2024-03-04"),
+		"occ-2":  occurrence("occ-2", "2024-03-11"),
+		"review": {
+			"id":                         "review",
+			"iCalUId":                    "review-uid",
+			"subject":                    "Review",
+			"start":                      map[string]string{"dateTime": "2024-03-05T14:00:00.0000000", "timeZone": "UTC"},
+			"end":                        map[string]string{"dateTime": "2024-03-05T15:00:00.0000000", "timeZone": "UTC"},
+			"location":                   map[string]string{"displayName": "Room 1"},
+			"isReminderOn":               true,
+			"reminderMinutesBeforeStart": 10,
+			"organizer":                  map[string]any{"emailAddress": map[string]string{"address": "me@example.com"}},
+			"attendees": []map[string]any{{
+				"emailAddress": map[string]string{"address": "ann@example.com", "name": "Ann"},
+				"status":       map[string]string{"response": "tentativelyAccepted"},
+			}},
+		},
+	}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /me/calendars", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			json.NewEncoder(w).Encode(map[string]any{"value": []map[string]string{{"id": "home", "name": "Home"}}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"value":           []map[string]string{{"id": "work", "name": "Work", "hexColor": "#ff0000"}},
+			"@odata.nextLink": f.server.URL + "/me/calendars?page=2",
+		})
+	})
+	mux.HandleFunc("GET /me/calendars/{id}/calendarView", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		value := []map[string]any{}
+		if r.PathValue("id") == "work" {
+			for _, id := range []string{"occ-1", "occ-2", "review"} {
+				if e, ok := f.events[id]; ok {
+					value = append(value, e)
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"value": value})
+	})
+	mux.HandleFunc("GET /me/events/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		e, ok := f.events[r.PathValue("id")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if e["recurrence"] != nil {
+			f.masters++
+		}
+		json.NewEncoder(w).Encode(e)
+	})
+	write := func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+		f.bodies = append(f.bodies, body)
+
+		id := r.PathValue("id")
+		switch r.Method {
+		case http.MethodPost:
+			body["id"] = fmt.Sprintf("new-%d", len(f.events))
+			f.events[body["id"].(string)] = body
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(body)
+		case http.MethodPatch:
+			if _, ok := f.events[id]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(f.events[id])
+		case http.MethodDelete:
+			if _, ok := f.events[id]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(f.events, id)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+	mux.HandleFunc("POST /me/calendars/{calendar}/events", write)
+	mux.HandleFunc("PATCH /me/events/{id}", write)
+	mux.HandleFunc("DELETE /me/events/{id}", write)
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeGraphCalendar) client() Client {
+	return NewGraphClientWithAPI(graph.NewWithHTTP(f.server.URL, f.server.Client()))
+}
+
+func TestGraphListEvents(t *testing.T) {
+	f := newFakeGraphCalendar(t)
+	client := f.client()
+
+	calendars, err := client.ListCalendars()
+	if err != nil {
+		t.Fatalf("ListCalendars: %v", err)
+	}
+	if len(calendars) != 2 || calendars[0].Color != "#ff0000" || calendars[1].Title != "Home" {
+		t.Fatalf("calendars = %+v, want both pages", calendars)
+	}
+
+	events, err := client.ListEvents(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+
+	standup := events[1]
+	if standup.Recurrence != "FREQ=WEEKLY;BYDAY=MO" || standup.Calendar != "Work" {
+		t.Errorf("occurrence = %+v, want the series rule", standup)
+	}
+	if !standup.OccurrenceDate.Equal(time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("OccurrenceDate = %v", standup.OccurrenceDate)
+	}
+	if f.masters != 1 {
+		t.Errorf("series master fetched %d times, want once", f.masters)
+	}
+
+	review := events[2]
+	if review.UID != "review-uid" || review.Location != "Room 1" || review.AlarmMinutesBefore != 10 ||
+		!review.StartTime.Equal(time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)) {
+		t.Errorf("event = %+v", review)
+	}
+	if review.Organizer.Email != "me@example.com" || len(review.Attendees) != 1 || review.Attendees[0].Status != StatusTentative {
+		t.Errorf("organizer %+v, attendees %+v", review.Organizer, review.Attendees)
+	}
+}
+
+func TestGraphEventCRUD(t *testing.T) {
+	f := newFakeGraphCalendar(t)
+	client := f.client()
+
+	start := time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC)
+	id, err := client.CreateEvent(Event{
+		Title:              "Lunch",
+		StartTime:          start,
+		EndTime:            start.Add(time.Hour),
+		Calendar:           "work",
+		AlarmMinutesBefore: 5,
+		Attendees:          []Attendee{{Email: "bob@example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+	if id == "" || f.events[id] == nil {
+		t.Fatalf("created id %q not stored", id)
+	}
+	created := f.bodies[0]
+	if created["subject"] != "Lunch" || created["reminderMinutesBeforeStart"] != float64(5) {
+		t.Errorf("create body = %v", created)
+	}
+	if start := created["start"].(map[string]any); start["dateTime"] != "2024-03-06T10:00:00" || start["timeZone"] != "UTC" {
+		t.Errorf("start = %v", start)
+	}
+	if attendees := created["attendees"].([]any); len(attendees) != 1 {
+		t.Errorf("attendees = %v", attendees)
+	}
+
+	if err := client.UpdateEvent(Event{ID: id, Title: "Long lunch", StartTime: start, EndTime: start.Add(2 * time.Hour)}); err != nil {
+		t.Fatalf("UpdateEvent: %v", err)
+	}
+	if f.bodies[1]["subject"] != "Long lunch" {
+		t.Errorf("update body = %v", f.bodies[1])
+	}
+	if err := client.UpdateEvent(Event{ID: "missing", StartTime: start, EndTime: start}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateEvent of missing event = %v, want ErrNotFound", err)
+	}
+
+	if err := client.DeleteEvent(id); err != nil {
+		t.Fatalf("DeleteEvent: %v", err)
+	}
+	if err := client.DeleteEvent(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteEvent = %v, want ErrNotFound", err)
+	}
+
+	// Deleting the following occurrences ends the series the day before
+	if err := client.DeleteOccurrence("occ-2", time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC), SpanFutureEvents); err != nil {
+		t.Fatalf("DeleteOccurrence: %v", err)
+	}
+	last := len(f.requests) - 1
+	if f.requests[last] != "PATCH /me/events/series" {
+		t.Fatalf("requests = %v, want the series patched", f.requests)
+	}
+	rng := f.bodies[last]["recurrence"].(map[string]any)["range"].(map[string]any)
+	if rng["type"] != "endDate" || rng["endDate"] != "2024-03-10" {
+		t.Errorf("range = %v", rng)
+	}
+
+	want := []string{"POST /me/calendars/work/events", "PATCH /me/events/" + id, "PATCH /me/events/missing",
+		"DELETE /me/events/" + id, "DELETE /me/events/" + id, "PATCH /me/events/series"}
+	if strings.Join(f.requests, ",") != strings.Join(want, ",") {
+		t.Errorf("requests = %v, want %v", f.requests, want)
+	}
+}
@@ -0,0 +1,564 @@
+package calendar
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/teambition/rrule-go"
+)
+
+// icalProdID identifies maily in the iCalendar objects it writes
+const icalProdID = "-//maily//maily//EN"
+
+func fromVEvent(ve ical.Event) (Event, error) {
+	start, err := icalTime(ve.Props.Get(ical.PropDateTimeStart))
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid DTSTART: %w", err)
+	}
+
+	event := Event{StartTime: start}
+	event.AllDay = isDate(ve.Props.Get(ical.PropDateTimeStart))
+
+	switch {
+	case ve.Props.Get(ical.PropDateTimeEnd) != nil:
+		event.EndTime, err = icalTime(ve.Props.Get(ical.PropDateTimeEnd))
+	case ve.Props.Get(ical.PropDuration) != nil:
+		var dur time.Duration
+		dur, err = ve.Props.Get(ical.PropDuration).Duration()
+		event.EndTime = start.Add(dur)
+	case event.AllDay:
+		event.EndTime = start.AddDate(0, 0, 1)
+	default:
+		event.EndTime = start
+	}
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid DTEND: %w", err)
+	}
+	if !event.AllDay {
+		event.TimeZone = icalZone(ve.Props.Get(ical.PropDateTimeStart))
+		event.StartTime = event.StartTime.Local()
+		event.EndTime = event.EndTime.Local()
+	}
+
+	event.UID, _ = ve.Props.Text(ical.PropUID)
+	event.Title, _ = ve.Props.Text(ical.PropSummary)
+	event.Location, _ = ve.Props.Text(ical.PropLocation)
+	event.Notes, _ = ve.Props.Text(ical.PropDescription)
+
+	event.AlarmMinutesBefore = firstAlarmMinutes(ve.Component)
+
+	if prop := ve.Props.Get(ical.PropSequence); prop != nil {
+		event.Sequence, _ = prop.Int()
+	}
+	if prop := ve.Props.Get(ical.PropOrganizer); prop != nil {
+		event.Organizer = toAttendee(prop)
+	}
+	attendees := ve.Props.Values(ical.PropAttendee)
+	for i := range attendees {
+		event.Attendees = append(event.Attendees, toAttendee(&attendees[i]))
+	}
+
+	if prop := ve.Props.Get(ical.PropRecurrenceRule); prop != nil {
+		event.Recurrence = prop.Value
+	}
+	if prop := ve.Props.Get(ical.PropRecurrenceID); prop != nil {
+		event.OccurrenceDate, err = icalTime(prop)
+		if err != nil {
+			return Event{}, fmt.Errorf("invalid RECURRENCE-ID: %w", err)
+		}
+		event.OccurrenceDate = event.OccurrenceDate.Local()
+	}
+
+	return event, nil
+}
+
+// expandICS decodes an iCalendar object into the events overlapping start
+// and end, expanding recurring events into one Event per occurrence.
+// Occurrences that were changed on their own (RECURRENCE-ID) replace the
+// ones generated from the rule.
+func expandICS(data []byte, id, calendar string, start, end time.Time) ([]Event, error) {
+	cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	var masters, overrides []Event
+	var masterComps []*ical.Component
+	overridden := make(map[int64]bool)
+	for _, ve := range cal.Events() {
+		event, err := fromVEvent(ve)
+		if err != nil {
+			return nil, err
+		}
+		event.ID = id
+		event.Calendar = calendar
+		if event.OccurrenceDate.IsZero() {
+			masters = append(masters, event)
+			masterComps = append(masterComps, ve.Component)
+			continue
+		}
+		overridden[event.OccurrenceDate.Unix()] = true
+		if status, _ := ve.Props.Text(ical.PropStatus); status != "CANCELLED" {
+			overrides = append(overrides, event)
+		}
+	}
+
+	overlaps := func(e Event) bool {
+		return e.StartTime.Before(end) && e.EndTime.After(start)
+	}
+
+	var events []Event
+	for i, master := range masters {
+		// Occurrences follow the clock of DTSTART's own zone
+		dtstart, _ := icalTime(masterComps[i].Props.Get(ical.PropDateTimeStart))
+		set, err := recurrenceSet(masterComps[i], dtstart)
+		if err != nil {
+			return nil, err
+		}
+		if set == nil {
+			if overlaps(master) {
+				events = append(events, master)
+			}
+			continue
+		}
+
+		duration := master.EndTime.Sub(master.StartTime)
+		days := int(duration.Round(24*time.Hour) / (24 * time.Hour))
+		for _, occ := range set.Between(start.Add(-duration), end, true) {
+			if overridden[occ.Unix()] {
+				continue
+			}
+			if !master.AllDay {
+				occ = occ.Local()
+			}
+			event := master
+			event.StartTime = occ
+			event.EndTime = occ.Add(duration)
+			if master.AllDay {
+				event.EndTime = occ.AddDate(0, 0, days)
+			}
+			event.OccurrenceDate = occ
+			if overlaps(event) {
+				events = append(events, event)
+			}
+		}
+		for j := range overrides {
+			overrides[j].Recurrence = master.Recurrence
+		}
+	}
+
+	for _, event := range overrides {
+		if overlaps(event) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// recurrenceSet returns the occurrences of a VEVENT starting at dtstart, or
+// nil if it does not repeat. Unlike go-ical's RecurrenceSet it reads RDATE
+// and comma-separated EXDATE lists.
+func recurrenceSet(ve *ical.Component, dtstart time.Time) (*rrule.Set, error) {
+	opt, err := ve.Props.RecurrenceRule()
+	if err != nil {
+		return nil, fmt.Errorf("invalid RRULE: %w", err)
+	}
+	rdates := icalTimes(ve.Props[ical.PropRecurrenceDates])
+	if opt == nil && len(rdates) == 0 {
+		return nil, nil
+	}
+
+	set := &rrule.Set{}
+	if opt != nil {
+		opt.Dtstart = dtstart
+		rule, err := rrule.NewRRule(*opt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRULE: %w", err)
+		}
+		set.RRule(rule)
+	} else {
+		set.RDate(dtstart)
+	}
+	set.DTStart(dtstart)
+	for _, t := range rdates {
+		set.RDate(t)
+	}
+	for _, t := range icalTimes(ve.Props[ical.PropExceptionDates]) {
+		set.ExDate(t)
+	}
+	return set, nil
+}
+
+// icalTimes parses the values of RDATE or EXDATE properties, which may each
+// hold a comma-separated list. Values that are not times (periods) are skipped.
+func icalTimes(props []ical.Prop) []time.Time {
+	var times []time.Time
+	for _, prop := range props {
+		for _, value := range strings.Split(prop.Value, ",") {
+			single := prop
+			single.Value = value
+			if t, err := icalTime(&single); err == nil {
+				times = append(times, t)
+			}
+		}
+	}
+	return times
+}
+
+// icalTime parses a DATE or DATE-TIME. Floating times and TZIDs Go does not
+// know (e.g. Windows zone names) are read as local time.
+func icalTime(prop *ical.Prop) (time.Time, error) {
+	if prop == nil {
+		return time.Time{}, fmt.Errorf("missing")
+	}
+	t, err := prop.DateTime(time.Local)
+	if err != nil && prop.Params.Get(ical.PropTimezoneID) != "" {
+		fallback := *prop
+		fallback.Params = ical.Params{}
+		t, err = fallback.DateTime(time.Local)
+	}
+	return t, err
+}
+
+// icalZone returns the IANA zone of a DATE-TIME with a TZID Go knows,
+// empty for UTC, floating and local times
+func icalZone(prop *ical.Prop) string {
+	tzid := prop.Params.Get(ical.PropTimezoneID)
+	if tzid == "" {
+		return ""
+	}
+	loc, err := time.LoadLocation(tzid)
+	if err != nil || loc == time.UTC {
+		return ""
+	}
+	return loc.String()
+}
+
+// isDate reports whether a DTSTART-like property holds a DATE (all-day)
+func isDate(prop *ical.Prop) bool {
+	return prop.ValueType() == ical.ValueDate || len(prop.Value) == len("20060102")
+}
+
+// encodeICS writes event as a single-VEVENT iCalendar object with the given UID
+func encodeICS(event Event, uid string) ([]byte, error) {
+	ve := ical.NewEvent()
+	ve.Props.SetText(ical.PropUID, uid)
+	setVEvent(ve.Component, event)
+	if event.Sequence > 0 {
+		setSequence(ve.Component, event.Sequence)
+	}
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropProductID, icalProdID)
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Children = append(cal.Children, ve.Component)
+	return encodeCalendar(cal)
+}
+
+// updateICS applies event to the main VEVENT of an existing iCalendar object,
+// keeping every property maily does not model (categories, ...) and the
+// parameters of attendees that stay (ROLE, CUTYPE, ...). An occurrence of a recurring event is written as its own VEVENT with a
+// RECURRENCE-ID, leaving the rest of the series alone.
+func updateICS(data []byte, event Event) ([]byte, error) {
+	cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	master := masterVEvent(cal)
+	if master == nil {
+		return nil, fmt.Errorf("%w: no VEVENT in calendar object", ErrNotFound)
+	}
+
+	target := master
+	if !event.OccurrenceDate.IsZero() && master.Props.Get(ical.PropRecurrenceRule) != nil {
+		target = occurrenceVEvent(cal, master, event.OccurrenceDate)
+	}
+	setVEvent(target, event)
+	seq := 0
+	if prop := target.Props.Get(ical.PropSequence); prop != nil {
+		seq, _ = prop.Int()
+	}
+	setSequence(target, seq+1)
+	return encodeCalendar(cal)
+}
+
+func setSequence(ve *ical.Component, seq int) {
+	prop := ical.NewProp(ical.PropSequence)
+	prop.Value = fmt.Sprint(seq)
+	ve.Props.Set(prop)
+}
+
+// masterVEvent returns the VEVENT without RECURRENCE-ID, nil if there is none
+func masterVEvent(cal *ical.Calendar) *ical.Component {
+	for _, child := range cal.Children {
+		if child.Name == ical.CompEvent && child.Props.Get(ical.PropRecurrenceID) == nil {
+			return child
+		}
+	}
+	return nil
+}
+
+// isOccurrence reports whether ve overrides the occurrence starting at t
+func isOccurrence(ve *ical.Component, t time.Time) bool {
+	if ve.Name != ical.CompEvent || ve.Props.Get(ical.PropRecurrenceID) == nil {
+		return false
+	}
+	id, err := icalTime(ve.Props.Get(ical.PropRecurrenceID))
+	return err == nil && id.Equal(t)
+}
+
+// occurrenceVEvent returns the VEVENT overriding the occurrence of master
+// starting at occurrence, adding a copy of master for it if there is none
+func occurrenceVEvent(cal *ical.Calendar, master *ical.Component, occurrence time.Time) *ical.Component {
+	for _, child := range cal.Children {
+		if isOccurrence(child, occurrence) {
+			return child
+		}
+	}
+
+	ve := ical.NewComponent(ical.CompEvent)
+	for name, props := range master.Props {
+		switch name {
+		case ical.PropRecurrenceRule, ical.PropRecurrenceDates, ical.PropExceptionDates:
+			continue
+		}
+		ve.Props[name] = append([]ical.Prop(nil), props...)
+	}
+	ve.Children = append([]*ical.Component(nil), master.Children...)
+	setRecurrenceID(ve, ical.PropRecurrenceID, master, occurrence)
+	cal.Children = append(cal.Children, ve)
+	return ve
+}
+
+// setRecurrenceID sets a RECURRENCE-ID or EXDATE for the occurrence of
+// master starting at t, as a date if master is all-day
+func setRecurrenceID(ve *ical.Component, name string, master *ical.Component, t time.Time) {
+	prop := ical.NewProp(name)
+	if isDate(master.Props.Get(ical.PropDateTimeStart)) {
+		prop.SetDate(t)
+	} else {
+		prop.SetDateTime(t.UTC())
+	}
+	if name == ical.PropExceptionDates {
+		ve.Props.Add(prop)
+	} else {
+		ve.Props.Set(prop)
+	}
+}
+
+// deleteOccurrenceICS removes the occurrence starting at occurrence (and
+// with SpanFutureEvents all later ones) from an iCalendar object. It returns
+// nil data when nothing is left and the whole object should be deleted.
+func deleteOccurrenceICS(data []byte, occurrence time.Time, span Span) ([]byte, error) {
+	cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		return nil, err
+	}
+	master := masterVEvent(cal)
+	if master == nil {
+		return nil, fmt.Errorf("%w: no VEVENT in calendar object", ErrNotFound)
+	}
+	start, err := icalTime(master.Props.Get(ical.PropDateTimeStart))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DTSTART: %w", err)
+	}
+	rule := master.Props.Get(ical.PropRecurrenceRule)
+	if rule == nil || (span == SpanFutureEvents && !occurrence.After(start)) {
+		return nil, nil
+	}
+
+	children := cal.Children[:0]
+	for _, child := range cal.Children {
+		if child.Name == ical.CompEvent && child != master {
+			id, err := icalTime(child.Props.Get(ical.PropRecurrenceID))
+			if err == nil && (id.Equal(occurrence) || (span == SpanFutureEvents && id.After(occurrence))) {
+				continue
+			}
+		}
+		children = append(children, child)
+	}
+	cal.Children = children
+
+	if span == SpanFutureEvents {
+		rule.Value = recurrenceUntil(rule.Value, occurrence, isDate(master.Props.Get(ical.PropDateTimeStart)))
+		master.Props.Set(rule)
+	} else {
+		setRecurrenceID(master, ical.PropExceptionDates, master, occurrence)
+	}
+	return encodeCalendar(cal)
+}
+
+// setVEvent writes the fields Event models into a VEVENT
+func setVEvent(ve *ical.Component, event Event) {
+	ve.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+	ve.Props.SetText(ical.PropSummary, event.Title)
+
+	ve.Props.Del(ical.PropDuration)
+	if event.AllDay {
+		ve.Props.SetDate(ical.PropDateTimeStart, event.StartTime)
+		end := event.EndTime
+		if !end.After(event.StartTime) {
+			end = event.StartTime.AddDate(0, 0, 1)
+		}
+		ve.Props.SetDate(ical.PropDateTimeEnd, end)
+	} else if loc := event.Zone(); event.TimeZone != "" && loc != time.Local {
+		// A TZID keeps the event at the same clock time in its own zone
+		// across daylight saving changes. Its IANA name is resolved by
+		// clients and servers without a VTIMEZONE (RFC 7809).
+		ve.Props.SetDateTime(ical.PropDateTimeStart, event.StartTime.In(loc))
+		ve.Props.SetDateTime(ical.PropDateTimeEnd, event.EndTime.In(loc))
+	} else {
+		ve.Props.SetDateTime(ical.PropDateTimeStart, event.StartTime.UTC())
+		ve.Props.SetDateTime(ical.PropDateTimeEnd, event.EndTime.UTC())
+	}
+
+	setOptionalText(ve, ical.PropLocation, event.Location)
+	setOptionalText(ve, ical.PropDescription, event.Notes)
+
+	// RRULE is a recurrence value, not text, so it must not be escaped
+	if ve.Props.Get(ical.PropRecurrenceID) == nil {
+		if event.Recurrence == "" {
+			ve.Props.Del(ical.PropRecurrenceRule)
+		} else {
+			rule := ical.NewProp(ical.PropRecurrenceRule)
+			rule.Value = event.Recurrence
+			ve.Props.Set(rule)
+		}
+	}
+
+	setAttendees(ve, event)
+
+	// Only touch alarms when the reminder changed, so alarms maily does not
+	// model (absolute, multiple) survive an edit
+	if firstAlarmMinutes(ve) == event.AlarmMinutesBefore {
+		return
+	}
+	children := ve.Children[:0]
+	for _, child := range ve.Children {
+		if child.Name != ical.CompAlarm {
+			children = append(children, child)
+		}
+	}
+	ve.Children = children
+
+	if event.AlarmMinutesBefore > 0 {
+		alarm := ical.NewComponent(ical.CompAlarm)
+		alarm.Props.SetText(ical.PropAction, "DISPLAY")
+		alarm.Props.SetText(ical.PropDescription, event.Title)
+		trigger := ical.NewProp(ical.PropTrigger)
+		trigger.SetDuration(-time.Duration(event.AlarmMinutesBefore) * time.Minute)
+		alarm.Props.Set(trigger)
+		ve.Children = append(ve.Children, alarm)
+	}
+}
+
+// setAttendees writes ORGANIZER and ATTENDEE. Events without attendees
+// are not meetings and have no organizer either. Attendees already in ve
+// keep the parameters maily does not model.
+func setAttendees(ve *ical.Component, event Event) {
+	existing := make(map[string]ical.Params)
+	for _, name := range []string{ical.PropOrganizer, ical.PropAttendee} {
+		for _, prop := range ve.Props.Values(name) {
+			existing[name+" "+strings.ToLower(toAttendee(&prop).Email)] = prop.Params
+		}
+	}
+	withParams := func(name string, a Attendee) *ical.Prop {
+		prop := attendeeProp(name, a)
+		for param, values := range existing[name+" "+strings.ToLower(a.Email)] {
+			if prop.Params.Get(param) == "" {
+				prop.Params[param] = values
+			}
+		}
+		return prop
+	}
+
+	ve.Props.Del(ical.PropAttendee)
+	if len(event.Attendees) == 0 {
+		ve.Props.Del(ical.PropOrganizer)
+		return
+	}
+	if event.Organizer.Email != "" {
+		ve.Props.Set(withParams(ical.PropOrganizer, event.Organizer))
+	}
+	for _, a := range event.Attendees {
+		prop := withParams(ical.PropAttendee, a)
+		status := a.Status
+		if status == "" {
+			status = StatusNeedsAction
+		}
+		prop.Params.Set(ical.ParamParticipationStatus, status)
+		if status == StatusNeedsAction {
+			prop.Params.Set(ical.ParamRSVP, "TRUE")
+		}
+		ve.Props.Add(prop)
+	}
+}
+
+func attendeeProp(name string, a Attendee) *ical.Prop {
+	prop := ical.NewProp(name)
+	prop.Value = "mailto:" + a.Email
+	if a.Name != "" {
+		prop.Params.Set(ical.ParamCommonName, a.Name)
+	}
+	return prop
+}
+
+func setOptionalText(comp *ical.Component, name, value string) {
+	if value == "" {
+		comp.Props.Del(name)
+		return
+	}
+	comp.Props.SetText(name, value)
+}
+
+// firstAlarmMinutes returns the minutes before the event of its first
+// relative alarm, 0 if none
+func firstAlarmMinutes(ve *ical.Component) int {
+	for _, child := range ve.Children {
+		if child.Name != ical.CompAlarm {
+			continue
+		}
+		if trigger := child.Props.Get(ical.PropTrigger); trigger != nil {
+			if dur, err := trigger.Duration(); err == nil && dur <= 0 {
+				return int(-dur / time.Minute)
+			}
+		}
+	}
+	return 0
+}
+
+func encodeCalendar(cal *ical.Calendar) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewUID returns a random UID for a new event
+func NewUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return strings.ToUpper(hex.EncodeToString(b)) + "@maily"
+}
+
+// eventUID returns the UID to store a new event under and the file name
+// for it. UIDs from elsewhere (invitations) may contain characters that are
+// unsafe in paths, so only maily's own UIDs double as file names.
+func eventUID(event Event) (uid, name string) {
+	uid = event.UID
+	if uid == "" {
+		uid = NewUID()
+	}
+	name = uid
+	if !strings.HasSuffix(name, "@maily") {
+		name = NewUID()
+	}
+	return uid, strings.TrimSuffix(name, "@maily") + ".ics"
+}
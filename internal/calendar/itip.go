@@ -0,0 +1,282 @@
+package calendar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+// iTIP methods (RFC 5546) maily handles
+const (
+	MethodRequest = "REQUEST"
+	MethodReply   = "REPLY"
+	MethodCancel  = "CANCEL"
+)
+
+// Participation statuses (PARTSTAT) of an attendee
+const (
+	StatusNeedsAction = "NEEDS-ACTION"
+	StatusAccepted    = "ACCEPTED"
+	StatusTentative   = "TENTATIVE"
+	StatusDeclined    = "DECLINED"
+)
+
+// Attendee is a participant of an event
+type Attendee struct {
+	Email  string
+	Name   string
+	Status string // PARTSTAT, e.g. StatusAccepted
+}
+
+// String returns the attendee as "Name <email>", or just the email
+func (a Attendee) String() string {
+	if a.Name == "" {
+		return a.Email
+	}
+	return a.Name + " <" + a.Email + ">"
+}
+
+// Invitation is an iTIP message for one event, as sent by email in a
+// text/calendar part
+type Invitation struct {
+	Method string // MethodRequest, MethodCancel, ...
+	Event  Event  // Event.UID identifies the event, Event.OccurrenceDate a single occurrence
+
+	cal    *ical.Calendar
+	vevent *ical.Component
+}
+
+// ParseInvitation decodes a text/calendar part. Invitations for a whole
+// series are described by the series' main VEVENT.
+func ParseInvitation(data []byte) (*Invitation, error) {
+	cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("invalid invitation: %w", err)
+	}
+
+	ve := masterVEvent(cal)
+	if ve == nil {
+		for _, child := range cal.Children {
+			if child.Name == ical.CompEvent {
+				ve = child
+				break
+			}
+		}
+	}
+	if ve == nil {
+		return nil, errors.New("invalid invitation: no VEVENT")
+	}
+
+	inv := &Invitation{cal: cal, vevent: ve}
+	inv.Method, _ = cal.Props.Text(ical.PropMethod)
+	inv.Method = strings.ToUpper(inv.Method)
+	if inv.Method == "" {
+		inv.Method = MethodRequest
+	}
+
+	inv.Event, err = fromVEvent(ical.Event{Component: ve})
+	if err != nil {
+		return nil, fmt.Errorf("invalid invitation: %w", err)
+	}
+	if inv.Event.UID == "" {
+		return nil, errors.New("invalid invitation: no UID")
+	}
+	return inv, nil
+}
+
+func toAttendee(prop *ical.Prop) Attendee {
+	email := prop.Value
+	if strings.HasPrefix(strings.ToLower(email), "mailto:") {
+		email = email[len("mailto:"):]
+	}
+	status := strings.ToUpper(prop.Params.Get(ical.ParamParticipationStatus))
+	if status == "" {
+		status = StatusNeedsAction
+	}
+	return Attendee{
+		Email:  email,
+		Name:   prop.Params.Get(ical.ParamCommonName),
+		Status: status,
+	}
+}
+
+// Attendee returns the attendee with the given email address
+func (e Event) Attendee(email string) (Attendee, bool) {
+	for _, a := range e.Attendees {
+		if strings.EqualFold(a.Email, email) {
+			return a, true
+		}
+	}
+	return Attendee{}, false
+}
+
+// Reply builds the METHOD:REPLY answer of attendee email with the given
+// status, to be sent to the organizer
+func (inv *Invitation) Reply(email, status string) ([]byte, error) {
+	ve := ical.NewComponent(ical.CompEvent)
+	for _, name := range []string{
+		ical.PropUID, ical.PropSequence, ical.PropDateTimeStart, ical.PropDateTimeEnd,
+		ical.PropDuration, ical.PropSummary, ical.PropOrganizer, ical.PropRecurrenceID,
+	} {
+		if props := inv.vevent.Props.Values(name); len(props) > 0 {
+			ve.Props[name] = append([]ical.Prop(nil), props...)
+		}
+	}
+	ve.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+
+	attendee := ical.NewProp(ical.PropAttendee)
+	attendee.Value = "mailto:" + email
+	if a, ok := inv.Event.Attendee(email); ok && a.Name != "" {
+		attendee.Params.Set(ical.ParamCommonName, a.Name)
+	}
+	attendee.Params.Set(ical.ParamParticipationStatus, status)
+	ve.Props.Set(attendee)
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropProductID, icalProdID)
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropMethod, MethodReply)
+	// Times with a TZID need their VTIMEZONE
+	for _, child := range inv.cal.Children {
+		if child.Name == ical.CompTimezone {
+			cal.Children = append(cal.Children, child)
+		}
+	}
+	cal.Children = append(cal.Children, ve)
+	return encodeCalendar(cal)
+}
+
+// NewRequest builds the METHOD:REQUEST message inviting event's attendees,
+// for a new event or an update. An occurrence of a recurring event
+// (OccurrenceDate set) is sent on its own.
+func NewRequest(event Event) ([]byte, error) {
+	return newITIP(MethodRequest, event, SpanThisEvent)
+}
+
+// NewCancel builds the METHOD:CANCEL message telling event's attendees it
+// was deleted; for an occurrence, span selects whether later ones are too
+func NewCancel(event Event, span Span) ([]byte, error) {
+	return newITIP(MethodCancel, event, span)
+}
+
+func newITIP(method string, event Event, span Span) ([]byte, error) {
+	if event.UID == "" {
+		return nil, errors.New("event has no UID to send")
+	}
+
+	ve := ical.NewComponent(ical.CompEvent)
+	ve.Props.SetText(ical.PropUID, event.UID)
+	if !event.OccurrenceDate.IsZero() {
+		prop := ical.NewProp(ical.PropRecurrenceID)
+		if event.AllDay {
+			prop.SetDate(event.OccurrenceDate)
+		} else {
+			prop.SetDateTime(event.OccurrenceDate.UTC())
+		}
+		if method == MethodCancel && span == SpanFutureEvents {
+			prop.Params.Set("RANGE", "THISANDFUTURE")
+		}
+		ve.Props.Set(prop)
+	}
+
+	// Reminders are the attendees' own business
+	event.AlarmMinutesBefore = 0
+	setVEvent(ve, event)
+	setSequence(ve, event.Sequence)
+	if method == MethodCancel {
+		ve.Props.SetText(ical.PropStatus, "CANCELLED")
+	}
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropProductID, icalProdID)
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropMethod, method)
+	cal.Children = append(cal.Children, ve)
+	return encodeCalendar(cal)
+}
+
+// SendsInvitations reports whether the calendar server emails attendees
+// itself when events change (Microsoft Graph does), so maily must not
+func SendsInvitations(client Client) bool {
+	_, ok := client.(*graphClient)
+	return ok
+}
+
+// findInvitationEvent looks for the event an invitation is about in client,
+// by UID or else by title and start time
+func findInvitationEvent(client Client, inv *Invitation) (Event, error) {
+	start := inv.Event.StartTime
+	if !inv.Event.OccurrenceDate.IsZero() {
+		start = inv.Event.OccurrenceDate
+	}
+	events, err := client.ListEvents(start.AddDate(0, 0, -31), start.AddDate(0, 0, 31))
+	if err != nil {
+		return Event{}, err
+	}
+
+	var fallback *Event
+	for i, e := range events {
+		if !inv.Event.OccurrenceDate.IsZero() && !e.OccurrenceDate.Equal(inv.Event.OccurrenceDate) {
+			continue
+		}
+		if e.UID != "" && e.UID == inv.Event.UID {
+			return e, nil
+		}
+		if fallback == nil && e.Title == inv.Event.Title && e.StartTime.Equal(inv.Event.StartTime) {
+			fallback = &events[i]
+		}
+	}
+	if fallback != nil {
+		return *fallback, nil
+	}
+	return Event{}, ErrNotFound
+}
+
+// SaveInvitation adds the invited event to the calendar, or updates it if
+// it is already there, and returns its ID
+func SaveInvitation(client Client, inv *Invitation) (string, error) {
+	event := inv.Event
+	existing, err := findInvitationEvent(client, inv)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		// An update to a single occurrence of a series we don't have
+		event.OccurrenceDate = time.Time{}
+		return client.CreateEvent(event)
+	case err != nil:
+		return "", err
+	}
+
+	event.ID = existing.ID
+	event.AlarmMinutesBefore = existing.AlarmMinutesBefore
+	if event.OccurrenceDate.IsZero() && (existing.IsRecurring() || event.IsRecurring()) {
+		// UpdateEvent on a listed occurrence would only change that
+		// occurrence, so replace the whole series (in the default calendar)
+		if err := client.DeleteEvent(existing.ID); err != nil {
+			return "", err
+		}
+		event.ID = ""
+		return client.CreateEvent(event)
+	}
+	if err := client.UpdateEvent(event); err != nil {
+		return "", err
+	}
+	return event.ID, nil
+}
+
+// CancelInvitation removes the event (or the single occurrence) an
+// invitation is about from the calendar. It returns ErrNotFound if the
+// event is not in the calendar.
+func CancelInvitation(client Client, inv *Invitation) error {
+	existing, err := findInvitationEvent(client, inv)
+	if err != nil {
+		return err
+	}
+	if !inv.Event.OccurrenceDate.IsZero() && existing.IsRecurring() {
+		return client.DeleteOccurrence(existing.ID, inv.Event.OccurrenceDate, SpanThisEvent)
+	}
+	return client.DeleteEvent(existing.ID)
+}
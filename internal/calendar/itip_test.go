@@ -0,0 +1,145 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testInvitation = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Example//Example//EN\r\n" +
+	"METHOD:REQUEST\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:review-42@example.com\r\n" +
+	"SEQUENCE:1\r\n" +
+	"DTSTAMP:20240301T090000Z\r\n" +
+	"DTSTART:20240305T140000Z\r\n" +
+	"DTEND:20240305T150000Z\r\n" +
+	"SUMMARY:Design review\r\n" +
+	"LOCATION:Room 3\r\n" +
+	"ORGANIZER;CN=Ann Lee:mailto:ann@example.com\r\n" +
+	"ATTENDEE;CN=Bob;PARTSTAT=ACCEPTED:mailto:bob@example.com\r\n" +
+	"ATTENDEE;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:me@example.com\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestInvitation(t *testing.T) {
+	inv, err := ParseInvitation([]byte(testInvitation))
+	if err != nil {
+		t.Fatalf("ParseInvitation: %v", err)
+	}
+	if inv.Method != MethodRequest || inv.Event.UID != "review-42@example.com" || inv.Event.Title != "Design review" || inv.Event.Sequence != 1 {
+		t.Fatalf("unexpected invitation: %+v", inv)
+	}
+	if inv.Event.Organizer.String() != "Ann Lee <ann@example.com>" {
+		t.Errorf("organizer = %q", inv.Event.Organizer)
+	}
+	if len(inv.Event.Attendees) != 2 || inv.Event.Attendees[0].Status != StatusAccepted || inv.Event.Attendees[1].Email != "me@example.com" {
+		t.Errorf("attendees = %+v", inv.Event.Attendees)
+	}
+
+	reply, err := inv.Reply("me@example.com", StatusTentative)
+	if err != nil {
+		t.Fatalf("Reply: %v", err)
+	}
+	for _, want := range []string{"METHOD:REPLY", "UID:review-42@example.com", "SEQUENCE:1", "ATTENDEE;PARTSTAT=TENTATIVE:mailto:me@example.com", "ORGANIZER;CN=Ann Lee:mailto:ann@example.com"} {
+		if !strings.Contains(string(reply), want) {
+			t.Errorf("reply lacks %q:\n%s", want, reply)
+		}
+	}
+	if strings.Contains(string(reply), "bob@example.com") {
+		t.Errorf("reply includes other attendees:\n%s", reply)
+	}
+
+	client, err := NewVdirClient(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := func() []Event {
+		t.Helper()
+		events, err := client.ListEvents(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("ListEvents: %v", err)
+		}
+		return events
+	}
+
+	if _, err := SaveInvitation(client, inv); err != nil {
+		t.Fatalf("SaveInvitation: %v", err)
+	}
+	// An updated invitation changes the event instead of adding another
+	inv.Event.Location = "Room 4"
+	if _, err := SaveInvitation(client, inv); err != nil {
+		t.Fatalf("SaveInvitation (update): %v", err)
+	}
+	events := list()
+	if len(events) != 1 || events[0].UID != "review-42@example.com" || events[0].Location != "Room 4" {
+		t.Fatalf("after saving invitation: %+v", events)
+	}
+
+	if err := CancelInvitation(client, inv); err != nil {
+		t.Fatalf("CancelInvitation: %v", err)
+	}
+	if events := list(); len(events) != 0 {
+		t.Fatalf("after cancelling: %+v", events)
+	}
+	if err := CancelInvitation(client, inv); err != ErrNotFound {
+		t.Errorf("CancelInvitation of a missing event = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMeetingRequest(t *testing.T) {
+	client, err := NewVdirClient(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)
+	event := Event{
+		UID:       NewUID(),
+		Title:     "Planning",
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+		Organizer: Attendee{Email: "me@example.com"},
+		Attendees: []Attendee{{Email: "ann@example.com", Name: "Ann Lee"}, {Email: "bob@example.com"}},
+	}
+	if _, err := client.CreateEvent(event); err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+	events, err := client.ListEvents(start.AddDate(0, 0, -1), start.AddDate(0, 0, 1))
+	if err != nil || len(events) != 1 {
+		t.Fatalf("ListEvents = %+v, %v", events, err)
+	}
+	saved := events[0]
+	if saved.UID != event.UID || saved.Organizer.Email != "me@example.com" || len(saved.Attendees) != 2 || saved.Attendees[0].Status != StatusNeedsAction {
+		t.Fatalf("attendees not stored: %+v", saved)
+	}
+
+	// What the attendees receive parses back into the same meeting
+	request, err := NewRequest(saved)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	inv, err := ParseInvitation(request)
+	if err != nil {
+		t.Fatalf("ParseInvitation(request): %v", err)
+	}
+	if inv.Method != MethodRequest || inv.Event.UID != event.UID || inv.Event.Organizer.Email != "me@example.com" || len(inv.Event.Attendees) != 2 || inv.Event.Attendees[0].Name != "Ann Lee" {
+		t.Fatalf("request = %+v", inv)
+	}
+	if strings.Contains(string(request), "VALARM") {
+		t.Errorf("request includes our reminder:\n%s", request)
+	}
+
+	saved.Sequence++
+	cancel, err := NewCancel(saved, SpanThisEvent)
+	if err != nil {
+		t.Fatalf("NewCancel: %v", err)
+	}
+	for _, want := range []string{"METHOD:CANCEL", "STATUS:CANCELLED", "SEQUENCE:1"} {
+		if !strings.Contains(string(cancel), want) {
+			t.Errorf("cancel lacks %q:\n%s", want, cancel)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// Span selects which occurrences of a recurring event DeleteOccurrence removes
+type Span int
+
+const (
+	SpanThisEvent    Span = iota // Only the given occurrence
+	SpanFutureEvents             // The given occurrence and all later ones
+)
+
+// IsRecurring reports whether the event is one occurrence of a series
+func (e Event) IsRecurring() bool {
+	return e.Recurrence != ""
+}
+
+// ParseRecurrence validates an RRULE value such as "FREQ=WEEKLY;BYDAY=MO"
+// and returns it without any "RRULE:" prefix. An empty rule is valid and
+// means the event does not repeat.
+func ParseRecurrence(rule string) (string, error) {
+	rule = strings.ToUpper(strings.TrimSpace(rule))
+	rule = strings.TrimPrefix(rule, "RRULE:")
+	if rule == "" {
+		return "", nil
+	}
+	if _, err := rrule.StrToROption(rule); err != nil {
+		return "", fmt.Errorf("invalid recurrence %q: %w", rule, err)
+	}
+	return rule, nil
+}
+
+var recurrenceUnits = map[rrule.Frequency]string{
+	rrule.DAILY:   "day",
+	rrule.WEEKLY:  "week",
+	rrule.MONTHLY: "month",
+	rrule.YEARLY:  "year",
+}
+
+var recurrenceWeekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// DescribeRecurrence returns a short description of an RRULE value, e.g.
+// "Weekly on Mon, Wed" or "Every 2 days, 10 times"
+func DescribeRecurrence(rule string) string {
+	opt, err := rrule.StrToROptionInLocation(rule, time.Local)
+	if err != nil {
+		return rule
+	}
+	unit, ok := recurrenceUnits[opt.Freq]
+	if !ok {
+		return rule
+	}
+
+	var desc string
+	switch {
+	case opt.Interval > 1:
+		desc = fmt.Sprintf("Every %d %ss", opt.Interval, unit)
+	case opt.Freq == rrule.DAILY:
+		desc = "Daily"
+	default:
+		desc = strings.ToUpper(unit[:1]) + unit[1:] + "ly"
+	}
+
+	if len(opt.Byweekday) > 0 {
+		days := make([]string, len(opt.Byweekday))
+		for i, wd := range opt.Byweekday {
+			days[i] = recurrenceWeekdays[wd.Day()]
+			switch n := wd.N(); {
+			case n == -1:
+				days[i] = "last " + days[i]
+			case n > 0:
+				days[i] = ordinal(n) + " " + days[i]
+			}
+		}
+		if opt.Freq == rrule.WEEKLY && opt.Interval <= 1 && strings.Join(days, ",") == "Mon,Tue,Wed,Thu,Fri" {
+			desc = "Every weekday"
+		} else {
+			desc += " on " + strings.Join(days, ", ")
+		}
+	}
+	if len(opt.Bymonthday) > 0 {
+		days := make([]string, len(opt.Bymonthday))
+		for i, d := range opt.Bymonthday {
+			days[i] = ordinal(d)
+		}
+		desc += " on the " + strings.Join(days, ", ")
+	}
+
+	switch {
+	case opt.Count > 0:
+		desc += fmt.Sprintf(", %d times", opt.Count)
+	case !opt.Until.IsZero():
+		desc += ", until " + opt.Until.Local().Format("Jan 2, 2006")
+	}
+	return desc
+}
+
+func ordinal(n int) string {
+	if n < 0 {
+		return "last"
+	}
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// recurrenceUntil returns rule changed to end just before occurrence, for
+// deleting it and all later occurrences
+func recurrenceUntil(rule string, occurrence time.Time, allDay bool) string {
+	var parts []string
+	for _, part := range strings.Split(rule, ";") {
+		if !strings.HasPrefix(part, "COUNT=") && !strings.HasPrefix(part, "UNTIL=") {
+			parts = append(parts, part)
+		}
+	}
+	if allDay {
+		parts = append(parts, "UNTIL="+occurrence.AddDate(0, 0, -1).Format("20060102"))
+	} else {
+		parts = append(parts, "UNTIL="+occurrence.Add(-time.Second).UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
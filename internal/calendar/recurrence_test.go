@@ -0,0 +1,122 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVdirRecurringEvents(t *testing.T) {
+	client, err := NewVdirClient(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mondays at 10:00, starting Monday 4 March 2024
+	first := time.Date(2024, 3, 4, 10, 0, 0, 0, time.Local)
+	id, err := client.CreateEvent(Event{
+		Title:      "Team standup",
+		StartTime:  first,
+		EndTime:    first.Add(30 * time.Minute),
+		Recurrence: "FREQ=WEEKLY;BYDAY=MO",
+	})
+	if err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+
+	month := func() []Event {
+		t.Helper()
+		events, err := client.ListEvents(time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local))
+		if err != nil {
+			t.Fatalf("ListEvents: %v", err)
+		}
+		return events
+	}
+
+	events := month()
+	if len(events) != 4 {
+		t.Fatalf("got %d occurrences in March, want 4: %+v", len(events), events)
+	}
+	for i, e := range events {
+		want := first.AddDate(0, 0, 7*i)
+		if e.ID != id || !e.StartTime.Equal(want) || !e.OccurrenceDate.Equal(want) || e.Recurrence != "FREQ=WEEKLY;BYDAY=MO" {
+			t.Fatalf("occurrence %d = %+v, want start %v", i, e, want)
+		}
+	}
+
+	// Moving one occurrence leaves the others alone
+	moved := events[1]
+	moved.Title = "Standup (moved)"
+	moved.StartTime = moved.StartTime.Add(2 * time.Hour)
+	moved.EndTime = moved.EndTime.Add(2 * time.Hour)
+	if err := client.UpdateEvent(moved); err != nil {
+		t.Fatalf("UpdateEvent: %v", err)
+	}
+	events = month()
+	if len(events) != 4 || events[1].Title != "Standup (moved)" || events[1].StartTime.Hour() != 12 || events[2].Title != "Team standup" {
+		t.Fatalf("after moving one occurrence: %+v", events)
+	}
+
+	if err := client.DeleteOccurrence(id, events[1].OccurrenceDate, SpanThisEvent); err != nil {
+		t.Fatalf("DeleteOccurrence(this): %v", err)
+	}
+	if events = month(); len(events) != 3 || !events[1].StartTime.Equal(first.AddDate(0, 0, 14)) {
+		t.Fatalf("after deleting one occurrence: %+v", events)
+	}
+
+	if err := client.DeleteOccurrence(id, first.AddDate(0, 0, 14), SpanFutureEvents); err != nil {
+		t.Fatalf("DeleteOccurrence(future): %v", err)
+	}
+	if events = month(); len(events) != 1 || !events[0].StartTime.Equal(first) {
+		t.Fatalf("after deleting future occurrences: %+v", events)
+	}
+
+	// Deleting from the first occurrence on removes the whole event
+	if err := client.DeleteOccurrence(id, first, SpanFutureEvents); err != nil {
+		t.Fatalf("DeleteOccurrence(all): %v", err)
+	}
+	if err := client.DeleteEvent(id); err != ErrNotFound {
+		t.Fatalf("event still exists after deleting all occurrences: %v", err)
+	}
+}
+
+func TestDescribeRecurrence(t *testing.T) {
+	tests := map[string]string{
+		"FREQ=DAILY":                         "Daily",
+		"FREQ=WEEKLY;BYDAY=MO":               "Weekly on Mon",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH": "Every 2 weeks on Tue, Thu",
+		"FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR":   "Every weekday",
+		"FREQ=MONTHLY;BYMONTHDAY=15;COUNT=6": "Monthly on the 15th, 6 times",
+		"FREQ=MONTHLY;BYDAY=-1FR":            "Monthly on last Fri",
+	}
+	for rule, want := range tests {
+		if got := DescribeRecurrence(rule); got != want {
+			t.Errorf("DescribeRecurrence(%q) = %q, want %q", rule, got, want)
+		}
+	}
+
+	if rule, err := ParseRecurrence("rrule:freq=weekly;byday=mo"); err != nil || rule != "FREQ=WEEKLY;BYDAY=MO" {
+		t.Errorf("ParseRecurrence = %q, %v", rule, err)
+	}
+	if _, err := ParseRecurrence("every monday"); err == nil {
+		t.Error("ParseRecurrence accepted an invalid rule")
+	}
+}
+
+func TestGraphRecurrenceRoundTrip(t *testing.T) {
+	start := time.Date(2024, 3, 4, 10, 0, 0, 0, time.Local)
+	for _, rule := range []string{
+		"FREQ=WEEKLY;BYDAY=MO",
+		"FREQ=DAILY;INTERVAL=2;COUNT=5",
+		"FREQ=MONTHLY;BYMONTHDAY=15",
+		"FREQ=MONTHLY;BYDAY=2TU",
+		"FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=4",
+	} {
+		rec, err := newGraphRecurrence(rule, start)
+		if err != nil {
+			t.Fatalf("newGraphRecurrence(%q): %v", rule, err)
+		}
+		if got := rec.rule(); got != rule {
+			t.Errorf("round trip of %q = %q", rule, got)
+		}
+	}
+}
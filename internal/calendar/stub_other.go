@@ -12,11 +12,15 @@ const (
 	AuthAuthorized
 )
 
-// GetAuthStatus returns the current calendar authorization status
+// GetAuthStatus returns the current calendar authorization status. There is
+// no system prompt off macOS; NewClient reports missing accounts instead.
 func GetAuthStatus() AuthStatus {
-	return AuthDenied
+	return AuthAuthorized
 }
 
+// NewClient returns the calendar of the first account with a CalDAV, local or
+// Outlook calendar, since EventKit is only available on macOS. Without any,
+// local calendars in the default directory are used.
 func NewClient() (Client, error) {
-	return nil, ErrNotSupported
+	return newConfiguredClient()
 }
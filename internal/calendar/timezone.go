@@ -0,0 +1,195 @@
+package calendar
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// zoneAliases maps abbreviations, countries and Windows zone names (as
+// Outlook sends them) to IANA zones. Abbreviations stand for the zone, so
+// "PT", "PST" and "PDT" all follow daylight saving time.
+var zoneAliases = map[string]string{
+	"PT": "America/Los_Angeles", "PST": "America/Los_Angeles", "PDT": "America/Los_Angeles", "PACIFIC": "America/Los_Angeles",
+	"MT": "America/Denver", "MST": "America/Denver", "MDT": "America/Denver", "MOUNTAIN": "America/Denver",
+	"CT": "America/Chicago", "CST": "America/Chicago", "CDT": "America/Chicago", "CENTRAL": "America/Chicago",
+	"ET": "America/New_York", "EST": "America/New_York", "EDT": "America/New_York", "EASTERN": "America/New_York",
+	"GMT": "Europe/London", "BST": "Europe/London", "UK": "Europe/London",
+	"WET": "Europe/Lisbon", "WEST": "Europe/Lisbon",
+	"CET": "Europe/Berlin", "CEST": "Europe/Berlin",
+	"EET": "Europe/Athens", "EEST": "Europe/Athens",
+	"MSK": "Europe/Moscow",
+	"IST": "Asia/Kolkata", "INDIA": "Asia/Kolkata",
+	"SGT": "Asia/Singapore",
+	"HKT": "Asia/Hong_Kong",
+	"JST": "Asia/Tokyo", "JAPAN": "Asia/Tokyo",
+	"KST":  "Asia/Seoul",
+	"AEST": "Australia/Sydney", "AEDT": "Australia/Sydney",
+	"NZST": "Pacific/Auckland", "NZDT": "Pacific/Auckland",
+	"UTC": "UTC", "Z": "UTC",
+
+	"PACIFIC STANDARD TIME":        "America/Los_Angeles",
+	"MOUNTAIN STANDARD TIME":       "America/Denver",
+	"CENTRAL STANDARD TIME":        "America/Chicago",
+	"EASTERN STANDARD TIME":        "America/New_York",
+	"GMT STANDARD TIME":            "Europe/London",
+	"W. EUROPE STANDARD TIME":      "Europe/Berlin",
+	"ROMANCE STANDARD TIME":        "Europe/Paris",
+	"CENTRAL EUROPE STANDARD TIME": "Europe/Budapest",
+	"E. EUROPE STANDARD TIME":      "Europe/Chisinau",
+	"INDIA STANDARD TIME":          "Asia/Kolkata",
+	"CHINA STANDARD TIME":          "Asia/Shanghai",
+	"SINGAPORE STANDARD TIME":      "Asia/Singapore",
+	"TOKYO STANDARD TIME":          "Asia/Tokyo",
+	"AUS EASTERN STANDARD TIME":    "Australia/Sydney",
+	"COORDINATED UNIVERSAL TIME":   "UTC",
+}
+
+// zoneCities maps cities that are not the name of their IANA zone
+var zoneCities = map[string]string{
+	"SAN FRANCISCO": "America/Los_Angeles", "SEATTLE": "America/Los_Angeles", "SF": "America/Los_Angeles",
+	"NYC": "America/New_York", "BOSTON": "America/New_York", "WASHINGTON": "America/New_York",
+	"AUSTIN": "America/Chicago", "DALLAS": "America/Chicago",
+	"MUNICH": "Europe/Berlin", "FRANKFURT": "Europe/Berlin", "HAMBURG": "Europe/Berlin",
+	"BANGALORE": "Asia/Kolkata", "BENGALURU": "Asia/Kolkata", "MUMBAI": "Asia/Kolkata", "DELHI": "Asia/Kolkata",
+	"BEIJING":   "Asia/Shanghai",
+	"SÃO PAULO": "America/Sao_Paulo",
+}
+
+// zoneRegions are tried in turn for city names, e.g. "Berlin" -> "Europe/Berlin"
+var zoneRegions = []string{"Europe", "America", "Asia", "Australia", "Africa", "Pacific"}
+
+// ResolveTimeZone finds the zone for an IANA name ("Europe/Berlin"), an
+// abbreviation ("PT", "CET"), a Windows zone name or a city ("Berlin",
+// "New York"). A trailing "time" is ignored.
+func ResolveTimeZone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	key := strings.ToUpper(name)
+	// Cut the suffix from name itself: upper case may be longer in bytes
+	const suffix = " time"
+	if n := len(name) - len(suffix); n >= 0 && strings.EqualFold(name[n:], suffix) && zoneAliases[key] == "" {
+		name = name[:n]
+		key = strings.ToUpper(name)
+	}
+	if name == "" || strings.EqualFold(name, "local") {
+		return nil, fmt.Errorf("invalid time zone %q", name)
+	}
+	if zone, ok := zoneAliases[key]; ok {
+		return time.LoadLocation(zone)
+	}
+	if zone, ok := zoneCities[key]; ok {
+		return time.LoadLocation(zone)
+	}
+	if strings.Contains(name, "/") {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc, nil
+		}
+	}
+
+	words := strings.Fields(strings.ToLower(name))
+	for i, w := range words {
+		r, size := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(r)) + w[size:]
+	}
+	city := strings.Join(words, "_")
+	for _, region := range zoneRegions {
+		if loc, err := time.LoadLocation(region + "/" + city); err == nil {
+			return loc, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown time zone %q", name)
+}
+
+// zonePhrase matches a time followed by up to three words that may name
+// its zone, as in "3pm PT", "10:00 Berlin time" or "9:30 am (CET)"
+var zonePhrase = regexp.MustCompile(`(?i)\b(\d{1,2}(?::\d\d)?)\s*([ap]\.?m\.?)?\s+\(?([\p{L}/_]+(?:\s+[\p{L}/_]+){0,2})`)
+
+// FindTimeZone looks for a zone named right after a time in text, e.g. the
+// PT of "call at 3pm PT". Words after a time only name a zone when they are
+// an abbreviation or IANA name or are followed by "time", so "3pm tomorrow"
+// has none.
+func FindTimeZone(text string) (*time.Location, bool) {
+	for _, m := range zonePhrase.FindAllStringSubmatch(text, -1) {
+		if !strings.Contains(m[1], ":") && m[2] == "" {
+			// A bare number is more likely a date than a time
+			continue
+		}
+		words := strings.Fields(m[3])
+		for n := len(words); n > 0; n-- {
+			candidate := strings.Join(words[:n], " ")
+			explicit := n < len(words) && strings.EqualFold(words[n], "time")
+			_, abbrev := zoneAliases[strings.ToUpper(candidate)]
+			if !explicit && !abbrev && !strings.Contains(candidate, "/") {
+				continue
+			}
+			if loc, err := ResolveTimeZone(candidate); err == nil {
+				return loc, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// InTimeZone returns the instant at which t's wall clock time is reached in
+// loc, e.g. 15:00 local becomes 15:00 in loc
+func InTimeZone(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// ParsedTimeZone settles the zone of an event parsed from input, whose
+// parser reported zone (possibly empty) and the times start and end. A zone
+// named in input but missed by the parser moves the times to that zone's
+// clock. The times are returned in local time.
+func ParsedTimeZone(input, zone string, start, end time.Time) (string, time.Time, time.Time, error) {
+	if zone != "" {
+		loc, err := ResolveTimeZone(zone)
+		if err != nil {
+			return "", start, end, err
+		}
+		return loc.String(), start.Local(), end.Local(), nil
+	}
+	if loc, ok := FindTimeZone(input); ok {
+		return loc.String(), InTimeZone(start, loc).Local(), InTimeZone(end, loc).Local(), nil
+	}
+	return "", start.Local(), end.Local(), nil
+}
+
+// Zone returns the time zone the event was scheduled in, the local zone if
+// it has none
+func (e Event) Zone() *time.Location {
+	if e.TimeZone != "" {
+		if loc, err := time.LoadLocation(e.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// ForeignZone returns the event's time zone if its clock differs from the
+// local one when the event starts
+func (e Event) ForeignZone() (*time.Location, bool) {
+	if e.AllDay || e.TimeZone == "" {
+		return nil, false
+	}
+	loc := e.Zone()
+	_, offset := e.StartTime.In(loc).Zone()
+	_, local := e.StartTime.Local().Zone()
+	return loc, offset != local
+}
+
+// ZoneLabel names loc at t for display: its abbreviation ("PST", "CET"),
+// or its city when the zone has no letters for one ("Dubai")
+func ZoneLabel(loc *time.Location, t time.Time) string {
+	abbrev, _ := t.In(loc).Zone()
+	if abbrev != "" && !strings.ContainsAny(abbrev, "+-0123456789") {
+		return abbrev
+	}
+	name := loc.String()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ReplaceAll(name, "_", " ")
+}
@@ -0,0 +1,75 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeZones(t *testing.T) {
+	for input, want := range map[string]string{
+		"call with Dana at 3pm PT":           "America/Los_Angeles",
+		"standup 10:00 Berlin time":          "Europe/Berlin",
+		"sync at 9:30 am (CET) on Friday":    "Europe/Berlin",
+		"review 4pm New York time":           "America/New_York",
+		"lunch tomorrow at 12pm":             "",
+		"dinner on March 5 at Tokyo station": "",
+		"demo at 2pm tomorrow in the office": "",
+		"planning at 11:00 Asia/Singapore":   "Asia/Singapore",
+		"call at 3pm ɐɐɐɐɐɐ/ time":           "", // longer in upper case
+	} {
+		loc, ok := FindTimeZone(input)
+		got := ""
+		if ok {
+			got = loc.String()
+		}
+		if got != want {
+			t.Errorf("FindTimeZone(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	for name, want := range map[string]string{
+		"PT":                      "America/Los_Angeles",
+		"Berlin time":             "Europe/Berlin",
+		"new york":                "America/New_York",
+		"Europe/Paris":            "Europe/Paris",
+		"W. Europe Standard Time": "Europe/Berlin",
+	} {
+		if loc, err := ResolveTimeZone(name); err != nil || loc.String() != want {
+			t.Errorf("ResolveTimeZone(%q) = %v, %v, want %s", name, loc, err, want)
+		}
+	}
+
+	// A zone the parser missed moves the times to that zone's clock
+	la, _ := time.LoadLocation("America/Los_Angeles")
+	start := time.Date(2024, 3, 4, 15, 0, 0, 0, time.Local)
+	zone, gotStart, _, err := ParsedTimeZone("call at 3pm PT", "", start, start.Add(time.Hour))
+	if err != nil || zone != "America/Los_Angeles" || !gotStart.Equal(time.Date(2024, 3, 4, 15, 0, 0, 0, la)) {
+		t.Fatalf("ParsedTimeZone = %q, %v, %v", zone, gotStart, err)
+	}
+
+	// Events keep their zone through a calendar
+	client, err := NewVdirClient(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := time.Date(2024, 3, 4, 9, 0, 0, 0, la)
+	if _, err := client.CreateEvent(Event{
+		Title:      "West coast sync",
+		StartTime:  first.Local(),
+		EndTime:    first.Add(time.Hour).Local(),
+		TimeZone:   zone,
+		Recurrence: "FREQ=WEEKLY;BYDAY=MO",
+	}); err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+	events, err := client.ListEvents(first.AddDate(0, 0, -1), first.AddDate(0, 0, 28))
+	if err != nil || len(events) != 4 {
+		t.Fatalf("ListEvents = %d events, %v", len(events), err)
+	}
+	for _, e := range events {
+		// 9:00 in Los Angeles, also after daylight saving time starts there
+		if e.TimeZone != "America/Los_Angeles" || e.StartTime.In(la).Hour() != 9 || e.StartTime.Location() != time.Local {
+			t.Errorf("occurrence %v in %q, want 9:00 America/Los_Angeles", e.StartTime.In(la), e.TimeZone)
+		}
+	}
+}
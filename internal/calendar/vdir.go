@@ -0,0 +1,229 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// vdirDefaultCalendar is created when a new event is added to an empty vdir
+const vdirDefaultCalendar = "default"
+
+// vdirClient stores events as .ics files in a vdir (the layout used by
+// vdirsyncer and khal): one subdirectory per calendar, one file per event,
+// with optional "displayname" and "color" files in each calendar.
+// Event IDs are file paths relative to the root.
+type vdirClient struct {
+	root string
+}
+
+// NewVdirClient returns a client for the vdir at root, creating it if needed
+func NewVdirClient(root string) (Client, error) {
+	if strings.HasPrefix(root, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		root = filepath.Join(home, root[2:])
+	}
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, err
+	}
+	return &vdirClient{root: root}, nil
+}
+
+// DefaultVdirPath is where local calendars live unless configured otherwise
+func DefaultVdirPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "maily", "calendars"), nil
+}
+
+// readMeta reads a vdir metadata file such as "displayname"
+func (c *vdirClient) readMeta(calendar, name string) string {
+	data, err := os.ReadFile(filepath.Join(c.root, calendar, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (c *vdirClient) ListCalendars() ([]Calendar, error) {
+	entries, err := os.ReadDir(c.root)
+	if err != nil {
+		return nil, err
+	}
+
+	var calendars []Calendar
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		title := c.readMeta(entry.Name(), "displayname")
+		if title == "" {
+			title = entry.Name()
+		}
+		calendars = append(calendars, Calendar{
+			ID:    entry.Name(),
+			Title: title,
+			Color: c.readMeta(entry.Name(), "color"),
+		})
+	}
+	return calendars, nil
+}
+
+func (c *vdirClient) ListEvents(start, end time.Time) ([]Event, error) {
+	calendars, err := c.ListCalendars()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, cal := range calendars {
+		files, err := filepath.Glob(filepath.Join(c.root, cal.ID, "*.ics"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			parsed, err := expandICS(data, cal.ID+"/"+filepath.Base(file), cal.Title, start, end)
+			if err != nil {
+				// Skip files we cannot parse rather than hiding the whole calendar
+				continue
+			}
+			events = append(events, parsed...)
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].StartTime.Before(events[j].StartTime)
+	})
+	return events, nil
+}
+
+// CreateEvent writes the event to event.Calendar (a calendar ID or title), or
+// the first calendar if it is empty
+func (c *vdirClient) CreateEvent(event Event) (string, error) {
+	calendars, err := c.ListCalendars()
+	if err != nil {
+		return "", err
+	}
+
+	calendarID := vdirDefaultCalendar
+	if len(calendars) > 0 {
+		calendarID = calendars[0].ID
+	}
+	for _, cal := range calendars {
+		if cal.ID == event.Calendar || cal.Title == event.Calendar {
+			calendarID = cal.ID
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(c.root, calendarID), 0700); err != nil {
+		return "", err
+	}
+
+	uid, name := eventUID(event)
+	data, err := encodeICS(event, uid)
+	if err != nil {
+		return "", err
+	}
+
+	id := calendarID + "/" + name
+	if err := c.writeFile(id, data); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// writeFile replaces an event file atomically, as the vdir format requires
+func (c *vdirClient) writeFile(id string, data []byte) error {
+	path, err := c.path(id)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*.ics")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// path maps an event ID to its file, refusing IDs outside the root
+func (c *vdirClient) path(id string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(id))
+	if filepath.IsAbs(clean) || clean == "." || strings.HasPrefix(clean, "..") {
+		return "", fmt.Errorf("%w: invalid event id %q", ErrFailed, id)
+	}
+	return filepath.Join(c.root, clean), nil
+}
+
+func (c *vdirClient) UpdateEvent(event Event) error {
+	data, err := c.readFile(event.ID)
+	if err != nil {
+		return err
+	}
+	updated, err := updateICS(data, event)
+	if err != nil {
+		return err
+	}
+	return c.writeFile(event.ID, updated)
+}
+
+func (c *vdirClient) DeleteOccurrence(id string, occurrence time.Time, span Span) error {
+	data, err := c.readFile(id)
+	if err != nil {
+		return err
+	}
+	updated, err := deleteOccurrenceICS(data, occurrence, span)
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return c.DeleteEvent(id)
+	}
+	return c.writeFile(id, updated)
+}
+
+func (c *vdirClient) readFile(id string) ([]byte, error) {
+	path, err := c.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (c *vdirClient) DeleteEvent(id string) error {
+	path, err := c.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package calendar
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+func TestVdirClient(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "work"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(root, "work", "displayname"), []byte("Work\n"), 0600)
+	os.WriteFile(filepath.Join(root, "work", "color"), []byte("#ff0000\n"), 0600)
+	os.MkdirAll(filepath.Join(root, "home"), 0700)
+
+	client, err := NewVdirClient(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calendars, err := client.ListCalendars()
+	if err != nil {
+		t.Fatalf("ListCalendars: %v", err)
+	}
+	if len(calendars) != 2 || calendars[0].ID != "home" || calendars[1].Title != "Work" || calendars[1].Color != "#ff0000" {
+		t.Fatalf("calendars = %+v", calendars)
+	}
+
+	start := time.Date(2024, 3, 5, 9, 0, 0, 0, time.Local)
+	id, err := client.CreateEvent(Event{Title: "Standup", StartTime: start, EndTime: start.Add(15 * time.Minute), Calendar: "Work"})
+	if err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+	if filepath.Dir(id) != "work" {
+		t.Fatalf("event id = %q, want a file in work/", id)
+	}
+
+	events, err := client.ListEvents(start.Add(-time.Hour), start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(events) != 1 || events[0].ID != id || events[0].Title != "Standup" || events[0].Calendar != "Work" || !events[0].StartTime.Equal(start) {
+		t.Fatalf("events = %+v", events)
+	}
+
+	if events, _ := client.ListEvents(start.Add(time.Hour), start.Add(2*time.Hour)); len(events) != 0 {
+		t.Fatalf("events outside the range were listed: %+v", events)
+	}
+
+	if err := client.DeleteEvent("../outside.ics"); err == nil {
+		t.Fatal("DeleteEvent accepted an id outside the vdir")
+	}
+	if err := client.DeleteEvent(id); err != nil {
+		t.Fatalf("DeleteEvent: %v", err)
+	}
+	if err := client.DeleteEvent(id); err != ErrNotFound {
+		t.Fatalf("second DeleteEvent = %v, want ErrNotFound", err)
+	}
+}
+
+func TestVdirUpdateKeepsUnmodelledProperties(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "home"), 0700)
+	ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:test\r\nBEGIN:VEVENT\r\n" +
+		"UID:weekly\r\nDTSTAMP:20240101T000000Z\r\nDTSTART:20240304T090000Z\r\nDTEND:20240304T100000Z\r\n" +
+		"SUMMARY:Sync\r\nRRULE:FREQ=WEEKLY\r\nORGANIZER:mailto:me@example.com\r\n" +
+		"ATTENDEE;CN=Ann;ROLE=OPT-PARTICIPANT;CUTYPE=INDIVIDUAL;PARTSTAT=ACCEPTED:mailto:ann@example.com\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT10M\r\nEND:VALARM\r\n" +
+		"BEGIN:VALARM\r\nACTION:AUDIO\r\nTRIGGER;VALUE=DATE-TIME:20240304T080000Z\r\nEND:VALARM\r\n" +
+		"END:VEVENT\r\nEND:VCALENDAR\r\n"
+	os.WriteFile(filepath.Join(root, "home", "weekly.ics"), []byte(ics), 0600)
+
+	client, err := NewVdirClient(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	events, err := client.ListEvents(start.Add(-time.Hour), start.Add(2*time.Hour))
+	if err != nil || len(events) != 1 {
+		t.Fatalf("ListEvents = %+v, %v", events, err)
+	}
+
+	event := events[0]
+	event.Title = "Weekly sync"
+	event.EndTime = start.Add(30 * time.Minute)
+	event.Attendees = append(event.Attendees, Attendee{Email: "bob@example.com"})
+	if err := client.UpdateEvent(event); err != nil {
+		t.Fatalf("UpdateEvent: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(root, "home", "weekly.ics"))
+	for _, want := range []string{"SUMMARY:Weekly sync", "DTEND:20240304T093000Z", "RRULE:FREQ=WEEKLY", "ATTENDEE;CN=Ann", "ACTION:AUDIO", "SEQUENCE:1"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("updated file lacks %q:\n%s", want, data)
+		}
+	}
+
+	// Ann keeps her role, Bob is invited
+	cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The occurrence was written as its own VEVENT
+	override := cal.Children[len(cal.Children)-1]
+	if override.Props.Get(ical.PropRecurrenceID) == nil {
+		t.Fatalf("no occurrence VEVENT in:\n%s", data)
+	}
+	attendees := override.Props.Values(ical.PropAttendee)
+	if len(attendees) != 2 {
+		t.Fatalf("attendees = %+v", attendees)
+	}
+	ann, bob := attendees[0].Params, attendees[1].Params
+	if ann.Get(ical.ParamRole) != "OPT-PARTICIPANT" || ann.Get(ical.ParamCalendarUserType) != "INDIVIDUAL" ||
+		ann.Get(ical.ParamCommonName) != "Ann" || ann.Get(ical.ParamParticipationStatus) != StatusAccepted {
+		t.Errorf("Ann's params = %v", ann)
+	}
+	if bob.Get(ical.ParamRole) != "" || bob.Get(ical.ParamRSVP) != "TRUE" {
+		t.Errorf("Bob's params = %v", bob)
+	}
+
+	event.ID = "home/missing.ics"
+	if err := client.UpdateEvent(event); err != ErrNotFound {
+		t.Fatalf("UpdateEvent of a missing event = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"maily/config"
+	"maily/internal/ai"
+)
+
+var aiTestTask string
+
+var aiCmd = &cobra.Command{
+	Use:   "ai",
+	Short: "Show the AI provider and model for each task",
+	Long: `Show which AI provider and model maily uses for each task. Configure them
+under "ai" in ~/.config/maily/config.json.
+
+Prompts for providers that are not on this machine can have email
+addresses, phone numbers, IBANs, card numbers and your own patterns masked,
+and can be logged to ~/.config/maily/ai-audit.log:
+
+  "ai": { "privacy": { "redact": true, "patterns": ["ACME-[0-9]{6}"], "audit_log": true } }`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runAIShow()
+	},
+}
+
+var aiTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Send a test prompt to the AI provider",
+	Long: `Send a short prompt to the AI provider and show its answer and how long it
+took.
+
+Examples:
+  maily ai test
+  maily ai test --task draft`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runAITest(ai.Task(aiTestTask))
+	},
+}
+
+func init() {
+	var tasks []string
+	for _, task := range ai.Tasks {
+		tasks = append(tasks, string(task))
+	}
+	aiTestCmd.Flags().StringVar(&aiTestTask, "task", "", "Test the provider of a task: "+strings.Join(tasks, ", "))
+	aiCmd.AddCommand(aiTestCmd)
+	rootCmd.AddCommand(aiCmd)
+}
+
+func runAIShow() {
+	describe := func(client *ai.Client) string {
+		if !client.Available() {
+			return "none (no AI CLI found)"
+		}
+		return client.Provider()
+	}
+
+	fmt.Printf("  %-10s %s\n", "default", describe(ai.NewClient()))
+	for _, task := range ai.Tasks {
+		fmt.Printf("  %-10s %s\n", task, describe(ai.NewTaskClient(task)))
+	}
+
+	cfg, _ := config.Load()
+	privacy := cfg.AI.Privacy
+	redact := "off"
+	if privacy.Redact {
+		redact = "on"
+		if len(privacy.Patterns) > 0 {
+			redact += ", also " + strings.Join(privacy.Patterns, ", ")
+		}
+	}
+	audit := "off"
+	if privacy.AuditLog {
+		audit, _ = ai.AuditLogPath()
+	}
+	fmt.Println()
+	fmt.Printf("  %-10s %s\n", "redaction", redact)
+	fmt.Printf("  %-10s %s\n", "audit log", audit)
+}
+
+func runAITest(task ai.Task) {
+	valid := task == ai.TaskDefault
+	for _, t := range ai.Tasks {
+		valid = valid || t == task
+	}
+	if !valid {
+		fmt.Printf("Error: unknown task %q\n", task)
+		os.Exit(1)
+	}
+
+	client := ai.NewTaskClient(task)
+	if !client.Available() {
+		fmt.Println("Error: No AI provider configured and no AI CLI found.")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Testing %s...\n", client.Provider())
+	start := time.Now()
+	response, err := client.Call("Reply with exactly one word: OK")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ %s answered in %s: %s\n", client.Provider(), time.Since(start).Round(10*time.Millisecond), truncate(response, 60))
+}
@@ -10,6 +10,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
 	"maily/internal/ai"
+	"maily/internal/auth"
 	"maily/internal/calendar"
 	"maily/internal/ui"
 )
@@ -24,7 +25,10 @@ var calendarCmd = &cobra.Command{
 	},
 }
 
-var calendarAddDebug bool
+var (
+	calendarAccount  string
+	calendarAddDebug bool
+)
 
 var calendarAddCmd = &cobra.Command{
 	Use:   "add [natural language description]",
@@ -60,6 +64,7 @@ var calendarListCmd = &cobra.Command{
 }
 
 func init() {
+	calendarCmd.Flags().StringVar(&calendarAccount, "account", "", "Account that organizes new meetings (default: first account)")
 	calendarAddCmd.Flags().BoolVar(&calendarAddDebug, "debug", false, "Show raw AI response")
 	calendarCmd.AddCommand(calendarAddCmd)
 	calendarCmd.AddCommand(calendarListCmd)
@@ -89,7 +94,7 @@ func runCalendarList() {
 
 func runCalendarAdd(input string) {
 	// Check AI availability
-	aiClient := ai.NewClient()
+	aiClient := ai.NewTaskClient(ai.TaskCalendar)
 	if !aiClient.Available() {
 		fmt.Println("Error: No AI CLI found.")
 		fmt.Println()
@@ -98,6 +103,8 @@ func runCalendarAdd(input string) {
 		fmt.Println("  - codex  (Codex CLI)")
 		fmt.Println("  - gemini (Gemini CLI)")
 		fmt.Println("  - ollama (Ollama)")
+		fmt.Println()
+		fmt.Println("or configure an OpenAI-compatible or Ollama API under \"ai\" in ~/.config/maily/config.json")
 		os.Exit(1)
 	}
 
@@ -136,11 +143,33 @@ func runCalendarAdd(input string) {
 		os.Exit(1)
 	}
 
+	recurrence, err := calendar.ParseRecurrence(parsed.Recurrence)
+	if err != nil {
+		fmt.Printf("Error parsing recurrence: %v\n", err)
+		os.Exit(1)
+	}
+
+	timeZone, startTime, endTime, err := calendar.ParsedTimeZone(input, parsed.TimeZone, startTime, endTime)
+	if err != nil {
+		fmt.Printf("Error parsing time zone: %v\n", err)
+		os.Exit(1)
+	}
+	var theirTime string
+	if loc, ok := (calendar.Event{StartTime: startTime, TimeZone: timeZone}).ForeignZone(); ok {
+		theirTime = fmt.Sprintf("%s - %s %s", startTime.In(loc).Format("3:04 PM"), endTime.In(loc).Format("3:04 PM"), calendar.ZoneLabel(loc, startTime))
+	}
+
 	// Step 1: Show parsed event
 	fmt.Println("  ┌─ Parsed Event ─────────────────────────────────┐")
 	fmt.Printf("  │  Title:    %-37s│\n", truncate(parsed.Title, 37))
 	fmt.Printf("  │  Date:     %-37s│\n", startTime.Format("Monday, Jan 2, 2006"))
 	fmt.Printf("  │  Time:     %-37s│\n", fmt.Sprintf("%s - %s", startTime.Format("3:04 PM"), endTime.Format("3:04 PM")))
+	if theirTime != "" {
+		fmt.Printf("  │  Their time: %-35s│\n", truncate(theirTime, 35))
+	}
+	if recurrence != "" {
+		fmt.Printf("  │  Repeats:  %-37s│\n", truncate(calendar.DescribeRecurrence(recurrence), 37))
+	}
 	if parsed.Location != "" {
 		fmt.Printf("  │  Location: %-37s│\n", truncate(parsed.Location, 37))
 	}
@@ -185,6 +214,12 @@ func runCalendarAdd(input string) {
 	fmt.Printf("  │  Title:    %-37s│\n", truncate(parsed.Title, 37))
 	fmt.Printf("  │  Date:     %-37s│\n", startTime.Format("Monday, Jan 2, 2006"))
 	fmt.Printf("  │  Time:     %-37s│\n", fmt.Sprintf("%s - %s", startTime.Format("3:04 PM"), endTime.Format("3:04 PM")))
+	if theirTime != "" {
+		fmt.Printf("  │  Their time: %-35s│\n", truncate(theirTime, 35))
+	}
+	if recurrence != "" {
+		fmt.Printf("  │  Repeats:  %-37s│\n", truncate(calendar.DescribeRecurrence(recurrence), 37))
+	}
 	if parsed.Location != "" {
 		fmt.Printf("  │  Location: %-37s│\n", truncate(parsed.Location, 37))
 	}
@@ -212,9 +247,11 @@ func runCalendarAdd(input string) {
 		Title:              parsed.Title,
 		StartTime:          startTime,
 		EndTime:            endTime,
+		TimeZone:           timeZone,
 		Location:           parsed.Location,
 		AlarmMinutesBefore: alarmMinutes,
 		Calendar:           calendarID,
+		Recurrence:         recurrence,
 	}
 
 	eventID, err := client.CreateEvent(event)
@@ -298,8 +335,24 @@ func runCalendarTUI() {
 		os.Exit(1)
 	}
 
+	// Invitations to attendees are sent from the mail accounts, if any
+	store, _ := auth.LoadAccountStore()
+	var organizer *auth.Account
+	if store != nil && len(store.Accounts) > 0 {
+		organizer = &store.Accounts[0]
+	}
+	if calendarAccount != "" {
+		if store != nil {
+			organizer = store.GetAccount(calendarAccount)
+		}
+		if organizer == nil {
+			fmt.Printf("Error: no account %s\n", calendarAccount)
+			os.Exit(1)
+		}
+	}
+
 	p := tea.NewProgram(
-		ui.NewCalendarApp(client),
+		ui.NewCalendarApp(client, store, organizer),
 		tea.WithAltScreen(),
 		tea.WithMouseCellMotion(),
 	)
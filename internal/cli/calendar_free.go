@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"maily/internal/calendar"
+)
+
+var (
+	calendarFreeDuration     time.Duration
+	calendarFreeWithin       string
+	calendarFreeWorkingHours string
+	calendarFreeWeekends     bool
+)
+
+var calendarFreeCmd = &cobra.Command{
+	Use:   "free",
+	Short: "Find open slots in your calendar",
+	Long: `List the periods in your calendar with no events that are long enough for
+a meeting, within working hours. All-day events don't count as busy.
+
+Examples:
+  maily c free
+  maily c free --duration 1h --within "next week"
+  maily c free --duration 30m --within "this week" --working-hours 9-17
+  maily c free --within 3d --working-hours 8:30-16:30`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runCalendarFree()
+	},
+}
+
+func init() {
+	calendarFreeCmd.Flags().DurationVar(&calendarFreeDuration, "duration", 30*time.Minute, "Minimum length of a slot")
+	calendarFreeCmd.Flags().StringVar(&calendarFreeWithin, "within", "this week", "Period to search: today, tomorrow, this week, next week or e.g. 3d")
+	calendarFreeCmd.Flags().StringVar(&calendarFreeWorkingHours, "working-hours", "9-17", "Hours of the day to search, e.g. 9-17 or 8:30-16:30")
+	calendarFreeCmd.Flags().BoolVar(&calendarFreeWeekends, "weekends", false, "Include Saturdays and Sundays")
+	calendarCmd.AddCommand(calendarFreeCmd)
+}
+
+func runCalendarFree() {
+	if calendarFreeDuration <= 0 {
+		fmt.Println("Error: --duration must be positive")
+		os.Exit(1)
+	}
+	hours, err := calendar.ParseWorkingHours(calendarFreeWorkingHours)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	hours.Weekends = calendarFreeWeekends
+	start, end, err := calendar.ParseWithin(calendarFreeWithin, time.Now())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, err := calendar.NewClient()
+	if err != nil {
+		fmt.Printf("Error accessing calendar: %v\n", err)
+		os.Exit(1)
+	}
+	events, err := client.ListEvents(start, end)
+	if err != nil {
+		fmt.Printf("Error listing events: %v\n", err)
+		os.Exit(1)
+	}
+
+	slots := calendar.FreeSlots(events, start, end, calendarFreeDuration, hours)
+	if len(slots) == 0 {
+		fmt.Printf("No free %s slots %s.\n", calendarFreeDuration, calendarFreeWithin)
+		return
+	}
+	fmt.Print(calendar.FormatSlots(slots))
+}
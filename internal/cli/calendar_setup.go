@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"maily/internal/auth"
+	"maily/internal/calendar"
+)
+
+var (
+	calendarSetupAccount  string
+	calendarSetupServer   string
+	calendarSetupUsername string
+	calendarSetupPath     string
+)
+
+var calendarSetupCmd = &cobra.Command{
+	Use:   "setup <google|fastmail|icloud|nextcloud|local|URL>",
+	Short: "Connect an account to a calendar",
+	Long: `Connect an account to a CalDAV calendar. Use a preset or the URL of any
+CalDAV server (e.g. Radicale), or "local" for a directory of .ics files
+(vdir, as used by vdirsyncer and khal).
+
+Examples:
+  maily c setup fastmail
+  maily c setup nextcloud --server cloud.example.com
+  maily c setup icloud --username you@icloud.com
+  maily c setup http://localhost:5232/ --account you@example.com
+  maily c setup local --path ~/.calendars`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runCalendarSetup(args[0])
+	},
+}
+
+func init() {
+	calendarSetupCmd.Flags().StringVar(&calendarSetupAccount, "account", "", "Account email (default: prompt)")
+	calendarSetupCmd.Flags().StringVar(&calendarSetupServer, "server", "", "Server host for the nextcloud preset")
+	calendarSetupCmd.Flags().StringVar(&calendarSetupUsername, "username", "", "CalDAV username (default: account email)")
+	calendarSetupCmd.Flags().StringVar(&calendarSetupPath, "path", "", "Directory for local calendars (default: ~/.config/maily/calendars)")
+	calendarCmd.AddCommand(calendarSetupCmd)
+}
+
+func runCalendarSetup(target string) {
+	store, err := auth.LoadAccountStore()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(store.Accounts) == 0 {
+		fmt.Println("No accounts configured. Run: maily login")
+		os.Exit(1)
+	}
+
+	account := selectSetupAccount(store)
+	if account == nil {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	if target == auth.CalendarLocal {
+		account.Calendar = &auth.CalendarConfig{Type: auth.CalendarLocal, Path: calendarSetupPath}
+		saveCalendarSetup(store, account)
+		return
+	}
+
+	serverURL := target
+	if _, ok := calendar.CalDAVPresets[target]; ok {
+		serverURL, err = calendar.CalDAVPresetURL(target, account.Credentials.Email, calendarSetupServer)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("CalDAV server: %s\n", serverURL)
+	fmt.Print("Password (Enter to reuse the mail password): ")
+	passwordBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	account.Calendar = &auth.CalendarConfig{
+		Type:     auth.CalendarCalDAV,
+		URL:      serverURL,
+		Username: calendarSetupUsername,
+		Password: strings.TrimSpace(string(passwordBytes)),
+	}
+
+	saveCalendarSetup(store, account)
+}
+
+// saveCalendarSetup verifies the account's calendar and saves it
+func saveCalendarSetup(store *auth.AccountStore, account *auth.Account) {
+	client, err := calendar.NewAccountClient(account)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	calendars, err := client.ListCalendars()
+	if err != nil {
+		fmt.Printf("Error connecting to calendar: %v\n", err)
+		os.Exit(1)
+	}
+
+	store.AddAccount(*account)
+	if err := store.Save(); err != nil {
+		fmt.Printf("Error saving account: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Calendar connected for %s\n", account.Credentials.Email)
+	fmt.Println()
+	for _, cal := range calendars {
+		fmt.Printf("  %s\n", cal.Title)
+	}
+}
+
+// selectSetupAccount returns the account named by --account, the only
+// account, or the one picked in a selector
+func selectSetupAccount(store *auth.AccountStore) *auth.Account {
+	if calendarSetupAccount != "" {
+		account := store.GetAccount(calendarSetupAccount)
+		if account == nil {
+			fmt.Printf("Account not found: %s\n", calendarSetupAccount)
+			os.Exit(1)
+		}
+		return account
+	}
+	if len(store.Accounts) == 1 {
+		return &store.Accounts[0]
+	}
+
+	items := make([]SelectorItem, len(store.Accounts))
+	for i, acc := range store.Accounts {
+		items[i] = SelectorItem{ID: acc.Credentials.Email, Label: acc.Credentials.Email}
+	}
+	email, _, cancelled := RunSelector("Select Account", items)
+	if cancelled {
+		return nil
+	}
+	return store.GetAccount(email)
+}
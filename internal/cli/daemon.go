@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,27 +11,45 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/emersion/go-imap/v2"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 
+	"maily/config"
 	"maily/internal/auth"
 	"maily/internal/cache"
+	"maily/internal/control"
+	"maily/internal/mail"
+	"maily/internal/notify"
 	"maily/internal/sync"
 	"maily/internal/version"
 )
 
 const (
-	syncInterval = 30 * time.Minute
-	maxLogSize   = 10 * 1024 * 1024 // 10MB
+	defaultSyncInterval  = 30 * time.Minute
+	defaultMaxConcurrent = 4
+	pushRetryWait        = time.Minute
+	maxLogSize           = 10 * 1024 * 1024 // 10MB
 )
 
 var daemonCmd = &cobra.Command{
 	Use:   "daemon",
 	Short: "Background sync daemon",
-	Long:  "The daemon syncs your email in the background.",
+	Long: `The daemon syncs your email in the background, each account on its own
+schedule (see daemon in ~/.config/maily/config.json), and shows desktop
+notifications for new mail (see notifications).
+
+It listens on ~/.config/maily/daemon.sock, over which the TUI and
+'maily daemon sync' ask it to sync now, 'maily daemon status' asks for the
+sync state of each account, and the TUI follows its events to refresh as
+soon as new mail is synced.
+
+The TUI starts the daemon when it opens. 'maily daemon install' runs it
+under systemd or launchd instead, so it starts at login.`,
 }
 
 var daemonStartCmd = &cobra.Command{
@@ -48,6 +68,24 @@ var daemonStatusCmd = &cobra.Command{
 	},
 }
 
+var daemonSyncMailbox string
+
+var daemonSyncCmd = &cobra.Command{
+	Use:   "sync [account]",
+	Short: "Ask the running daemon to sync now",
+	Long: `Ask the running daemon to sync now and wait until it has: all accounts, or
+only the one given. Without --mailbox the daemon syncs the inbox and the
+mailboxes with notifications.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		account := ""
+		if len(args) > 0 {
+			account = args[0]
+		}
+		runDaemonSync(account, daemonSyncMailbox)
+	},
+}
+
 var daemonStopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stop the daemon",
@@ -60,6 +98,8 @@ func init() {
 	daemonCmd.AddCommand(daemonStartCmd)
 	daemonCmd.AddCommand(daemonStatusCmd)
 	daemonCmd.AddCommand(daemonStopCmd)
+	daemonSyncCmd.Flags().StringVar(&daemonSyncMailbox, "mailbox", "", "Sync only this mailbox (needs an account)")
+	daemonCmd.AddCommand(daemonSyncCmd)
 	rootCmd.AddCommand(daemonCmd)
 }
 
@@ -127,13 +167,18 @@ func isMailyProcess(pid int) bool {
 
 // startDaemonBackground starts the daemon in the background
 // If a daemon is running with a different version, it will be restarted
+// An installed service is started, or restarted, by the service manager.
 func startDaemonBackground() {
 	pid, daemonVer, err := parsePidFile()
-	if err == nil && isMailyProcess(pid) {
-		// Daemon is running - check if version matches
-		if daemonVer == version.Version {
-			return // Already running with correct version
-		}
+	running := err == nil && isMailyProcess(pid)
+	if running && daemonVer == version.Version {
+		return // Already running with correct version
+	}
+	if svc := installedDaemonService(); svc != nil {
+		svc.Start()
+		return
+	}
+	if running {
 		// Version mismatch - stop old daemon and start new one
 		if process, err := os.FindProcess(pid); err == nil {
 			process.Signal(syscall.SIGTERM)
@@ -160,15 +205,18 @@ func startDaemonBackground() {
 
 // stopDaemon stops the daemon if running
 func stopDaemon() {
-	pidFile := getDaemonPidFile()
-	data, err := os.ReadFile(pidFile)
-	if err != nil {
-		fmt.Println("Daemon is not running.")
+	if svc := installedDaemonService(); svc != nil {
+		if err := svc.Stop(); err != nil {
+			fmt.Println("Error stopping daemon:", err)
+			return
+		}
+		fmt.Printf("Daemon stopped (%s)\n", svc.Name())
 		return
 	}
 
-	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
-	if err != nil || pid <= 0 {
+	pidFile := getDaemonPidFile()
+	pid, _, err := parsePidFile()
+	if err != nil {
 		os.Remove(pidFile)
 		fmt.Println("Daemon is not running.")
 		return
@@ -201,9 +249,12 @@ func stopDaemon() {
 
 func checkDaemonStatus() {
 	logFile := getDaemonLogFile()
+	svc := installedDaemonService()
 
-	pid, daemonVer, err := parsePidFile()
-	if err == nil && isMailyProcess(pid) {
+	if status, err := control.GetStatus(); err == nil {
+		printDaemonStatus(status)
+	} else if pid, daemonVer, err := parsePidFile(); err == nil && isMailyProcess(pid) {
+		// A daemon of an older version, without a control socket
 		if daemonVer != "" {
 			fmt.Printf("Daemon is running (PID: %d, version: %s)\n", pid, daemonVer)
 		} else {
@@ -213,6 +264,20 @@ func checkDaemonStatus() {
 		fmt.Println("Daemon is not running")
 	}
 
+	if svc != nil {
+		fmt.Printf("Installed as %s (%s)\n", svc.Name(), svc.State())
+		if logs, ok := svc.Logs(10); ok {
+			fmt.Println()
+			fmt.Println("Recent logs (journal):")
+			for _, line := range strings.Split(strings.TrimRight(logs, "\n"), "\n") {
+				fmt.Println(" ", line)
+			}
+			return
+		}
+	} else {
+		fmt.Println("Started by maily (run 'maily daemon install' to start it at login)")
+	}
+
 	fmt.Println("Log file:", logFile)
 
 	// Show recent logs
@@ -242,6 +307,83 @@ func checkDaemonStatus() {
 	}
 }
 
+func runDaemonSync(account, mailbox string) {
+	if mailbox != "" && account == "" {
+		fmt.Println("Error: --mailbox needs an account")
+		os.Exit(1)
+	}
+	fmt.Println("Syncing...")
+	if err := control.Sync(account, mailbox); err != nil {
+		if errors.Is(err, control.ErrNotRunning) {
+			fmt.Println("Daemon is not running. Start it with 'maily daemon start' or use 'maily sync'.")
+		} else {
+			fmt.Printf("Error: %v\n", err)
+		}
+		os.Exit(1)
+	}
+	fmt.Println("✓ Sync complete")
+}
+
+// printDaemonStatus prints the status a daemon sent over its control
+// socket, with the health of each account
+func printDaemonStatus(status *control.Status) {
+	fmt.Printf("Daemon is running (PID: %d, version: %s)\n", status.PID, status.Version)
+	fmt.Printf("Running since %s, at most %d syncs at once\n", formatDaemonTime(status.Started), status.MaxConcurrent)
+
+	fmt.Println("\nAccounts:")
+	for _, acc := range status.Accounts {
+		var health string
+		switch {
+		case acc.Failures > 0:
+			health = fmt.Sprintf("failing (%d in a row, last at %s): %s", acc.Failures, formatDaemonTime(acc.ErrorAt), acc.LastError)
+		case acc.LastError != "":
+			// The account synced, one of its other mailboxes did not
+			health = fmt.Sprintf("error at %s: %s", formatDaemonTime(acc.ErrorAt), acc.LastError)
+		case acc.LastSync.IsZero():
+			health = "not synced yet"
+		default:
+			health = "ok, synced at " + formatDaemonTime(acc.LastSync)
+		}
+		fmt.Printf("  %-30s %s\n", acc.Email, health)
+
+		var schedule string
+		switch {
+		case acc.Syncing:
+			schedule = "syncing now"
+		case acc.NextRun.IsZero():
+			continue
+		case acc.Failures > 0:
+			schedule = fmt.Sprintf("retry at %s, backing off (every %s when ok)", formatDaemonTime(acc.NextRun), formatInterval(acc.Interval))
+		default:
+			schedule = fmt.Sprintf("next sync at %s (every %s)", formatDaemonTime(acc.NextRun), formatInterval(acc.Interval))
+		}
+		fmt.Printf("  %-30s %s\n", "", schedule)
+	}
+	fmt.Println()
+}
+
+// formatInterval formats d without zero units, e.g. "30m" or "1h"
+func formatInterval(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// formatDaemonTime formats t as a time of day, with the date unless it is
+// today
+func formatDaemonTime(t time.Time) string {
+	t, now := t.Local(), time.Now()
+	if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+		return t.Format("15:04")
+	}
+	return t.Format("Jan 2 15:04")
+}
+
 func setupLogging(logFile string, alsoToTerminal bool) {
 	// Rotate if log exceeds max size
 	if info, err := os.Stat(logFile); err == nil && info.Size() > maxLogSize {
@@ -286,10 +428,12 @@ func setupLogging(logFile string, alsoToTerminal bool) {
 func runDaemon() {
 	isTerminal := term.IsTerminal(int(os.Stdin.Fd()))
 
-	// Always set up log file
+	// Set up log file, unless systemd sends the output to the journal
 	logFile := getDaemonLogFile()
-	if err := os.MkdirAll(filepath.Dir(logFile), 0700); err == nil {
-		setupLogging(logFile, isTerminal)
+	if os.Getenv("JOURNAL_STREAM") == "" {
+		if err := os.MkdirAll(filepath.Dir(logFile), 0700); err == nil {
+			setupLogging(logFile, isTerminal)
+		}
 	}
 
 	// Write PID file with version (format: "PID:VERSION")
@@ -332,18 +476,81 @@ func runDaemon() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Initial sync
-	syncAllAccounts(store, c)
+	// Sync settings, see daemon in config.json
+	cfg, _ := config.Load()
+	maxConcurrent := cfg.Daemon.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrent
+	}
 
-	ticker := time.NewTicker(syncInterval)
-	defer ticker.Stop()
+	// Control socket, over which the TUI and CLI ask for syncs and status
+	// and follow what the daemon does
+	var emails []string
+	for _, account := range store.Accounts {
+		emails = append(emails, account.Credentials.Email)
+	}
+	server := control.NewServer(version.Version, maxConcurrent, emails)
+	if listener := control.Activated(); listener != nil {
+		server.Serve(listener)
+	} else if path, err := control.SocketPath(); err == nil {
+		if err := server.Listen(path); err != nil {
+			fmt.Println("Control socket unavailable:", err)
+		}
+	}
+	defer server.Close()
+
+	// Each account syncs in a worker of its own, right away and then on
+	// its own interval
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scheduler := sync.NewScheduler(maxConcurrent, func(email, mailbox string) error {
+		return syncScheduled(store, c, server, email, mailbox)
+	})
+	scheduler.OnSchedule = func(s sync.Schedule) {
+		server.Scheduled(s.Account, s.Interval, s.Next, s.Failures)
+	}
+	for _, email := range emails {
+		scheduler.Add(email, syncIntervalOf(cfg.Daemon, email))
+	}
+	scheduler.Start(ctx)
 
-	fmt.Println("Daemon started, syncing every", syncInterval)
+	// Accounts whose server pushes changes (JMAP) are also synced on every change
+	pushChan := make(chan int, len(store.Accounts))
+	for i := range store.Accounts {
+		go watchAccount(ctx, &store.Accounts[i], i, pushChan)
+	}
+
+	fmt.Printf("Daemon started, syncing %d accounts, at most %d at once\n", len(emails), maxConcurrent)
+
+	// Daily digest, if digest.time is set in config.json. It is built off
+	// the loop; one still being built when the next is due skips that one.
+	digestChan := scheduleDigest(cfg.Digest)
+	var digesting atomic.Bool
 
 	for {
 		select {
-		case <-ticker.C:
-			syncAllAccounts(store, c)
+		case i := <-pushChan:
+			scheduler.Poke(store.Accounts[i].Credentials.Email)
+		case req := <-server.Syncs():
+			// Answered once the workers have synced
+			go func() {
+				req.Reply(syncRequested(ctx, scheduler, req))
+			}()
+		case <-digestChan:
+			// Reload to pick up changes made while the daemon runs
+			cfg, _ = config.Load()
+			if digesting.CompareAndSwap(false, true) {
+				go func(cfg config.DigestConfig) {
+					defer digesting.Store(false)
+					// Sync first so the digest has the latest mail; this
+					// waits for the workers, which may all be busy
+					scheduler.SyncAllNow(ctx)
+					runScheduledDigest(store, c, cfg)
+				}(cfg.Digest)
+			} else {
+				fmt.Println("Digest: the last one is still being built, skipping")
+			}
+			digestChan = scheduleDigest(cfg.Digest)
 		case sig := <-sigChan:
 			fmt.Println("Received signal:", sig)
 			return
@@ -351,15 +558,185 @@ func runDaemon() {
 	}
 }
 
-func syncAllAccounts(store *auth.AccountStore, c *cache.Cache) {
-	for i := range store.Accounts {
-		account := &store.Accounts[i]
-		syncer := sync.NewSyncer(c, account)
+// syncIntervalOf returns how often the daemon syncs account
+func syncIntervalOf(cfg config.DaemonConfig, account string) time.Duration {
+	interval := cfg.Interval
+	if acc := cfg.Accounts[account]; acc.Interval != "" {
+		interval = acc.Interval
+	}
+	if interval == "" {
+		return defaultSyncInterval
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil || d < time.Minute {
+		fmt.Printf("Invalid sync interval %q for %s, syncing every %s\n", interval, account, defaultSyncInterval)
+		return defaultSyncInterval
+	}
+	return d
+}
 
-		if err := syncer.FullSync("INBOX"); err != nil {
-			fmt.Printf("Error syncing %s: %v\n", account.Credentials.Email, err)
-		} else {
-			fmt.Printf("Synced %s\n", account.Credentials.Email)
+// syncRequested runs a sync a client asked for over the control socket
+func syncRequested(ctx context.Context, scheduler *sync.Scheduler, req control.SyncRequest) error {
+	if req.Account == "" {
+		return scheduler.SyncAllNow(ctx)
+	}
+	return scheduler.SyncNow(ctx, req.Account, req.Mailbox)
+}
+
+// syncScheduled is what an account's worker runs: a sync of the account,
+// or of one of its mailboxes
+func syncScheduled(store *auth.AccountStore, c *cache.Cache, server *control.Server, email, mailbox string) error {
+	account := store.GetAccount(email)
+	if account == nil {
+		return fmt.Errorf("no account %s", email)
+	}
+	if mailbox == "" {
+		return syncAccount(account, c, server)
+	}
+	syncer := sync.NewSyncer(c, account)
+	useRules(syncer, account)
+	return syncMailbox(syncer, notificationPolicy(), c, server, email, mailbox)
+}
+
+// syncAccount syncs the inbox of an account and the other mailboxes with
+// notifications, then triages new mail
+func syncAccount(account *auth.Account, c *cache.Cache, server *control.Server) error {
+	syncer := sync.NewSyncer(c, account)
+	useRules(syncer, account)
+	policy := notificationPolicy()
+
+	email := account.Credentials.Email
+	if err := syncMailbox(syncer, policy, c, server, email, "INBOX"); err != nil {
+		fmt.Printf("Error syncing %s: %v\n", email, err)
+		return err
+	}
+	fmt.Printf("Synced %s\n", email)
+
+	// Other mailboxes with notifications are synced too
+	for _, mailbox := range policy.Mailboxes(email) {
+		if strings.EqualFold(mailbox, "INBOX") {
+			continue
+		}
+		if err := syncMailbox(syncer, policy, c, server, email, mailbox); err != nil {
+			fmt.Printf("Error syncing %s/%s: %v\n", email, mailbox, err)
+		}
+	}
+	triageAccount(account, c)
+	return nil
+}
+
+// syncMailbox syncs one mailbox of account, shows notifications for the
+// mail it added and reports both to control socket subscribers
+func syncMailbox(syncer *sync.Syncer, policy *notify.Policy, c *cache.Cache, server *control.Server, account, mailbox string) error {
+	server.SyncStarted(account)
+	before := snapshotMailbox(c, account, mailbox)
+	err := syncer.FullSync(mailbox)
+	if err == nil {
+		if added := newMail(c, account, mailbox, before); len(added) > 0 {
+			server.NewMail(account, mailbox, len(added))
+			notifyNewMail(policy, account, mailbox, added)
+		}
+	}
+	server.SyncFinished(account, mailbox, err)
+	return err
+}
+
+// notificationPolicy loads the notification settings, reloaded on every
+// sync so they can change while the daemon runs
+func notificationPolicy() *notify.Policy {
+	cfg, _ := config.Load()
+	policy, err := notify.NewPolicy(cfg.Notifications)
+	if err != nil {
+		fmt.Println("Notifications off:", err)
+		policy, _ = notify.NewPolicy(config.NotificationConfig{Off: true})
+	}
+	return policy
+}
+
+// mailboxSnapshot is what the cache held of a mailbox before a sync
+type mailboxSnapshot struct {
+	uidValidity uint32
+	uids        map[imap.UID]bool
+}
+
+// snapshotMailbox returns the cached UIDs of a mailbox, nil if it has
+// never been synced
+func snapshotMailbox(c *cache.Cache, account, mailbox string) *mailboxSnapshot {
+	meta, err := c.LoadMetadata(account, mailbox)
+	if err != nil || meta == nil {
+		return nil
+	}
+	uids, err := c.GetCachedUIDs(account, mailbox)
+	if err != nil {
+		return nil
+	}
+	return &mailboxSnapshot{uidValidity: meta.UIDValidity, uids: uids}
+}
+
+// newMail returns the mail a sync added to the cache. Nothing is new on a
+// first sync or after the server renumbered the mailbox.
+func newMail(c *cache.Cache, account, mailbox string, before *mailboxSnapshot) []cache.CachedEmail {
+	if before == nil {
+		return nil
+	}
+	meta, err := c.LoadMetadata(account, mailbox)
+	if err != nil || meta == nil || meta.UIDValidity != before.uidValidity {
+		return nil
+	}
+	emails, err := c.LoadEmails(account, mailbox)
+	if err != nil {
+		return nil
+	}
+	var added []cache.CachedEmail
+	for _, e := range emails {
+		if !before.uids[e.UID] {
+			added = append(added, e)
+		}
+	}
+	return added
+}
+
+// notifyNewMail shows notifications for new mail
+func notifyNewMail(policy *notify.Policy, account, mailbox string, added []cache.CachedEmail) {
+	for _, n := range policy.Notifications(account, mailbox, added, time.Now()) {
+		if err := notify.Send(n); err != nil {
+			fmt.Printf("Notification failed: %v\n", err)
+			return
+		}
+	}
+}
+
+// watchAccount keeps a push connection open for JMAP accounts and sends the
+// account index on pushed whenever the server reports new state
+func watchAccount(ctx context.Context, account *auth.Account, idx int, pushed chan<- int) {
+	if account.Credentials.Provider != auth.ProviderJMAP {
+		return
+	}
+
+	changed := func() {
+		select {
+		case pushed <- idx:
+		default: // a sync for this account is already queued
+		}
+	}
+
+	for {
+		client, err := mail.Dial(&account.Credentials)
+		if err == nil {
+			if pusher, ok := client.(mail.Pusher); ok {
+				err = pusher.Watch(ctx, changed)
+			}
+			client.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		fmt.Printf("Push connection for %s lost: %v\n", account.Credentials.Email, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pushRetryWait):
 		}
 	}
 }
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const launchdLabel = "com.maily.daemon"
+
+// launchdService runs the daemon as a launchd agent, started at login
+type launchdService struct {
+	path string // ~/Library/LaunchAgents/com.maily.daemon.plist
+}
+
+func newLaunchdService() *launchdService {
+	homeDir, _ := os.UserHomeDir()
+	return &launchdService{path: filepath.Join(homeDir, "Library", "LaunchAgents", launchdLabel+".plist")}
+}
+
+func (s *launchdService) Name() string {
+	return "launchd agent " + launchdLabel
+}
+
+func (s *launchdService) Files() []string {
+	return []string{s.path}
+}
+
+func (s *launchdService) Installed() bool {
+	_, err := os.Stat(s.path)
+	return err == nil
+}
+
+// domain is the launchd domain of the user's agents
+func (s *launchdService) domain() string {
+	return fmt.Sprintf("gui/%d", os.Getuid())
+}
+
+func (s *launchdService) target() string {
+	return s.domain() + "/" + launchdLabel
+}
+
+func (s *launchdService) loaded() bool {
+	return exec.Command("launchctl", "print", s.target()).Run() == nil
+}
+
+func (s *launchdService) Install(exe string) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, []byte(launchdPlist(exe)), 0644); err != nil {
+		return err
+	}
+	// Reload an agent installed before, e.g. for another binary
+	if s.loaded() {
+		runServiceCommand("launchctl", "bootout", s.target())
+	}
+	return runServiceCommand("launchctl", "bootstrap", s.domain(), s.path)
+}
+
+func (s *launchdService) Uninstall() error {
+	if s.loaded() {
+		if err := runServiceCommand("launchctl", "bootout", s.target()); err != nil {
+			return err
+		}
+	}
+	return os.Remove(s.path)
+}
+
+func (s *launchdService) Start() error {
+	if !s.loaded() {
+		return runServiceCommand("launchctl", "bootstrap", s.domain(), s.path)
+	}
+	return runServiceCommand("launchctl", "kickstart", "-k", s.target())
+}
+
+// Stop ends the daemon; it exits cleanly on SIGTERM, so launchd leaves it
+// stopped until the next login or Start
+func (s *launchdService) Stop() error {
+	return runServiceCommand("launchctl", "kill", "SIGTERM", s.target())
+}
+
+func (s *launchdService) State() string {
+	if s.loaded() {
+		return "loaded"
+	}
+	return "not loaded"
+}
+
+func (s *launchdService) Logs(n int) (string, bool) {
+	return "", false
+}
+
+// launchdPlist returns the agent running exe at login, restarted when it
+// fails
+func launchdPlist(exe string) string {
+	var path bytes.Buffer
+	xml.EscapeText(&path, []byte(exe))
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + launchdLabel + `</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>` + path.String() + `</string>
+		<string>daemon</string>
+		<string>start</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>ThrottleInterval</key>
+	<integer>30</integer>
+	<key>ProcessType</key>
+	<string>Background</string>
+</dict>
+</plist>
+`
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// daemonService runs the daemon under the system's service manager:
+// systemd on Linux, launchd on macOS
+type daemonService interface {
+	// Name describes the service, e.g. "systemd user service maily.service"
+	Name() string
+	// Files returns the files Install writes
+	Files() []string
+	Installed() bool
+	// Install writes the service files for the maily binary at exe, then
+	// enables and starts the service
+	Install(exe string) error
+	Uninstall() error
+	// Start starts the service, or restarts it if it runs
+	Start() error
+	Stop() error
+	// State describes whether the service is enabled and running
+	State() string
+	// Logs returns the last n lines the daemon logged, false if they are
+	// in the log file
+	Logs(n int) (string, bool)
+}
+
+var daemonInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Run the daemon as a systemd user service or launchd agent",
+	Long: `Run the daemon under the system's service manager, so it starts at login
+rather than when the TUI opens, and is restarted if it fails.
+
+On Linux this writes and enables a systemd user service, maily.service,
+and a socket unit, maily.socket, that starts the daemon when the TUI or
+'maily daemon sync' connects to it. The daemon logs to the journal:
+  journalctl --user -u maily.service
+
+On macOS this writes and loads a launchd agent, com.maily.daemon, that
+starts at login and logs to ~/.config/maily/daemon.log.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runDaemonInstall()
+	},
+}
+
+var daemonUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop running the daemon as a service",
+	Long:  "Stop and remove the systemd user service or launchd agent. The TUI starts the daemon itself again.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runDaemonUninstall()
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonInstallCmd)
+	daemonCmd.AddCommand(daemonUninstallCmd)
+}
+
+// newDaemonService returns the service manager of this system, nil if
+// there is none maily knows
+func newDaemonService() daemonService {
+	switch runtime.GOOS {
+	case "linux":
+		if _, err := exec.LookPath("systemctl"); err == nil {
+			return newSystemdService()
+		}
+	case "darwin":
+		return newLaunchdService()
+	}
+	return nil
+}
+
+// installedDaemonService returns the service the daemon is installed as,
+// nil if it is not
+func installedDaemonService() daemonService {
+	if svc := newDaemonService(); svc != nil && svc.Installed() {
+		return svc
+	}
+	return nil
+}
+
+func runDaemonInstall() {
+	svc := newDaemonService()
+	if svc == nil {
+		fmt.Printf("Error: no supported service manager on %s (systemd or launchd)\n", runtime.GOOS)
+		os.Exit(1)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	// A daemon the TUI started would sync next to the service
+	if !svc.Installed() && isDaemonRunning() {
+		stopDaemon()
+	}
+	if err := svc.Install(executable); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Installed the daemon as %s\n", svc.Name())
+	for _, file := range svc.Files() {
+		fmt.Println("  " + file)
+	}
+}
+
+func runDaemonUninstall() {
+	svc := installedDaemonService()
+	if svc == nil {
+		fmt.Println("The daemon is not installed as a service.")
+		return
+	}
+	if err := svc.Uninstall(); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Removed %s\n", svc.Name())
+}
+
+// runServiceCommand runs a service manager command, with its output in
+// the error if it fails
+func runServiceCommand(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s %s: %s", name, strings.Join(args, " "), msg)
+		}
+		return fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
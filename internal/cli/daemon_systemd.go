@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"maily/internal/control"
+)
+
+const systemdUnit = "maily"
+
+// systemdService runs the daemon as a systemd user service, started at
+// login and by connections to its control socket
+type systemdService struct {
+	dir string // ~/.config/systemd/user
+}
+
+func newSystemdService() *systemdService {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		homeDir, _ := os.UserHomeDir()
+		configDir = filepath.Join(homeDir, ".config")
+	}
+	return &systemdService{dir: filepath.Join(configDir, "systemd", "user")}
+}
+
+func (s *systemdService) Name() string {
+	return "systemd user service " + systemdUnit + ".service"
+}
+
+func (s *systemdService) servicePath() string {
+	return filepath.Join(s.dir, systemdUnit+".service")
+}
+
+func (s *systemdService) socketPath() string {
+	return filepath.Join(s.dir, systemdUnit+".socket")
+}
+
+func (s *systemdService) Files() []string {
+	return []string{s.servicePath(), s.socketPath()}
+}
+
+func (s *systemdService) Installed() bool {
+	_, err := os.Stat(s.servicePath())
+	return err == nil
+}
+
+func (s *systemdService) Install(exe string) error {
+	socket, err := control.SocketPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.servicePath(), []byte(systemdServiceUnit(exe)), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.socketPath(), []byte(systemdSocketUnit(socket)), 0644); err != nil {
+		return err
+	}
+	if err := s.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return s.systemctl("enable", "--now", systemdUnit+".socket", systemdUnit+".service")
+}
+
+func (s *systemdService) Uninstall() error {
+	if err := s.systemctl("disable", "--now", systemdUnit+".service", systemdUnit+".socket"); err != nil {
+		return err
+	}
+	os.Remove(s.servicePath())
+	os.Remove(s.socketPath())
+	return s.systemctl("daemon-reload")
+}
+
+func (s *systemdService) Start() error {
+	if err := s.systemctl("start", systemdUnit+".socket"); err != nil {
+		return err
+	}
+	return s.systemctl("restart", systemdUnit+".service")
+}
+
+// Stop stops the socket too, or the next connection would start the
+// daemon again
+func (s *systemdService) Stop() error {
+	return s.systemctl("stop", systemdUnit+".socket", systemdUnit+".service")
+}
+
+func (s *systemdService) State() string {
+	// is-enabled and is-active print the state and fail unless it is
+	// enabled or active
+	enabled, _ := exec.Command("systemctl", "--user", "is-enabled", systemdUnit+".service").Output()
+	active, _ := exec.Command("systemctl", "--user", "is-active", systemdUnit+".service").Output()
+	return strings.TrimSpace(string(enabled)) + ", " + strings.TrimSpace(string(active))
+}
+
+func (s *systemdService) Logs(n int) (string, bool) {
+	out, err := exec.Command("journalctl", "--user", "--unit", systemdUnit+".service",
+		"--lines", fmt.Sprint(n), "--no-pager", "--output", "short").Output()
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+func (s *systemdService) systemctl(args ...string) error {
+	return runServiceCommand("systemctl", append([]string{"--user"}, args...)...)
+}
+
+// systemdServiceUnit returns the service unit running exe. systemd sends
+// what the daemon prints to the journal.
+func systemdServiceUnit(exe string) string {
+	return `[Unit]
+Description=maily mail sync daemon
+Requires=` + systemdUnit + `.socket
+After=` + systemdUnit + `.socket
+
+[Service]
+ExecStart=` + systemdQuote(exe) + ` daemon start
+Restart=on-failure
+RestartSec=30
+
+[Install]
+WantedBy=default.target
+`
+}
+
+// systemdSocketUnit returns the socket unit that listens on the daemon's
+// control socket
+func systemdSocketUnit(socket string) string {
+	return `[Unit]
+Description=maily mail sync daemon control socket
+
+[Socket]
+ListenStream=` + strings.ReplaceAll(socket, "%", "%%") + `
+SocketMode=0600
+
+[Install]
+WantedBy=sockets.target
+`
+}
+
+// systemdQuote quotes a path for a command line in a unit file, where %
+// starts a specifier
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,337 @@
+package cli
+
+import (
+	"fmt"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"maily/config"
+	"maily/internal/ai"
+	"maily/internal/auth"
+	"maily/internal/cache"
+	"maily/internal/mail"
+)
+
+var (
+	digestSince   string
+	digestGroupBy string
+	digestOutput  string
+	digestSend    bool
+	digestNoAI    bool
+)
+
+var digestCmd = &cobra.Command{
+	Use:   "digest",
+	Short: "Catch up on unread mail with an AI digest",
+	Long: `Summarize unread mail of all accounts since a given time, grouped by thread
+or sender, with action items first. The digest reads the local cache, so
+run 'maily sync' first or keep the daemon running.
+
+The daemon can also write or send a digest every day: set "digest" in
+~/.config/maily/config.json.
+
+Examples:
+  maily digest
+  maily digest --since 2d --group-by sender
+  maily digest --since yesterday --output ~/digest.txt
+  maily digest --send`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runDigest()
+	},
+}
+
+func init() {
+	digestCmd.Flags().StringVar(&digestSince, "since", "24h", "Include mail since: a duration (12h, 3d), today, yesterday or a date (2006-01-02)")
+	digestCmd.Flags().StringVar(&digestGroupBy, "group-by", "thread", "Group emails by thread or sender")
+	digestCmd.Flags().StringVarP(&digestOutput, "output", "o", "", "Write the digest to a file instead of printing it")
+	digestCmd.Flags().BoolVar(&digestSend, "send", false, "Email the digest to yourself")
+	digestCmd.Flags().BoolVar(&digestNoAI, "no-ai", false, "List the unread emails without summarizing them")
+	rootCmd.AddCommand(digestCmd)
+}
+
+func runDigest() {
+	since, err := parseSince(digestSince, time.Now())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if digestGroupBy != "thread" && digestGroupBy != "sender" {
+		fmt.Println("Error: --group-by must be thread or sender")
+		os.Exit(1)
+	}
+
+	store, err := auth.LoadAccountStore()
+	if err != nil {
+		fmt.Println("Error loading accounts:", err)
+		os.Exit(1)
+	}
+	if len(store.Accounts) == 0 {
+		fmt.Println("No accounts configured. Run 'maily login' first.")
+		os.Exit(1)
+	}
+	c, err := cache.New()
+	if err != nil {
+		fmt.Println("Error creating cache:", err)
+		os.Exit(1)
+	}
+
+	var client *ai.Client
+	if !digestNoAI {
+		client = ai.NewTaskClient(ai.TaskSummarize)
+		if client.Available() && digestOutput == "" && !digestSend {
+			fmt.Printf("Summarizing with %s...\n\n", client.Provider())
+		}
+	}
+
+	subject, body, err := buildDigest(store, c, client, since, digestGroupBy)
+	if err != nil {
+		// The digest still lists the emails
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if err := deliverDigest(store, subject, body, digestOutput, digestSend); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if digestOutput != "" {
+		fmt.Println("✓ Digest written to", digestOutput)
+	}
+	if digestSend {
+		fmt.Println("✓ Digest sent to", store.Accounts[0].Credentials.Email)
+	}
+}
+
+// parseSince parses --since: a duration like "12h" or "3d", "today",
+// "yesterday" or a date
+func parseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch s {
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (use e.g. 12h, 3d, yesterday or 2006-01-02)", s)
+}
+
+// unreadSince returns the cached unread inbox emails of all accounts
+// received since since
+func unreadSince(store *auth.AccountStore, c *cache.Cache, since time.Time) []cache.CachedEmail {
+	var unread []cache.CachedEmail
+	for _, account := range store.Accounts {
+		emails, err := c.LoadEmails(account.Credentials.Email, "INBOX")
+		if err != nil {
+			continue
+		}
+		for _, e := range emails {
+			date := e.Date
+			if date.IsZero() {
+				date = e.InternalDate
+			}
+			if e.Unread && !date.Before(since) {
+				unread = append(unread, e)
+			}
+		}
+	}
+	return unread
+}
+
+// groupDigest groups emails by thread or by sender, most recent group first
+func groupDigest(emails []cache.CachedEmail, groupBy string) []ai.DigestGroup {
+	var groups [][]cache.CachedEmail
+	if groupBy == "sender" {
+		bySender := make(map[string][]cache.CachedEmail)
+		var senders []string
+		for _, e := range emails {
+			from := senderAddress(e.From)
+			if _, ok := bySender[from]; !ok {
+				senders = append(senders, from)
+			}
+			bySender[from] = append(bySender[from], e)
+		}
+		for _, from := range senders {
+			group := bySender[from]
+			sort.SliceStable(group, func(i, j int) bool { return group[i].Date.Before(group[j].Date) })
+			groups = append(groups, group)
+		}
+		sort.SliceStable(groups, func(i, j int) bool {
+			return groups[i][len(groups[i])-1].Date.After(groups[j][len(groups[j])-1].Date)
+		})
+	} else {
+		groups = cache.Threads(emails)
+	}
+
+	digest := make([]ai.DigestGroup, len(groups))
+	for i, group := range groups {
+		title := group[0].Subject
+		if groupBy == "sender" {
+			title = group[0].From
+		}
+		if title == "" {
+			title = "(no subject)"
+		}
+		digest[i].Title = title
+		for _, e := range group {
+			body := e.Body
+			if body == "" {
+				body = e.Snippet
+			}
+			digest[i].Messages = append(digest[i].Messages, ai.ThreadMessage{
+				From:    e.From,
+				Subject: e.Subject,
+				Date:    e.Date,
+				Body:    body,
+			})
+		}
+	}
+	return digest
+}
+
+// buildDigest returns the subject and text of a digest of unread mail since
+// since. With an available AI client the text starts with its summary; the
+// list of emails always follows. An AI error is returned with the list.
+func buildDigest(store *auth.AccountStore, c *cache.Cache, client *ai.Client, since time.Time, groupBy string) (string, string, error) {
+	emails := unreadSince(store, c, since)
+	groups := groupDigest(emails, groupBy)
+	subject := fmt.Sprintf("Digest: %d unread since %s", len(emails), since.Format("Mon Jan 2 15:04"))
+
+	if len(emails) == 0 {
+		return subject, fmt.Sprintf("No unread mail since %s.\n", since.Format("Mon Jan 2 15:04")), nil
+	}
+
+	var b strings.Builder
+	var aiErr error
+	if client != nil && client.Available() {
+		summary, err := client.Call(ai.DigestPrompt(groups, since))
+		if err != nil {
+			aiErr = fmt.Errorf("AI summary failed: %w", err)
+		} else {
+			fmt.Fprintf(&b, "%s\n\n(summarized by %s)\n\n", summary, client.Provider())
+		}
+	}
+
+	fmt.Fprintf(&b, "UNREAD (%d)\n", len(emails))
+	for i, g := range groups {
+		fmt.Fprintf(&b, "\n%d. %s\n", i+1, g.Title)
+		for _, m := range g.Messages {
+			line := m.From
+			if groupBy == "sender" {
+				line = m.Subject
+			}
+			fmt.Fprintf(&b, "   %s  %s\n", m.Date.Local().Format("Jan 2 15:04"), truncate(line, 70))
+		}
+	}
+	return subject, b.String(), aiErr
+}
+
+// deliverDigest writes the digest to output, emails it to the first
+// account, or prints it if neither is requested
+func deliverDigest(store *auth.AccountStore, subject, body, output string, send bool) error {
+	if output != "" {
+		path := expandHome(output)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(subject+"\n\n"+body), 0600); err != nil {
+			return err
+		}
+	}
+	if send {
+		creds := &store.Accounts[0].Credentials
+		if err := mail.NewSender(creds).Send(creds.Email, subject, body); err != nil {
+			return fmt.Errorf("sending digest: %w", err)
+		}
+	}
+	if output == "" && !send {
+		fmt.Print(subject + "\n\n" + body)
+	}
+	return nil
+}
+
+// runScheduledDigest builds the digest configured in config.json for the
+// last day and writes or sends it (daemon)
+func runScheduledDigest(store *auth.AccountStore, c *cache.Cache, cfg config.DigestConfig) {
+	groupBy := cfg.GroupBy
+	if groupBy != "sender" {
+		groupBy = "thread"
+	}
+	subject, body, err := buildDigest(store, c, ai.NewTaskClient(ai.TaskSummarize), time.Now().Add(-24*time.Hour), groupBy)
+	if err != nil {
+		fmt.Println("Digest:", err)
+	}
+	if cfg.Output == "" && !cfg.Send {
+		fmt.Println("Digest: set output or send in config.json")
+		return
+	}
+	if err := deliverDigest(store, subject, body, cfg.Output, cfg.Send); err != nil {
+		fmt.Println("Error delivering digest:", err)
+		return
+	}
+	fmt.Println("Digest delivered:", subject)
+}
+
+// scheduleDigest returns a channel that fires at the next digest time
+// configured in config.json, or nil if no digest is scheduled
+func scheduleDigest(cfg config.DigestConfig) <-chan time.Time {
+	if cfg.Time == "" {
+		return nil
+	}
+	next, err := nextDigest(cfg.Time, time.Now())
+	if err != nil {
+		fmt.Println("Digest:", err)
+		return nil
+	}
+	fmt.Println("Next digest at", next.Format("Mon Jan 2 15:04"))
+	return time.After(time.Until(next))
+}
+
+// nextDigest returns the next time at clock ("08:00") after now
+func nextDigest(clock string, now time.Time) (time.Time, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid digest time %q (use e.g. 08:00)", clock)
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// senderAddress returns the lowercased address of a From header, or the
+// header itself if it does not parse
+func senderAddress(from string) string {
+	if addr, err := netmail.ParseAddress(from); err == nil {
+		return strings.ToLower(addr.Address)
+	}
+	return strings.ToLower(strings.TrimSpace(from))
+}
+
+// expandHome replaces a leading ~ with the home directory
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
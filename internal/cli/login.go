@@ -12,7 +12,7 @@ import (
 var loginCmd = &cobra.Command{
 	Use:   "login [provider]",
 	Short: "Add an email account",
-	Long:  "Add an email account. Currently supports: gmail, yahoo",
+	Long:  "Add an email account. Currently supports: gmail, yahoo, outlook, jmap",
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) == 0 {
@@ -47,12 +47,18 @@ func handleLogin(provider string) {
 		loginWithProvider("gmail")
 	case "yahoo":
 		loginWithProvider("yahoo")
+	case "outlook":
+		loginWithProvider("outlook")
+	case "jmap":
+		loginWithProvider("jmap")
 	default:
 		fmt.Printf("Unknown provider: %s\n", provider)
 		fmt.Println()
 		fmt.Println("Available providers:")
 		fmt.Println("  gmail    Login with Gmail")
 		fmt.Println("  yahoo    Login with Yahoo Mail")
+		fmt.Println("  outlook  Login with Outlook.com or Microsoft 365")
+		fmt.Println("  jmap     Login with a JMAP server (Fastmail, Stalwart, ...)")
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"maily/internal/auth"
+	"maily/internal/cache"
+	"maily/internal/mail"
+	"maily/internal/rules"
+	"maily/internal/sync"
+)
+
+var rulesDryRun bool
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "List mail filtering rules",
+	Long: `List the rules in ~/.config/maily/rules.yml. The daemon and maily sync apply
+them to new mail in a mailbox after its first sync.
+
+Example rules.yml:
+
+  rules:
+    - name: newsletters
+      conditions:
+        - field: header
+          header: List-Unsubscribe
+        - field: from
+          not: true
+          is: boss@example.com
+      actions:
+        - mark_read: true
+        - move: Newsletters
+    - name: invoices
+      any: true
+      conditions:
+        - field: subject
+          matches: "invoice|receipt"
+        - field: attachments
+          contains: .pdf
+      actions:
+        - label: Invoices
+        - forward: accounting@example.com
+        - command: ~/bin/file-invoice
+      stop: true
+
+Conditions test from, to (To and Cc), subject, header, body or attachments
+(file names) with contains, is or matches (a regular expression), all
+without regard to case. Actions are move, label, mark_read, flag, delete,
+forward and command; commands get the body on stdin and $MAILY_FROM,
+$MAILY_SUBJECT and the like. A rule can be limited to one account and one
+mailbox (INBOX by default).`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runRulesList()
+	},
+}
+
+var rulesTestCmd = &cobra.Command{
+	Use:   "test <rule>",
+	Short: "Apply a rule to cached mail",
+	Long: `Apply a rule to the cached mail of its mailbox, e.g. to file mail that
+arrived before the rule was written. With --dry-run, only show which
+messages match.
+
+Examples:
+  maily rules test newsletters --dry-run
+  maily rules test newsletters`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runRulesTest(args[0], rulesDryRun)
+	},
+}
+
+var rulesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export rules as a Sieve script",
+	Long: `Print the rules as a Sieve script for servers that filter mail on delivery
+with ManageSieve. Conditions on attachments and commands have no Sieve
+equivalent and are left out.
+
+Example:
+  maily rules export > maily.sieve`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runRulesExport()
+	},
+}
+
+func init() {
+	rulesTestCmd.Flags().BoolVar(&rulesDryRun, "dry-run", false, "Only show the messages that match")
+	rulesCmd.AddCommand(rulesTestCmd)
+	rulesCmd.AddCommand(rulesExportCmd)
+	rootCmd.AddCommand(rulesCmd)
+}
+
+func loadRules() *rules.Set {
+	set, err := rules.Load()
+	if err != nil {
+		fmt.Println("Error loading rules:", err)
+		os.Exit(1)
+	}
+	return set
+}
+
+func runRulesList() {
+	set := loadRules()
+	if len(set.Rules) == 0 {
+		path, _ := rules.Path()
+		fmt.Printf("No rules. Add them to %s (see 'maily rules --help').\n", path)
+		return
+	}
+
+	for _, r := range set.Rules {
+		where := "all accounts"
+		if r.Account != "" {
+			where = r.Account
+		}
+		mailbox := r.Mailbox
+		if mailbox == "" {
+			mailbox = mail.INBOX
+		}
+		var actions []string
+		for _, a := range r.Actions {
+			actions = append(actions, a.String())
+		}
+		if r.Stop {
+			actions = append(actions, "stop")
+		}
+		fmt.Printf("  %-20s %s/%s → %s\n", r.Name, where, mailbox, strings.Join(actions, ", "))
+	}
+}
+
+func runRulesTest(name string, dryRun bool) {
+	rule := loadRules().Get(name)
+	if rule == nil {
+		fmt.Printf("Error: no rule named %q\n", name)
+		os.Exit(1)
+	}
+	mailbox := rule.Mailbox
+	if mailbox == "" {
+		mailbox = mail.INBOX
+	}
+
+	store, err := auth.LoadAccountStore()
+	if err != nil {
+		fmt.Println("Error loading accounts:", err)
+		os.Exit(1)
+	}
+	c, err := cache.New()
+	if err != nil {
+		fmt.Println("Error creating cache:", err)
+		os.Exit(1)
+	}
+
+	total := 0
+	for i := range store.Accounts {
+		account := &store.Accounts[i]
+		if !rule.Applies(account.Credentials.Email, mailbox) {
+			continue
+		}
+
+		if !dryRun {
+			syncer := sync.NewSyncer(c, account)
+			syncer.SetRules(nil, mail.NewSender(&account.Credentials), nil)
+			results, err := syncer.RunRules(mailbox, []rules.Rule{*rule})
+			if err != nil {
+				fmt.Printf("Error applying rule to %s: %v\n", account.Credentials.Email, err)
+				continue
+			}
+			for _, result := range results {
+				printRuleResult(result)
+			}
+			total += len(results)
+			continue
+		}
+
+		emails, err := c.LoadEmails(account.Credentials.Email, mailbox)
+		if err != nil {
+			continue
+		}
+		for _, e := range emails {
+			if !rule.Match(rules.FromCached(e)) {
+				continue
+			}
+			total++
+			fmt.Printf("  %s  %-25s  %s\n", e.Date.Local().Format("Jan 02 15:04"), truncate(e.From, 25), truncate(e.Subject, 50))
+		}
+	}
+
+	switch {
+	case total == 0:
+		fmt.Println("No cached messages match.")
+	case dryRun:
+		fmt.Printf("%d messages would match %q.\n", total, rule.Name)
+	}
+}
+
+// useRules has syncer apply rules.yml to new mail, reading it again on
+// each sync so edits take effect while the daemon runs
+func useRules(syncer *sync.Syncer, account *auth.Account) {
+	set, err := rules.Load()
+	if err != nil {
+		fmt.Println("Error loading rules:", err)
+		return
+	}
+	if len(set.Rules) > 0 {
+		syncer.SetRules(set, mail.NewSender(&account.Credentials), printRuleResult)
+	}
+}
+
+// printRuleResult shows what a rule did with a message
+func printRuleResult(result rules.Result) {
+	subject := truncate(result.Message.Subject, 50)
+	if result.Err != nil {
+		fmt.Printf("Rule %q failed on %q: %v\n", result.Rule, subject, result.Err)
+		return
+	}
+	fmt.Printf("Rule %q: %s → %s\n", result.Rule, subject, strings.Join(result.Done, ", "))
+}
+
+func runRulesExport() {
+	script, skipped := loadRules().Sieve()
+	fmt.Print(script)
+	for _, s := range skipped {
+		fmt.Fprintln(os.Stderr, "Skipped", s)
+	}
+}
@@ -37,7 +37,9 @@ For Gmail accounts, full Gmail search syntax is supported:
   label:important            Emails with label
 
 For other providers (Yahoo, etc.), basic text search is used:
-  Simply enter keywords to search in email body and headers.`,
+  Simply enter keywords to search in email body and headers.
+
+For JMAP accounts, the server's full-text search is used.`,
 	Example: `  maily search -a me@gmail.com -q "from:temu"
   maily search -a me@yahoo.com -q "meeting notes"`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"maily/internal/auth"
+	"maily/internal/mail"
+)
+
+var sieveAccount string
+
+var sieveCmd = &cobra.Command{
+	Use:   "sieve",
+	Short: "Manage server-side filters (ManageSieve)",
+	Long: `Manage the Sieve filter scripts on an account's ManageSieve server (Dovecot,
+Cyrus and others). The server runs the active script on delivery, so its
+filters work while maily is not running. In the TUI, /sieve opens an
+editor.
+
+The server is the IMAP host on port 4190 unless the account sets
+sieve_host and sieve_port in ~/.config/maily/accounts.yml. maily logs in
+with the account's password over STARTTLS.
+
+Examples:
+  maily sieve list
+  maily sieve get main > main.sieve
+  maily sieve check main.sieve
+  maily sieve put main main.sieve
+  maily rules export | maily sieve put maily
+  maily sieve activate main`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runSieveList()
+	},
+}
+
+var sieveListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the scripts on the server",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runSieveList()
+	},
+}
+
+var sieveGetCmd = &cobra.Command{
+	Use:   "get <script>",
+	Short: "Print a script",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runSieveGet(args[0])
+	},
+}
+
+var sievePutCmd = &cobra.Command{
+	Use:   "put <script> [file]",
+	Short: "Upload a script from a file or stdin",
+	Long: `Upload a script from a file, or from stdin if none is given, replacing the
+script of that name. The server rejects scripts that do not compile.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runSievePut(args[0], args[1:])
+	},
+}
+
+var sieveActivateCmd = &cobra.Command{
+	Use:   "activate <script>",
+	Short: "Make a script the one the server runs",
+	Long:  `Make a script the one the server runs on delivery. An empty name ("") turns filtering off.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runSieveActivate(args[0])
+	},
+}
+
+var sieveCheckCmd = &cobra.Command{
+	Use:   "check [file]",
+	Short: "Check that a script compiles on the server",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runSieveCheck(args)
+	},
+}
+
+func init() {
+	sieveCmd.PersistentFlags().StringVar(&sieveAccount, "account", "", "Account email (default: first account)")
+	sieveCmd.AddCommand(sieveListCmd)
+	sieveCmd.AddCommand(sieveGetCmd)
+	sieveCmd.AddCommand(sievePutCmd)
+	sieveCmd.AddCommand(sieveActivateCmd)
+	sieveCmd.AddCommand(sieveCheckCmd)
+	rootCmd.AddCommand(sieveCmd)
+}
+
+// dialSieve connects to the ManageSieve server of the --account account
+func dialSieve() *mail.SieveClient {
+	store, err := auth.LoadAccountStore()
+	if err != nil {
+		fmt.Println("Error loading accounts:", err)
+		os.Exit(1)
+	}
+	if len(store.Accounts) == 0 {
+		fmt.Println("No accounts configured. Run 'maily login' first.")
+		os.Exit(1)
+	}
+
+	account := &store.Accounts[0]
+	if sieveAccount != "" {
+		if account = store.GetAccount(sieveAccount); account == nil {
+			fmt.Printf("Error: no account %s\n", sieveAccount)
+			os.Exit(1)
+		}
+	}
+
+	client, err := mail.NewSieveClient(&account.Credentials)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return client
+}
+
+// readScript reads a script from the file in args, or stdin
+func readScript(args []string) string {
+	var data []byte
+	var err error
+	if len(args) > 0 {
+		data, err = os.ReadFile(args[0])
+	} else {
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		fmt.Println("Error reading script:", err)
+		os.Exit(1)
+	}
+	return string(data)
+}
+
+func runSieveList() {
+	client := dialSieve()
+	defer client.Close()
+
+	scripts, err := client.Scripts()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(scripts) == 0 {
+		fmt.Println("No scripts on the server.")
+	}
+	for _, s := range scripts {
+		if s.Active {
+			fmt.Printf("  %s (active)\n", s.Name)
+		} else {
+			fmt.Printf("  %s\n", s.Name)
+		}
+	}
+	fmt.Println()
+	if impl := client.Implementation(); impl != "" {
+		fmt.Printf("  Server:     %s\n", impl)
+	}
+	fmt.Printf("  Extensions: %s\n", strings.Join(client.Extensions(), " "))
+}
+
+func runSieveGet(name string) {
+	client := dialSieve()
+	defer client.Close()
+
+	script, err := client.Script(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(script)
+}
+
+func runSievePut(name string, args []string) {
+	script := readScript(args)
+	client := dialSieve()
+	defer client.Close()
+
+	warnings, err := client.PutScript(name, script)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	printSieveWarnings(warnings)
+	fmt.Printf("✓ Uploaded %s\n", name)
+}
+
+func runSieveActivate(name string) {
+	client := dialSieve()
+	defer client.Close()
+
+	if err := client.SetActive(name); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if name == "" {
+		fmt.Println("✓ Filtering turned off")
+	} else {
+		fmt.Printf("✓ %s is now active\n", name)
+	}
+}
+
+func runSieveCheck(args []string) {
+	script := readScript(args)
+	client := dialSieve()
+	defer client.Close()
+
+	warnings, err := client.CheckScript(script)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	printSieveWarnings(warnings)
+	fmt.Println("✓ Script is valid")
+}
+
+func printSieveWarnings(warnings string) {
+	if warnings != "" {
+		fmt.Printf("Warning: %s\n", warnings)
+	}
+}
@@ -51,6 +51,7 @@ func runSync() {
 		fmt.Printf("  Syncing %s...", account.Credentials.Email)
 
 		syncer := sync.NewSyncer(c, account)
+		useRules(syncer, account)
 		if err := syncer.FullSync("INBOX"); err != nil {
 			fmt.Printf(" error: %v\n", err)
 		} else {
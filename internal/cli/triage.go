@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"maily/internal/ai"
+	"maily/internal/auth"
+	"maily/internal/cache"
+	"maily/internal/sync"
+)
+
+// triageLimit caps how many emails one sync classifies per account
+const triageLimit = 50
+
+var (
+	triageAllow []string
+	triageDeny  []string
+)
+
+var triageCmd = &cobra.Command{
+	Use:   "triage",
+	Short: "AI triage of new mail",
+	Long: `With triage enabled for an account, the daemon classifies new mail after
+each sync: a category (action, fyi, personal, notification, newsletter) and a
+priority from 1 (high) to 3 (low). Each message is classified once. In the
+mail list, T filters by category and P sorts by priority.
+
+Only accounts that opt in are classified. Allow and deny lists of addresses
+or domains keep senders from being sent to the AI.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runTriageStatus()
+	},
+}
+
+var triageEnableCmd = &cobra.Command{
+	Use:   "enable [account]",
+	Short: "Enable triage for an account",
+	Long: `Enable triage for an account (the first account if none is given).
+--allow and --deny add senders to its lists.
+
+Examples:
+  maily triage enable
+  maily triage enable me@example.com --deny bank.com --deny doctor@clinic.org
+  maily triage enable work@example.com --allow example.com`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runTriageEnable(args, true)
+	},
+}
+
+var triageDisableCmd = &cobra.Command{
+	Use:   "disable [account]",
+	Short: "Disable triage for an account",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runTriageEnable(args, false)
+	},
+}
+
+var triageRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Classify new cached mail now",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runTriageNow()
+	},
+}
+
+func init() {
+	triageEnableCmd.Flags().StringSliceVar(&triageAllow, "allow", nil, "Only classify mail from these addresses or domains")
+	triageEnableCmd.Flags().StringSliceVar(&triageDeny, "deny", nil, "Never classify mail from these addresses or domains")
+	triageCmd.AddCommand(triageEnableCmd)
+	triageCmd.AddCommand(triageDisableCmd)
+	triageCmd.AddCommand(triageRunCmd)
+	rootCmd.AddCommand(triageCmd)
+}
+
+func runTriageStatus() {
+	store, err := auth.LoadAccountStore()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(store.Accounts) == 0 {
+		fmt.Println("No accounts configured. Run 'maily login' first.")
+		return
+	}
+
+	fmt.Println()
+	for _, acc := range store.Accounts {
+		t := acc.Triage
+		if t == nil || !t.Enabled {
+			fmt.Printf("  %s: off\n", acc.Credentials.Email)
+			continue
+		}
+		fmt.Printf("  %s: on\n", acc.Credentials.Email)
+		if len(t.Allow) > 0 {
+			fmt.Printf("    allow: %s\n", strings.Join(t.Allow, ", "))
+		}
+		if len(t.Deny) > 0 {
+			fmt.Printf("    deny:  %s\n", strings.Join(t.Deny, ", "))
+		}
+	}
+	fmt.Println()
+	fmt.Printf("  AI: %s\n\n", ai.NewTaskClient(ai.TaskTriage).Provider())
+}
+
+func runTriageEnable(args []string, enabled bool) {
+	store, err := auth.LoadAccountStore()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(store.Accounts) == 0 {
+		fmt.Println("No accounts configured. Run 'maily login' first.")
+		os.Exit(1)
+	}
+
+	account := &store.Accounts[0]
+	if len(args) == 1 {
+		if account = store.GetAccount(args[0]); account == nil {
+			fmt.Printf("Error: no account %s\n", args[0])
+			os.Exit(1)
+		}
+	}
+
+	if account.Triage == nil {
+		account.Triage = &auth.TriageConfig{}
+	}
+	account.Triage.Enabled = enabled
+	account.Triage.Allow = appendNew(account.Triage.Allow, triageAllow)
+	account.Triage.Deny = appendNew(account.Triage.Deny, triageDeny)
+	if err := store.Save(); err != nil {
+		fmt.Printf("Error saving accounts: %v\n", err)
+		os.Exit(1)
+	}
+
+	if enabled {
+		fmt.Printf("✓ Triage enabled for %s; the daemon classifies new mail after each sync\n", account.Credentials.Email)
+	} else {
+		fmt.Printf("✓ Triage disabled for %s\n", account.Credentials.Email)
+	}
+}
+
+func runTriageNow() {
+	store, err := auth.LoadAccountStore()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	c, err := cache.New()
+	if err != nil {
+		fmt.Println("Error creating cache:", err)
+		os.Exit(1)
+	}
+	for i := range store.Accounts {
+		triageAccount(&store.Accounts[i], c)
+	}
+}
+
+// triageAccount classifies new cached inbox mail of an account that opted
+// in to triage (daemon and 'maily triage run')
+func triageAccount(account *auth.Account, c *cache.Cache) {
+	if account.Triage == nil || !account.Triage.Enabled {
+		return
+	}
+	client := ai.NewTaskClient(ai.TaskTriage)
+	if !client.Available() {
+		fmt.Printf("Triage for %s skipped: no AI provider\n", account.Credentials.Email)
+		return
+	}
+
+	n, err := sync.NewSyncer(c, account).Triage("INBOX", aiClassifier(client), triageLimit)
+	if err != nil {
+		fmt.Printf("Error triaging %s: %v\n", account.Credentials.Email, err)
+	}
+	if n > 0 {
+		fmt.Printf("Triaged %d emails of %s with %s\n", n, account.Credentials.Email, client.Provider())
+	}
+}
+
+// aiClassifier classifies emails with the triage prompt
+func aiClassifier(client *ai.Client) sync.Classifier {
+	return func(email cache.CachedEmail) (*cache.Triage, error) {
+		body := email.Body
+		if body == "" {
+			body = email.Snippet
+		}
+		response, err := client.Call(ai.TriagePrompt(email.From, email.Subject, truncate(body, 2000)))
+		if err != nil {
+			return nil, err
+		}
+		t, err := ai.ParseTriageResponse(response)
+		if err != nil {
+			return nil, err
+		}
+		return &cache.Triage{Category: t.Category, Priority: t.Priority}, nil
+	}
+}
+
+// appendNew appends the values of add that list does not have yet
+func appendNew(list, add []string) []string {
+	for _, v := range add {
+		v = strings.TrimSpace(v)
+		found := v == ""
+		for _, have := range list {
+			found = found || strings.EqualFold(have, v)
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
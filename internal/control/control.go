@@ -0,0 +1,190 @@
+// Package control is the daemon's control socket: a Unix domain socket over
+// which the TUI and CLI ask the daemon to sync now, query its status and
+// subscribe to its events. Requests and replies are JSON, one per line.
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+
+	"maily/config"
+)
+
+const socketFileName = "daemon.sock"
+
+// statusTimeout limits how long a status request may take; a sync runs as
+// long as the daemon needs
+const statusTimeout = 5 * time.Second
+
+// Operations a request can ask for
+const (
+	OpSync      = "sync"
+	OpStatus    = "status"
+	OpSubscribe = "subscribe"
+)
+
+// Event types
+const (
+	EventNewMail      = "new_mail"
+	EventSyncFinished = "sync_finished"
+)
+
+// ErrNotRunning is returned when no daemon listens on the socket
+var ErrNotRunning = errors.New("daemon is not running")
+
+// Request is what a client sends
+type Request struct {
+	Op      string `json:"op"`
+	Account string `json:"account,omitempty"` // sync: empty for all accounts
+	Mailbox string `json:"mailbox,omitempty"` // sync: empty for the daemon's usual mailboxes
+}
+
+// Reply answers a request; a subscription is followed by events
+type Reply struct {
+	OK     bool    `json:"ok"`
+	Error  string  `json:"error,omitempty"`
+	Status *Status `json:"status,omitempty"`
+}
+
+// Status is the state of the daemon
+type Status struct {
+	PID           int             `json:"pid"`
+	Version       string          `json:"version"`
+	Started       time.Time       `json:"started"`
+	MaxConcurrent int             `json:"max_concurrent"` // accounts syncing at once
+	Accounts      []AccountStatus `json:"accounts"`
+}
+
+// AccountStatus is the sync state and health of one account
+type AccountStatus struct {
+	Email     string        `json:"email"`
+	Syncing   bool          `json:"syncing,omitempty"`
+	LastSync  time.Time     `json:"last_sync"`  // last sync that succeeded
+	LastError string        `json:"last_error"` // of the last sync, empty if it succeeded
+	ErrorAt   time.Time     `json:"error_at"`
+	Failures  int           `json:"failures"` // syncs in a row that failed
+	Interval  time.Duration `json:"interval"`
+	NextRun   time.Time     `json:"next_run"` // later than the interval while syncs fail
+}
+
+// Event is something that happened in the daemon
+type Event struct {
+	Type    string    `json:"type"`
+	Account string    `json:"account"`
+	Mailbox string    `json:"mailbox,omitempty"`
+	Count   int       `json:"count,omitempty"` // new_mail: messages added
+	Error   string    `json:"error,omitempty"` // sync_finished: why it failed
+	Time    time.Time `json:"time"`
+}
+
+// SocketPath returns the path of the control socket
+func SocketPath() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, socketFileName), nil
+}
+
+// dial connects to the daemon and sends req
+func dial(req Request) (net.Conn, *bufio.Reader, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, nil, ErrNotRunning
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, bufio.NewReader(conn), nil
+}
+
+// readReply reads the reply to a request
+func readReply(r *bufio.Reader) (*Reply, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("no reply from daemon: %w", err)
+	}
+	var reply Reply
+	if err := json.Unmarshal(line, &reply); err != nil {
+		return nil, fmt.Errorf("invalid reply from daemon: %w", err)
+	}
+	if !reply.OK {
+		return nil, errors.New(reply.Error)
+	}
+	return &reply, nil
+}
+
+// Sync asks the daemon to sync a mailbox of an account now and waits until
+// it has. An empty account syncs all accounts, an empty mailbox the ones
+// the daemon usually syncs.
+func Sync(account, mailbox string) error {
+	conn, r, err := dial(Request{Op: OpSync, Account: account, Mailbox: mailbox})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = readReply(r)
+	return err
+}
+
+// GetStatus asks the daemon for its status
+func GetStatus() (*Status, error) {
+	conn, r, err := dial(Request{Op: OpStatus})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(statusTimeout))
+	reply, err := readReply(r)
+	if err != nil {
+		return nil, err
+	}
+	if reply.Status == nil {
+		return nil, fmt.Errorf("daemon sent no status")
+	}
+	return reply.Status, nil
+}
+
+// Subscription receives the daemon's events
+type Subscription struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Subscribe starts receiving the daemon's events
+func Subscribe() (*Subscription, error) {
+	conn, r, err := dial(Request{Op: OpSubscribe})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := readReply(r); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Subscription{conn: conn, r: r}, nil
+}
+
+// Next waits for the next event; it fails once the daemon stops
+func (s *Subscription) Next() (Event, error) {
+	var event Event
+	line, err := s.r.ReadBytes('\n')
+	if err != nil {
+		return event, err
+	}
+	err = json.Unmarshal(line, &event)
+	return event, err
+}
+
+func (s *Subscription) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,95 @@
+package control
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".config", "maily"), 0700)
+
+	if _, err := GetStatus(); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("GetStatus without daemon = %v, want ErrNotRunning", err)
+	}
+
+	path, _ := SocketPath()
+	// A socket left behind by a daemon that died
+	os.WriteFile(path, nil, 0600)
+
+	server := NewServer("1.0", 2, []string{"a@x.com", "b@x.com"})
+	if err := server.Listen(path); err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer server.Close()
+
+	if err := NewServer("1.0", 1, nil).Listen(path); err == nil {
+		t.Error("second daemon could listen on the socket")
+	}
+
+	// The daemon's side: sync b@x.com, which finds 2 new messages, and
+	// fail on a@x.com
+	go func() {
+		for req := range server.Syncs() {
+			server.SyncStarted(req.Account)
+			var err error
+			if req.Account == "a@x.com" {
+				err = errors.New("login failed")
+			} else {
+				server.NewMail(req.Account, req.Mailbox, 2)
+			}
+			server.SyncFinished(req.Account, req.Mailbox, err)
+			if err != nil {
+				server.Scheduled(req.Account, time.Hour, time.Now().Add(time.Minute), 1)
+			}
+			req.Reply(err)
+		}
+	}()
+
+	sub, err := Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	if err := Sync("b@x.com", "INBOX"); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if err := Sync("a@x.com", ""); err == nil || err.Error() != "login failed" {
+		t.Errorf("Sync of failing account = %v", err)
+	}
+
+	for _, want := range []Event{
+		{Type: EventNewMail, Account: "b@x.com", Mailbox: "INBOX", Count: 2},
+		{Type: EventSyncFinished, Account: "b@x.com", Mailbox: "INBOX"},
+		{Type: EventSyncFinished, Account: "a@x.com", Error: "login failed"},
+	} {
+		got, err := sub.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got.Time = want.Time
+		if got != want {
+			t.Errorf("event = %+v, want %+v", got, want)
+		}
+	}
+
+	status, err := GetStatus()
+	if err != nil {
+		t.Fatalf("GetStatus: %v", err)
+	}
+	if status.Version != "1.0" || status.PID != os.Getpid() || len(status.Accounts) != 2 {
+		t.Fatalf("status = %+v", status)
+	}
+	a, b := status.Accounts[0], status.Accounts[1]
+	if a.LastError != "login failed" || !a.LastSync.IsZero() || a.Failures != 1 || a.Interval != time.Hour {
+		t.Errorf("a@x.com = %+v", a)
+	}
+	if b.LastError != "" || b.LastSync.IsZero() || b.Syncing {
+		t.Errorf("b@x.com = %+v", b)
+	}
+}
@@ -0,0 +1,303 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before it misses some
+const subscriberBuffer = 64
+
+// SyncRequest is a client asking the daemon to sync now
+type SyncRequest struct {
+	Account string
+	Mailbox string
+	done    chan error
+}
+
+// Reply tells the client the sync is done; err is why it failed
+func (r SyncRequest) Reply(err error) {
+	r.done <- err
+}
+
+// Server serves the control socket. The daemon runs the syncs it is asked
+// for and reports what it does, which the server keeps as its status and
+// passes on to subscribers.
+type Server struct {
+	listener net.Listener
+	syncs    chan SyncRequest
+	closed   chan struct{}
+
+	mu          sync.Mutex
+	status      Status
+	subscribers map[chan Event]bool
+}
+
+// NewServer returns a server for a daemon of version syncing accounts, at
+// most maxConcurrent at once
+func NewServer(version string, maxConcurrent int, accounts []string) *Server {
+	s := &Server{
+		syncs:       make(chan SyncRequest),
+		closed:      make(chan struct{}),
+		subscribers: make(map[chan Event]bool),
+		status: Status{
+			PID:           os.Getpid(),
+			Version:       version,
+			Started:       time.Now(),
+			MaxConcurrent: maxConcurrent,
+		},
+	}
+	for _, email := range accounts {
+		s.status.Accounts = append(s.status.Accounts, AccountStatus{Email: email})
+	}
+	return s
+}
+
+// Listen opens the socket at path and serves it until Close. A socket
+// left behind by a daemon that died is replaced.
+func (s *Server) Listen(path string) error {
+	listener, err := net.Listen("unix", path)
+	if err != nil && errors.Is(err, syscall.EADDRINUSE) {
+		if conn, dialErr := net.Dial("unix", path); dialErr == nil {
+			conn.Close()
+			return fmt.Errorf("another daemon is listening on %s", path)
+		}
+		os.Remove(path)
+		listener, err = net.Listen("unix", path)
+	}
+	if err != nil {
+		return err
+	}
+	os.Chmod(path, 0600)
+	s.Serve(listener)
+	return nil
+}
+
+// Serve serves an open socket, e.g. one passed by systemd, until Close
+func (s *Server) Serve(listener net.Listener) {
+	s.listener = listener
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+}
+
+// Activated returns the socket systemd passed on socket activation, nil if
+// the daemon was not started that way
+func Activated() net.Listener {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil
+	}
+	// Children must not take the socket for theirs
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	// Passed sockets start at file descriptor 3
+	f := os.NewFile(3, "daemon.sock")
+	defer f.Close()
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return nil
+	}
+	return listener
+}
+
+// Close stops serving. A socket Listen opened is removed; one systemd
+// passed stays for the next activation.
+func (s *Server) Close() error {
+	close(s.closed)
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// Syncs returns the sync requests of clients. Each must be replied to.
+func (s *Server) Syncs() <-chan SyncRequest {
+	return s.syncs
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		writeReply(conn, Reply{Error: "invalid request: " + err.Error()})
+		return
+	}
+
+	switch req.Op {
+	case OpStatus:
+		status := s.Status()
+		writeReply(conn, Reply{OK: true, Status: &status})
+	case OpSync:
+		writeReply(conn, toReply(s.sync(req)))
+	case OpSubscribe:
+		s.subscribe(conn)
+	default:
+		writeReply(conn, Reply{Error: fmt.Sprintf("unknown operation %q", req.Op)})
+	}
+}
+
+// sync hands a sync request to the daemon and waits for it to be done
+func (s *Server) sync(req Request) error {
+	sr := SyncRequest{Account: req.Account, Mailbox: req.Mailbox, done: make(chan error, 1)}
+	select {
+	case s.syncs <- sr:
+	case <-s.closed:
+		return ErrNotRunning
+	}
+	select {
+	case err := <-sr.done:
+		return err
+	case <-s.closed:
+		return ErrNotRunning
+	}
+}
+
+// subscribe sends events to conn until the client goes away
+func (s *Server) subscribe(conn net.Conn) {
+	events := make(chan Event, subscriberBuffer)
+	s.mu.Lock()
+	s.subscribers[events] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, events)
+		s.mu.Unlock()
+	}()
+
+	// Subscribers send nothing more; a read ending means they left
+	gone := make(chan struct{})
+	go func() {
+		conn.Read(make([]byte, 1))
+		close(gone)
+	}()
+
+	if writeReply(conn, Reply{OK: true}) != nil {
+		return
+	}
+	enc := json.NewEncoder(conn)
+	for {
+		select {
+		case event := <-events:
+			if enc.Encode(event) != nil {
+				return
+			}
+		case <-gone:
+			return
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+func writeReply(conn net.Conn, reply Reply) error {
+	return json.NewEncoder(conn).Encode(reply)
+}
+
+func toReply(err error) Reply {
+	if err != nil {
+		return Reply{Error: err.Error()}
+	}
+	return Reply{OK: true}
+}
+
+// Status returns the daemon's status
+func (s *Server) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	status.Accounts = append([]AccountStatus(nil), s.status.Accounts...)
+	return status
+}
+
+// Scheduled records when the daemon syncs account next, and how many of
+// its syncs in a row failed
+func (s *Server) Scheduled(account string, interval time.Duration, next time.Time, failures int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if acc := s.account(account); acc != nil {
+		acc.Interval, acc.NextRun, acc.Failures = interval, next, failures
+	}
+}
+
+// SyncStarted records that the daemon is syncing account
+func (s *Server) SyncStarted(account string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if acc := s.account(account); acc != nil {
+		acc.Syncing = true
+	}
+}
+
+// SyncFinished records that a sync of account is done and tells
+// subscribers; err is why it failed
+func (s *Server) SyncFinished(account, mailbox string, err error) {
+	now := time.Now()
+	event := Event{Type: EventSyncFinished, Account: account, Mailbox: mailbox, Time: now}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if acc := s.account(account); acc != nil {
+		acc.Syncing = false
+		if err != nil {
+			acc.LastError, acc.ErrorAt = err.Error(), now
+		} else {
+			acc.LastSync, acc.LastError = now, ""
+		}
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	s.publish(event)
+}
+
+// NewMail tells subscribers that a sync added count messages to a mailbox
+func (s *Server) NewMail(account, mailbox string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publish(Event{Type: EventNewMail, Account: account, Mailbox: mailbox, Count: count, Time: time.Now()})
+}
+
+// publish sends event to every subscriber that keeps up; s.mu is held
+func (s *Server) publish(event Event) {
+	for events := range s.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// account returns the status of an account; s.mu is held
+func (s *Server) account(email string) *AccountStatus {
+	for i := range s.status.Accounts {
+		if s.status.Accounts[i].Email == email {
+			return &s.status.Accounts[i]
+		}
+	}
+	return nil
+}
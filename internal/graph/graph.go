@@ -0,0 +1,270 @@
+// Package graph is a small Microsoft Graph REST client shared by the
+// Outlook mail and calendar backends.
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"maily/internal/auth"
+)
+
+// DefaultBaseURL is the Graph API root. MAILY_GRAPH_URL overrides it, e.g.
+// to point maily at a local stub server.
+const DefaultBaseURL = "https://graph.microsoft.com/v1.0"
+
+// prefer is sent with every request: message bodies as plain text, times in
+// UTC and ids that stay the same when a message moves between folders
+const prefer = `outlook.body-content-type="text", outlook.timezone="UTC", IdType="ImmutableId"`
+
+// MaxBatch is the most requests Graph takes in one JSON batch
+const MaxBatch = 20
+
+// Client sends authenticated requests to Microsoft Graph
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// Error is a non-2xx Graph response
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("graph: %s: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("graph: HTTP %d", e.Status)
+}
+
+// New returns a client authenticated as the given OAuth account
+func New(creds *auth.Credentials) (*Client, error) {
+	ts, err := auth.TokenSource(creds)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithTokenSource(ts), nil
+}
+
+// NewWithTokenSource returns a client authorized by ts, e.g. a token that was
+// just issued during login
+func NewWithTokenSource(ts oauth2.TokenSource) *Client {
+	httpClient := oauth2.NewClient(context.Background(), ts)
+	httpClient.Timeout = 60 * time.Second
+
+	baseURL := os.Getenv("MAILY_GRAPH_URL")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return NewWithHTTP(baseURL, httpClient)
+}
+
+// NewWithHTTP returns a client that sends requests through httpClient, which
+// is expected to add authorization itself
+func NewWithHTTP(baseURL string, httpClient *http.Client) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), http: httpClient}
+}
+
+// Get decodes the response of a GET request into result
+func (c *Client) Get(path string, result any) error {
+	return c.Do(http.MethodGet, path, nil, result)
+}
+
+// Post sends body as JSON and decodes the response into result (may be nil)
+func (c *Client) Post(path string, body, result any) error {
+	return c.Do(http.MethodPost, path, body, result)
+}
+
+// Patch sends body as JSON and decodes the response into result (may be nil)
+func (c *Client) Patch(path string, body, result any) error {
+	return c.Do(http.MethodPatch, path, body, result)
+}
+
+// Delete sends a DELETE request
+func (c *Client) Delete(path string) error {
+	return c.Do(http.MethodDelete, path, nil, nil)
+}
+
+// Do sends a request. path is relative to the API root, or an absolute
+// @odata.nextLink.
+func (c *Client) Do(method, path string, body, result any) error {
+	resp, err := c.send(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// GetRaw returns the body of a GET request as is, e.g. a message's MIME
+// source from /$value
+func (c *Client) GetRaw(path string) ([]byte, error) {
+	resp, err := c.send(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// send sends a request and turns a non-2xx response into an *Error
+func (c *Client) send(method, path string, body any) (*http.Response, error) {
+	url := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		url = c.baseURL + path
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Prefer", prefer)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		var errBody struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errBody)
+		return nil, &Error{Status: resp.StatusCode, Code: errBody.Error.Code, Message: errBody.Error.Message}
+	}
+	return resp, nil
+}
+
+// BatchRequest is one request of a JSON batch; URL is relative to the API
+// root
+type BatchRequest struct {
+	ID      string            `json:"id"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// BatchResponse answers the request with the same ID
+type BatchResponse struct {
+	ID     string          `json:"id"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// Err returns the error of a non-2xx response, nil otherwise
+func (r BatchResponse) Err() error {
+	if r.Status >= 200 && r.Status <= 299 {
+		return nil
+	}
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(r.Body, &body)
+	return &Error{Status: r.Status, Code: body.Error.Code, Message: body.Error.Message}
+}
+
+// Batch sends up to MaxBatch requests at once through $batch. Responses come
+// in any order; each has the status of its own request.
+func (c *Client) Batch(requests []BatchRequest) ([]BatchResponse, error) {
+	if len(requests) > MaxBatch {
+		return nil, fmt.Errorf("graph: %d requests in one batch, at most %d allowed", len(requests), MaxBatch)
+	}
+	// Headers of the batch do not apply to the requests in it
+	for i := range requests {
+		if requests[i].Headers == nil {
+			requests[i].Headers = make(map[string]string)
+		}
+		requests[i].Headers["Prefer"] = prefer
+	}
+
+	var result struct {
+		Responses []BatchResponse `json:"responses"`
+	}
+	if err := c.Post("/$batch", map[string]any{"requests": requests}, &result); err != nil {
+		return nil, err
+	}
+	return result.Responses, nil
+}
+
+// List fetches a collection, following @odata.nextLink until limit items
+// (0 for all) have been read
+func List[T any](c *Client, path string, limit int) ([]T, error) {
+	var items []T
+	for path != "" {
+		var page struct {
+			Value    []T    `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		if err := c.Get(path, &page); err != nil {
+			return nil, err
+		}
+
+		items = append(items, page.Value...)
+		if limit > 0 && len(items) >= limit {
+			return items[:limit], nil
+		}
+		path = page.NextLink
+	}
+	return items, nil
+}
+
+// Delta runs a delta query from path, or from the @odata.deltaLink of an
+// earlier round, and returns what changed since along with the link for
+// the next round
+func Delta[T any](c *Client, path string) ([]T, string, error) {
+	var items []T
+	for {
+		var page struct {
+			Value     []T    `json:"value"`
+			NextLink  string `json:"@odata.nextLink"`
+			DeltaLink string `json:"@odata.deltaLink"`
+		}
+		if err := c.Get(path, &page); err != nil {
+			return nil, "", err
+		}
+
+		items = append(items, page.Value...)
+		if page.NextLink == "" {
+			if page.DeltaLink == "" {
+				return nil, "", fmt.Errorf("graph: delta query returned no delta link")
+			}
+			return items, page.DeltaLink, nil
+		}
+		path = page.NextLink
+	}
+}
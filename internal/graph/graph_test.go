@@ -0,0 +1,174 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type item struct {
+	ID string `json:"id"`
+}
+
+// pages serves items one per page at /items, linking each page to the next;
+// the last page links to the delta round if delta is set
+func pages(t *testing.T, ids []string, delta bool) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if prefer := r.Header.Get("Prefer"); !strings.Contains(prefer, `IdType="ImmutableId"`) {
+			t.Errorf("Prefer = %q, want immutable ids", prefer)
+		}
+		skip := 0
+		fmt.Sscan(r.URL.Query().Get("skip"), &skip)
+		page := map[string]any{"value": []item{{ID: ids[skip]}}}
+		if skip+1 < len(ids) {
+			page["@odata.nextLink"] = fmt.Sprintf("%s/items?skip=%d", server.URL, skip+1)
+		} else if delta {
+			page["@odata.deltaLink"] = server.URL + "/items?token=next"
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestList(t *testing.T) {
+	server := pages(t, []string{"a", "b", "c"}, false)
+	c := NewWithHTTP(server.URL+"/", server.Client())
+
+	items, err := List[item](c, "/items", 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(items) != 3 || items[2].ID != "c" {
+		t.Errorf("items = %v, want all three pages", items)
+	}
+
+	items, err = List[item](c, "/items", 2)
+	if err != nil || len(items) != 2 {
+		t.Errorf("List with limit 2 = %v, %v", items, err)
+	}
+}
+
+func TestDelta(t *testing.T) {
+	server := pages(t, []string{"a", "b"}, true)
+	c := NewWithHTTP(server.URL, server.Client())
+
+	items, link, err := Delta[item](c, "/items")
+	if err != nil {
+		t.Fatalf("Delta: %v", err)
+	}
+	if len(items) != 2 || link != server.URL+"/items?token=next" {
+		t.Errorf("Delta = %v, %q", items, link)
+	}
+
+	// A round must end with a delta link to continue from
+	plain := pages(t, []string{"a"}, false)
+	if _, _, err := Delta[item](NewWithHTTP(plain.URL, plain.Client()), "/items"); err == nil {
+		t.Error("Delta accepted a round without a delta link")
+	}
+}
+
+func TestTokenRefresh(t *testing.T) {
+	var mu sync.Mutex
+	refreshes := 0
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "refresh" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		refreshes++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "fresh", "token_type": "Bearer", "expires_in": 3600})
+	}))
+	defer tokens.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{
+				"code": "InvalidAuthenticationToken", "message": "Access token has expired",
+			}})
+			return
+		}
+		json.NewEncoder(w).Encode(item{ID: "me"})
+	}))
+	defer api.Close()
+	t.Setenv("MAILY_GRAPH_URL", api.URL)
+
+	// The stored access token has expired, so the client refreshes it once
+	cfg := &oauth2.Config{ClientID: "maily", Endpoint: oauth2.Endpoint{TokenURL: tokens.URL}}
+	expired := &oauth2.Token{AccessToken: "stale", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)}
+	c := NewWithTokenSource(cfg.TokenSource(context.Background(), expired))
+	for range 2 {
+		var me item
+		if err := c.Get("/me", &me); err != nil || me.ID != "me" {
+			t.Fatalf("Get = %v, %v", me, err)
+		}
+	}
+	if refreshes != 1 {
+		t.Errorf("token refreshed %d times, want 1", refreshes)
+	}
+
+	// A rejected token surfaces as a Graph error
+	err := NewWithHTTP(api.URL, api.Client()).Get("/me", nil)
+	var gerr *Error
+	if !errors.As(err, &gerr) || gerr.Status != http.StatusUnauthorized || gerr.Code != "InvalidAuthenticationToken" {
+		t.Errorf("Get without token = %v, want 401 InvalidAuthenticationToken", err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch struct {
+			Requests []BatchRequest `json:"requests"`
+		}
+		json.NewDecoder(r.Body).Decode(&batch)
+		responses := []map[string]any{}
+		for _, req := range batch.Requests {
+			if !strings.Contains(req.Headers["Prefer"], `IdType="ImmutableId"`) {
+				t.Errorf("request %s has Prefer %q", req.ID, req.Headers["Prefer"])
+			}
+			if req.URL == "/items/gone" {
+				responses = append(responses, map[string]any{"id": req.ID, "status": 404,
+					"body": map[string]any{"error": map[string]string{"code": "ErrorItemNotFound"}}})
+			} else {
+				responses = append(responses, map[string]any{"id": req.ID, "status": 200, "body": item{ID: req.URL}})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"responses": responses})
+	}))
+	defer server.Close()
+	c := NewWithHTTP(server.URL, server.Client())
+
+	responses, err := c.Batch([]BatchRequest{
+		{ID: "1", Method: http.MethodGet, URL: "/items/a"},
+		{ID: "2", Method: http.MethodGet, URL: "/items/gone"},
+	})
+	if err != nil || len(responses) != 2 {
+		t.Fatalf("Batch = %v, %v", responses, err)
+	}
+	if responses[0].Err() != nil || !strings.Contains(string(responses[0].Body), "/items/a") {
+		t.Errorf("first response = %+v", responses[0])
+	}
+	var gerr *Error
+	if err := responses[1].Err(); !errors.As(err, &gerr) || gerr.Status != 404 || gerr.Code != "ErrorItemNotFound" {
+		t.Errorf("second response error = %v", err)
+	}
+
+	if _, err := c.Batch(make([]BatchRequest, MaxBatch+1)); err == nil {
+		t.Error("Batch accepted more than MaxBatch requests")
+	}
+}
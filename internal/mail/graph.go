@@ -0,0 +1,687 @@
+package mail
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+
+	"maily/internal/auth"
+	"maily/internal/graph"
+)
+
+// graphPageSize is the $top used when listing folders and messages
+const graphPageSize = 100
+
+// graphDeltaDays limits delta queries to recent mail; it matches the sync
+// window in internal/sync
+const graphDeltaDays = 14
+
+// graphMessageFields are selected for full messages
+const graphMessageFields = "id,internetMessageId,subject,from,toRecipients,replyTo," +
+	"receivedDateTime,sentDateTime,isRead,body,flag,internetMessageHeaders"
+
+// graphAttachmentExpand fetches attachment metadata along with the messages
+const graphAttachmentExpand = "attachments($select=name,size,contentType)"
+
+// GraphClient reads and sends Microsoft 365 / Outlook.com mail through
+// Microsoft Graph.
+//
+// Like JMAPClient, message ids are mapped to UIDs with an idMap and unknown
+// UIDs are found by re-listing the selected folder. Folders are synced with
+// delta queries.
+type GraphClient struct {
+	creds *auth.Credentials
+	api   *graph.Client
+
+	mu       sync.Mutex
+	folders  []graphFolder
+	selected string
+	ids      idMap
+}
+
+type graphFolder struct {
+	ID               string `json:"id"`
+	DisplayName      string `json:"displayName"`
+	ChildFolderCount int    `json:"childFolderCount"`
+	TotalItemCount   uint32 `json:"totalItemCount"`
+
+	path string
+}
+
+type graphAddress struct {
+	EmailAddress struct {
+		Name    string `json:"name,omitempty"`
+		Address string `json:"address"`
+	} `json:"emailAddress"`
+}
+
+type graphBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type graphMessage struct {
+	ODataType         string         `json:"@odata.type"` // set for derived types, e.g. meeting messages
+	ID                string         `json:"id"`
+	InternetMessageID string         `json:"internetMessageId"`
+	Subject           string         `json:"subject"`
+	From              *graphAddress  `json:"from"`
+	ToRecipients      []graphAddress `json:"toRecipients"`
+	ReplyTo           []graphAddress `json:"replyTo"`
+	ReceivedDateTime  time.Time      `json:"receivedDateTime"`
+	SentDateTime      time.Time      `json:"sentDateTime"`
+	IsRead            bool           `json:"isRead"`
+	Body              graphBody      `json:"body"`
+	Flag              *graphFlag     `json:"flag,omitempty"`
+	Headers           []graphHeader  `json:"internetMessageHeaders,omitempty"`
+
+	Attachments []graphAttachment `json:"attachments,omitempty"`
+}
+
+type graphFlag struct {
+	FlagStatus string `json:"flagStatus"` // "flagged", "notFlagged" or "complete"
+}
+
+type graphHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type graphAttachment struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+}
+
+// NewGraphClient returns a client for an Outlook account
+func NewGraphClient(creds *auth.Credentials) (*GraphClient, error) {
+	api, err := graph.New(creds)
+	if err != nil {
+		return nil, err
+	}
+	return NewGraphClientWithAPI(creds, api), nil
+}
+
+// NewGraphClientWithAPI returns a client that talks through an existing Graph
+// client, e.g. one pointed at a test server
+func NewGraphClientWithAPI(creds *auth.Credentials, api *graph.Client) *GraphClient {
+	return &GraphClient{creds: creds, api: api, ids: idMap{path: idsPath(creds.Email)}}
+}
+
+func (c *GraphClient) Close() error {
+	return nil
+}
+
+func graphRecipients(addrs ...string) []graphAddress {
+	var recipients []graphAddress
+	for _, addr := range addrs {
+		for _, a := range strings.Split(addr, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				var r graphAddress
+				r.EmailAddress.Address = a
+				recipients = append(recipients, r)
+			}
+		}
+	}
+	return recipients
+}
+
+func formatGraphAddress(addr *graphAddress) string {
+	if addr == nil {
+		return ""
+	}
+	if addr.EmailAddress.Name != "" {
+		return fmt.Sprintf("%s <%s>", addr.EmailAddress.Name, addr.EmailAddress.Address)
+	}
+	return addr.EmailAddress.Address
+}
+
+func (c *GraphClient) loadFolders() ([]graphFolder, error) {
+	c.mu.Lock()
+	cached := c.folders
+	c.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	var inbox graphFolder
+	if err := c.api.Get("/me/mailFolders/inbox?$select=id", &inbox); err != nil {
+		return nil, err
+	}
+
+	// Nested folders are shown as "Parent/Child" like IMAP hierarchies
+	var folders []graphFolder
+	var walk func(path, prefix string, depth int) error
+	walk = func(path, prefix string, depth int) error {
+		children, err := graph.List[graphFolder](c.api, fmt.Sprintf("%s?$top=%d", path, graphPageSize), 0)
+		if err != nil {
+			return err
+		}
+		for _, f := range children {
+			name := f.DisplayName
+			if f.ID == inbox.ID {
+				name = INBOX
+			}
+			f.path = prefix + name
+			folders = append(folders, f)
+			if f.ChildFolderCount > 0 && depth < 32 {
+				if err := walk("/me/mailFolders/"+url.PathEscape(f.ID)+"/childFolders", f.path+"/", depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk("/me/mailFolders", "", 0); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.folders = folders
+	c.mu.Unlock()
+	return folders, nil
+}
+
+func (c *GraphClient) folder(name string) (*graphFolder, error) {
+	folders, err := c.loadFolders()
+	if err != nil {
+		return nil, err
+	}
+	for i := range folders {
+		if folders[i].path == name || (strings.EqualFold(name, INBOX) && folders[i].path == INBOX) {
+			return &folders[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no such mailbox: %s", name)
+}
+
+func (c *GraphClient) ListMailboxes() ([]string, error) {
+	folders, err := c.loadFolders()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(folders))
+	for i, f := range folders {
+		names[i] = f.path
+	}
+	return names, nil
+}
+
+func (c *GraphClient) SelectMailbox(name string) error {
+	_, err := c.SelectMailboxWithInfo(name)
+	return err
+}
+
+// SelectMailboxWithInfo resolves the folder. Graph ids are stable, so the
+// UIDVALIDITY is derived from the folder id.
+func (c *GraphClient) SelectMailboxWithInfo(name string) (*MailboxInfo, error) {
+	f, err := c.folder(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var current graphFolder
+	if err := c.api.Get("/me/mailFolders/"+url.PathEscape(f.ID)+"?$select=id,totalItemCount", &current); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.selected = name
+	c.mu.Unlock()
+
+	return &MailboxInfo{
+		UIDValidity: uint32(uidForID(f.ID)),
+		NumMessages: current.TotalItemCount,
+	}, nil
+}
+
+// listMessages lists a folder's messages, newest first, up to limit (0 for all)
+func (c *GraphClient) listMessages(folderID string, query url.Values, limit int) ([]graphMessage, error) {
+	query.Set("$top", fmt.Sprint(graphPageSize))
+	if query.Get("$search") == "" {
+		// $search cannot be combined with $orderby
+		query.Set("$orderby", "receivedDateTime desc")
+	}
+	path := "/me/mailFolders/" + url.PathEscape(folderID) + "/messages?" + query.Encode()
+	return graph.List[graphMessage](c.api, path, limit)
+}
+
+func (c *GraphClient) toEmail(m graphMessage) Email {
+	email := Email{
+		UID:          c.ids.remember(m.ID),
+		MessageID:    strings.Trim(m.InternetMessageID, "<>"),
+		InternalDate: m.ReceivedDateTime,
+		From:         formatGraphAddress(m.From),
+		Subject:      m.Subject,
+		Date:         m.SentDateTime,
+		Unread:       !m.IsRead,
+		Flagged:      m.Flag != nil && m.Flag.FlagStatus == "flagged",
+	}
+	if len(m.ToRecipients) > 0 {
+		email.To = formatGraphAddress(&m.ToRecipients[0])
+	}
+	if len(m.ReplyTo) > 0 {
+		email.ReplyTo = m.ReplyTo[0].EmailAddress.Address
+	}
+	if email.Date.IsZero() {
+		email.Date = m.ReceivedDateTime
+	}
+
+	email.Body = m.Body.Content
+	if strings.EqualFold(m.Body.ContentType, "html") {
+		email.Body = stripHTML(email.Body)
+	}
+	email.Snippet = truncateSnippet(email.Body)
+
+	if len(m.Headers) > 0 {
+		email.Headers = make(map[string]string, len(m.Headers))
+		for _, h := range m.Headers {
+			addHeader(email.Headers, h.Name, h.Value)
+		}
+	}
+
+	for _, a := range m.Attachments {
+		email.Attachments = append(email.Attachments, Attachment{
+			PartID:      a.ID,
+			Filename:    a.Name,
+			ContentType: a.ContentType,
+			Size:        a.Size,
+		})
+	}
+
+	return email
+}
+
+func (c *GraphClient) toEmails(messages []graphMessage) []Email {
+	emails := make([]Email, 0, len(messages))
+	for _, m := range messages {
+		email := c.toEmail(m)
+		if m.hasCalendar() {
+			email.Calendar = c.calendarPart(m.ID)
+		}
+		emails = append(emails, email)
+	}
+	return emails
+}
+
+// hasCalendar reports whether a message carries an iCalendar part: Exchange
+// turns invitations into meeting messages, other senders' come as .ics
+// attachments
+func (m graphMessage) hasCalendar() bool {
+	if strings.HasPrefix(m.ODataType, "#microsoft.graph.eventMessage") {
+		return true
+	}
+	for _, a := range m.Attachments {
+		contentType, _, _ := strings.Cut(a.ContentType, ";")
+		if isCalendarType(strings.ToLower(strings.TrimSpace(contentType))) {
+			return true
+		}
+	}
+	return false
+}
+
+// calendarPart returns the text/calendar part of a message's MIME source,
+// which Graph only hands out whole. The invitation is left out if that fails.
+func (c *GraphClient) calendarPart(id string) string {
+	raw, err := c.api.GetRaw("/me/messages/" + url.PathEscape(id) + "/$value")
+	if err != nil {
+		return ""
+	}
+	_, _, calendar := parseBody(raw)
+	return calendar
+}
+
+// resolve maps UIDs back to Graph ids, re-listing the selected folder for
+// UIDs this client has not handed out (e.g. ones loaded from the disk cache)
+func (c *GraphClient) resolve(uids []imap.UID) ([]string, error) {
+	if ids, ok := c.ids.lookup(uids); ok {
+		return ids, nil
+	}
+
+	c.mu.Lock()
+	selected := c.selected
+	c.mu.Unlock()
+	if selected == "" {
+		selected = INBOX
+	}
+
+	f, err := c.folder(selected)
+	if err != nil {
+		return nil, err
+	}
+	all, err := c.listMessages(f.ID, url.Values{"$select": {"id"}}, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range all {
+		c.ids.remember(m.ID)
+	}
+
+	if ids, ok := c.ids.lookup(uids); ok {
+		return ids, nil
+	}
+	return nil, fmt.Errorf("message not found in %s", selected)
+}
+
+func (c *GraphClient) FetchUIDsAndFlags(mailbox string, since time.Time) (map[imap.UID]bool, error) {
+	f, err := c.folder(mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select mailbox: %w", err)
+	}
+
+	messages, err := c.listMessages(f.ID, url.Values{
+		"$select": {"id,isRead"},
+		"$filter": {"receivedDateTime ge " + since.UTC().Format(time.RFC3339)},
+	}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	result := make(map[imap.UID]bool, len(messages))
+	for _, m := range messages {
+		result[c.ids.remember(m.ID)] = !m.IsRead
+	}
+	return result, nil
+}
+
+// graphChange is a message in the result of a delta query
+type graphChange struct {
+	ID      string `json:"id"`
+	IsRead  bool   `json:"isRead"`
+	Removed *struct {
+		Reason string `json:"reason"`
+	} `json:"@removed,omitempty"`
+}
+
+// deltaPath is the delta query that tracks the messages of a folder
+// received since the start of the sync window, so a first round does not
+// page through the whole folder
+func deltaPath(folderID string, now time.Time) string {
+	since := now.AddDate(0, 0, -graphDeltaDays).UTC().Format(time.RFC3339)
+	query := url.Values{
+		"$select": {"isRead"},
+		"$filter": {"receivedDateTime ge " + since},
+		"$top":    {fmt.Sprint(graphPageSize)},
+	}
+	return "/me/mailFolders/" + url.PathEscape(folderID) + "/messages/delta?" + query.Encode()
+}
+
+// State runs a delta query over the folder's recent mail; the delta link it
+// ends with is the state. Graph tracks folders separately, so the mailbox
+// state is unused.
+func (c *GraphClient) State(mailbox string) (*SyncState, error) {
+	f, err := c.folder(mailbox)
+	if err != nil {
+		return nil, err
+	}
+	_, link, err := graph.Delta[graphChange](c.api, deltaPath(f.ID, time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	return &SyncState{Email: link}, nil
+}
+
+// Changes follows the delta link of since to describe what happened to the
+// folder. Graph drops delta links it no longer tracks with 410 Gone.
+func (c *GraphClient) Changes(mailbox string, since SyncState) (*MailboxChanges, error) {
+	if _, err := c.folder(mailbox); err != nil {
+		return nil, err
+	}
+	changed, link, err := graph.Delta[graphChange](c.api, since.Email)
+	if gerr, ok := err.(*graph.Error); ok && gerr.Status == http.StatusGone {
+		return nil, ErrStateReset
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &MailboxChanges{State: SyncState{Email: link}, Flags: make(map[imap.UID]bool)}
+	for _, m := range changed {
+		if m.Removed == nil {
+			changes.Flags[c.ids.remember(m.ID)] = !m.IsRead
+		} else if uid, ok := c.ids.known(m.ID); ok {
+			// Ids never handed out cannot be in the cache
+			changes.Removed = append(changes.Removed, uid)
+		}
+	}
+	return changes, nil
+}
+
+func (c *GraphClient) FetchMessagesByUIDs(mailbox string, uids []imap.UID) ([]Email, error) {
+	if len(uids) == 0 {
+		return []Email{}, nil
+	}
+	if err := c.SelectMailbox(mailbox); err != nil {
+		return nil, fmt.Errorf("failed to select mailbox: %w", err)
+	}
+
+	ids, err := c.resolve(uids)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := c.getMessages(ids)
+	if err != nil {
+		return nil, fmt.Errorf("fetch failed: %w", err)
+	}
+	return c.toEmails(messages), nil
+}
+
+// getMessages fetches full messages by id, a JSON batch of GETs at a time,
+// in the order of ids. Missing ids are silently skipped.
+func (c *GraphClient) getMessages(ids []string) ([]graphMessage, error) {
+	query := url.Values{"$select": {graphMessageFields}, "$expand": {graphAttachmentExpand}}.Encode()
+	found := make(map[string]graphMessage, len(ids))
+	for start := 0; start < len(ids); start += graph.MaxBatch {
+		batch := ids[start:min(start+graph.MaxBatch, len(ids))]
+		requests := make([]graph.BatchRequest, len(batch))
+		for i, id := range batch {
+			requests[i] = graph.BatchRequest{ID: fmt.Sprint(i), Method: http.MethodGet, URL: "/me/messages/" + url.PathEscape(id) + "?" + query}
+		}
+		responses, err := c.api.Batch(requests)
+		if err != nil {
+			return nil, err
+		}
+		for _, resp := range responses {
+			if resp.Status == http.StatusNotFound {
+				continue
+			}
+			if err := resp.Err(); err != nil {
+				return nil, err
+			}
+			var i int
+			if _, err := fmt.Sscan(resp.ID, &i); err != nil || i < 0 || i >= len(batch) {
+				return nil, fmt.Errorf("graph: response to unknown request %q", resp.ID)
+			}
+			var m graphMessage
+			if err := json.Unmarshal(resp.Body, &m); err != nil {
+				return nil, err
+			}
+			found[batch[i]] = m
+		}
+	}
+
+	messages := make([]graphMessage, 0, len(found))
+	for _, id := range ids {
+		if m, ok := found[id]; ok {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (c *GraphClient) FetchMessages(mailbox string, limit uint32) ([]Email, error) {
+	return c.queryMessages(mailbox, url.Values{}, limit)
+}
+
+func (c *GraphClient) FetchMessagesSince(mailbox string, since time.Time, limit uint32) ([]Email, error) {
+	return c.queryMessages(mailbox, url.Values{
+		"$filter": {"receivedDateTime ge " + since.UTC().Format(time.RFC3339)},
+	}, limit)
+}
+
+// SearchMessages uses the server's full-text search
+func (c *GraphClient) SearchMessages(mailbox string, query string) ([]Email, error) {
+	quoted := `"` + strings.ReplaceAll(query, `"`, "") + `"`
+	emails, err := c.queryMessages(mailbox, url.Values{"$search": {quoted}}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	return emails, nil
+}
+
+func (c *GraphClient) queryMessages(mailbox string, query url.Values, limit uint32) ([]Email, error) {
+	f, err := c.folder(mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select mailbox: %w", err)
+	}
+	c.mu.Lock()
+	c.selected = mailbox
+	c.mu.Unlock()
+
+	query.Set("$select", graphMessageFields)
+	query.Set("$expand", graphAttachmentExpand)
+	messages, err := c.listMessages(f.ID, query, int(limit))
+	if err != nil {
+		return nil, err
+	}
+	return c.toEmails(messages), nil
+}
+
+// eachMessage resolves uids and calls fn with every message path
+func (c *GraphClient) eachMessage(uids []imap.UID, fn func(path string) error) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	ids, err := c.resolve(uids)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := fn("/me/messages/" + url.PathEscape(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *GraphClient) setRead(uids []imap.UID, read bool) error {
+	return c.eachMessage(uids, func(path string) error {
+		return c.api.Patch(path, map[string]bool{"isRead": read}, nil)
+	})
+}
+
+func (c *GraphClient) MarkAsRead(uid imap.UID) error {
+	return c.setRead([]imap.UID{uid}, true)
+}
+
+func (c *GraphClient) MarkAsUnread(uid imap.UID) error {
+	return c.setRead([]imap.UID{uid}, false)
+}
+
+func (c *GraphClient) MarkMessagesAsRead(uids []imap.UID) error {
+	return c.setRead(uids, true)
+}
+
+func (c *GraphClient) DeleteMessage(uid imap.UID) error {
+	return c.DeleteMessages([]imap.UID{uid})
+}
+
+func (c *GraphClient) DeleteMessages(uids []imap.UID) error {
+	return c.eachMessage(uids, c.api.Delete)
+}
+
+func (c *GraphClient) MoveToTrash(uids []imap.UID) error {
+	if err := c.move(uids, "deleteditems"); err != nil {
+		return fmt.Errorf("failed to move to trash: %w", err)
+	}
+	return nil
+}
+
+func (c *GraphClient) ArchiveMessages(uids []imap.UID) error {
+	return c.move(uids, "archive")
+}
+
+// move moves messages to a folder id or well-known folder name
+func (c *GraphClient) move(uids []imap.UID, destination string) error {
+	return c.eachMessage(uids, func(path string) error {
+		return c.api.Post(path+"/move", map[string]string{"destinationId": destination}, nil)
+	})
+}
+
+// MoveMessages moves messages to the folder mailbox
+func (c *GraphClient) MoveMessages(uids []imap.UID, mailbox string) error {
+	f, err := c.folder(mailbox)
+	if err != nil {
+		return err
+	}
+	return c.move(uids, f.ID)
+}
+
+// CopyMessages copies messages to the folder mailbox
+func (c *GraphClient) CopyMessages(uids []imap.UID, mailbox string) error {
+	f, err := c.folder(mailbox)
+	if err != nil {
+		return err
+	}
+	return c.eachMessage(uids, func(path string) error {
+		return c.api.Post(path+"/copy", map[string]string{"destinationId": f.ID}, nil)
+	})
+}
+
+func (c *GraphClient) SetFlagged(uids []imap.UID, flagged bool) error {
+	status := "notFlagged"
+	if flagged {
+		status = "flagged"
+	}
+	return c.eachMessage(uids, func(path string) error {
+		return c.api.Patch(path, map[string]any{"flag": graphFlag{FlagStatus: status}}, nil)
+	})
+}
+
+func (c *GraphClient) newMessage(to, subject, body string) map[string]any {
+	return map[string]any{
+		"subject":      subject,
+		"body":         graphBody{ContentType: "Text", Content: body},
+		"toRecipients": graphRecipients(to),
+	}
+}
+
+// SaveDraft saves an email to the Drafts folder
+func (c *GraphClient) SaveDraft(to, subject, body string) error {
+	return c.api.Post("/me/messages", c.newMessage(to, subject, body), nil)
+}
+
+// Send sends a new message and keeps a copy in Sent Items
+func (c *GraphClient) Send(to, subject, body string) error {
+	return c.api.Post("/me/sendMail", map[string]any{
+		"message":         c.newMessage(to, subject, body),
+		"saveToSentItems": true,
+	}, nil)
+}
+
+// Reply replies to the original message so Outlook keeps the conversation
+// together. If the original cannot be found it falls back to a new message.
+func (c *GraphClient) Reply(to, subject, body, inReplyTo, references string) error {
+	if id := strings.Trim(inReplyTo, "<> "); id != "" {
+		filter := url.Values{
+			"$filter": {fmt.Sprintf("internetMessageId eq '<%s>'", strings.ReplaceAll(id, "'", "''"))},
+			"$select": {"id"},
+		}
+		originals, err := graph.List[graphMessage](c.api, "/me/messages?"+filter.Encode(), 1)
+		if err == nil && len(originals) > 0 {
+			return c.api.Post("/me/messages/"+url.PathEscape(originals[0].ID)+"/reply", map[string]any{
+				"message": c.newMessage(to, subject, body),
+			}, nil)
+		}
+	}
+	return c.Send(to, subject, body)
+}
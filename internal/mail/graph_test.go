@@ -0,0 +1,313 @@
+package mail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/emersion/go-imap/v2"
+
+	"maily/internal/auth"
+	"maily/internal/graph"
+)
+
+// fakeGraph serves the Graph endpoints GraphClient uses, for one inbox with a
+// nested "Work" folder
+type fakeGraph struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	messages []map[string]any
+	requests []string         // "METHOD path" of every write
+	fetches  []string         // "METHOD path" of every batched message fetch
+	sources  []string         // ids of the messages whose MIME source was fetched
+	changes  []map[string]any // delta query results, in order
+	bodies   []map[string]any
+}
+
+func newFakeGraph(t *testing.T) *fakeGraph {
+	// Clients keep their UID tables in the cache under HOME
+	t.Setenv("HOME", t.TempDir())
+	f := &fakeGraph{}
+	for i := 1; i <= 3; i++ {
+		f.messages = append(f.messages, map[string]any{
+			"id":                fmt.Sprintf("msg-%d", i),
+			"internetMessageId": fmt.Sprintf("<m%d@example.com>", i),
+			"subject":           fmt.Sprintf("Message %d", i),
+			"from":              map[string]any{"emailAddress": map[string]string{"name": "Ann", "address": "ann@example.com"}},
+			"receivedDateTime":  fmt.Sprintf("2024-01-0%dT10:00:00Z", 4-i),
+			"isRead":            false,
+			"body":              map[string]string{"contentType": "text", "content": "hello"},
+		})
+	}
+	f.messages[0]["attachments"] = []map[string]any{
+		{"id": "att-1", "name": "report.pdf", "contentType": "application/pdf", "size": 1024},
+	}
+	// Exchange hands invitations out as meeting messages
+	f.messages[1]["@odata.type"] = "#microsoft.graph.eventMessageRequest"
+	f.messages[2]["attachments"] = []map[string]any{
+		{"id": "att-2", "name": "invite.ics", "contentType": "text/calendar; charset=utf-8", "size": 512},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /me/mailFolders/inbox", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"id": "f-inbox"})
+	})
+	mux.HandleFunc("GET /me/mailFolders", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"value": []map[string]any{
+			{"id": "f-inbox", "displayName": "Inbox", "childFolderCount": 1},
+			{"id": "f-trash", "displayName": "Deleted Items"},
+		}})
+	})
+	mux.HandleFunc("GET /me/mailFolders/f-inbox", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"id": "f-inbox", "totalItemCount": len(f.messages)})
+	})
+	mux.HandleFunc("GET /me/mailFolders/f-inbox/childFolders", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"value": []map[string]any{
+			{"id": "f-work", "displayName": "Work"},
+		}})
+	})
+	mux.HandleFunc("GET /me/mailFolders/f-inbox/messages", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		// Serve one message per page to exercise @odata.nextLink
+		skip := 0
+		fmt.Sscan(r.URL.Query().Get("skip"), &skip)
+		page := map[string]any{"value": f.messages[skip : skip+1]}
+		if skip+1 < len(f.messages) {
+			page["@odata.nextLink"] = fmt.Sprintf("%s/me/mailFolders/f-inbox/messages?skip=%d", f.server.URL, skip+1)
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+	mux.HandleFunc("GET /me/mailFolders/f-inbox/messages/delta", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		token := r.URL.Query().Get("token")
+		if token == "expired" {
+			w.WriteHeader(http.StatusGone)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": "SyncStateNotFound"}})
+			return
+		}
+		// A first round lists the messages of the sync window, one page
+		// each; later ones the changes since their token
+		var page map[string]any
+		if filter := r.URL.Query().Get("$filter"); token == "" && r.URL.Query().Get("skip") == "" && !strings.HasPrefix(filter, "receivedDateTime ge ") {
+			t.Errorf("delta query not limited to the sync window: $filter=%q", filter)
+		}
+		if token == "" {
+			skip := 0
+			fmt.Sscan(r.URL.Query().Get("skip"), &skip)
+			page = map[string]any{"value": f.messages[skip : skip+1]}
+			if skip+1 < len(f.messages) {
+				page["@odata.nextLink"] = fmt.Sprintf("%s/me/mailFolders/f-inbox/messages/delta?skip=%d", f.server.URL, skip+1)
+			}
+		} else {
+			since := 0
+			fmt.Sscan(token, &since)
+			page = map[string]any{"value": f.changes[since:]}
+		}
+		if page["@odata.nextLink"] == nil {
+			page["@odata.deltaLink"] = fmt.Sprintf("%s/me/mailFolders/f-inbox/messages/delta?token=%d", f.server.URL, len(f.changes))
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+	mux.HandleFunc("POST /$batch", func(w http.ResponseWriter, r *http.Request) {
+		var batch struct {
+			Requests []struct {
+				ID      string            `json:"id"`
+				Method  string            `json:"method"`
+				URL     string            `json:"url"`
+				Headers map[string]string `json:"headers"`
+			} `json:"requests"`
+		}
+		json.NewDecoder(r.Body).Decode(&batch)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		// Answer in reverse, as Graph may answer in any order
+		var responses []map[string]any
+		for i := len(batch.Requests) - 1; i >= 0; i-- {
+			req := batch.Requests[i]
+			path, query, _ := strings.Cut(req.URL, "?")
+			f.fetches = append(f.fetches, req.Method+" "+path)
+			if !strings.Contains(query, "expand=attachments") || !strings.Contains(req.Headers["Prefer"], "ImmutableId") {
+				t.Errorf("batch request %s lacks attachments or immutable ids", req.URL)
+			}
+			resp := map[string]any{"id": req.ID, "status": http.StatusNotFound}
+			for _, m := range f.messages {
+				if path == "/me/messages/"+m["id"].(string) {
+					resp = map[string]any{"id": req.ID, "status": http.StatusOK, "body": m}
+				}
+			}
+			responses = append(responses, resp)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"responses": responses})
+	})
+	mux.HandleFunc("GET /me/messages", func(w http.ResponseWriter, r *http.Request) {
+		var found []map[string]any
+		if strings.Contains(r.URL.Query().Get("$filter"), "<m2@example.com>") {
+			found = append(found, map[string]any{"id": "msg-2"})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"value": found})
+	})
+	mux.HandleFunc("GET /me/messages/{id}/$value", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.sources = append(f.sources, r.PathValue("id"))
+		f.mu.Unlock()
+		fmt.Fprint(w, "Content-Type: multipart/alternative; boundary=b\r\n\r\n"+
+			"--b\r\nContent-Type: text/plain\r\n\r\nhello\r\n"+
+			"--b\r\nContent-Type: text/calendar; method=REQUEST\r\n\r\nBEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"+
+			"--b--\r\n")
+	})
+	write := func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+		f.bodies = append(f.bodies, body)
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}
+	mux.HandleFunc("PATCH /me/messages/{id}", write)
+	mux.HandleFunc("POST /me/messages/{id}/{action}", write)
+	mux.HandleFunc("POST /me/sendMail", write)
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeGraph) client() *GraphClient {
+	api := graph.NewWithHTTP(f.server.URL, f.server.Client())
+	return NewGraphClientWithAPI(&auth.Credentials{Email: "me@example.com", Provider: auth.ProviderOutlook}, api)
+}
+
+func TestGraphFetchAndMove(t *testing.T) {
+	f := newFakeGraph(t)
+	client := f.client()
+
+	names, err := client.ListMailboxes()
+	if err != nil {
+		t.Fatalf("ListMailboxes: %v", err)
+	}
+	if strings.Join(names, ",") != "INBOX,INBOX/Work,Deleted Items" {
+		t.Fatalf("mailboxes = %v", names)
+	}
+
+	emails, err := client.FetchMessages(INBOX, 0)
+	if err != nil {
+		t.Fatalf("FetchMessages: %v", err)
+	}
+	if len(emails) != 3 {
+		t.Fatalf("got %d emails across pages, want 3", len(emails))
+	}
+	if emails[0].From != "Ann <ann@example.com>" || emails[0].MessageID != "m1@example.com" || !emails[0].Unread {
+		t.Fatalf("unexpected email: %+v", emails[0])
+	}
+	if len(emails[0].Attachments) != 1 || emails[0].Attachments[0].Filename != "report.pdf" {
+		t.Fatalf("attachments = %+v", emails[0].Attachments)
+	}
+
+	// Only the sources of the meeting message and the one with an .ics are
+	// fetched, for their invitations
+	if emails[0].Calendar != "" || !strings.HasPrefix(emails[1].Calendar, "BEGIN:VCALENDAR") || emails[2].Calendar == "" {
+		t.Fatalf("calendars = %q, %q, %q", emails[0].Calendar, emails[1].Calendar, emails[2].Calendar)
+	}
+	if strings.Join(f.sources, ",") != "msg-2,msg-3" {
+		t.Fatalf("sources fetched = %v", f.sources)
+	}
+
+	// Messages fetched by UID come in one batch, in the order asked for
+	fetched, err := client.FetchMessagesByUIDs(INBOX, []imap.UID{emails[2].UID, emails[0].UID})
+	if err != nil {
+		t.Fatalf("FetchMessagesByUIDs: %v", err)
+	}
+	if len(fetched) != 2 || fetched[0].Subject != "Message 3" || len(fetched[1].Attachments) != 1 {
+		t.Fatalf("fetched = %+v", fetched)
+	}
+	if strings.Join(f.fetches, ",") != "GET /me/messages/msg-1,GET /me/messages/msg-3" {
+		t.Fatalf("batch = %v", f.fetches)
+	}
+
+	// A fresh client does not know the UIDs yet and must re-list the folder
+	other := f.client()
+	if err := other.MarkAsRead(emails[1].UID); err != nil {
+		t.Fatalf("MarkAsRead: %v", err)
+	}
+	if err := client.MoveToTrash([]imap.UID{emails[2].UID}); err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+
+	want := []string{"PATCH /me/messages/msg-2", "POST /me/messages/msg-3/move"}
+	if strings.Join(f.requests, ",") != strings.Join(want, ",") {
+		t.Fatalf("requests = %v, want %v", f.requests, want)
+	}
+	if f.bodies[0]["isRead"] != true || f.bodies[1]["destinationId"] != "deleteditems" {
+		t.Fatalf("unexpected bodies: %v", f.bodies)
+	}
+}
+
+func TestGraphReply(t *testing.T) {
+	f := newFakeGraph(t)
+	client := f.client()
+
+	if err := client.Reply("ann@example.com", "Re: Message 2", "thanks", "m2@example.com", ""); err != nil {
+		t.Fatalf("Reply: %v", err)
+	}
+	if err := client.Reply("ann@example.com", "Re: gone", "thanks", "missing@example.com", ""); err != nil {
+		t.Fatalf("Reply to unknown message: %v", err)
+	}
+
+	want := []string{"POST /me/messages/msg-2/reply", "POST /me/sendMail"}
+	if strings.Join(f.requests, ",") != strings.Join(want, ",") {
+		t.Fatalf("requests = %v, want %v", f.requests, want)
+	}
+}
+
+func TestGraphChanges(t *testing.T) {
+	f := newFakeGraph(t)
+	client := f.client()
+
+	state, err := client.State(INBOX)
+	if err != nil {
+		t.Fatalf("State: %v", err)
+	}
+	if !strings.HasSuffix(state.Email, "?token=0") {
+		t.Fatalf("state = %+v, want the delta link of the first round", state)
+	}
+	emails, err := client.FetchMessages(INBOX, 0)
+	if err != nil {
+		t.Fatalf("FetchMessages: %v", err)
+	}
+
+	f.mu.Lock()
+	f.changes = append(f.changes,
+		map[string]any{"id": "msg-1", "isRead": true},
+		map[string]any{"id": "msg-4", "isRead": false},
+		map[string]any{"id": "msg-2", "@removed": map[string]string{"reason": "deleted"}},
+		map[string]any{"id": "never-seen", "@removed": map[string]string{"reason": "changed"}},
+	)
+	f.mu.Unlock()
+
+	changes, err := client.Changes(INBOX, *state)
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	if !strings.HasSuffix(changes.State.Email, "?token=4") {
+		t.Errorf("new state = %+v", changes.State)
+	}
+	if len(changes.Flags) != 2 || changes.Flags[emails[0].UID] {
+		t.Errorf("Flags = %v, want msg-1 read and msg-4 new", changes.Flags)
+	}
+	if len(changes.Removed) != 1 || changes.Removed[0] != emails[1].UID {
+		t.Errorf("Removed = %v, want only msg-2", changes.Removed)
+	}
+
+	expired := SyncState{Email: f.server.URL + "/me/mailFolders/f-inbox/messages/delta?token=expired"}
+	if _, err := client.Changes(INBOX, expired); !errors.Is(err, ErrStateReset) {
+		t.Errorf("Changes from expired state = %v, want ErrStateReset", err)
+	}
+}
@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"mime"
+	"net/textproto"
+	"strings"
+)
+
+// traceHeaders are left out of Email.Headers: they are long, repeated on
+// every hop and of no use to mail rules
+var traceHeaders = map[string]bool{
+	"Received":                   true,
+	"X-Received":                 true,
+	"Dkim-Signature":             true,
+	"Arc-Seal":                   true,
+	"Arc-Message-Signature":      true,
+	"Arc-Authentication-Results": true,
+	"X-Google-Smtp-Source":       true,
+	"X-Gm-Message-State":         true,
+}
+
+// parseHeaders returns the decoded header fields of a raw message,
+// repeated fields joined with ", "
+func parseHeaders(raw []byte) map[string]string {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(raw)))
+	header, err := r.ReadMIMEHeader()
+	if len(header) == 0 && err != nil {
+		return nil
+	}
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		addHeader(headers, name, strings.Join(values, ", "))
+	}
+	return headers
+}
+
+// addHeader adds a header field to headers, skipping trace fields
+func addHeader(headers map[string]string, name, value string) {
+	name = textproto.CanonicalMIMEHeaderKey(name)
+	if traceHeaders[name] {
+		return
+	}
+	value = strings.TrimSpace(value)
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(value); err == nil {
+		value = decoded
+	}
+	if have, ok := headers[name]; ok {
+		value = have + ", " + value
+	}
+	headers[name] = value
+}
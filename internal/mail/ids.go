@@ -0,0 +1,154 @@
+package mail
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/emersion/go-imap/v2"
+)
+
+// idsFileName is the table of UIDs handed out, kept in the account's cache
+// directory next to the mailboxes
+const idsFileName = "ids"
+
+// idMap hands out UIDs for backends whose message ids are strings (JMAP,
+// Graph). The rest of maily identifies messages by IMAP UID, so ids are
+// hashed into UIDs; an id whose hash is taken gets the next free UID.
+//
+// Every UID handed out is appended to a table on disk, so an id keeps its
+// UID across runs even when it collided with another.
+type idMap struct {
+	path string // the table; empty keeps UIDs in memory only
+
+	mu     sync.Mutex
+	loaded bool
+	ids    map[imap.UID]string
+	uids   map[string]imap.UID
+}
+
+// idsPath returns the path of an account's table, empty if there is none
+func idsPath(account string) string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil || account == "" {
+		return ""
+	}
+	return filepath.Join(homeDir, ".config", "maily", "cache", account, idsFileName)
+}
+
+// uidForID maps a string id onto a stable, non-zero UID
+func uidForID(id string) imap.UID {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	if uid := h.Sum32(); uid != 0 {
+		return imap.UID(uid)
+	}
+	return 1
+}
+
+// load reads the table once; m.mu is held
+func (m *idMap) load() {
+	if m.loaded {
+		return
+	}
+	m.loaded = true
+	m.ids = make(map[imap.UID]string)
+	m.uids = make(map[string]imap.UID)
+	if m.path == "" {
+		return
+	}
+	f, err := os.Open(m.path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		uidStr, id, ok := strings.Cut(scanner.Text(), " ")
+		uid, err := strconv.ParseUint(uidStr, 10, 32)
+		if !ok || err != nil || uid == 0 || id == "" {
+			continue
+		}
+		// The first entry wins, should two runs have raced
+		if _, taken := m.ids[imap.UID(uid)]; taken {
+			continue
+		}
+		if _, known := m.uids[id]; known {
+			continue
+		}
+		m.ids[imap.UID(uid)] = id
+		m.uids[id] = imap.UID(uid)
+	}
+}
+
+// save appends an entry to the table; m.mu is held. UIDs still work for
+// this run if it fails.
+func (m *idMap) save(uid imap.UID, id string) {
+	if m.path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0700); err != nil {
+		return
+	}
+	f, err := os.OpenFile(m.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "%d %s\n", uid, id)
+}
+
+// remember records id and returns its UID
+func (m *idMap) remember(id string) imap.UID {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.load()
+	if uid, ok := m.uids[id]; ok {
+		return uid
+	}
+
+	uid := uidForID(id)
+	for {
+		if _, taken := m.ids[uid]; !taken {
+			break
+		}
+		if uid++; uid == 0 {
+			uid = 1
+		}
+	}
+	m.ids[uid] = id
+	m.uids[id] = uid
+	m.save(uid, id)
+	return uid
+}
+
+// known returns the UID handed out for id, or false if there is none
+func (m *idMap) known(id string) (imap.UID, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.load()
+	uid, ok := m.uids[id]
+	return uid, ok
+}
+
+// lookup returns the ids for uids, or false if any of them is unknown
+func (m *idMap) lookup(uids []imap.UID) ([]string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.load()
+	ids := make([]string, 0, len(uids))
+	for _, uid := range uids {
+		id, ok := m.ids[uid]
+		if !ok {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/emersion/go-imap/v2"
+)
+
+func TestIDMapCollision(t *testing.T) {
+	// Two ids with the same FNV-1a hash
+	a, b := "msg-1122789", "msg-1339192"
+	if uidForID(a) != uidForID(b) {
+		t.Fatal("ids do not collide")
+	}
+
+	path := filepath.Join(t.TempDir(), "acct", idsFileName)
+	m := &idMap{path: path}
+	uidB := m.remember(b)
+	uidA := m.remember(a)
+	if uidA == uidB {
+		t.Fatalf("colliding ids share UID %d", uidA)
+	}
+	if m.remember(a) != uidA {
+		t.Error("remembering an id again changed its UID")
+	}
+	if ids, ok := m.lookup([]imap.UID{uidA, uidB}); !ok || ids[0] != a || ids[1] != b {
+		t.Errorf("lookup = %v, %v", ids, ok)
+	}
+
+	// A later run meets the ids the other way round but keeps their UIDs
+	fresh := &idMap{path: path}
+	if fresh.remember(a) != uidA || fresh.remember(b) != uidB {
+		t.Error("UIDs changed across runs")
+	}
+	if _, ok := fresh.known("msg-other"); ok {
+		t.Error("unknown id reported as known")
+	}
+}
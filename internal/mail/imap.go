@@ -3,6 +3,7 @@ package mail
 import (
 	"fmt"
 	"io"
+	"net"
 	"strings"
 	"time"
 
@@ -41,6 +42,11 @@ type Email struct {
 	Unread       bool
 	References   string       // For threading
 	Attachments  []Attachment // Attachment metadata (content fetched on demand)
+	Calendar     string       // text/calendar part (meeting invitation), if any
+	Category     string       // AI triage category, empty if not classified
+	Priority     int          // AI triage priority, 1 (high) to 3 (low); 0 if not classified
+	Flagged      bool
+	Headers      map[string]string // Header fields by canonical name, for mail rules
 }
 
 func NewIMAPClient(creds *auth.Credentials) (*IMAPClient, error) {
@@ -51,6 +57,17 @@ func NewIMAPClient(creds *auth.Credentials) (*IMAPClient, error) {
 		return nil, fmt.Errorf("failed to connect to IMAP server: %w", err)
 	}
 
+	return login(client, creds)
+}
+
+// NewIMAPClientFromConn logs in over an already established connection.
+// Used to talk to servers that are not reachable via DialTLS, such as an
+// in-process test server.
+func NewIMAPClientFromConn(conn net.Conn, creds *auth.Credentials) (*IMAPClient, error) {
+	return login(imapclient.New(conn, nil), creds)
+}
+
+func login(client *imapclient.Client, creds *auth.Credentials) (*IMAPClient, error) {
 	if err := client.Login(creds.Email, creds.Password).Wait(); err != nil {
 		client.Close()
 		return nil, fmt.Errorf("login failed: %w", err)
@@ -334,28 +351,34 @@ func (c *IMAPClient) parseMessage(msg *imapclient.FetchMessageBuffer) Email {
 
 	email.Unread = true
 	for _, flag := range msg.Flags {
-		if flag == imap.FlagSeen {
+		switch flag {
+		case imap.FlagSeen:
 			email.Unread = false
-			break
+		case imap.FlagFlagged:
+			email.Flagged = true
 		}
 	}
 
 	if len(msg.BodySection) > 0 {
-		body, snippet := c.parseBody(msg.BodySection[0].Bytes)
+		body, snippet, calendar := parseBody(msg.BodySection[0].Bytes)
 		email.Body = body
 		email.Snippet = snippet
+		email.Calendar = calendar
+		email.Headers = parseHeaders(msg.BodySection[0].Bytes)
 	}
 
 	return email
 }
 
-func (c *IMAPClient) parseBody(body []byte) (string, string) {
+// parseBody returns the text of a message, its snippet and its first
+// text/calendar part (invitations are sent inline or as .ics attachments)
+func parseBody(body []byte) (string, string, string) {
 	mr, err := mail.CreateReader(strings.NewReader(string(body)))
 	if err != nil {
-		return string(body), truncateSnippet(string(body))
+		return string(body), truncateSnippet(string(body)), ""
 	}
 
-	var textBody string
+	var textBody, calendar string
 	for {
 		part, err := mr.NextPart()
 		if err == io.EOF {
@@ -374,6 +397,15 @@ func (c *IMAPClient) parseBody(body []byte) (string, string) {
 			} else if strings.HasPrefix(contentType, "text/html") && textBody == "" {
 				b, _ := io.ReadAll(part.Body)
 				textBody = stripHTML(string(b))
+			} else if isCalendarType(contentType) && calendar == "" {
+				b, _ := io.ReadAll(part.Body)
+				calendar = string(b)
+			}
+		case *mail.AttachmentHeader:
+			contentType, _, _ := h.ContentType()
+			if isCalendarType(contentType) && calendar == "" {
+				b, _ := io.ReadAll(part.Body)
+				calendar = string(b)
 			}
 		}
 	}
@@ -382,7 +414,11 @@ func (c *IMAPClient) parseBody(body []byte) (string, string) {
 		textBody = string(body)
 	}
 
-	return textBody, truncateSnippet(textBody)
+	return textBody, truncateSnippet(textBody), calendar
+}
+
+func isCalendarType(contentType string) bool {
+	return contentType == "text/calendar" || contentType == "application/ics"
 }
 
 func truncateSnippet(s string) string {
@@ -601,6 +637,61 @@ func (c *IMAPClient) MoveToTrash(uids []imap.UID) error {
 	return nil
 }
 
+// MoveMessages moves messages from the selected mailbox to mailbox
+func (c *IMAPClient) MoveMessages(uids []imap.UID, mailbox string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	if !c.mailboxExists(mailbox) {
+		return fmt.Errorf("no such mailbox: %s", mailbox)
+	}
+
+	uidSet := imap.UIDSet{}
+	for _, uid := range uids {
+		uidSet.AddNum(uid)
+	}
+	_, err := c.client.Move(uidSet, mailbox).Wait()
+	return err
+}
+
+// CopyMessages copies messages from the selected mailbox to mailbox; on
+// Gmail that adds the mailbox's label
+func (c *IMAPClient) CopyMessages(uids []imap.UID, mailbox string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	if !c.mailboxExists(mailbox) {
+		return fmt.Errorf("no such mailbox: %s", mailbox)
+	}
+
+	uidSet := imap.UIDSet{}
+	for _, uid := range uids {
+		uidSet.AddNum(uid)
+	}
+	_, err := c.client.Copy(uidSet, mailbox).Wait()
+	return err
+}
+
+func (c *IMAPClient) SetFlagged(uids []imap.UID, flagged bool) error {
+	if len(uids) == 0 {
+		return nil
+	}
+
+	uidSet := imap.UIDSet{}
+	for _, uid := range uids {
+		uidSet.AddNum(uid)
+	}
+
+	storeFlags := &imap.StoreFlags{
+		Op:    imap.StoreFlagsAdd,
+		Flags: []imap.Flag{imap.FlagFlagged},
+	}
+	if !flagged {
+		storeFlags.Op = imap.StoreFlagsDel
+	}
+	return c.client.Store(uidSet, storeFlags, nil).Close()
+}
+
 func (c *IMAPClient) findTrashFolder() (string, error) {
 	// Try Gmail-specific trash folder first
 	if c.mailboxExists(GmailTrash) {
@@ -825,9 +916,10 @@ func (c *IMAPClient) SearchMessages(mailbox string, query string) ([]Email, erro
 		email := c.parseMessageHeader(msg)
 		// Parse body if available
 		if len(msg.BodySection) > 0 {
-			body, snippet := c.parseBody(msg.BodySection[0].Bytes)
+			body, snippet, calendar := parseBody(msg.BodySection[0].Bytes)
 			email.Body = body
 			email.Snippet = snippet
+			email.Calendar = calendar
 		}
 		emails = append(emails, email)
 	}
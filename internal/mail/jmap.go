@@ -0,0 +1,1121 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+
+	"maily/internal/auth"
+)
+
+// JMAP capability URNs
+const (
+	jmapCore       = "urn:ietf:params:jmap:core"
+	jmapMail       = "urn:ietf:params:jmap:mail"
+	jmapSubmission = "urn:ietf:params:jmap:submission"
+)
+
+// jmapBatchSize is how many ids are sent per /get call and fetched per /query page
+const jmapBatchSize = 100
+
+// jmapEmailProperties are fetched for full messages
+var jmapEmailProperties = []string{
+	"id", "threadId", "mailboxIds", "keywords", "messageId", "inReplyTo",
+	"from", "to", "replyTo", "subject", "sentAt", "receivedAt",
+	"textBody", "htmlBody", "bodyValues", "attachments", "headers",
+}
+
+// JMAPClient talks to a JMAP server (RFC 8620/8621), e.g. Fastmail or Stalwart.
+//
+// Email ids are mapped to UIDs with an idMap; UIDs the client has not handed
+// out yet are found by re-listing the selected mailbox.
+type JMAPClient struct {
+	creds *auth.Credentials
+	http  *http.Client
+
+	mu        sync.Mutex
+	session   *jmapSession
+	basicAuth bool
+	accountID string
+	mailboxes []jmapMailbox
+	selected  string
+	ids       idMap
+}
+
+type jmapSession struct {
+	APIURL          string            `json:"apiUrl"`
+	EventSourceURL  string            `json:"eventSourceUrl"`
+	PrimaryAccounts map[string]string `json:"primaryAccounts"`
+}
+
+type jmapMailbox struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	ParentID string `json:"parentId"`
+	Role     string `json:"role"`
+
+	path string
+}
+
+type jmapAddress struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type jmapBodyPart struct {
+	PartID string `json:"partId"`
+	BlobID string `json:"blobId"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+}
+
+type jmapBodyValue struct {
+	Value string `json:"value"`
+}
+
+type jmapEmail struct {
+	ID          string                   `json:"id"`
+	ThreadID    string                   `json:"threadId"`
+	MailboxIDs  map[string]bool          `json:"mailboxIds"`
+	Keywords    map[string]bool          `json:"keywords"`
+	MessageID   []string                 `json:"messageId"`
+	InReplyTo   []string                 `json:"inReplyTo"`
+	From        []jmapAddress            `json:"from"`
+	To          []jmapAddress            `json:"to"`
+	ReplyTo     []jmapAddress            `json:"replyTo"`
+	Subject     string                   `json:"subject"`
+	SentAt      *time.Time               `json:"sentAt"`
+	ReceivedAt  time.Time                `json:"receivedAt"`
+	TextBody    []jmapBodyPart           `json:"textBody"`
+	HTMLBody    []jmapBodyPart           `json:"htmlBody"`
+	BodyValues  map[string]jmapBodyValue `json:"bodyValues"`
+	Attachments []jmapBodyPart           `json:"attachments"`
+	Headers     []jmapHeader             `json:"headers"`
+}
+
+type jmapHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type jmapInvocation struct {
+	name   string
+	args   any
+	callID string
+}
+
+func (i jmapInvocation) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{i.name, i.args, i.callID})
+}
+
+type jmapRequest struct {
+	Using       []string         `json:"using"`
+	MethodCalls []jmapInvocation `json:"methodCalls"`
+}
+
+type jmapResponse struct {
+	MethodResponses [][]json.RawMessage `json:"methodResponses"`
+}
+
+type jmapMethodError struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// NewJMAPClient fetches the JMAP session and returns a connected client
+func NewJMAPClient(creds *auth.Credentials) (*JMAPClient, error) {
+	c := newJMAPClient(creds)
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// newJMAPClient returns a client that connects on first use
+func newJMAPClient(creds *auth.Credentials) *JMAPClient {
+	return &JMAPClient{
+		creds: creds,
+		http:  &http.Client{Timeout: 60 * time.Second},
+		ids:   idMap{path: idsPath(creds.Email)},
+	}
+}
+
+func (c *JMAPClient) connect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.session != nil {
+		return nil
+	}
+
+	// API tokens use Bearer auth; app passwords use Basic
+	resp, err := c.getSession(false)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		resp, err = c.getSession(true)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to JMAP server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("login failed: %s", resp.Status)
+	}
+
+	var session jmapSession
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return fmt.Errorf("invalid JMAP session: %w", err)
+	}
+
+	accountID := session.PrimaryAccounts[jmapMail]
+	if accountID == "" {
+		return fmt.Errorf("server has no JMAP mail account")
+	}
+
+	// Session URLs may be relative to the session resource
+	base, err := url.Parse(c.creds.JMAPURL)
+	if err != nil {
+		return err
+	}
+	if u, err := base.Parse(session.APIURL); err == nil {
+		session.APIURL = u.String()
+	}
+	if session.EventSourceURL != "" && !strings.Contains(session.EventSourceURL, "://") {
+		session.EventSourceURL = base.Scheme + "://" + base.Host + session.EventSourceURL
+	}
+
+	c.session = &session
+	c.accountID = accountID
+	return nil
+}
+
+func (c *JMAPClient) getSession(basic bool) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, c.creds.JMAPURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.basicAuth = basic
+	c.authorize(req)
+	return c.http.Do(req)
+}
+
+func (c *JMAPClient) authorize(req *http.Request) {
+	if c.basicAuth {
+		req.SetBasicAuth(c.creds.Email, c.creds.Password)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.creds.Password)
+	}
+}
+
+// do sends a batch of method calls and returns the raw arguments of each response
+func (c *JMAPClient) do(calls ...jmapInvocation) ([]json.RawMessage, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(jmapRequest{
+		Using:       []string{jmapCore, jmapMail, jmapSubmission},
+		MethodCalls: calls,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.session.APIURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("JMAP request failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result jmapResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid JMAP response: %w", err)
+	}
+
+	args := make([]json.RawMessage, len(calls))
+	for _, r := range result.MethodResponses {
+		if len(r) != 3 {
+			continue
+		}
+		var name, callID string
+		json.Unmarshal(r[0], &name)
+		json.Unmarshal(r[2], &callID)
+
+		for i, call := range calls {
+			if call.callID != callID {
+				continue
+			}
+			if name == "error" {
+				var merr jmapMethodError
+				json.Unmarshal(r[1], &merr)
+				if merr.Type == "cannotCalculateChanges" {
+					return nil, ErrStateReset
+				}
+				if merr.Description != "" {
+					return nil, fmt.Errorf("%s failed: %s: %s", call.name, merr.Type, merr.Description)
+				}
+				return nil, fmt.Errorf("%s failed: %s", call.name, merr.Type)
+			}
+			args[i] = r[1]
+		}
+	}
+
+	for i, a := range args {
+		if a == nil {
+			return nil, fmt.Errorf("%s: no response", calls[i].name)
+		}
+	}
+	return args, nil
+}
+
+// call sends a single method call and decodes its response into result
+func (c *JMAPClient) call(method string, args map[string]any, result any) error {
+	// The account id comes with the session
+	if err := c.connect(); err != nil {
+		return err
+	}
+	args["accountId"] = c.accountID
+	resp, err := c.do(jmapInvocation{name: method, args: args, callID: "0"})
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp[0], result)
+}
+
+// setEmails runs Email/set and reports the first per-object failure
+func (c *JMAPClient) setEmails(args map[string]any) (map[string]jmapEmail, error) {
+	var resp struct {
+		Created      map[string]jmapEmail       `json:"created"`
+		NotCreated   map[string]jmapMethodError `json:"notCreated"`
+		NotUpdated   map[string]jmapMethodError `json:"notUpdated"`
+		NotDestroyed map[string]jmapMethodError `json:"notDestroyed"`
+	}
+	if err := c.call("Email/set", args, &resp); err != nil {
+		return nil, err
+	}
+	for _, failed := range []map[string]jmapMethodError{resp.NotCreated, resp.NotUpdated, resp.NotDestroyed} {
+		for _, e := range failed {
+			return nil, fmt.Errorf("Email/set failed: %s %s", e.Type, e.Description)
+		}
+	}
+	return resp.Created, nil
+}
+
+func (c *JMAPClient) Close() error {
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+func (c *JMAPClient) loadMailboxes() ([]jmapMailbox, error) {
+	c.mu.Lock()
+	cached := c.mailboxes
+	c.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	var resp struct {
+		List []jmapMailbox `json:"list"`
+	}
+	err := c.call("Mailbox/get", map[string]any{
+		"ids":        nil,
+		"properties": []string{"id", "name", "parentId", "role"},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*jmapMailbox, len(resp.List))
+	for i := range resp.List {
+		byID[resp.List[i].ID] = &resp.List[i]
+	}
+	// Nested mailboxes are shown as "Parent/Child" like IMAP hierarchies
+	var pathOf func(mbox *jmapMailbox, depth int) string
+	pathOf = func(mbox *jmapMailbox, depth int) string {
+		name := mbox.Name
+		if mbox.Role == "inbox" {
+			name = INBOX
+		}
+		if parent := byID[mbox.ParentID]; parent != nil && depth < 32 {
+			return pathOf(parent, depth+1) + "/" + name
+		}
+		return name
+	}
+	for i := range resp.List {
+		resp.List[i].path = pathOf(&resp.List[i], 0)
+	}
+
+	c.mu.Lock()
+	c.mailboxes = resp.List
+	c.mu.Unlock()
+	return resp.List, nil
+}
+
+func (c *JMAPClient) mailboxID(name string) (string, error) {
+	mailboxes, err := c.loadMailboxes()
+	if err != nil {
+		return "", err
+	}
+	for _, mbox := range mailboxes {
+		if mbox.path == name || (strings.EqualFold(name, INBOX) && mbox.Role == "inbox") {
+			return mbox.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no such mailbox: %s", name)
+}
+
+func (c *JMAPClient) mailboxByRole(role string) (string, error) {
+	mailboxes, err := c.loadMailboxes()
+	if err != nil {
+		return "", err
+	}
+	for _, mbox := range mailboxes {
+		if mbox.Role == role {
+			return mbox.ID, nil
+		}
+	}
+	return "", fmt.Errorf("%s folder not found", role)
+}
+
+func (c *JMAPClient) ListMailboxes() ([]string, error) {
+	mailboxes, err := c.loadMailboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(mailboxes))
+	for i, mbox := range mailboxes {
+		names[i] = mbox.path
+	}
+	return names, nil
+}
+
+func (c *JMAPClient) SelectMailbox(name string) error {
+	_, err := c.SelectMailboxWithInfo(name)
+	return err
+}
+
+// SelectMailboxWithInfo resolves the mailbox. JMAP ids never change, so the
+// UIDVALIDITY is derived from the mailbox id.
+func (c *JMAPClient) SelectMailboxWithInfo(name string) (*MailboxInfo, error) {
+	id, err := c.mailboxID(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		List []struct {
+			TotalEmails uint32 `json:"totalEmails"`
+		} `json:"list"`
+	}
+	if err := c.call("Mailbox/get", map[string]any{
+		"ids":        []string{id},
+		"properties": []string{"totalEmails"},
+	}, &resp); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.selected = name
+	c.mu.Unlock()
+
+	info := &MailboxInfo{UIDValidity: uint32(uidForID(id))}
+	if len(resp.List) > 0 {
+		info.NumMessages = resp.List[0].TotalEmails
+	}
+	return info, nil
+}
+
+// queryIDs runs Email/query, following pages until limit ids (0 for all)
+func (c *JMAPClient) queryIDs(filter map[string]any, limit int) ([]string, error) {
+	var ids []string
+	for {
+		pageSize := jmapBatchSize
+		if limit > 0 && limit-len(ids) < pageSize {
+			pageSize = limit - len(ids)
+		}
+
+		var resp struct {
+			IDs []string `json:"ids"`
+		}
+		err := c.call("Email/query", map[string]any{
+			"filter":   filter,
+			"sort":     []map[string]any{{"property": "receivedAt", "isAscending": false}},
+			"position": len(ids),
+			"limit":    pageSize,
+		}, &resp)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, resp.IDs...)
+		if len(resp.IDs) < pageSize || (limit > 0 && len(ids) >= limit) {
+			return ids, nil
+		}
+	}
+}
+
+// getEmails runs Email/get in batches. Missing ids are silently skipped.
+func (c *JMAPClient) getEmails(ids []string, properties []string) ([]jmapEmail, error) {
+	var emails []jmapEmail
+	for start := 0; start < len(ids); start += jmapBatchSize {
+		end := min(start+jmapBatchSize, len(ids))
+
+		var resp struct {
+			List []jmapEmail `json:"list"`
+		}
+		err := c.call("Email/get", map[string]any{
+			"ids":        ids[start:end],
+			"properties": properties,
+			// All text parts, so an invitation's text/calendar part comes
+			// with its value too
+			"fetchAllBodyValues": true,
+		}, &resp)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, resp.List...)
+	}
+	return emails, nil
+}
+
+func (c *JMAPClient) fetchEmails(ids []string) ([]Email, error) {
+	list, err := c.getEmails(ids, jmapEmailProperties)
+	if err != nil {
+		return nil, err
+	}
+
+	emails := make([]Email, 0, len(list))
+	for _, e := range list {
+		emails = append(emails, c.toEmail(e))
+	}
+	sort.Slice(emails, func(i, j int) bool {
+		return emails[i].InternalDate.After(emails[j].InternalDate)
+	})
+	return emails, nil
+}
+
+// resolve maps UIDs back to JMAP ids, re-listing the selected mailbox for
+// UIDs this client has not handed out (e.g. ones loaded from the disk cache)
+func (c *JMAPClient) resolve(uids []imap.UID) ([]string, error) {
+	if ids, ok := c.ids.lookup(uids); ok {
+		return ids, nil
+	}
+
+	c.mu.Lock()
+	selected := c.selected
+	c.mu.Unlock()
+	if selected == "" {
+		selected = INBOX
+	}
+
+	mailboxID, err := c.mailboxID(selected)
+	if err != nil {
+		return nil, err
+	}
+	all, err := c.queryIDs(map[string]any{"inMailbox": mailboxID}, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range all {
+		c.ids.remember(id)
+	}
+
+	if ids, ok := c.ids.lookup(uids); ok {
+		return ids, nil
+	}
+	return nil, fmt.Errorf("message not found in %s", selected)
+}
+
+func (c *JMAPClient) toEmail(e jmapEmail) Email {
+	email := Email{
+		UID:          c.ids.remember(e.ID),
+		InternalDate: e.ReceivedAt,
+		From:         formatJMAPAddress(e.From),
+		To:           formatJMAPAddress(e.To),
+		Subject:      e.Subject,
+		Unread:       !e.Keywords["$seen"],
+		Flagged:      e.Keywords["$flagged"],
+		References:   strings.Join(e.InReplyTo, " "),
+	}
+	if len(e.ReplyTo) > 0 {
+		email.ReplyTo = e.ReplyTo[0].Email
+	}
+	if len(e.MessageID) > 0 {
+		email.MessageID = e.MessageID[0]
+	}
+	if e.SentAt != nil {
+		email.Date = *e.SentAt
+	} else {
+		email.Date = e.ReceivedAt
+	}
+
+	email.Body = jmapBodyText(e, e.TextBody)
+	if email.Body == "" {
+		email.Body = jmapBodyText(e, e.HTMLBody)
+	}
+	email.Snippet = truncateSnippet(email.Body)
+
+	if len(e.Headers) > 0 {
+		email.Headers = make(map[string]string, len(e.Headers))
+		for _, h := range e.Headers {
+			addHeader(email.Headers, h.Name, h.Value)
+		}
+	}
+
+	for _, part := range e.Attachments {
+		if value, ok := e.BodyValues[part.PartID]; ok && email.Calendar == "" && isCalendarType(strings.ToLower(part.Type)) {
+			email.Calendar = value.Value
+		}
+		if part.Name == "" {
+			continue
+		}
+		email.Attachments = append(email.Attachments, Attachment{
+			PartID:      part.BlobID,
+			Filename:    part.Name,
+			ContentType: part.Type,
+			Size:        part.Size,
+		})
+	}
+
+	return email
+}
+
+func jmapBodyText(e jmapEmail, parts []jmapBodyPart) string {
+	var texts []string
+	for _, part := range parts {
+		value, ok := e.BodyValues[part.PartID]
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(part.Type, "text/html") {
+			texts = append(texts, stripHTML(value.Value))
+		} else {
+			texts = append(texts, value.Value)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func formatJMAPAddress(addrs []jmapAddress) string {
+	if len(addrs) == 0 {
+		return ""
+	}
+	if addrs[0].Name != "" {
+		return fmt.Sprintf("%s <%s>", addrs[0].Name, addrs[0].Email)
+	}
+	return addrs[0].Email
+}
+
+func (c *JMAPClient) FetchUIDsAndFlags(mailbox string, since time.Time) (map[imap.UID]bool, error) {
+	mailboxID, err := c.mailboxID(mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select mailbox: %w", err)
+	}
+
+	ids, err := c.queryIDs(map[string]any{
+		"inMailbox": mailboxID,
+		"after":     since.UTC().Format(time.RFC3339),
+	}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	emails, err := c.getEmails(ids, []string{"id", "keywords"})
+	if err != nil {
+		return nil, fmt.Errorf("fetch failed: %w", err)
+	}
+
+	result := make(map[imap.UID]bool, len(emails))
+	for _, e := range emails {
+		result[c.ids.remember(e.ID)] = !e.Keywords["$seen"]
+	}
+	return result, nil
+}
+
+func (c *JMAPClient) FetchMessagesByUIDs(mailbox string, uids []imap.UID) ([]Email, error) {
+	if len(uids) == 0 {
+		return []Email{}, nil
+	}
+	if err := c.SelectMailbox(mailbox); err != nil {
+		return nil, fmt.Errorf("failed to select mailbox: %w", err)
+	}
+
+	ids, err := c.resolve(uids)
+	if err != nil {
+		return nil, err
+	}
+	return c.fetchEmails(ids)
+}
+
+func (c *JMAPClient) FetchMessages(mailbox string, limit uint32) ([]Email, error) {
+	return c.queryEmails(mailbox, map[string]any{}, limit)
+}
+
+func (c *JMAPClient) FetchMessagesSince(mailbox string, since time.Time, limit uint32) ([]Email, error) {
+	return c.queryEmails(mailbox, map[string]any{"after": since.UTC().Format(time.RFC3339)}, limit)
+}
+
+// SearchMessages uses the server's full-text search
+func (c *JMAPClient) SearchMessages(mailbox string, query string) ([]Email, error) {
+	emails, err := c.queryEmails(mailbox, map[string]any{"text": query}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	return emails, nil
+}
+
+func (c *JMAPClient) queryEmails(mailbox string, filter map[string]any, limit uint32) ([]Email, error) {
+	mailboxID, err := c.mailboxID(mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select mailbox: %w", err)
+	}
+	c.mu.Lock()
+	c.selected = mailbox
+	c.mu.Unlock()
+
+	filter["inMailbox"] = mailboxID
+	ids, err := c.queryIDs(filter, int(limit))
+	if err != nil {
+		return nil, err
+	}
+	return c.fetchEmails(ids)
+}
+
+func (c *JMAPClient) setKeyword(uids []imap.UID, keyword string, set bool) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	ids, err := c.resolve(uids)
+	if err != nil {
+		return err
+	}
+
+	var value any
+	if set {
+		value = true
+	}
+	update := make(map[string]any, len(ids))
+	for _, id := range ids {
+		update[id] = map[string]any{"keywords/" + keyword: value}
+	}
+	_, err = c.setEmails(map[string]any{"update": update})
+	return err
+}
+
+func (c *JMAPClient) MarkAsRead(uid imap.UID) error {
+	return c.setKeyword([]imap.UID{uid}, "$seen", true)
+}
+
+func (c *JMAPClient) MarkAsUnread(uid imap.UID) error {
+	return c.setKeyword([]imap.UID{uid}, "$seen", false)
+}
+
+func (c *JMAPClient) MarkMessagesAsRead(uids []imap.UID) error {
+	return c.setKeyword(uids, "$seen", true)
+}
+
+func (c *JMAPClient) DeleteMessage(uid imap.UID) error {
+	return c.DeleteMessages([]imap.UID{uid})
+}
+
+func (c *JMAPClient) DeleteMessages(uids []imap.UID) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	ids, err := c.resolve(uids)
+	if err != nil {
+		return err
+	}
+	_, err = c.setEmails(map[string]any{"destroy": ids})
+	return err
+}
+
+func (c *JMAPClient) MoveToTrash(uids []imap.UID) error {
+	if err := c.moveToRole(uids, "trash"); err != nil {
+		return fmt.Errorf("failed to move to trash: %w", err)
+	}
+	return nil
+}
+
+func (c *JMAPClient) ArchiveMessages(uids []imap.UID) error {
+	return c.moveToRole(uids, "archive")
+}
+
+func (c *JMAPClient) moveToRole(uids []imap.UID, role string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	dest, err := c.mailboxByRole(role)
+	if err != nil {
+		return err
+	}
+	return c.setMailbox(uids, dest, true)
+}
+
+// MoveMessages moves messages to mailbox
+func (c *JMAPClient) MoveMessages(uids []imap.UID, mailbox string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	dest, err := c.mailboxID(mailbox)
+	if err != nil {
+		return err
+	}
+	return c.setMailbox(uids, dest, true)
+}
+
+// CopyMessages adds mailbox to the mailboxes of messages, which keep the
+// ones they are in
+func (c *JMAPClient) CopyMessages(uids []imap.UID, mailbox string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	dest, err := c.mailboxID(mailbox)
+	if err != nil {
+		return err
+	}
+	return c.setMailbox(uids, dest, false)
+}
+
+func (c *JMAPClient) SetFlagged(uids []imap.UID, flagged bool) error {
+	return c.setKeyword(uids, "$flagged", flagged)
+}
+
+// setMailbox puts messages in the mailbox with id dest, taking them out of
+// all others if move is set
+func (c *JMAPClient) setMailbox(uids []imap.UID, dest string, move bool) error {
+	ids, err := c.resolve(uids)
+	if err != nil {
+		return err
+	}
+
+	update := make(map[string]any, len(ids))
+	for _, id := range ids {
+		if move {
+			update[id] = map[string]any{"mailboxIds": map[string]bool{dest: true}}
+		} else {
+			update[id] = map[string]any{"mailboxIds/" + dest: true}
+		}
+	}
+	_, err = c.setEmails(map[string]any{"update": update})
+	return err
+}
+
+// SaveDraft saves an email to the Drafts mailbox
+func (c *JMAPClient) SaveDraft(to, subject, body string) error {
+	_, _, err := c.createDraft(to, subject, body, "", "")
+	return err
+}
+
+// createDraft stores a message in Drafts and returns its id and the drafts mailbox id
+func (c *JMAPClient) createDraft(to, subject, body, inReplyTo, references string) (string, string, error) {
+	drafts, err := c.mailboxByRole("drafts")
+	if err != nil {
+		return "", "", err
+	}
+
+	draft := map[string]any{
+		"mailboxIds": map[string]bool{drafts: true},
+		"keywords":   map[string]bool{"$draft": true, "$seen": true},
+		"from":       []jmapAddress{{Email: c.creds.Email}},
+		"to":         []jmapAddress{{Email: to}},
+		"subject":    subject,
+		"bodyValues": map[string]any{"body": map[string]string{"value": body}},
+		"textBody":   []map[string]string{{"partId": "body", "type": "text/plain"}},
+	}
+	if inReplyTo != "" {
+		draft["inReplyTo"] = messageIDs(inReplyTo)
+		draft["references"] = messageIDs(references + " " + inReplyTo)
+	}
+
+	created, err := c.setEmails(map[string]any{"create": map[string]any{"draft": draft}})
+	if err != nil {
+		return "", "", err
+	}
+	return created["draft"].ID, drafts, nil
+}
+
+// messageIDs splits a header value into bare message ids, as JMAP expects
+func messageIDs(header string) []string {
+	var ids []string
+	for _, f := range strings.Fields(header) {
+		if id := strings.Trim(f, "<>"); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Send submits a new message through EmailSubmission
+func (c *JMAPClient) Send(to, subject, body string) error {
+	return c.submit(to, subject, body, "", "")
+}
+
+// Reply submits a reply with threading headers through EmailSubmission
+func (c *JMAPClient) Reply(to, subject, body, inReplyTo, references string) error {
+	return c.submit(to, subject, body, inReplyTo, references)
+}
+
+func (c *JMAPClient) submit(to, subject, body, inReplyTo, references string) error {
+	identityID, err := c.identityID()
+	if err != nil {
+		return err
+	}
+
+	emailID, drafts, err := c.createDraft(to, subject, body, inReplyTo, references)
+	if err != nil {
+		return fmt.Errorf("failed to create message: %w", err)
+	}
+
+	// Once sent, move the message out of Drafts into Sent
+	onSuccess := map[string]any{"keywords/$draft": nil}
+	if sent, err := c.mailboxByRole("sent"); err == nil {
+		onSuccess["mailboxIds/"+drafts] = nil
+		onSuccess["mailboxIds/"+sent] = true
+	}
+
+	var resp struct {
+		NotCreated map[string]jmapMethodError `json:"notCreated"`
+	}
+	err = c.call("EmailSubmission/set", map[string]any{
+		"create": map[string]any{
+			"send": map[string]string{"identityId": identityID, "emailId": emailID},
+		},
+		"onSuccessUpdateEmail": map[string]any{"#send": onSuccess},
+	}, &resp)
+	if err != nil {
+		return fmt.Errorf("failed to send: %w", err)
+	}
+	for _, e := range resp.NotCreated {
+		return fmt.Errorf("failed to send: %s %s", e.Type, e.Description)
+	}
+	return nil
+}
+
+// identityID picks the sending identity matching the account address
+func (c *JMAPClient) identityID() (string, error) {
+	var resp struct {
+		List []struct {
+			ID    string `json:"id"`
+			Email string `json:"email"`
+		} `json:"list"`
+	}
+	if err := c.call("Identity/get", map[string]any{"ids": nil}, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.List) == 0 {
+		return "", fmt.Errorf("no sending identity configured")
+	}
+	for _, identity := range resp.List {
+		if strings.EqualFold(identity.Email, c.creds.Email) {
+			return identity.ID, nil
+		}
+	}
+	return resp.List[0].ID, nil
+}
+
+// State returns the current Email and Mailbox states
+func (c *JMAPClient) State(mailbox string) (*SyncState, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	args := map[string]any{"accountId": c.accountID, "ids": []string{}}
+	resp, err := c.do(
+		jmapInvocation{name: "Email/get", args: args, callID: "email"},
+		jmapInvocation{name: "Mailbox/get", args: args, callID: "mailbox"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var email, mbox struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(resp[0], &email); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(resp[1], &mbox); err != nil {
+		return nil, err
+	}
+	return &SyncState{Email: email.State, Mailbox: mbox.State}, nil
+}
+
+// Changes uses Mailbox/changes and Email/changes to describe what happened
+// to a mailbox since the given state
+func (c *JMAPClient) Changes(mailbox string, since SyncState) (*MailboxChanges, error) {
+	mailboxID, err := c.mailboxID(mailbox)
+	if err != nil {
+		return nil, err
+	}
+
+	mailboxState := since.Mailbox
+	for {
+		var resp struct {
+			NewState       string   `json:"newState"`
+			HasMoreChanges bool     `json:"hasMoreChanges"`
+			Created        []string `json:"created"`
+			Updated        []string `json:"updated"`
+			Destroyed      []string `json:"destroyed"`
+		}
+		if err := c.call("Mailbox/changes", map[string]any{"sinceState": mailboxState}, &resp); err != nil {
+			return nil, err
+		}
+		for _, id := range resp.Destroyed {
+			if id == mailboxID {
+				return nil, ErrStateReset
+			}
+		}
+		if len(resp.Created)+len(resp.Updated)+len(resp.Destroyed) > 0 {
+			// Names or hierarchy may have changed
+			c.mu.Lock()
+			c.mailboxes = nil
+			c.mu.Unlock()
+		}
+		mailboxState = resp.NewState
+		if !resp.HasMoreChanges {
+			break
+		}
+	}
+
+	changes := &MailboxChanges{Flags: make(map[imap.UID]bool)}
+	var changed []string
+	emailState := since.Email
+	for {
+		var resp struct {
+			NewState       string   `json:"newState"`
+			HasMoreChanges bool     `json:"hasMoreChanges"`
+			Created        []string `json:"created"`
+			Updated        []string `json:"updated"`
+			Destroyed      []string `json:"destroyed"`
+		}
+		if err := c.call("Email/changes", map[string]any{"sinceState": emailState}, &resp); err != nil {
+			return nil, err
+		}
+		changed = append(changed, resp.Created...)
+		changed = append(changed, resp.Updated...)
+		for _, id := range resp.Destroyed {
+			// Ids never handed out cannot be in the cache
+			if uid, ok := c.ids.known(id); ok {
+				changes.Removed = append(changes.Removed, uid)
+			}
+		}
+		emailState = resp.NewState
+		if !resp.HasMoreChanges {
+			break
+		}
+	}
+
+	// Changes are account-wide; keep the ones that touch this mailbox
+	emails, err := c.getEmails(changed, []string{"id", "mailboxIds", "keywords"})
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(emails))
+	for _, e := range emails {
+		found[e.ID] = true
+		uid := c.ids.remember(e.ID)
+		if e.MailboxIDs[mailboxID] {
+			changes.Flags[uid] = !e.Keywords["$seen"]
+		} else {
+			changes.Removed = append(changes.Removed, uid)
+		}
+	}
+	for _, id := range changed {
+		if uid, ok := c.ids.known(id); ok && !found[id] {
+			changes.Removed = append(changes.Removed, uid)
+		}
+	}
+
+	changes.State = SyncState{Email: emailState, Mailbox: mailboxState}
+	return changes, nil
+}
+
+// Watch listens on the JMAP EventSource and calls notify whenever the
+// server reports an Email or Mailbox state change
+func (c *JMAPClient) Watch(ctx context.Context, notify func()) error {
+	if err := c.connect(); err != nil {
+		return err
+	}
+	if c.session.EventSourceURL == "" {
+		return fmt.Errorf("server does not support push")
+	}
+
+	eventURL := strings.NewReplacer(
+		"{types}", "Email,Mailbox",
+		"{closeafter}", "no",
+		"{ping}", "60",
+	).Replace(c.session.EventSourceURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, eventURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	c.authorize(req)
+
+	// No timeout: the stream stays open until ctx is cancelled
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("event source failed: %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if event == "" || event == "state" {
+				notify()
+			}
+		case line == "":
+			event = ""
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("event stream closed")
+}
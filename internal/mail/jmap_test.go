@@ -0,0 +1,561 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+
+	"maily/internal/auth"
+)
+
+// fakeJMAP is a small in-memory JMAP server covering the methods JMAPClient uses
+type fakeJMAP struct {
+	mu          sync.Mutex
+	server      *httptest.Server
+	emails      map[string]*fakeJMAPEmail
+	nextID      int
+	state       int
+	log         []fakeJMAPChange // one entry per state bump
+	submissions []string         // email ids handed to EmailSubmission/set
+	push        chan struct{}
+}
+
+type fakeJMAPEmail struct {
+	id         string
+	mailboxIDs map[string]bool
+	keywords   map[string]bool
+	subject    string
+	from       string
+	body       string
+	calendar   string // an inline text/calendar part, if set
+	inReplyTo  []string
+	receivedAt time.Time
+}
+
+type fakeJMAPChange struct {
+	id   string
+	kind string // created, updated, destroyed
+}
+
+var fakeJMAPMailboxes = []map[string]any{
+	{"id": "mb-inbox", "name": "Inbox", "parentId": nil, "role": "inbox"},
+	{"id": "mb-drafts", "name": "Drafts", "parentId": nil, "role": "drafts"},
+	{"id": "mb-sent", "name": "Sent", "parentId": nil, "role": "sent"},
+	{"id": "mb-trash", "name": "Trash", "parentId": nil, "role": "trash"},
+	{"id": "mb-work", "name": "Work", "parentId": "mb-inbox", "role": nil},
+}
+
+func newFakeJMAP(t *testing.T) *fakeJMAP {
+	// Clients keep their UID tables in the cache under HOME
+	t.Setenv("HOME", t.TempDir())
+	f := &fakeJMAP{emails: make(map[string]*fakeJMAPEmail), push: make(chan struct{}, 1)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/jmap", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"apiUrl":          "/api",
+			"eventSourceUrl":  "/events?types={types}&closeafter={closeafter}&ping={ping}",
+			"primaryAccounts": map[string]string{jmapMail: "acct"},
+		})
+	})
+	mux.HandleFunc("/api", f.handleAPI)
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-f.push:
+				fmt.Fprintf(w, "event: state\ndata: {\"changed\":{}}\n\n")
+				w.(http.Flusher).Flush()
+			}
+		}
+	})
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeJMAP) creds() *auth.Credentials {
+	creds := auth.JMAPCredentials("me@example.com", "token", f.server.URL)
+	return &creds
+}
+
+// add delivers a message into a mailbox and returns its id
+func (f *fakeJMAP) add(mailbox, subject string, seen bool) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keywords := map[string]bool{}
+	if seen {
+		keywords["$seen"] = true
+	}
+	return f.create(&fakeJMAPEmail{
+		mailboxIDs: map[string]bool{mailbox: true},
+		keywords:   keywords,
+		subject:    subject,
+		from:       "sender@example.com",
+		body:       "Body of " + subject,
+		receivedAt: time.Now(),
+	})
+}
+
+func (f *fakeJMAP) create(e *fakeJMAPEmail) string {
+	f.nextID++
+	e.id = "e" + strconv.Itoa(f.nextID)
+	f.emails[e.id] = e
+	f.record(e.id, "created")
+	return e.id
+}
+
+func (f *fakeJMAP) record(id, kind string) {
+	f.state++
+	f.log = append(f.log, fakeJMAPChange{id: id, kind: kind})
+}
+
+func (f *fakeJMAP) email(id string) *fakeJMAPEmail {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.emails[id]
+}
+
+func (f *fakeJMAP) handleAPI(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MethodCalls [][]json.RawMessage `json:"methodCalls"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var responses [][]any
+	for _, call := range req.MethodCalls {
+		var name, callID string
+		var args map[string]any
+		json.Unmarshal(call[0], &name)
+		json.Unmarshal(call[1], &args)
+		json.Unmarshal(call[2], &callID)
+
+		// Like real servers, reject calls for an account we do not have
+		result, err := any(nil), "accountNotFound"
+		if args["accountId"] == "acct" {
+			result, err = f.method(name, args)
+		}
+		if err != "" {
+			responses = append(responses, []any{"error", map[string]string{"type": err}, callID})
+		} else {
+			responses = append(responses, []any{name, result, callID})
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"methodResponses": responses})
+}
+
+func (f *fakeJMAP) method(name string, args map[string]any) (any, string) {
+	state := strconv.Itoa(f.state)
+
+	switch name {
+	case "Mailbox/get":
+		if ids, ok := args["ids"].([]any); ok {
+			var list []map[string]any
+			for _, id := range ids {
+				total := 0
+				for _, e := range f.emails {
+					if e.mailboxIDs[id.(string)] {
+						total++
+					}
+				}
+				list = append(list, map[string]any{"id": id, "totalEmails": total})
+			}
+			return map[string]any{"state": "m1", "list": list}, ""
+		}
+		return map[string]any{"state": "m1", "list": fakeJMAPMailboxes}, ""
+
+	case "Mailbox/changes":
+		return map[string]any{"newState": "m1", "hasMoreChanges": false}, ""
+
+	case "Email/query":
+		filter, _ := args["filter"].(map[string]any)
+		var matched []*fakeJMAPEmail
+		for _, e := range f.emails {
+			if mbox, ok := filter["inMailbox"].(string); ok && !e.mailboxIDs[mbox] {
+				continue
+			}
+			if text, ok := filter["text"].(string); ok && !strings.Contains(e.subject+" "+e.body, text) {
+				continue
+			}
+			matched = append(matched, e)
+		}
+		sort.Slice(matched, func(i, j int) bool { return matched[i].id > matched[j].id })
+
+		position, limit := int(args["position"].(float64)), int(args["limit"].(float64))
+		ids := []string{}
+		for i := position; i < len(matched) && len(ids) < limit; i++ {
+			ids = append(ids, matched[i].id)
+		}
+		return map[string]any{"ids": ids}, ""
+
+	case "Email/get":
+		list := []map[string]any{}
+		for _, id := range args["ids"].([]any) {
+			e, ok := f.emails[id.(string)]
+			if !ok {
+				continue
+			}
+			// Only text/plain and text/html values come without
+			// fetchAllBodyValues
+			parts := []map[string]string{}
+			values := map[string]any{"1": map[string]string{"value": e.body}}
+			if e.calendar != "" {
+				parts = append(parts, map[string]string{"partId": "2", "type": "text/calendar"})
+				if args["fetchAllBodyValues"] == true {
+					values["2"] = map[string]string{"value": e.calendar}
+				}
+			}
+			list = append(list, map[string]any{
+				"id":          e.id,
+				"mailboxIds":  e.mailboxIDs,
+				"keywords":    e.keywords,
+				"messageId":   []string{e.id + "@example.com"},
+				"inReplyTo":   e.inReplyTo,
+				"from":        []map[string]string{{"name": "Sender", "email": e.from}},
+				"to":          []map[string]string{{"email": "me@example.com"}},
+				"subject":     e.subject,
+				"receivedAt":  e.receivedAt.UTC().Format(time.RFC3339),
+				"textBody":    []map[string]string{{"partId": "1", "type": "text/plain"}},
+				"attachments": parts,
+				"bodyValues":  values,
+			})
+		}
+		return map[string]any{"state": state, "list": list}, ""
+
+	case "Email/changes":
+		since, err := strconv.Atoi(args["sinceState"].(string))
+		if err != nil || since > f.state {
+			return nil, "cannotCalculateChanges"
+		}
+		created, updated, destroyed := []string{}, []string{}, []string{}
+		for _, c := range f.log[since:] {
+			switch c.kind {
+			case "created":
+				created = append(created, c.id)
+			case "updated":
+				updated = append(updated, c.id)
+			case "destroyed":
+				destroyed = append(destroyed, c.id)
+			}
+		}
+		return map[string]any{
+			"newState": state, "hasMoreChanges": false,
+			"created": created, "updated": updated, "destroyed": destroyed,
+		}, ""
+
+	case "Email/set":
+		created := map[string]any{}
+		if create, ok := args["create"].(map[string]any); ok {
+			for key, raw := range create {
+				obj := raw.(map[string]any)
+				e := &fakeJMAPEmail{
+					mailboxIDs: map[string]bool{},
+					keywords:   map[string]bool{},
+					subject:    obj["subject"].(string),
+					from:       "me@example.com",
+					body:       obj["bodyValues"].(map[string]any)["body"].(map[string]any)["value"].(string),
+					receivedAt: time.Now(),
+				}
+				for id := range obj["mailboxIds"].(map[string]any) {
+					e.mailboxIDs[id] = true
+				}
+				for kw := range obj["keywords"].(map[string]any) {
+					e.keywords[kw] = true
+				}
+				if ids, ok := obj["inReplyTo"].([]any); ok {
+					for _, id := range ids {
+						e.inReplyTo = append(e.inReplyTo, id.(string))
+					}
+				}
+				created[key] = map[string]string{"id": f.create(e)}
+			}
+		}
+		if update, ok := args["update"].(map[string]any); ok {
+			for id, patch := range update {
+				f.patch(id, patch.(map[string]any))
+			}
+		}
+		if destroy, ok := args["destroy"].([]any); ok {
+			for _, id := range destroy {
+				delete(f.emails, id.(string))
+				f.record(id.(string), "destroyed")
+			}
+		}
+		return map[string]any{"newState": strconv.Itoa(f.state), "created": created}, ""
+
+	case "Identity/get":
+		return map[string]any{"list": []map[string]string{
+			{"id": "other", "email": "alias@example.com"},
+			{"id": "me", "email": "me@example.com"},
+		}}, ""
+
+	case "EmailSubmission/set":
+		for _, raw := range args["create"].(map[string]any) {
+			sub := raw.(map[string]any)
+			if sub["identityId"] != "me" {
+				return nil, "invalidArguments"
+			}
+			emailID := sub["emailId"].(string)
+			f.submissions = append(f.submissions, emailID)
+			if onSuccess, ok := args["onSuccessUpdateEmail"].(map[string]any)["#send"]; ok {
+				f.patch(emailID, onSuccess.(map[string]any))
+			}
+		}
+		return map[string]any{}, ""
+	}
+
+	return nil, "unknownMethod"
+}
+
+// patch applies a JMAP PatchObject to an email
+func (f *fakeJMAP) patch(id string, patch map[string]any) {
+	e, ok := f.emails[id]
+	if !ok {
+		return
+	}
+	for path, value := range patch {
+		switch {
+		case path == "mailboxIds":
+			e.mailboxIDs = map[string]bool{}
+			for mbox := range value.(map[string]any) {
+				e.mailboxIDs[mbox] = true
+			}
+		case strings.HasPrefix(path, "mailboxIds/"):
+			set(e.mailboxIDs, strings.TrimPrefix(path, "mailboxIds/"), value)
+		case strings.HasPrefix(path, "keywords/"):
+			set(e.keywords, strings.TrimPrefix(path, "keywords/"), value)
+		}
+	}
+	f.record(id, "updated")
+}
+
+func set(m map[string]bool, key string, value any) {
+	if value == nil {
+		delete(m, key)
+	} else {
+		m[key] = true
+	}
+}
+
+func TestJMAPFetchAndMarkRead(t *testing.T) {
+	f := newFakeJMAP(t)
+	f.add("mb-inbox", "first", true)
+	second := f.add("mb-inbox", "second", false)
+	f.add("mb-work", "elsewhere", false)
+
+	client, err := NewJMAPClient(f.creds())
+	if err != nil {
+		t.Fatalf("NewJMAPClient: %v", err)
+	}
+
+	mailboxes, err := client.ListMailboxes()
+	if err != nil {
+		t.Fatalf("ListMailboxes: %v", err)
+	}
+	if !strings.Contains(strings.Join(mailboxes, ","), "INBOX/Work") {
+		t.Errorf("nested mailbox missing from %v", mailboxes)
+	}
+
+	emails, err := client.FetchMessages(INBOX, 10)
+	if err != nil {
+		t.Fatalf("FetchMessages: %v", err)
+	}
+	if len(emails) != 2 {
+		t.Fatalf("got %d emails, want 2", len(emails))
+	}
+	if emails[0].Subject != "second" || !emails[0].Unread || emails[0].Body != "Body of second" {
+		t.Errorf("unexpected first email: %+v", emails[0])
+	}
+	if emails[0].From != "Sender <sender@example.com>" {
+		t.Errorf("From = %q", emails[0].From)
+	}
+	if emails[0].Calendar != "" {
+		t.Errorf("Calendar = %q, want none", emails[0].Calendar)
+	}
+
+	// A fresh client has never seen the UID and must look it up
+	fresh, err := NewJMAPClient(f.creds())
+	if err != nil {
+		t.Fatalf("NewJMAPClient: %v", err)
+	}
+	if err := fresh.SelectMailbox(INBOX); err != nil {
+		t.Fatalf("SelectMailbox: %v", err)
+	}
+	if err := fresh.MarkAsRead(emails[0].UID); err != nil {
+		t.Fatalf("MarkAsRead: %v", err)
+	}
+	if !f.email(second).keywords["$seen"] {
+		t.Error("message not marked $seen on server")
+	}
+
+	if err := fresh.MoveToTrash([]imap.UID{emails[0].UID}); err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	if e := f.email(second); !e.mailboxIDs["mb-trash"] || e.mailboxIDs["mb-inbox"] {
+		t.Errorf("mailboxIds after trash = %v", e.mailboxIDs)
+	}
+}
+
+func TestJMAPInvitation(t *testing.T) {
+	f := newFakeJMAP(t)
+	f.mu.Lock()
+	f.create(&fakeJMAPEmail{
+		mailboxIDs: map[string]bool{"mb-inbox": true},
+		subject:    "Invitation: Review",
+		from:       "ann@example.com",
+		body:       "You are invited",
+		calendar:   "BEGIN:VCALENDAR\r\nMETHOD:REQUEST\r\nEND:VCALENDAR\r\n",
+		receivedAt: time.Now(),
+	})
+	f.mu.Unlock()
+
+	client, err := NewJMAPClient(f.creds())
+	if err != nil {
+		t.Fatalf("NewJMAPClient: %v", err)
+	}
+	emails, err := client.FetchMessages(INBOX, 10)
+	if err != nil || len(emails) != 1 {
+		t.Fatalf("FetchMessages = %v, %v", emails, err)
+	}
+	if !strings.Contains(emails[0].Calendar, "METHOD:REQUEST") || emails[0].Body != "You are invited" {
+		t.Errorf("Calendar = %q, Body = %q", emails[0].Calendar, emails[0].Body)
+	}
+	if len(emails[0].Attachments) != 0 {
+		t.Errorf("inline calendar part listed as an attachment: %+v", emails[0].Attachments)
+	}
+}
+
+func TestJMAPSendUsesSubmission(t *testing.T) {
+	f := newFakeJMAP(t)
+	client := NewSender(f.creds())
+
+	if err := client.Reply("you@example.com", "Re: hi", "hello", "<orig@example.com>", ""); err != nil {
+		t.Fatalf("Reply: %v", err)
+	}
+
+	if len(f.submissions) != 1 {
+		t.Fatalf("got %d submissions, want 1", len(f.submissions))
+	}
+	sent := f.email(f.submissions[0])
+	if !sent.mailboxIDs["mb-sent"] || sent.mailboxIDs["mb-drafts"] || sent.keywords["$draft"] {
+		t.Errorf("sent message not moved to Sent: mailboxes %v keywords %v", sent.mailboxIDs, sent.keywords)
+	}
+	if len(sent.inReplyTo) != 1 || sent.inReplyTo[0] != "orig@example.com" {
+		t.Errorf("inReplyTo = %v", sent.inReplyTo)
+	}
+}
+
+func TestJMAPChanges(t *testing.T) {
+	f := newFakeJMAP(t)
+	kept := f.add("mb-inbox", "kept", false)
+	gone := f.add("mb-inbox", "gone", false)
+	moved := f.add("mb-inbox", "moved", false)
+
+	client, err := NewJMAPClient(f.creds())
+	if err != nil {
+		t.Fatalf("NewJMAPClient: %v", err)
+	}
+	state, err := client.State(INBOX)
+	if err != nil {
+		t.Fatalf("State: %v", err)
+	}
+	// Sync the mailbox first, as only messages handed out can be removed
+	if _, err := client.FetchUIDsAndFlags(INBOX, time.Time{}); err != nil {
+		t.Fatalf("FetchUIDsAndFlags: %v", err)
+	}
+	uid := func(id string) imap.UID {
+		uid, _ := client.ids.known(id)
+		return uid
+	}
+
+	added := f.add("mb-inbox", "new", false)
+	f.add("mb-work", "other mailbox", false)
+	f.mu.Lock()
+	f.patch(kept, map[string]any{"keywords/$seen": true})
+	f.patch(moved, map[string]any{"mailboxIds": map[string]any{"mb-trash": true}})
+	delete(f.emails, gone)
+	f.record(gone, "destroyed")
+	f.mu.Unlock()
+
+	changes, err := client.Changes(INBOX, *state)
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+
+	if len(changes.Flags) != 2 {
+		t.Errorf("Flags = %v, want kept and new", changes.Flags)
+	}
+	if unread, ok := changes.Flags[uid(kept)]; !ok || unread {
+		t.Error("kept should be reported as read")
+	}
+	if unread, ok := changes.Flags[uid(added)]; !ok || !unread {
+		t.Error("new message should be reported as unread")
+	}
+
+	removed := map[imap.UID]bool{}
+	for _, uid := range changes.Removed {
+		removed[uid] = true
+	}
+	if !removed[uid(gone)] || !removed[uid(moved)] {
+		t.Errorf("Removed = %v, want gone and moved", changes.Removed)
+	}
+
+	if _, err := client.Changes(INBOX, SyncState{Email: "999", Mailbox: "m1"}); !errors.Is(err, ErrStateReset) {
+		t.Errorf("Changes from unknown state = %v, want ErrStateReset", err)
+	}
+}
+
+func TestJMAPWatch(t *testing.T) {
+	f := newFakeJMAP(t)
+	client, err := NewJMAPClient(f.creds())
+	if err != nil {
+		t.Fatalf("NewJMAPClient: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	notified := make(chan struct{}, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Watch(ctx, func() { notified <- struct{}{} })
+	}()
+
+	f.push <- struct{}{}
+	select {
+	case <-notified:
+	case <-ctx.Done():
+		t.Fatal("no notification received")
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Watch returned %v, want context.Canceled", err)
+	}
+}
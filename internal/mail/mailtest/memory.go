@@ -0,0 +1,470 @@
+// Package mailtest provides in-memory fakes of the mail backends for tests
+package mailtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+
+	"maily/internal/mail"
+)
+
+// MemoryStore is an in-memory Store for tests. It keeps messages per mailbox
+// and assigns UIDs the way an IMAP server would.
+type MemoryStore struct {
+	mu        sync.Mutex
+	mailboxes map[string]*memoryMailbox
+	order     []string
+	selected  string
+	closed    bool
+}
+
+type memoryMailbox struct {
+	uidValidity uint32
+	nextUID     imap.UID
+	messages    []mail.Email
+}
+
+// SentMessage records a message handed to a MemorySender
+type SentMessage struct {
+	To         string
+	Subject    string
+	Body       string
+	InReplyTo  string
+	References string
+	Calendar   string // iCalendar part sent with SendCalendar
+}
+
+// MemorySender is a Sender that records messages instead of delivering them
+type MemorySender struct {
+	mu   sync.Mutex
+	Sent []SentMessage
+}
+
+// NewMemoryStore creates an empty store containing only INBOX
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{mailboxes: make(map[string]*memoryMailbox)}
+	s.CreateMailbox(mail.INBOX)
+	return s
+}
+
+// CreateMailbox adds a mailbox, or resets it with a new UIDVALIDITY if it exists
+func (s *MemoryStore) CreateMailbox(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	validity := uint32(1)
+	if mbox, ok := s.mailboxes[name]; ok {
+		validity = mbox.uidValidity + 1
+	} else {
+		s.order = append(s.order, name)
+	}
+	s.mailboxes[name] = &memoryMailbox{uidValidity: validity, nextUID: 1}
+}
+
+// Add appends a message to a mailbox and returns its assigned UID.
+// InternalDate defaults to now when unset.
+func (s *MemoryStore) Add(mailbox string, email mail.Email) imap.UID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mbox, ok := s.mailboxes[mailbox]
+	if !ok {
+		mbox = &memoryMailbox{uidValidity: 1, nextUID: 1}
+		s.mailboxes[mailbox] = mbox
+		s.order = append(s.order, mailbox)
+	}
+
+	email.UID = mbox.nextUID
+	mbox.nextUID++
+	if email.InternalDate.IsZero() {
+		email.InternalDate = time.Now()
+	}
+	mbox.messages = append(mbox.messages, email)
+	return email.UID
+}
+
+// Messages returns a copy of the messages in a mailbox, oldest first
+func (s *MemoryStore) Messages(mailbox string) []mail.Email {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mbox, ok := s.mailboxes[mailbox]
+	if !ok {
+		return nil
+	}
+	return append([]mail.Email(nil), mbox.messages...)
+}
+
+// Closed reports whether Close has been called
+func (s *MemoryStore) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *MemoryStore) ListMailboxes() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.order...), nil
+}
+
+func (s *MemoryStore) SelectMailbox(name string) error {
+	_, err := s.SelectMailboxWithInfo(name)
+	return err
+}
+
+func (s *MemoryStore) SelectMailboxWithInfo(name string) (*mail.MailboxInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mbox, err := s.mailboxLocked(name)
+	if err != nil {
+		return nil, err
+	}
+	s.selected = name
+	return &mail.MailboxInfo{
+		UIDValidity: mbox.uidValidity,
+		NumMessages: uint32(len(mbox.messages)),
+	}, nil
+}
+
+func (s *MemoryStore) FetchUIDsAndFlags(mailbox string, since time.Time) (map[imap.UID]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mbox, err := s.selectLocked(mailbox)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[imap.UID]bool)
+	for _, msg := range mbox.messages {
+		if !msg.InternalDate.Before(since) {
+			result[msg.UID] = msg.Unread
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) FetchMessagesByUIDs(mailbox string, uids []imap.UID) ([]mail.Email, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mbox, err := s.selectLocked(mailbox)
+	if err != nil {
+		return nil, err
+	}
+
+	want := make(map[imap.UID]bool, len(uids))
+	for _, uid := range uids {
+		want[uid] = true
+	}
+
+	emails := []mail.Email{}
+	for _, msg := range mbox.messages {
+		if want[msg.UID] {
+			emails = append(emails, msg)
+		}
+	}
+	return emails, nil
+}
+
+func (s *MemoryStore) FetchMessages(mailbox string, limit uint32) ([]mail.Email, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mbox, err := s.selectLocked(mailbox)
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest (highest sequence number) first, like IMAPClient
+	emails := []mail.Email{}
+	for i := len(mbox.messages) - 1; i >= 0 && uint32(len(emails)) < limit; i-- {
+		emails = append(emails, mbox.messages[i])
+	}
+	return emails, nil
+}
+
+func (s *MemoryStore) FetchMessagesSince(mailbox string, since time.Time, limit uint32) ([]mail.Email, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mbox, err := s.selectLocked(mailbox)
+	if err != nil {
+		return nil, err
+	}
+
+	emails := []mail.Email{}
+	for _, msg := range mbox.messages {
+		if !msg.InternalDate.Before(since) {
+			emails = append(emails, msg)
+		}
+	}
+	sort.Slice(emails, func(i, j int) bool {
+		return emails[i].InternalDate.After(emails[j].InternalDate)
+	})
+	if uint32(len(emails)) > limit {
+		emails = emails[:limit]
+	}
+	return emails, nil
+}
+
+// SearchMessages does a case-insensitive substring match on the subject,
+// sender and body
+func (s *MemoryStore) SearchMessages(mailbox string, query string) ([]mail.Email, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mbox, err := s.selectLocked(mailbox)
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	emails := []mail.Email{}
+	for i := len(mbox.messages) - 1; i >= 0; i-- {
+		msg := mbox.messages[i]
+		text := strings.ToLower(msg.Subject + "\n" + msg.From + "\n" + msg.Body)
+		if strings.Contains(text, query) {
+			emails = append(emails, msg)
+		}
+	}
+	return emails, nil
+}
+
+func (s *MemoryStore) MarkAsRead(uid imap.UID) error {
+	return s.setUnread([]imap.UID{uid}, false)
+}
+
+func (s *MemoryStore) MarkAsUnread(uid imap.UID) error {
+	return s.setUnread([]imap.UID{uid}, true)
+}
+
+func (s *MemoryStore) MarkMessagesAsRead(uids []imap.UID) error {
+	return s.setUnread(uids, false)
+}
+
+// SetUnread changes the unread flag of messages in a mailbox, as if another
+// client had changed it on the server
+func (s *MemoryStore) SetUnread(mailbox string, uid imap.UID, unread bool) error {
+	if err := s.SelectMailbox(mailbox); err != nil {
+		return err
+	}
+	return s.setUnread([]imap.UID{uid}, unread)
+}
+
+func (s *MemoryStore) DeleteMessage(uid imap.UID) error {
+	return s.DeleteMessages([]imap.UID{uid})
+}
+
+func (s *MemoryStore) DeleteMessages(uids []imap.UID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mbox, err := s.mailboxLocked(s.selected)
+	if err != nil {
+		return err
+	}
+	mbox.remove(uids)
+	return nil
+}
+
+func (s *MemoryStore) MoveToTrash(uids []imap.UID) error {
+	return s.moveTo(mail.Trash, uids)
+}
+
+func (s *MemoryStore) ArchiveMessages(uids []imap.UID) error {
+	return s.moveTo(mail.Archive, uids)
+}
+
+// MoveMessages moves messages from the selected mailbox to mailbox
+func (s *MemoryStore) MoveMessages(uids []imap.UID, mailbox string) error {
+	s.mu.Lock()
+	_, err := s.mailboxLocked(mailbox)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.moveTo(mailbox, uids)
+}
+
+// CopyMessages copies messages from the selected mailbox to mailbox
+func (s *MemoryStore) CopyMessages(uids []imap.UID, mailbox string) error {
+	s.mu.Lock()
+	src, err := s.mailboxLocked(s.selected)
+	if err == nil {
+		_, err = s.mailboxLocked(mailbox)
+	}
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	var copies []mail.Email
+	for _, msg := range src.messages {
+		for _, uid := range uids {
+			if msg.UID == uid {
+				copies = append(copies, msg)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for _, msg := range copies {
+		s.Add(mailbox, msg)
+	}
+	return nil
+}
+
+func (s *MemoryStore) SetFlagged(uids []imap.UID, flagged bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mbox, err := s.mailboxLocked(s.selected)
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		for i := range mbox.messages {
+			if mbox.messages[i].UID == uid {
+				mbox.messages[i].Flagged = flagged
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) SaveDraft(to, subject, body string) error {
+	s.Add(mail.Drafts, mail.Email{
+		To:      to,
+		Subject: subject,
+		Body:    body,
+		Snippet: body,
+		Date:    time.Now(),
+	})
+	return nil
+}
+
+func (s *MemoryStore) mailboxLocked(name string) (*memoryMailbox, error) {
+	mbox, ok := s.mailboxes[name]
+	if !ok {
+		return nil, fmt.Errorf("no such mailbox: %s", name)
+	}
+	return mbox, nil
+}
+
+func (s *MemoryStore) selectLocked(name string) (*memoryMailbox, error) {
+	mbox, err := s.mailboxLocked(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select mailbox: %w", err)
+	}
+	s.selected = name
+	return mbox, nil
+}
+
+func (s *MemoryStore) setUnread(uids []imap.UID, unread bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mbox, err := s.mailboxLocked(s.selected)
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		for i := range mbox.messages {
+			if mbox.messages[i].UID == uid {
+				mbox.messages[i].Unread = unread
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) moveTo(dest string, uids []imap.UID) error {
+	if len(uids) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	src, err := s.mailboxLocked(s.selected)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	moved := src.remove(uids)
+	s.mu.Unlock()
+
+	for _, msg := range moved {
+		s.Add(dest, msg)
+	}
+	return nil
+}
+
+// remove deletes messages by UID and returns the removed messages
+func (m *memoryMailbox) remove(uids []imap.UID) []mail.Email {
+	drop := make(map[imap.UID]bool, len(uids))
+	for _, uid := range uids {
+		drop[uid] = true
+	}
+
+	var kept, removed []mail.Email
+	for _, msg := range m.messages {
+		if drop[msg.UID] {
+			removed = append(removed, msg)
+		} else {
+			kept = append(kept, msg)
+		}
+	}
+	m.messages = kept
+	return removed
+}
+
+func (s *MemorySender) Send(to, subject, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Sent = append(s.Sent, SentMessage{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+func (s *MemorySender) Reply(to, subject, body, inReplyTo, references string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Sent = append(s.Sent, SentMessage{
+		To:         to,
+		Subject:    subject,
+		Body:       body,
+		InReplyTo:  inReplyTo,
+		References: references,
+	})
+	return nil
+}
+
+func (s *MemorySender) SendCalendar(to []string, subject, body string, ics []byte, method string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Sent = append(s.Sent, SentMessage{
+		To:       strings.Join(to, ", "),
+		Subject:  subject,
+		Body:     body,
+		Calendar: string(ics),
+	})
+	return nil
+}
+
+var (
+	_ mail.Store          = (*MemoryStore)(nil)
+	_ mail.Sender         = (*MemorySender)(nil)
+	_ mail.CalendarSender = (*MemorySender)(nil)
+)
@@ -0,0 +1,412 @@
+package mail
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"maily/internal/auth"
+)
+
+// sieveTimeout limits how long a ManageSieve command may take
+const sieveTimeout = 30 * time.Second
+
+// sieveMaxLiteral is the largest literal read from a server, well above
+// the script sizes servers accept
+const sieveMaxLiteral = 1 << 20
+
+// SieveScript is a filter script stored on a ManageSieve server
+type SieveScript struct {
+	Name   string
+	Active bool // the script the server runs on delivery
+}
+
+// SieveClient manages the server-side filter scripts of an account over
+// ManageSieve (RFC 5804). The server runs the active script on delivery,
+// so its filters work while maily is not running.
+type SieveClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	caps map[string]string
+}
+
+// sieveToken is a word of a server response: an atom, or a quoted or
+// literal string
+type sieveToken struct {
+	value string
+	atom  bool
+}
+
+// sieveResponse is the data lines a command returned and its result
+type sieveResponse struct {
+	lines  [][]sieveToken
+	status string // OK, NO or BYE
+	code   string // response code, e.g. WARNINGS
+	text   string
+}
+
+// SieveAddr returns the ManageSieve server of an account: the configured
+// one, else the IMAP host on the ManageSieve port
+func SieveAddr(creds *auth.Credentials) (string, error) {
+	host := creds.SieveHost
+	if host == "" {
+		switch creds.Provider {
+		case auth.ProviderGmail, auth.ProviderYahoo, auth.ProviderOutlook:
+			return "", fmt.Errorf("%s does not offer ManageSieve", creds.Provider)
+		}
+		host = creds.IMAPHost
+	}
+	if host == "" {
+		return "", fmt.Errorf("no ManageSieve server; set sieve_host for %s in accounts.yml", creds.Email)
+	}
+	port := creds.SievePort
+	if port == 0 {
+		port = auth.SievePort
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// NewSieveClient connects to the account's ManageSieve server, switches to
+// TLS and logs in with the account's password
+func NewSieveClient(creds *auth.Credentials) (*SieveClient, error) {
+	addr, err := SieveAddr(creds)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("tcp", addr, sieveTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ManageSieve server: %w", err)
+	}
+
+	c := newSieveClient(conn)
+	if err := c.greet(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	if err := c.startTLS(host); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	if err := c.login(creds); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewSieveClientFromConn logs in over an already established connection
+// without switching to TLS. Used to talk to an in-process test server.
+func NewSieveClientFromConn(conn net.Conn, creds *auth.Credentials) (*SieveClient, error) {
+	c := newSieveClient(conn)
+	if err := c.greet(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := c.login(creds); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func newSieveClient(conn net.Conn) *SieveClient {
+	return &SieveClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// greet reads the capabilities the server sends on connect
+func (c *SieveClient) greet() error {
+	c.conn.SetDeadline(time.Now().Add(sieveTimeout))
+	resp, err := c.read()
+	if err != nil {
+		return fmt.Errorf("failed to read ManageSieve greeting: %w", err)
+	}
+	if resp.status != "OK" {
+		return fmt.Errorf("ManageSieve server refused connection: %s", resp.text)
+	}
+	c.setCapabilities(resp)
+	return nil
+}
+
+func (c *SieveClient) setCapabilities(resp *sieveResponse) {
+	c.caps = make(map[string]string)
+	for _, line := range resp.lines {
+		if len(line) == 0 {
+			continue
+		}
+		value := ""
+		if len(line) > 1 {
+			value = line[1].value
+		}
+		c.caps[strings.ToUpper(line[0].value)] = value
+	}
+}
+
+// startTLS upgrades the connection; passwords are never sent in the clear
+func (c *SieveClient) startTLS(host string) error {
+	if _, ok := c.caps["STARTTLS"]; !ok {
+		return fmt.Errorf("ManageSieve server does not offer STARTTLS")
+	}
+	if _, err := c.command("STARTTLS"); err != nil {
+		return fmt.Errorf("STARTTLS failed: %w", err)
+	}
+	tlsConn := tls.Client(c.conn, &tls.Config{ServerName: host})
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	c.conn = tlsConn
+	c.r = bufio.NewReader(tlsConn)
+	// The server sends its capabilities again, now with the SASL
+	// mechanisms it allows over TLS
+	return c.greet()
+}
+
+func (c *SieveClient) login(creds *auth.Credentials) error {
+	plain := false
+	for _, mech := range strings.Fields(c.caps["SASL"]) {
+		plain = plain || strings.EqualFold(mech, "PLAIN")
+	}
+	if !plain {
+		return fmt.Errorf("ManageSieve server does not allow password login")
+	}
+	response := base64.StdEncoding.EncodeToString([]byte("\x00" + creds.Email + "\x00" + creds.Password))
+	if _, err := c.command("AUTHENTICATE", sieveQuote("PLAIN"), sieveQuote(response)); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	return nil
+}
+
+// Extensions returns the Sieve extensions the server supports
+func (c *SieveClient) Extensions() []string {
+	return strings.Fields(c.caps["SIEVE"])
+}
+
+// Implementation returns the server's name and version
+func (c *SieveClient) Implementation() string {
+	return c.caps["IMPLEMENTATION"]
+}
+
+func (c *SieveClient) Close() error {
+	c.command("LOGOUT")
+	return c.conn.Close()
+}
+
+// Scripts lists the scripts on the server
+func (c *SieveClient) Scripts() ([]SieveScript, error) {
+	resp, err := c.command("LISTSCRIPTS")
+	if err != nil {
+		return nil, err
+	}
+	var scripts []SieveScript
+	for _, line := range resp.lines {
+		if len(line) == 0 {
+			continue
+		}
+		script := SieveScript{Name: line[0].value}
+		script.Active = len(line) > 1 && strings.EqualFold(line[1].value, "ACTIVE")
+		scripts = append(scripts, script)
+	}
+	return scripts, nil
+}
+
+// Script returns the content of the script named name
+func (c *SieveClient) Script(name string) (string, error) {
+	resp, err := c.command("GETSCRIPT", sieveQuote(name))
+	if err != nil {
+		return "", err
+	}
+	if len(resp.lines) == 0 || len(resp.lines[0]) == 0 {
+		return "", fmt.Errorf("server sent no script")
+	}
+	return resp.lines[0][0].value, nil
+}
+
+// PutScript uploads a script, replacing one of the same name. The server
+// rejects scripts that do not compile; warnings are returned.
+func (c *SieveClient) PutScript(name, content string) (string, error) {
+	resp, err := c.command("PUTSCRIPT", sieveQuote(name), sieveLiteral(content))
+	if err != nil {
+		return "", err
+	}
+	return resp.warnings(), nil
+}
+
+// CheckScript asks the server whether content compiles, without storing
+// it; warnings are returned
+func (c *SieveClient) CheckScript(content string) (string, error) {
+	if _, ok := c.caps["VERSION"]; !ok {
+		return "", fmt.Errorf("ManageSieve server cannot check scripts")
+	}
+	resp, err := c.command("CHECKSCRIPT", sieveLiteral(content))
+	if err != nil {
+		return "", err
+	}
+	return resp.warnings(), nil
+}
+
+// SetActive makes the script named name the one the server runs; an empty
+// name deactivates filtering
+func (c *SieveClient) SetActive(name string) error {
+	_, err := c.command("SETACTIVE", sieveQuote(name))
+	return err
+}
+
+// command sends a command and reads its response; NO and BYE become errors
+func (c *SieveClient) command(args ...string) (*sieveResponse, error) {
+	c.conn.SetDeadline(time.Now().Add(sieveTimeout))
+	if _, err := io.WriteString(c.conn, strings.Join(args, " ")+"\r\n"); err != nil {
+		return nil, err
+	}
+	resp, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	if resp.status != "OK" {
+		if resp.text == "" {
+			return nil, fmt.Errorf("%s failed", args[0])
+		}
+		return nil, errors.New(resp.text)
+	}
+	return resp, nil
+}
+
+// read reads data lines up to the OK, NO or BYE that ends a response
+func (c *SieveClient) read() (*sieveResponse, error) {
+	resp := &sieveResponse{}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) > 0 && line[0].atom {
+			switch status := strings.ToUpper(line[0].value); status {
+			case "OK", "NO", "BYE":
+				resp.status = status
+				for _, t := range line[1:] {
+					if t.atom && strings.HasPrefix(t.value, "(") {
+						resp.code = strings.Trim(t.value, "()")
+					} else {
+						resp.text = t.value
+					}
+				}
+				return resp, nil
+			}
+		}
+		resp.lines = append(resp.lines, line)
+	}
+}
+
+// readLine reads the words of one response line; a literal's content
+// may span several lines
+func (c *SieveClient) readLine() ([]sieveToken, error) {
+	var tokens []sieveToken
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch b {
+		case ' ', '\r':
+		case '\n':
+			return tokens, nil
+		case '"':
+			s, err := c.readQuoted()
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, sieveToken{value: s})
+		case '{':
+			s, err := c.readLiteral()
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, sieveToken{value: s})
+		case '(':
+			code, err := c.r.ReadString(')')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, sieveToken{value: "(" + code, atom: true})
+		default:
+			var atom strings.Builder
+			atom.WriteByte(b)
+			for {
+				next, err := c.r.Peek(1)
+				if err != nil || next[0] == ' ' || next[0] == '\r' || next[0] == '\n' {
+					break
+				}
+				c.r.ReadByte()
+				atom.WriteByte(next[0])
+			}
+			tokens = append(tokens, sieveToken{value: atom.String(), atom: true})
+		}
+	}
+}
+
+func (c *SieveClient) readQuoted() (string, error) {
+	var s strings.Builder
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '"':
+			return s.String(), nil
+		case '\\':
+			if b, err = c.r.ReadByte(); err != nil {
+				return "", err
+			}
+		}
+		s.WriteByte(b)
+	}
+}
+
+// readLiteral reads a {size} literal after its opening brace
+func (c *SieveClient) readLiteral() (string, error) {
+	header, err := c.r.ReadString('}')
+	if err != nil {
+		return "", err
+	}
+	size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(header, "}"), "+"))
+	if err != nil || size < 0 {
+		return "", fmt.Errorf("invalid literal {%s", header)
+	}
+	if size > sieveMaxLiteral {
+		return "", fmt.Errorf("literal of %d bytes is larger than %d", size, sieveMaxLiteral)
+	}
+	if _, err := c.r.ReadString('\n'); err != nil {
+		return "", err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (r *sieveResponse) warnings() string {
+	if strings.EqualFold(r.code, "WARNINGS") {
+		return r.text
+	}
+	return ""
+}
+
+// sieveQuote returns s as a quoted string
+func sieveQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// sieveLiteral returns s as a literal the server reads without a
+// continuation
+func sieveLiteral(s string) string {
+	return fmt.Sprintf("{%d+}\r\n%s", len(s), s)
+}
@@ -0,0 +1,174 @@
+package mail
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+
+	"maily/internal/auth"
+)
+
+// fakeSieve is a small ManageSieve server keeping scripts in memory. It
+// reads commands with the client's own tokenizer.
+type fakeSieve struct {
+	scripts map[string]string
+	active  string
+}
+
+func (f *fakeSieve) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := newSieveClient(conn)
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	reply(`"IMPLEMENTATION" "Fake"` + "\r\n" + `"SASL" "PLAIN"` + "\r\n" + `"SIEVE" "fileinto imap4flags"` + "\r\n" + `"VERSION" "1.0"` + "\r\n" + `OK "ready"`)
+	for {
+		line, err := reader.readLine()
+		if err != nil {
+			return
+		}
+		args := make([]string, len(line))
+		for i, t := range line {
+			args[i] = t.value
+		}
+		switch strings.ToUpper(args[0]) {
+		case "AUTHENTICATE":
+			creds, _ := base64.StdEncoding.DecodeString(args[2])
+			if string(creds) != "\x00me@example.com\x00secret" {
+				reply(`NO "bad password"`)
+				continue
+			}
+			reply("OK")
+		case "LISTSCRIPTS":
+			var names []string
+			for name := range f.scripts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if name == f.active {
+					reply("%s ACTIVE", sieveQuote(name))
+				} else {
+					reply("%s", sieveQuote(name))
+				}
+			}
+			reply("OK")
+		case "GETSCRIPT":
+			if args[1] == "huge" {
+				reply("{%d}", 1<<31-1)
+				continue
+			}
+			script, ok := f.scripts[args[1]]
+			if !ok {
+				reply(`NO (NONEXISTENT) "no such script"`)
+				continue
+			}
+			reply("{%d}\r\n%s\r\nOK", len(script), script)
+		case "CHECKSCRIPT", "PUTSCRIPT":
+			script := args[len(args)-1]
+			if strings.Contains(script, "bogus") {
+				msg := "line 2: unknown command 'bogus'\r\nerror: validation failed."
+				reply("NO {%d}\r\n%s", len(msg), msg)
+				continue
+			}
+			if args[0] == "PUTSCRIPT" {
+				f.scripts[args[1]] = script
+			}
+			if strings.Contains(script, "vacation") {
+				reply(`OK (WARNINGS) "line 1: vacation is deprecated"`)
+				continue
+			}
+			reply("OK")
+		case "SETACTIVE":
+			f.active = args[1]
+			reply("OK")
+		case "LOGOUT":
+			reply("OK")
+			return
+		default:
+			reply(`NO "unknown command"`)
+		}
+	}
+}
+
+func TestSieveClient(t *testing.T) {
+	fake := &fakeSieve{scripts: map[string]string{"old": "keep;\r\n"}}
+	server, conn := net.Pipe()
+	go fake.serve(server)
+
+	c, err := NewSieveClientFromConn(conn, &auth.Credentials{Email: "me@example.com", Password: "secret"})
+	if err != nil {
+		t.Fatalf("NewSieveClientFromConn: %v", err)
+	}
+	defer c.Close()
+	if got := c.Extensions(); len(got) != 2 || got[1] != "imap4flags" || c.Implementation() != "Fake" {
+		t.Errorf("capabilities = %v, %q", got, c.Implementation())
+	}
+
+	script := "require \"fileinto\";\r\nif header :contains \"subject\" \"\\\"sale\\\"\" {\r\n  fileinto \"Ads\";\r\n}\r\n"
+	if warnings, err := c.PutScript("maily \"rules\"", script); err != nil || warnings != "" {
+		t.Fatalf("PutScript = %q, %v", warnings, err)
+	}
+	if err := c.SetActive("maily \"rules\""); err != nil {
+		t.Fatalf("SetActive: %v", err)
+	}
+	scripts, err := c.Scripts()
+	if err != nil {
+		t.Fatalf("Scripts: %v", err)
+	}
+	want := []SieveScript{{Name: "maily \"rules\"", Active: true}, {Name: "old"}}
+	if fmt.Sprint(scripts) != fmt.Sprint(want) {
+		t.Errorf("Scripts = %v, want %v", scripts, want)
+	}
+	if got, err := c.Script("maily \"rules\""); err != nil || got != script {
+		t.Errorf("Script = %q, %v, want %q", got, err, script)
+	}
+	if _, err := c.Script("missing"); err == nil || err.Error() != "no such script" {
+		t.Errorf("Script(missing) error = %v", err)
+	}
+
+	// Compile errors come back as literals, warnings with OK
+	if _, err := c.CheckScript("keep;\r\nbogus;\r\n"); err == nil || !strings.Contains(err.Error(), "line 2: unknown command") {
+		t.Errorf("CheckScript(bogus) error = %v", err)
+	}
+	if warnings, err := c.CheckScript("vacation \"away\";"); err != nil || !strings.Contains(warnings, "deprecated") {
+		t.Errorf("CheckScript(vacation) = %q, %v", warnings, err)
+	}
+
+	// A literal larger than any script is refused before it is read
+	if _, err := c.Script("huge"); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("Script(huge) error = %v", err)
+	}
+}
+
+func TestSieveClientBadPassword(t *testing.T) {
+	server, conn := net.Pipe()
+	go (&fakeSieve{scripts: map[string]string{}}).serve(server)
+
+	_, err := NewSieveClientFromConn(conn, &auth.Credentials{Email: "me@example.com", Password: "wrong"})
+	if err == nil || !strings.Contains(err.Error(), "bad password") {
+		t.Errorf("error = %v, want bad password", err)
+	}
+}
+
+func TestSieveAddr(t *testing.T) {
+	tests := []struct {
+		creds auth.Credentials
+		want  string
+	}{
+		{auth.Credentials{IMAPHost: "imap.example.com"}, "imap.example.com:4190"},
+		{auth.Credentials{IMAPHost: "imap.example.com", SieveHost: "sieve.example.com", SievePort: 2000}, "sieve.example.com:2000"},
+		{auth.Credentials{Provider: auth.ProviderGmail, IMAPHost: auth.GmailIMAPHost}, ""},
+		{auth.Credentials{Provider: auth.ProviderJMAP}, ""},
+	}
+	for _, tt := range tests {
+		got, err := SieveAddr(&tt.creds)
+		if got != tt.want || (tt.want == "") != (err != nil) {
+			t.Errorf("SieveAddr(%+v) = %q, %v, want %q", tt.creds, got, err, tt.want)
+		}
+	}
+}
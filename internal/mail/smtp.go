@@ -1,8 +1,14 @@
 package mail
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"mime"
+	"mime/multipart"
 	"net/smtp"
+	"net/textproto"
+	"strings"
 
 	"maily/internal/auth"
 )
@@ -20,15 +26,8 @@ func (c *SMTPClient) Send(to, subject, body string) error {
 
 	auth := smtp.PlainAuth("", c.creds.Email, c.creds.Password, c.creds.SMTPHost)
 
-	msg := fmt.Sprintf("From: %s\r\n"+
-		"To: %s\r\n"+
-		"Subject: %s\r\n"+
-		"MIME-Version: 1.0\r\n"+
-		"Content-Type: text/plain; charset=\"utf-8\"\r\n"+
-		"\r\n"+
-		"%s", c.creds.Email, to, subject, body)
-
-	return smtp.SendMail(addr, auth, c.creds.Email, []string{to}, []byte(msg))
+	msg := textMessage(c.creds.Email, to, subject, body, "", "")
+	return smtp.SendMail(addr, auth, c.creds.Email, []string{to}, msg)
 }
 
 func (c *SMTPClient) Reply(to, subject, body, inReplyTo, references string) error {
@@ -42,15 +41,110 @@ func (c *SMTPClient) Reply(to, subject, body, inReplyTo, references string) erro
 		references = references + " " + inReplyTo
 	}
 
+	msg := textMessage(c.creds.Email, to, subject, body, inReplyTo, references)
+	return smtp.SendMail(addr, auth, c.creds.Email, []string{to}, msg)
+}
+
+// textMessage builds a plain text message; a reply has inReplyTo and
+// references set
+func textMessage(from, to, subject, body, inReplyTo, references string) []byte {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n"+
+		"To: %s\r\n"+
+		"Subject: %s\r\n", from, to, encodeSubject(subject))
+	if inReplyTo != "" {
+		fmt.Fprintf(&msg, "In-Reply-To: %s\r\n"+
+			"References: %s\r\n", oneLine(inReplyTo), oneLine(references))
+	}
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n"+
+		"Content-Type: text/plain; charset=\"utf-8\"\r\n"+
+		"\r\n"+
+		"%s", body)
+	return []byte(msg.String())
+}
+
+// SendCalendar sends an iTIP message: body as text/plain with ics as the
+// text/calendar alternative, which mail clients show as an invitation, and
+// ics again as an invite.ics attachment for those that don't
+func (c *SMTPClient) SendCalendar(to []string, subject, body string, ics []byte, method string) error {
+	addr := fmt.Sprintf("%s:%d", c.creds.SMTPHost, c.creds.SMTPPort)
+
+	auth := smtp.PlainAuth("", c.creds.Email, c.creds.Password, c.creds.SMTPHost)
+
+	msg, err := calendarMessage(c.creds.Email, to, subject, body, ics, method)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(addr, auth, c.creds.Email, to, msg)
+}
+
+// calendarMessage builds the message SendCalendar sends
+func calendarMessage(from string, to []string, subject, body string, ics []byte, method string) ([]byte, error) {
+	var alt bytes.Buffer
+	aw := multipart.NewWriter(&alt)
+	w, err := aw.CreatePart(textproto.MIMEHeader{"Content-Type": {`text/plain; charset="utf-8"`}})
+	if err != nil {
+		return nil, err
+	}
+	w.Write([]byte(body))
+	w, err = aw.CreatePart(textproto.MIMEHeader{"Content-Type": {fmt.Sprintf(`text/calendar; charset="utf-8"; method=%s`, method)}})
+	if err != nil {
+		return nil, err
+	}
+	w.Write(ics)
+	aw.Close()
+
+	var parts bytes.Buffer
+	mw := multipart.NewWriter(&parts)
+	w, err = mw.CreatePart(textproto.MIMEHeader{"Content-Type": {fmt.Sprintf(`multipart/alternative; boundary="%s"`, aw.Boundary())}})
+	if err != nil {
+		return nil, err
+	}
+	w.Write(alt.Bytes())
+	w, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {`application/ics; name="invite.ics"`},
+		"Content-Disposition":       {`attachment; filename="invite.ics"`},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.Write([]byte(wrapBase64(ics)))
+	mw.Close()
+
 	msg := fmt.Sprintf("From: %s\r\n"+
 		"To: %s\r\n"+
 		"Subject: %s\r\n"+
-		"In-Reply-To: %s\r\n"+
-		"References: %s\r\n"+
 		"MIME-Version: 1.0\r\n"+
-		"Content-Type: text/plain; charset=\"utf-8\"\r\n"+
+		"Content-Type: multipart/mixed; boundary=\"%s\"\r\n"+
 		"\r\n"+
-		"%s", c.creds.Email, to, subject, inReplyTo, references, body)
+		"%s", from, strings.Join(to, ", "), encodeSubject(subject), mw.Boundary(), parts.String())
 
-	return smtp.SendMail(addr, auth, c.creds.Email, []string{to}, []byte(msg))
+	return []byte(msg), nil
+}
+
+// encodeSubject makes subject safe for a Subject header: line breaks, e.g.
+// from an invitation's SUMMARY or a decoded subject being forwarded, would
+// start headers of their own, and non-ASCII text is RFC 2047 encoded
+func encodeSubject(subject string) string {
+	return mime.QEncoding.Encode("utf-8", oneLine(subject))
+}
+
+// oneLine joins the lines of a header value
+func oneLine(value string) string {
+	return strings.Join(strings.FieldsFunc(value, func(r rune) bool {
+		return r == '\r' || r == '\n'
+	}), " ")
+}
+
+// wrapBase64 encodes data as base64 in 76-character lines
+func wrapBase64(data []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+	return b.String()
 }
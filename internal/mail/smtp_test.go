@@ -0,0 +1,80 @@
+package mail
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"maily/internal/calendar"
+)
+
+func TestCalendarMessageSubject(t *testing.T) {
+	// A SUMMARY with an escaped newline unescapes to a line break
+	inv, err := calendar.ParseInvitation([]byte("BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//Example//Example//EN\r\n" +
+		"METHOD:REQUEST\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:sync-1@example.com\r\n" +
+		"DTSTAMP:20240301T090000Z\r\n" +
+		"DTSTART:20240305T140000Z\r\n" +
+		"DTEND:20240305T150000Z\r\n" +
+		"SUMMARY:Sync\\nBcc: attacker@example.com\r\n" +
+		"ORGANIZER:mailto:ann@example.com\r\n" +
+		"ATTENDEE;PARTSTAT=NEEDS-ACTION:mailto:me@example.com\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"))
+	if err != nil {
+		t.Fatalf("ParseInvitation: %v", err)
+	}
+	if !strings.Contains(inv.Event.Title, "\n") {
+		t.Fatalf("title %q has no line break to test with", inv.Event.Title)
+	}
+
+	reply, err := inv.Reply("me@example.com", calendar.StatusAccepted)
+	if err != nil {
+		t.Fatalf("Reply: %v", err)
+	}
+	data, err := calendarMessage("me@example.com", []string{"ann@example.com"}, "Accepted: "+inv.Event.Title+" – ok", "body", reply, calendar.MethodReply)
+	if err != nil {
+		t.Fatalf("calendarMessage: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if bcc := msg.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("SUMMARY injected Bcc: %s", bcc)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Accepted: Sync Bcc: attacker@example.com – ok" {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+}
+
+func TestTextMessageSubject(t *testing.T) {
+	// An encoded word can carry a line break into a decoded subject, which
+	// a forward rule sends on
+	subject, err := new(mime.WordDecoder).DecodeHeader("=?utf-8?q?Invoice=0D=0ABcc:_attacker@example.com?=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := textMessage("me@example.com", "ann@example.com", "Fwd: "+subject, "body", "<a@example.com>", "<a@example.com>")
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if bcc := msg.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("subject injected Bcc: %s", bcc)
+	}
+	if got := msg.Header.Get("Subject"); got != "Fwd: Invoice Bcc: attacker@example.com" {
+		t.Errorf("Subject = %q", got)
+	}
+	if msg.Header.Get("In-Reply-To") != "<a@example.com>" {
+		t.Errorf("In-Reply-To = %q", msg.Header.Get("In-Reply-To"))
+	}
+}
@@ -0,0 +1,143 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+
+	"maily/internal/auth"
+)
+
+// Store is a connected mailbox backend. IMAPClient, JMAPClient and
+// GraphClient are the production implementations; mailtest.MemoryStore is
+// an in-memory fake for tests.
+type Store interface {
+	Close() error
+
+	ListMailboxes() ([]string, error)
+	SelectMailbox(name string) error
+	SelectMailboxWithInfo(name string) (*MailboxInfo, error)
+
+	FetchUIDsAndFlags(mailbox string, since time.Time) (map[imap.UID]bool, error)
+	FetchMessagesByUIDs(mailbox string, uids []imap.UID) ([]Email, error)
+	FetchMessages(mailbox string, limit uint32) ([]Email, error)
+	FetchMessagesSince(mailbox string, since time.Time, limit uint32) ([]Email, error)
+	SearchMessages(mailbox string, query string) ([]Email, error)
+
+	MarkAsRead(uid imap.UID) error
+	MarkAsUnread(uid imap.UID) error
+	MarkMessagesAsRead(uids []imap.UID) error
+	DeleteMessage(uid imap.UID) error
+	DeleteMessages(uids []imap.UID) error
+	MoveToTrash(uids []imap.UID) error
+	ArchiveMessages(uids []imap.UID) error
+	SaveDraft(to, subject, body string) error
+}
+
+// Sender delivers outgoing mail. SMTPClient, JMAPClient and GraphClient are
+// the production implementations.
+type Sender interface {
+	Send(to, subject, body string) error
+	Reply(to, subject, body, inReplyTo, references string) error
+}
+
+// CalendarSender is implemented by senders that can deliver iTIP messages
+// (RFC 6047): a text body with an iCalendar alternative of the given method
+type CalendarSender interface {
+	SendCalendar(to []string, subject, body string, ics []byte, method string) error
+}
+
+// Organizer is implemented by stores that can file and flag messages in the
+// selected mailbox, as mail rules do. A label is a copy in another mailbox,
+// which is how Gmail and JMAP servers show labels.
+type Organizer interface {
+	MoveMessages(uids []imap.UID, mailbox string) error
+	CopyMessages(uids []imap.UID, mailbox string) error
+	SetFlagged(uids []imap.UID, flagged bool) error
+}
+
+// ErrStateReset is returned by ChangeTracker.Changes when the server can no
+// longer compute changes from the given state; callers must resync from scratch.
+var ErrStateReset = errors.New("server cannot calculate changes from this state")
+
+// SyncState is an opaque server position for delta sync
+type SyncState struct {
+	Email   string
+	Mailbox string
+}
+
+// MailboxChanges describes what happened to a mailbox since a SyncState
+type MailboxChanges struct {
+	State   SyncState
+	Flags   map[imap.UID]bool // created or changed messages in the mailbox -> unread
+	Removed []imap.UID        // destroyed or moved out of the mailbox
+}
+
+// ChangeTracker is implemented by stores that can report changes since a
+// server state (JMAP, Graph), so the syncer does not have to diff UIDs.
+type ChangeTracker interface {
+	State(mailbox string) (*SyncState, error)
+	Changes(mailbox string, since SyncState) (*MailboxChanges, error)
+}
+
+// Pusher is implemented by stores that push change notifications. Watch
+// blocks, calling notify on every change, until ctx is done or the stream fails.
+type Pusher interface {
+	Watch(ctx context.Context, notify func()) error
+}
+
+// Dialer opens a Store for the given credentials
+type Dialer func(creds *auth.Credentials) (Store, error)
+
+// Dial connects to the mail backend for the given credentials
+func Dial(creds *auth.Credentials) (Store, error) {
+	switch creds.Provider {
+	case auth.ProviderJMAP:
+		client, err := NewJMAPClient(creds)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case auth.ProviderOutlook:
+		client, err := NewGraphClient(creds)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+
+	client, err := NewIMAPClient(creds)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// NewSender returns the outgoing mail transport for the given credentials.
+// JMAP accounts submit through EmailSubmission and Outlook accounts through
+// Graph instead of SMTP.
+func NewSender(creds *auth.Credentials) Sender {
+	switch creds.Provider {
+	case auth.ProviderJMAP:
+		return newJMAPClient(creds)
+	case auth.ProviderOutlook:
+		if client, err := NewGraphClient(creds); err == nil {
+			return client
+		}
+	}
+	return NewSMTPClient(creds)
+}
+
+var (
+	_ Store          = (*IMAPClient)(nil)
+	_ Store          = (*JMAPClient)(nil)
+	_ Store          = (*GraphClient)(nil)
+	_ Sender         = (*SMTPClient)(nil)
+	_ Sender         = (*JMAPClient)(nil)
+	_ Sender         = (*GraphClient)(nil)
+	_ CalendarSender = (*SMTPClient)(nil)
+	_ ChangeTracker  = (*JMAPClient)(nil)
+	_ Pusher         = (*JMAPClient)(nil)
+)
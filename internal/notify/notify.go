@@ -0,0 +1,189 @@
+// Package notify shows desktop notifications for new mail: over D-Bus on
+// Linux and other Unix desktops, and with osascript on macOS.
+package notify
+
+import (
+	"fmt"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"maily/config"
+	"maily/internal/auth"
+	"maily/internal/cache"
+	"maily/internal/mail"
+)
+
+// defaultBatch is how many new messages get a notification each; more
+// arriving at once get one together
+const defaultBatch = 3
+
+// batchSenders is how many senders a batched notification names
+const batchSenders = 3
+
+// Notification is one desktop notification
+type Notification struct {
+	Title string
+	Body  string
+}
+
+// Send shows a notification on the desktop
+func Send(n Notification) error {
+	return send(n)
+}
+
+// Policy decides which new mail gets a notification
+type Policy struct {
+	cfg        config.NotificationConfig
+	quiet      bool
+	quietStart int // minutes after midnight
+	quietEnd   int
+}
+
+// NewPolicy returns the policy of cfg
+func NewPolicy(cfg config.NotificationConfig) (*Policy, error) {
+	p := &Policy{cfg: cfg}
+	if cfg.QuietHours != "" {
+		start, end, ok := strings.Cut(cfg.QuietHours, "-")
+		var err error
+		if ok {
+			if p.quietStart, err = parseClock(start); err == nil {
+				p.quietEnd, err = parseClock(end)
+			}
+		}
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid quiet_hours %q (use e.g. 22:00-07:00)", cfg.QuietHours)
+		}
+		p.quiet = true
+	}
+	return p, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Mailboxes returns the mailboxes of account to notify about, none if
+// notifications are off for it
+func (p *Policy) Mailboxes(account string) []string {
+	if p.cfg.Off {
+		return nil
+	}
+	acc := p.cfg.Accounts[account]
+	if acc.Off {
+		return nil
+	}
+	if len(acc.Mailboxes) == 0 {
+		return []string{mail.INBOX}
+	}
+	return acc.Mailboxes
+}
+
+// Quiet reports whether t falls in the quiet hours
+func (p *Policy) Quiet(t time.Time) bool {
+	if !p.quiet {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if p.quietStart <= p.quietEnd {
+		return now >= p.quietStart && now < p.quietEnd
+	}
+	// Over midnight, e.g. 22:00-07:00
+	return now >= p.quietStart || now < p.quietEnd
+}
+
+// Notifications returns the notifications for emails newly arrived in a
+// mailbox of account at now: one per unread message, or one for all of
+// them when more than the batch size arrive at once
+func (p *Policy) Notifications(account, mailbox string, emails []cache.CachedEmail, now time.Time) []Notification {
+	wanted := false
+	for _, m := range p.Mailboxes(account) {
+		wanted = wanted || strings.EqualFold(m, mailbox)
+	}
+	if !wanted || p.Quiet(now) {
+		return nil
+	}
+
+	var notify []cache.CachedEmail
+	for _, e := range emails {
+		if !e.Unread {
+			continue
+		}
+		if p.cfg.VIPOnly && !auth.SenderMatches(e.From, p.cfg.VIPs) {
+			continue
+		}
+		notify = append(notify, e)
+	}
+
+	where := ""
+	if !strings.EqualFold(mailbox, mail.INBOX) {
+		where = " in " + mailbox
+	}
+
+	batch := p.cfg.Batch
+	if batch <= 0 {
+		batch = defaultBatch
+	}
+	if len(notify) > batch {
+		return []Notification{{
+			Title: fmt.Sprintf("%d new messages%s", len(notify), where),
+			Body:  "From " + p.senders(notify) + "\n" + account,
+		}}
+	}
+
+	var notifications []Notification
+	for _, e := range notify {
+		title := senderName(e.From)
+		if auth.SenderMatches(e.From, p.cfg.VIPs) {
+			title = "★ " + title
+		}
+		subject := e.Subject
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		notifications = append(notifications, Notification{Title: title + where, Body: subject})
+	}
+	return notifications
+}
+
+// senders names the senders of emails, VIPs first
+func (p *Policy) senders(emails []cache.CachedEmail) string {
+	var vips, others []string
+	seen := make(map[string]bool)
+	for _, e := range emails {
+		name := senderName(e.From)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if auth.SenderMatches(e.From, p.cfg.VIPs) {
+			vips = append(vips, name)
+		} else {
+			others = append(others, name)
+		}
+	}
+	names := append(vips, others...)
+	switch rest := len(names) - batchSenders; {
+	case rest == 1:
+		return strings.Join(names[:batchSenders], ", ") + " and 1 other"
+	case rest > 1:
+		return fmt.Sprintf("%s and %d others", strings.Join(names[:batchSenders], ", "), rest)
+	}
+	return strings.Join(names, ", ")
+}
+
+// senderName returns the display name of from, else its address
+func senderName(from string) string {
+	addr, err := netmail.ParseAddress(from)
+	if err != nil {
+		return strings.TrimSpace(from)
+	}
+	if addr.Name != "" {
+		return addr.Name
+	}
+	return addr.Address
+}
@@ -0,0 +1,19 @@
+//go:build darwin
+
+package notify
+
+import (
+	"os/exec"
+	"strings"
+)
+
+func send(n Notification) error {
+	script := "display notification " + appleScriptString(n.Body) + " with title " + appleScriptString(n.Title)
+	return exec.Command("osascript", "-e", script).Run()
+}
+
+// appleScriptString quotes s as an AppleScript string
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
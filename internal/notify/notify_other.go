@@ -0,0 +1,54 @@
+//go:build !darwin
+
+package notify
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// expireTimeout is how long a notification shows, in milliseconds
+const expireTimeout = 10000
+
+// send calls Notify on the session bus's org.freedesktop.Notifications
+// with gdbus, or has notify-send do it where gdbus is missing
+func send(n Notification) error {
+	if _, err := exec.LookPath("gdbus"); err == nil {
+		return run(exec.Command("gdbus", "call", "--session",
+			"--dest", "org.freedesktop.Notifications",
+			"--object-path", "/org/freedesktop/Notifications",
+			"--method", "org.freedesktop.Notifications.Notify",
+			gvariantString("maily"), "uint32 0", gvariantString("mail-unread"),
+			gvariantString(n.Title), gvariantString(markupEscape(n.Body)),
+			"@as []", "@a{sv} {}", fmt.Sprintf("int32 %d", expireTimeout),
+		))
+	}
+	if _, err := exec.LookPath("notify-send"); err == nil {
+		return run(exec.Command("notify-send", "--app-name=maily", "--icon=mail-unread",
+			fmt.Sprintf("--expire-time=%d", expireTimeout), "--", n.Title, markupEscape(n.Body)))
+	}
+	return fmt.Errorf("no D-Bus client found (install gdbus or notify-send)")
+}
+
+// run runs cmd, with its output in the error if it fails
+func run(cmd *exec.Cmd) error {
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// gvariantString quotes s in the GVariant text format gdbus parses
+func gvariantString(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`).Replace(s)
+	return "'" + s + "'"
+}
+
+// markupEscape escapes the body markup notification servers may support
+func markupEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
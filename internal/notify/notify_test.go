@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"maily/config"
+	"maily/internal/cache"
+)
+
+func TestNotifications(t *testing.T) {
+	policy, err := NewPolicy(config.NotificationConfig{
+		QuietHours: "22:00-07:00",
+		VIPs:       []string{"boss@work.com", "family.org"},
+		Accounts: map[string]config.NotificationAccountConfig{
+			"me@home.com": {Off: true},
+			"me@work.com": {Mailboxes: []string{"INBOX", "Team"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	day := time.Date(2026, 3, 2, 14, 0, 0, 0, time.Local)
+	night := time.Date(2026, 3, 2, 6, 59, 0, 0, time.Local)
+
+	emails := []cache.CachedEmail{
+		{From: "The Boss <boss@work.com>", Subject: "Budget", Unread: true},
+		{From: "news@shop.com", Subject: "Sale", Unread: true},
+		{From: "ann@x.com", Subject: "Already seen"},
+	}
+	got := policy.Notifications("me@work.com", "Team", emails, day)
+	want := []Notification{{Title: "★ The Boss in Team", Body: "Budget"}, {Title: "news@shop.com in Team", Body: "Sale"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Notifications = %v, want %v", got, want)
+	}
+
+	for _, tt := range []struct {
+		account, mailbox string
+		at               time.Time
+	}{
+		{"me@home.com", "INBOX", day},   // account off
+		{"me@work.com", "Spam", day},    // mailbox not picked
+		{"me@work.com", "INBOX", night}, // quiet hours
+	} {
+		if got := policy.Notifications(tt.account, tt.mailbox, emails, tt.at); len(got) != 0 {
+			t.Errorf("Notifications(%s, %s, %s) = %v, want none", tt.account, tt.mailbox, tt.at.Format("15:04"), got)
+		}
+	}
+
+	// Many messages at once make one notification, VIPs named first
+	var many []cache.CachedEmail
+	for _, from := range []string{"a@x.com", "b@x.com", "Mum <mum@family.org>", "c@x.com", "a@x.com"} {
+		many = append(many, cache.CachedEmail{From: from, Unread: true})
+	}
+	got = policy.Notifications("other@x.com", "INBOX", many, day)
+	want = []Notification{{Title: "5 new messages", Body: "From Mum, a@x.com, b@x.com and 1 other\nother@x.com"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("batched = %v, want %v", got, want)
+	}
+
+	policy, _ = NewPolicy(config.NotificationConfig{VIPs: []string{"family.org"}, VIPOnly: true})
+	if got := policy.Notifications("other@x.com", "INBOX", many, day); len(got) != 1 || got[0].Title != "★ Mum" {
+		t.Errorf("VIP only = %v", got)
+	}
+
+	if _, err := NewPolicy(config.NotificationConfig{QuietHours: "late"}); err == nil {
+		t.Error("NewPolicy accepted quiet hours \"late\"")
+	}
+}
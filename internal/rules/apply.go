@@ -0,0 +1,142 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+
+	"maily/internal/mail"
+)
+
+// commandTimeout limits how long a rule's command may run
+const commandTimeout = 30 * time.Second
+
+// Result is what the rules did with one message
+type Result struct {
+	Rule    string
+	Message Message
+	Done    []string // actions taken
+	Removed bool     // moved or deleted out of the mailbox
+	Read    bool     // marked read
+	Err     error    // the action that failed; later ones were not taken
+}
+
+// Runner applies rules to messages of a connected mailbox. Forwarding
+// needs Sender. Commands run with sh -c, with the message's body on stdin
+// and $MAILY_FROM, $MAILY_TO, $MAILY_SUBJECT, $MAILY_MESSAGE_ID,
+// $MAILY_MAILBOX and $MAILY_UID set.
+type Runner struct {
+	Store  mail.Store
+	Sender mail.Sender
+}
+
+// Apply runs the actions of the first matching rules on each message of
+// mailbox, in order, until a rule stops or files it away
+func (r *Runner) Apply(rules []Rule, mailbox string, messages []Message) []Result {
+	var results []Result
+	for _, m := range messages {
+		for _, rule := range rules {
+			if !rule.Match(m) {
+				continue
+			}
+			result := r.run(rule, mailbox, m)
+			results = append(results, result)
+			if rule.Stop || result.Removed || result.Err != nil {
+				break
+			}
+		}
+	}
+	return results
+}
+
+// run takes the actions of rule on m, moving or deleting it last
+func (r *Runner) run(rule Rule, mailbox string, m Message) Result {
+	result := Result{Rule: rule.Name, Message: m}
+	for _, a := range rule.ordered() {
+		if err := r.do(a, mailbox, m); err != nil {
+			result.Err = fmt.Errorf("%s: %w", a, err)
+			return result
+		}
+		result.Done = append(result.Done, a.String())
+		result.Read = result.Read || a.MarkRead
+		result.Removed = result.Removed || a.files()
+	}
+	return result
+}
+
+func (r *Runner) do(a Action, mailbox string, m Message) error {
+	uids := []imap.UID{m.UID}
+	organizer, canOrganize := r.Store.(mail.Organizer)
+
+	switch {
+	case a.MarkRead:
+		return r.Store.MarkMessagesAsRead(uids)
+	case a.Delete:
+		return r.Store.MoveToTrash(uids)
+	case a.Move != "":
+		if !canOrganize {
+			return fmt.Errorf("this account cannot move mail")
+		}
+		return organizer.MoveMessages(uids, a.Move)
+	case a.Label != "":
+		if !canOrganize {
+			return fmt.Errorf("this account cannot label mail")
+		}
+		return organizer.CopyMessages(uids, a.Label)
+	case a.Flag:
+		if !canOrganize {
+			return fmt.Errorf("this account cannot flag mail")
+		}
+		return organizer.SetFlagged(uids, true)
+	case a.Forward != "":
+		if r.Sender == nil {
+			return fmt.Errorf("no way to send mail")
+		}
+		return r.Sender.Send(a.Forward, "Fwd: "+m.Subject, forwardBody(m))
+	case a.Command != "":
+		return runCommand(a.Command, mailbox, m)
+	}
+	return nil
+}
+
+// forwardBody quotes m below a forwarding header
+func forwardBody(m Message) string {
+	var b strings.Builder
+	b.WriteString("---------- Forwarded message ----------\n")
+	b.WriteString("From: " + m.From + "\n")
+	if date, ok := m.header("Date"); ok {
+		b.WriteString("Date: " + date + "\n")
+	}
+	b.WriteString("Subject: " + m.Subject + "\n")
+	b.WriteString("To: " + m.To + "\n\n")
+	b.WriteString(m.Body)
+	return b.String()
+}
+
+func runCommand(command, mailbox string, m Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = strings.NewReader(m.Body)
+	cmd.Env = append(os.Environ(),
+		"MAILY_FROM="+m.From,
+		"MAILY_TO="+m.To,
+		"MAILY_SUBJECT="+m.Subject,
+		"MAILY_MESSAGE_ID="+m.MessageID,
+		"MAILY_MAILBOX="+mailbox,
+		fmt.Sprintf("MAILY_UID=%d", m.UID),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package rules
+
+import (
+	netmail "net/mail"
+	"strings"
+
+	"github.com/emersion/go-imap/v2"
+
+	"maily/internal/cache"
+	"maily/internal/mail"
+)
+
+// Message is what rules see of an email
+type Message struct {
+	UID         imap.UID
+	MessageID   string
+	From        string
+	To          string
+	Subject     string
+	Body        string
+	Headers     map[string]string
+	Attachments []string // file names
+}
+
+// FromEmail returns the message rules see of a fetched email
+func FromEmail(e mail.Email) Message {
+	m := Message{UID: e.UID, MessageID: e.MessageID, From: e.From, To: e.To, Subject: e.Subject, Body: e.Body, Headers: e.Headers}
+	for _, a := range e.Attachments {
+		m.Attachments = append(m.Attachments, a.Filename)
+	}
+	return m
+}
+
+// FromCached returns the message rules see of a cached email
+func FromCached(e cache.CachedEmail) Message {
+	m := Message{UID: e.UID, MessageID: e.MessageID, From: e.From, To: e.To, Subject: e.Subject, Body: e.Body, Headers: e.Headers}
+	for _, a := range e.Attachments {
+		m.Attachments = append(m.Attachments, a.Filename)
+	}
+	return m
+}
+
+// header returns a header field and whether the message has it
+func (m Message) header(name string) (string, bool) {
+	for k, v := range m.Headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Match reports whether m meets the rule's conditions
+func (r Rule) Match(m Message) bool {
+	for _, c := range r.Conditions {
+		matched := c.Match(m)
+		if r.Any && matched {
+			return true
+		}
+		if !r.Any && !matched {
+			return false
+		}
+	}
+	return !r.Any
+}
+
+// Match reports whether m meets the condition
+func (c Condition) Match(m Message) bool {
+	return c.match(m) != c.Not
+}
+
+func (c Condition) match(m Message) bool {
+	switch c.Field {
+	case FieldFrom:
+		return c.test(m.From, true)
+	case FieldTo:
+		to := m.To
+		if h, ok := m.header("To"); ok {
+			to = h
+		}
+		if cc, ok := m.header("Cc"); ok {
+			to += ", " + cc
+		}
+		return c.test(to, true)
+	case FieldSubject:
+		return c.test(m.Subject, false)
+	case FieldBody:
+		return c.test(m.Body, false)
+	case FieldHeader:
+		value, ok := m.header(c.Header)
+		if !ok {
+			return false
+		}
+		return !c.hasTest() || c.test(value, false)
+	case FieldAttachments:
+		if !c.hasTest() {
+			return len(m.Attachments) > 0
+		}
+		for _, name := range m.Attachments {
+			if c.test(name, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c Condition) hasTest() bool {
+	return c.Contains != "" || c.Is != "" || c.Matches != ""
+}
+
+// test checks value against the condition's tests, all of which must
+// pass. With addresses, is compares each address in value.
+func (c Condition) test(value string, addresses bool) bool {
+	if c.Contains != "" && !strings.Contains(strings.ToLower(value), strings.ToLower(c.Contains)) {
+		return false
+	}
+	if c.Is != "" && !c.is(value, addresses) {
+		return false
+	}
+	if c.re != nil && !c.re.MatchString(value) {
+		return false
+	}
+	return true
+}
+
+func (c Condition) is(value string, addresses bool) bool {
+	if strings.EqualFold(strings.TrimSpace(value), c.Is) {
+		return true
+	}
+	if !addresses {
+		return false
+	}
+	list, err := netmail.ParseAddressList(value)
+	if err != nil {
+		return false
+	}
+	for _, addr := range list {
+		if strings.EqualFold(addr.Address, c.Is) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+// Package rules filters new mail with rules from ~/.config/maily/rules.yml:
+// conditions on the sender, recipients, subject, headers, body and
+// attachments of a message, and actions to take when they match.
+package rules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"maily/config"
+	"maily/internal/mail"
+)
+
+const rulesFileName = "rules.yml"
+
+// Condition fields
+const (
+	FieldFrom        = "from"
+	FieldTo          = "to" // To and Cc
+	FieldSubject     = "subject"
+	FieldHeader      = "header"
+	FieldBody        = "body"
+	FieldAttachments = "attachments" // file names
+)
+
+// Set is the contents of rules.yml
+type Set struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule applies its actions to messages that meet its conditions: all of
+// them, or any with Any set
+type Rule struct {
+	Name       string      `yaml:"name"`
+	Account    string      `yaml:"account,omitempty"` // only this account; all if empty
+	Mailbox    string      `yaml:"mailbox,omitempty"` // default INBOX
+	Any        bool        `yaml:"any,omitempty"`
+	Conditions []Condition `yaml:"conditions"`
+	Actions    []Action    `yaml:"actions"`
+	Stop       bool        `yaml:"stop,omitempty"` // later rules skip messages this one matched
+}
+
+// Condition tests one field of a message. Text is compared without regard
+// to case; with no test, a header condition checks that the header is
+// there and an attachments condition that there are attachments.
+type Condition struct {
+	Field    string `yaml:"field"`
+	Header   string `yaml:"header,omitempty"`   // header name, for the header field
+	Contains string `yaml:"contains,omitempty"` // substring
+	Is       string `yaml:"is,omitempty"`       // whole value; an address for from and to
+	Matches  string `yaml:"matches,omitempty"`  // regular expression
+	Not      bool   `yaml:"not,omitempty"`      // negates the condition
+
+	re *regexp.Regexp
+}
+
+// Action is one thing to do with a matching message; exactly one field is
+// set. Move and delete take the message out of the mailbox, so they run
+// after the rule's other actions.
+type Action struct {
+	Move     string `yaml:"move,omitempty"`  // mailbox to move to
+	Label    string `yaml:"label,omitempty"` // mailbox to copy to (a Gmail label)
+	MarkRead bool   `yaml:"mark_read,omitempty"`
+	Flag     bool   `yaml:"flag,omitempty"`
+	Delete   bool   `yaml:"delete,omitempty"`  // move to trash
+	Forward  string `yaml:"forward,omitempty"` // address to forward to
+	Command  string `yaml:"command,omitempty"` // shell command; see Runner
+}
+
+// Path returns the path of rules.yml
+func Path() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, rulesFileName), nil
+}
+
+// Load reads and checks rules.yml. A missing file is an empty set.
+func Load() (*Set, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Set{}, nil
+		}
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse reads and checks rules in YAML
+func Parse(data []byte) (*Set, error) {
+	var set Set
+	if err := yaml.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	names := make(map[string]bool)
+	for i := range set.Rules {
+		r := &set.Rules[i]
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", i+1)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("rule %q is defined twice", r.Name)
+		}
+		names[r.Name] = true
+		if err := r.check(); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+	}
+	return &set, nil
+}
+
+// check validates a rule and compiles its expressions
+func (r *Rule) check() error {
+	if len(r.Conditions) == 0 {
+		return fmt.Errorf("no conditions")
+	}
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("no actions")
+	}
+	for i := range r.Conditions {
+		if err := r.Conditions[i].check(); err != nil {
+			return err
+		}
+	}
+	filing := 0
+	for _, a := range r.Actions {
+		if a.count() != 1 {
+			return fmt.Errorf("each action needs exactly one of move, label, mark_read, flag, delete, forward or command")
+		}
+		if a.files() {
+			filing++
+		}
+	}
+	if filing > 1 {
+		return fmt.Errorf("a rule can only move or delete a message once")
+	}
+	return nil
+}
+
+// ordered returns the rule's actions with the one that files the message
+// away last
+func (r Rule) ordered() []Action {
+	actions := make([]Action, 0, len(r.Actions))
+	for _, a := range r.Actions {
+		if !a.files() {
+			actions = append(actions, a)
+		}
+	}
+	for _, a := range r.Actions {
+		if a.files() {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
+
+func (c *Condition) check() error {
+	switch c.Field {
+	case FieldFrom, FieldTo, FieldSubject, FieldBody:
+		if c.Contains == "" && c.Is == "" && c.Matches == "" {
+			return fmt.Errorf("condition on %s needs contains, is or matches", c.Field)
+		}
+	case FieldHeader:
+		if c.Header == "" {
+			return fmt.Errorf("header condition needs a header name")
+		}
+	case FieldAttachments:
+	default:
+		return fmt.Errorf("unknown field %q (from, to, subject, header, body or attachments)", c.Field)
+	}
+	if c.Matches != "" {
+		re, err := regexp.Compile("(?i)" + c.Matches)
+		if err != nil {
+			return fmt.Errorf("invalid expression %q: %w", c.Matches, err)
+		}
+		c.re = re
+	}
+	return nil
+}
+
+func (a Action) count() int {
+	n := 0
+	for _, set := range []bool{a.Move != "", a.Label != "", a.MarkRead, a.Flag, a.Delete, a.Forward != "", a.Command != ""} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// files reports whether the action takes the message out of the mailbox
+func (a Action) files() bool {
+	return a.Move != "" || a.Delete
+}
+
+func (a Action) String() string {
+	switch {
+	case a.Move != "":
+		return "move to " + a.Move
+	case a.Label != "":
+		return "label " + a.Label
+	case a.MarkRead:
+		return "mark read"
+	case a.Flag:
+		return "flag"
+	case a.Delete:
+		return "delete"
+	case a.Forward != "":
+		return "forward to " + a.Forward
+	case a.Command != "":
+		return "run " + a.Command
+	}
+	return "nothing"
+}
+
+// Get returns the rule named name, nil if there is none
+func (s *Set) Get(name string) *Rule {
+	for i := range s.Rules {
+		if strings.EqualFold(s.Rules[i].Name, name) {
+			return &s.Rules[i]
+		}
+	}
+	return nil
+}
+
+// For returns the rules for a mailbox of account, in order
+func (s *Set) For(account, mailbox string) []Rule {
+	var rules []Rule
+	for _, r := range s.Rules {
+		if r.Applies(account, mailbox) {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// Applies reports whether the rule is for a mailbox of account
+func (r Rule) Applies(account, mailbox string) bool {
+	if r.Account != "" && !strings.EqualFold(r.Account, account) {
+		return false
+	}
+	want := r.Mailbox
+	if want == "" {
+		want = mail.INBOX
+	}
+	return strings.EqualFold(want, mailbox)
+}
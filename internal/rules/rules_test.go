@@ -0,0 +1,144 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"maily/internal/mail"
+	"maily/internal/mail/mailtest"
+)
+
+const testRules = `
+rules:
+  - name: newsletters
+    conditions:
+      - field: header
+        header: list-unsubscribe
+      - field: from
+        not: true
+        is: boss@example.com
+    actions:
+      - move: Newsletters
+      - mark_read: true
+  - name: invoices
+    any: true
+    conditions:
+      - field: subject
+        matches: "invoice|receipt"
+      - field: attachments
+        contains: .pdf
+    actions:
+      - flag: true
+      - forward: accounting@example.com
+    stop: true
+  - name: late
+    conditions:
+      - field: to
+        is: me@example.com
+    actions:
+      - label: Seen
+`
+
+func TestParse(t *testing.T) {
+	set, err := Parse([]byte(testRules))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(set.Rules) != 3 || set.Get("Invoices") == nil {
+		t.Fatalf("rules = %+v", set.Rules)
+	}
+	if got := set.For("me@example.com", "Archive"); len(got) != 0 {
+		t.Errorf("For(Archive) = %d rules, want 0", len(got))
+	}
+
+	for _, bad := range []string{
+		"rules:\n  - name: x\n    actions:\n      - flag: true\n",
+		"rules:\n  - name: x\n    conditions:\n      - field: cc\n        is: a\n    actions:\n      - flag: true\n",
+		"rules:\n  - name: x\n    conditions:\n      - field: subject\n        matches: \"(\"\n    actions:\n      - flag: true\n",
+		"rules:\n  - name: x\n    conditions:\n      - field: subject\n        is: a\n    actions:\n      - flag: true\n        delete: true\n",
+		"rules:\n  - name: x\n    conditions:\n      - field: subject\n        is: a\n    actions:\n      - move: A\n      - delete: true\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	set, err := Parse([]byte(testRules))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	store := mailtest.NewMemoryStore()
+	store.CreateMailbox("Newsletters")
+	store.CreateMailbox("Seen")
+	emails := []mail.Email{
+		{From: "News <news@shop.com>", To: "me@example.com", Subject: "Sale", Unread: true, Headers: map[string]string{"List-Unsubscribe": "<mailto:u@shop.com>"}},
+		{From: "Boss <boss@example.com>", To: "me@example.com", Subject: "Hi", Headers: map[string]string{"List-Unsubscribe": "<x>"}},
+		{From: "billing@acme.com", To: "me@example.com", Subject: "Your INVOICE", Body: "Due soon"},
+	}
+	var messages []Message
+	for _, e := range emails {
+		e.UID = store.Add(mail.INBOX, e)
+		messages = append(messages, FromEmail(e))
+	}
+	if err := store.SelectMailbox(mail.INBOX); err != nil {
+		t.Fatalf("SelectMailbox: %v", err)
+	}
+
+	sender := &mailtest.MemorySender{}
+	runner := Runner{Store: store, Sender: sender}
+	results := runner.Apply(set.For("me@example.com", mail.INBOX), mail.INBOX, messages)
+
+	var got []string
+	for _, r := range results {
+		if r.Err != nil {
+			t.Fatalf("rule %s: %v", r.Rule, r.Err)
+		}
+		got = append(got, r.Message.Subject+": "+r.Rule+" ("+strings.Join(r.Done, ", ")+")")
+	}
+	want := []string{
+		// Filing goes last, and nothing runs on a message after it
+		"Sale: newsletters (mark read, move to Newsletters)",
+		"Hi: late (label Seen)",
+		"Your INVOICE: invoices (flag, forward to accounting@example.com)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("results:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if moved := store.Messages("Newsletters"); len(moved) != 1 || moved[0].Unread {
+		t.Errorf("Newsletters = %+v, want the sale, read", moved)
+	}
+	if inbox := store.Messages(mail.INBOX); len(inbox) != 2 || !inbox[1].Flagged {
+		t.Errorf("INBOX = %+v, want the invoice flagged", inbox)
+	}
+	if len(sender.Sent) != 1 || sender.Sent[0].Subject != "Fwd: Your INVOICE" || !strings.Contains(sender.Sent[0].Body, "Due soon") {
+		t.Errorf("sent = %+v", sender.Sent)
+	}
+}
+
+func TestSieve(t *testing.T) {
+	set, err := Parse([]byte(testRules))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	script, skipped := set.Sieve()
+	for _, want := range []string{
+		`require ["copy", "fileinto", "imap4flags", "regex"];`,
+		`if allof (exists "list-unsubscribe",`,
+		`not address :all :is "from" "boss@example.com") {`,
+		"addflag \"\\\\Seen\";\n    fileinto \"Newsletters\";",
+		`if header :regex "subject" "invoice|receipt" {`,
+		`redirect :copy "accounting@example.com";`,
+		`fileinto :copy "Seen";`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script lacks %q:\n%s", want, script)
+		}
+	}
+	if len(skipped) != 1 || !strings.Contains(skipped[0], "attachments") {
+		t.Errorf("skipped = %q", skipped)
+	}
+}
@@ -0,0 +1,174 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sieve returns the rules as a Sieve script (RFC 5228) for a ManageSieve
+// server, and the parts Sieve cannot express, which are left out: tests on
+// attachments and command actions. A server runs the script on delivery,
+// so the rules' account and mailbox are dropped.
+func (s *Set) Sieve() (string, []string) {
+	var body strings.Builder
+	var skipped []string
+	extensions := make(map[string]bool)
+
+	for _, r := range s.Rules {
+		// Leaving out a condition of a rule that needs all of them would
+		// make it match more, so such a rule is left out whole
+		var tests []string
+		complete := true
+		for _, c := range r.Conditions {
+			test, ok := c.sieve(extensions)
+			if !ok {
+				skipped = append(skipped, fmt.Sprintf("rule %q: condition on %s", r.Name, c.Field))
+				complete = false
+				continue
+			}
+			tests = append(tests, test)
+		}
+		if len(tests) == 0 || (!complete && !r.Any) {
+			skipped = append(skipped, fmt.Sprintf("rule %q: left out", r.Name))
+			continue
+		}
+
+		// Flags set before fileinto go with the filed message
+		var actions []string
+		for _, a := range r.ordered() {
+			action, ok := a.sieve(extensions)
+			if !ok {
+				skipped = append(skipped, fmt.Sprintf("rule %q: %s", r.Name, a))
+				continue
+			}
+			actions = append(actions, action)
+		}
+		if r.Stop {
+			actions = append(actions, "stop;")
+		}
+		if len(actions) == 0 {
+			skipped = append(skipped, fmt.Sprintf("rule %q: no actions left", r.Name))
+			continue
+		}
+
+		fmt.Fprintf(&body, "\n# %s\n", r.Name)
+		op := "allof"
+		if r.Any {
+			op = "anyof"
+		}
+		if len(tests) == 1 {
+			fmt.Fprintf(&body, "if %s {\n", tests[0])
+		} else {
+			fmt.Fprintf(&body, "if %s (%s) {\n", op, strings.Join(tests, ",\n    "))
+		}
+		for _, action := range actions {
+			fmt.Fprintf(&body, "    %s\n", action)
+		}
+		body.WriteString("}\n")
+	}
+
+	var script strings.Builder
+	script.WriteString("# Generated by maily from rules.yml\n")
+	if len(extensions) > 0 {
+		var names []string
+		for name := range extensions {
+			names = append(names, sieveString(name))
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&script, "require [%s];\n", strings.Join(names, ", "))
+	}
+	script.WriteString(body.String())
+	return script.String(), skipped
+}
+
+// sieve returns the condition as a Sieve test; attachments have none
+func (c Condition) sieve(extensions map[string]bool) (string, bool) {
+	// Match types go between a test's name and its header names
+	var name, headers string
+	switch c.Field {
+	case FieldFrom:
+		name, headers = "address :all", `"from"`
+		if c.Is == "" {
+			name = "header"
+		}
+	case FieldTo:
+		name, headers = "address :all", `["to", "cc"]`
+		if c.Is == "" {
+			name = "header"
+		}
+	case FieldSubject:
+		name, headers = "header", `"subject"`
+	case FieldHeader:
+		name, headers = "header", sieveString(c.Header)
+	case FieldBody:
+		extensions["body"] = true
+		name = "body :text"
+	default:
+		return "", false
+	}
+	test := func(match, key string) string {
+		if headers == "" {
+			return fmt.Sprintf("%s %s %s", name, match, sieveString(key))
+		}
+		return fmt.Sprintf("%s %s %s %s", name, match, headers, sieveString(key))
+	}
+
+	var tests []string
+	if c.Contains != "" {
+		tests = append(tests, test(":contains", c.Contains))
+	}
+	if c.Is != "" {
+		tests = append(tests, test(":is", c.Is))
+	}
+	if c.Matches != "" {
+		extensions["regex"] = true
+		tests = append(tests, test(":regex", c.Matches))
+	}
+
+	var result string
+	switch len(tests) {
+	case 0: // a header that is there
+		result = "exists " + sieveString(c.Header)
+	case 1:
+		result = tests[0]
+	default:
+		result = "allof (" + strings.Join(tests, ", ") + ")"
+	}
+	if c.Not {
+		result = "not " + result
+	}
+	return result, true
+}
+
+// sieve returns the action as a Sieve command; commands have none
+func (a Action) sieve(extensions map[string]bool) (string, bool) {
+	switch {
+	case a.Move != "":
+		extensions["fileinto"] = true
+		return "fileinto " + sieveString(a.Move) + ";", true
+	case a.Label != "":
+		extensions["fileinto"] = true
+		extensions["copy"] = true
+		return "fileinto :copy " + sieveString(a.Label) + ";", true
+	case a.MarkRead:
+		extensions["imap4flags"] = true
+		return `addflag "\\Seen";`, true
+	case a.Flag:
+		extensions["imap4flags"] = true
+		return `addflag "\\Flagged";`, true
+	case a.Delete:
+		extensions["fileinto"] = true
+		return `fileinto "Trash";`, true
+	case a.Forward != "":
+		extensions["copy"] = true
+		return "redirect :copy " + sieveString(a.Forward) + ";", true
+	}
+	return "", false
+}
+
+// sieveString quotes s as a Sieve string
+func sieveString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,200 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// retryWait is how long a worker waits after its first failed sync;
+	// it doubles with each failure after that
+	retryWait = time.Minute
+	// maxBackoff is the longest a worker waits after failures, unless its
+	// interval is longer
+	maxBackoff = 4 * time.Hour
+)
+
+// Schedule is when a worker syncs its account next
+type Schedule struct {
+	Account  string
+	Interval time.Duration
+	Next     time.Time
+	Failures int // syncs in a row that failed
+}
+
+// Scheduler syncs accounts in the background: each in a worker goroutine
+// of its own and on its own interval, waiting exponentially longer while
+// its syncs fail, with at most a limited number of accounts syncing at
+// once. A slow or broken account delays no other.
+type Scheduler struct {
+	run     func(account, mailbox string) error
+	limit   chan struct{}
+	workers map[string]*worker
+	order   []string
+
+	// OnSchedule is called whenever a worker has decided when it syncs
+	// next. Set it before Start.
+	OnSchedule func(Schedule)
+}
+
+type worker struct {
+	account  string
+	interval time.Duration
+	requests chan syncRequest
+	poke     chan struct{}
+}
+
+// syncRequest asks a worker to sync now; done receives the result
+type syncRequest struct {
+	mailbox string
+	done    chan error
+}
+
+// NewScheduler returns a scheduler that syncs an account with run, at most
+// maxConcurrent at a time. An empty mailbox means the account's usual ones.
+func NewScheduler(maxConcurrent int, run func(account, mailbox string) error) *Scheduler {
+	return &Scheduler{
+		run:     run,
+		limit:   make(chan struct{}, max(1, maxConcurrent)),
+		workers: make(map[string]*worker),
+	}
+}
+
+// Add adds an account to sync every interval. Add all accounts before
+// Start.
+func (s *Scheduler) Add(account string, interval time.Duration) {
+	s.workers[account] = &worker{
+		account:  account,
+		interval: interval,
+		requests: make(chan syncRequest),
+		poke:     make(chan struct{}, 1),
+	}
+	s.order = append(s.order, account)
+}
+
+// Start starts the workers, which sync their accounts right away and
+// then on schedule until ctx is done
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, account := range s.order {
+		go s.work(ctx, s.workers[account])
+	}
+}
+
+// SyncNow has the worker of account sync mailbox now and waits for it. A
+// sync of the usual mailboxes counts as the scheduled one; one of a single
+// mailbox does not change when that is due.
+func (s *Scheduler) SyncNow(ctx context.Context, account, mailbox string) error {
+	w := s.workers[account]
+	if w == nil {
+		return fmt.Errorf("no account %s", account)
+	}
+	req := syncRequest{mailbox: mailbox, done: make(chan error, 1)}
+	select {
+	case w.requests <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SyncAllNow has every worker sync now and waits for all of them
+func (s *Scheduler) SyncAllNow(ctx context.Context) error {
+	errs := make([]error, len(s.order))
+	done := make(chan struct{})
+	for i, account := range s.order {
+		go func() {
+			if err := s.SyncNow(ctx, account, ""); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", account, err)
+			}
+			done <- struct{}{}
+		}()
+	}
+	for range s.order {
+		<-done
+	}
+	return errors.Join(errs...)
+}
+
+// Poke has the worker of account sync soon, e.g. because the server
+// reported a change, without waiting for it
+func (s *Scheduler) Poke(account string) {
+	if w := s.workers[account]; w != nil {
+		select {
+		case w.poke <- struct{}{}:
+		default: // a sync is already due
+		}
+	}
+}
+
+func (s *Scheduler) work(ctx context.Context, w *worker) {
+	failures := 0
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		var req syncRequest
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-w.poke:
+		case req = <-w.requests:
+		}
+
+		err := s.sync(ctx, w.account, req.mailbox)
+		if req.done != nil {
+			req.done <- err
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if req.mailbox != "" {
+			// A refresh of one mailbox leaves the account's schedule and
+			// health to its syncs of the usual mailboxes
+			continue
+		}
+		if err != nil {
+			failures++
+		} else {
+			failures = 0
+		}
+
+		wait := Backoff(w.interval, failures)
+		timer.Reset(wait)
+		if s.OnSchedule != nil {
+			s.OnSchedule(Schedule{Account: w.account, Interval: w.interval, Next: time.Now().Add(wait), Failures: failures})
+		}
+	}
+}
+
+// sync runs a sync once fewer than the maximum are running
+func (s *Scheduler) sync(ctx context.Context, account, mailbox string) error {
+	select {
+	case s.limit <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.limit }()
+	return s.run(account, mailbox)
+}
+
+// Backoff returns how long a worker syncing every interval waits after
+// failures syncs in a row failed: the interval if none did, else a minute
+// doubling with each failure, up to four hours or the interval
+func Backoff(interval time.Duration, failures int) time.Duration {
+	if failures == 0 {
+		return interval
+	}
+	limit := max(maxBackoff, interval)
+	wait := retryWait
+	for i := 1; i < failures && wait < limit; i++ {
+		wait *= 2
+	}
+	return min(wait, limit)
+}
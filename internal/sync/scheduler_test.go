@@ -0,0 +1,118 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	for _, tt := range []struct {
+		interval time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{30 * time.Minute, 0, 30 * time.Minute},
+		{30 * time.Minute, 1, time.Minute},
+		{30 * time.Minute, 3, 4 * time.Minute},
+		{30 * time.Minute, 20, 4 * time.Hour},
+		{12 * time.Hour, 20, 12 * time.Hour},
+	} {
+		if got := Backoff(tt.interval, tt.failures); got != tt.want {
+			t.Errorf("Backoff(%s, %d) = %s, want %s", tt.interval, tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	var running, most atomic.Int32
+	run := func(account, mailbox string) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		if n > most.Load() {
+			most.Store(n)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if account == "broken@x.com" {
+			return errors.New("login failed")
+		}
+		return nil
+	}
+
+	s := NewScheduler(2, run)
+	schedules := make(chan Schedule, 100)
+	s.OnSchedule = func(sc Schedule) { schedules <- sc }
+	for _, account := range []string{"a@x.com", "b@x.com", "c@x.com", "broken@x.com"} {
+		s.Add(account, time.Hour)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	// Every account syncs on start
+	failures := make(map[string]int)
+	for range 4 {
+		sc := <-schedules
+		failures[sc.Account] = sc.Failures
+		if sc.Account == "broken@x.com" && time.Until(sc.Next) > time.Minute {
+			t.Errorf("broken account retries at %s, want within a minute", sc.Next)
+		}
+	}
+	if failures["a@x.com"] != 0 || failures["broken@x.com"] != 1 {
+		t.Errorf("failures after start = %v", failures)
+	}
+	if most.Load() > 2 {
+		t.Errorf("%d accounts synced at once, want at most 2", most.Load())
+	}
+
+	if err := s.SyncNow(ctx, "broken@x.com", ""); err == nil {
+		t.Error("SyncNow of broken account succeeded")
+	}
+	if sc := <-schedules; sc.Failures != 2 || time.Until(sc.Next) < time.Minute {
+		t.Errorf("after second failure = %+v, want 2 failures and a longer wait", sc)
+	}
+	if err := s.SyncNow(ctx, "nobody@x.com", ""); err == nil {
+		t.Error("SyncNow of unknown account succeeded")
+	}
+
+	err := s.SyncAllNow(ctx)
+	if err == nil || err.Error() != "broken@x.com: login failed" {
+		t.Errorf("SyncAllNow = %v", err)
+	}
+}
+
+func TestSchedulerMailboxSync(t *testing.T) {
+	// Refreshing Work succeeds while syncs of the account fail
+	s := NewScheduler(1, func(account, mailbox string) error {
+		if mailbox == "Work" {
+			return nil
+		}
+		return errors.New("login failed")
+	})
+	schedules := make(chan Schedule, 10)
+	s.OnSchedule = func(sc Schedule) { schedules <- sc }
+	s.Add("a@x.com", time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+	first := <-schedules
+
+	if err := s.SyncNow(ctx, "a@x.com", "Work"); err != nil {
+		t.Fatalf("SyncNow of Work: %v", err)
+	}
+	select {
+	case sc := <-schedules:
+		t.Fatalf("mailbox refresh rescheduled the account: %+v", sc)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The backoff goes on from where the account's own syncs left it
+	s.SyncNow(ctx, "a@x.com", "")
+	if sc := <-schedules; first.Failures != 1 || sc.Failures != 2 {
+		t.Errorf("failures = %d then %d, want 1 then 2", first.Failures, sc.Failures)
+	}
+}
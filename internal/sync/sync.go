@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 	"maily/internal/auth"
 	"maily/internal/cache"
 	"maily/internal/mail"
+	"maily/internal/rules"
 )
 
 const (
@@ -22,16 +24,36 @@ const (
 type Syncer struct {
 	cache   *cache.Cache
 	account *auth.Account
+	dial    mail.Dialer
+
+	rules  *rules.Set
+	sender mail.Sender
+	report func(rules.Result)
 }
 
 // NewSyncer creates a new syncer for an account
 func NewSyncer(c *cache.Cache, account *auth.Account) *Syncer {
+	return NewSyncerWithDialer(c, account, mail.Dial)
+}
+
+// NewSyncerWithDialer creates a syncer that connects through the given dialer
+func NewSyncerWithDialer(c *cache.Cache, account *auth.Account, dial mail.Dialer) *Syncer {
 	return &Syncer{
 		cache:   c,
 		account: account,
+		dial:    dial,
 	}
 }
 
+// SetRules makes FullSync apply the rules of set to mail that arrives in a
+// mailbox after its first sync, and has rules forward through sender.
+// report is called with what each matching rule did.
+func (s *Syncer) SetRules(set *rules.Set, sender mail.Sender, report func(rules.Result)) {
+	s.rules = set
+	s.sender = sender
+	s.report = report
+}
+
 // FullSync performs a full sync for a mailbox (14 days)
 func (s *Syncer) FullSync(mailbox string) error {
 	email := s.account.Credentials.Email
@@ -46,8 +68,8 @@ func (s *Syncer) FullSync(mailbox string) error {
 	}
 	defer s.cache.ReleaseLock(email)
 
-	// Connect to IMAP
-	client, err := mail.NewIMAPClient(&s.account.Credentials)
+	// Connect to server
+	client, err := s.dial(&s.account.Credentials)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
@@ -73,62 +95,46 @@ func (s *Syncer) FullSync(mailbox string) error {
 		meta = nil
 	}
 
-	// Fetch UIDs and flags for last 14 days
-	since := time.Now().AddDate(0, 0, -SyncDays)
-	serverUIDs, err := client.FetchUIDsAndFlags(mailbox, since)
-	if err != nil {
-		return fmt.Errorf("failed to fetch UIDs: %w", err)
-	}
-
-	// Get cached UIDs
-	cachedUIDs, err := s.cache.GetCachedUIDs(email, mailbox)
-	if err != nil {
-		return fmt.Errorf("failed to get cached UIDs: %w", err)
-	}
-
-	// Find new UIDs (on server but not in cache)
-	var newUIDs []imap.UID
-	for uid := range serverUIDs {
-		if !cachedUIDs[uid] {
-			newUIDs = append(newUIDs, uid)
-		}
-	}
-
-	// Find deleted UIDs (in cache but not on server)
-	for uid := range cachedUIDs {
-		if _, ok := serverUIDs[uid]; !ok {
-			if err := s.cache.DeleteEmail(email, mailbox, uid); err != nil {
-				// Log but don't fail
-				continue
+	// Servers that track state (JMAP, Graph) report deltas; others are diffed by UID
+	var state *mail.SyncState
+	var fetched []mail.Email
+	tracker, hasTracker := client.(mail.ChangeTracker)
+	if hasTracker && meta != nil && meta.EmailState != "" {
+		since := mail.SyncState{Email: meta.EmailState, Mailbox: meta.MailboxState}
+		changes, err := tracker.Changes(mailbox, since)
+		switch {
+		case err == nil:
+			if fetched, err = s.applyChanges(client, mailbox, changes); err != nil {
+				return err
 			}
+			state = &changes.State
+		case errors.Is(err, mail.ErrStateReset):
+			if err := s.cache.InvalidateMailbox(email, mailbox); err != nil {
+				return fmt.Errorf("failed to invalidate cache: %w", err)
+			}
+			meta = nil
+		default:
+			return fmt.Errorf("failed to fetch changes: %w", err)
 		}
 	}
 
-	// Fetch new emails
-	if len(newUIDs) > 0 {
-		emails, err := client.FetchMessagesByUIDs(mailbox, newUIDs)
-		if err != nil {
-			return fmt.Errorf("failed to fetch new emails: %w", err)
-		}
-
-		for _, e := range emails {
-			cached := emailToCached(e)
-			if err := s.cache.SaveEmail(email, mailbox, cached); err != nil {
-				// Log but don't fail
-				continue
+	if state == nil {
+		if hasTracker {
+			// Take the state before listing so changes made during the sync
+			// are picked up next time
+			state, err = tracker.State(mailbox)
+			if err != nil {
+				return fmt.Errorf("failed to fetch state: %w", err)
 			}
 		}
+		if fetched, err = s.diffUIDs(client, mailbox); err != nil {
+			return err
+		}
 	}
 
-	// Update flags for existing emails
-	for uid, unread := range serverUIDs {
-		if cachedUIDs[uid] {
-			// Check if flag changed
-			if err := s.cache.UpdateEmailFlags(email, mailbox, uid, unread); err != nil {
-				// Log but don't fail
-				continue
-			}
-		}
+	// Mail of the first sync is not new; rules only see what arrives later
+	if meta != nil {
+		s.applyRules(client, mailbox, fetched)
 	}
 
 	// Cleanup old emails
@@ -140,6 +146,10 @@ func (s *Syncer) FullSync(mailbox string) error {
 		UIDValidity: info.UIDValidity,
 		LastSync:    time.Now(),
 	}
+	if state != nil {
+		newMeta.EmailState = state.Email
+		newMeta.MailboxState = state.Mailbox
+	}
 	if err := s.cache.SaveMetadata(email, mailbox, newMeta); err != nil {
 		return fmt.Errorf("failed to save metadata: %w", err)
 	}
@@ -161,8 +171,8 @@ func (s *Syncer) QuickRefresh(mailbox string) error {
 	}
 	defer s.cache.ReleaseLock(email)
 
-	// Connect to IMAP
-	client, err := mail.NewIMAPClient(&s.account.Credentials)
+	// Connect to server
+	client, err := s.dial(&s.account.Credentials)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
@@ -183,19 +193,205 @@ func (s *Syncer) QuickRefresh(mailbox string) error {
 		}
 	}
 
-	// Update metadata
+	// Update metadata, keeping any delta sync state: the next FullSync
+	// replays changes since then, which is harmless for what we just saved
 	info, err := client.SelectMailboxWithInfo(mailbox)
 	if err == nil {
-		meta := &cache.Metadata{
-			UIDValidity: info.UIDValidity,
-			LastSync:    time.Now(),
+		meta, _ := s.cache.LoadMetadata(email, mailbox)
+		if meta == nil || meta.UIDValidity != info.UIDValidity {
+			meta = &cache.Metadata{}
 		}
+		meta.UIDValidity = info.UIDValidity
+		meta.LastSync = time.Now()
 		s.cache.SaveMetadata(email, mailbox, meta)
 	}
 
 	return nil
 }
 
+// diffUIDs compares the server's UIDs and flags for the sync window with the
+// cache, fetching new messages and dropping deleted ones. It returns the
+// new messages.
+func (s *Syncer) diffUIDs(client mail.Store, mailbox string) ([]mail.Email, error) {
+	email := s.account.Credentials.Email
+
+	// Fetch UIDs and flags for last 14 days
+	since := time.Now().AddDate(0, 0, -SyncDays)
+	serverUIDs, err := client.FetchUIDsAndFlags(mailbox, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch UIDs: %w", err)
+	}
+
+	// Get cached UIDs
+	cachedUIDs, err := s.cache.GetCachedUIDs(email, mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached UIDs: %w", err)
+	}
+
+	// Find new UIDs (on server but not in cache)
+	var newUIDs []imap.UID
+	for uid := range serverUIDs {
+		if !cachedUIDs[uid] {
+			newUIDs = append(newUIDs, uid)
+		}
+	}
+
+	// Find deleted UIDs (in cache but not on server)
+	for uid := range cachedUIDs {
+		if _, ok := serverUIDs[uid]; !ok {
+			if err := s.cache.DeleteEmail(email, mailbox, uid); err != nil {
+				// Log but don't fail
+				continue
+			}
+		}
+	}
+
+	fetched, err := s.fetchNew(client, mailbox, newUIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update flags for existing emails
+	for uid, unread := range serverUIDs {
+		if cachedUIDs[uid] {
+			// Check if flag changed
+			if err := s.cache.UpdateEmailFlags(email, mailbox, uid, unread); err != nil {
+				// Log but don't fail
+				continue
+			}
+		}
+	}
+
+	return fetched, nil
+}
+
+// applyChanges updates the cache from a server-reported delta and returns
+// the new messages
+func (s *Syncer) applyChanges(client mail.Store, mailbox string, changes *mail.MailboxChanges) ([]mail.Email, error) {
+	email := s.account.Credentials.Email
+
+	cachedUIDs, err := s.cache.GetCachedUIDs(email, mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached UIDs: %w", err)
+	}
+
+	for _, uid := range changes.Removed {
+		if err := s.cache.DeleteEmail(email, mailbox, uid); err != nil {
+			// Log but don't fail
+			continue
+		}
+	}
+
+	var newUIDs []imap.UID
+	for uid, unread := range changes.Flags {
+		if cachedUIDs[uid] {
+			s.cache.UpdateEmailFlags(email, mailbox, uid, unread)
+		} else {
+			newUIDs = append(newUIDs, uid)
+		}
+	}
+
+	return s.fetchNew(client, mailbox, newUIDs)
+}
+
+// fetchNew downloads and caches full messages
+func (s *Syncer) fetchNew(client mail.Store, mailbox string, uids []imap.UID) ([]mail.Email, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	emails, err := client.FetchMessagesByUIDs(mailbox, uids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch new emails: %w", err)
+	}
+
+	for _, e := range emails {
+		cached := emailToCached(e)
+		if err := s.cache.SaveEmail(s.account.Credentials.Email, mailbox, cached); err != nil {
+			// Log but don't fail
+			continue
+		}
+	}
+	return emails, nil
+}
+
+// applyRules runs the rules for mailbox on newly fetched messages and
+// reports what they did
+func (s *Syncer) applyRules(client mail.Store, mailbox string, emails []mail.Email) {
+	if s.rules == nil || len(emails) == 0 {
+		return
+	}
+	set := s.rules.For(s.account.Credentials.Email, mailbox)
+	if len(set) == 0 {
+		return
+	}
+	if err := client.SelectMailbox(mailbox); err != nil {
+		return
+	}
+
+	messages := make([]rules.Message, len(emails))
+	for i, e := range emails {
+		messages[i] = rules.FromEmail(e)
+	}
+	for _, result := range s.runRules(client, mailbox, set, messages) {
+		if s.report != nil {
+			s.report(result)
+		}
+	}
+}
+
+// RunRules applies rules to the cached messages of mailbox, e.g. to file
+// mail that arrived before a rule was written
+func (s *Syncer) RunRules(mailbox string, set []rules.Rule) ([]rules.Result, error) {
+	account := s.account.Credentials.Email
+
+	acquired, err := s.cache.AcquireLock(account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	if !acquired {
+		return nil, fmt.Errorf("sync already in progress")
+	}
+	defer s.cache.ReleaseLock(account)
+
+	cached, err := s.cache.LoadEmails(account, mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load emails: %w", err)
+	}
+	messages := make([]rules.Message, len(cached))
+	for i, e := range cached {
+		messages[i] = rules.FromCached(e)
+	}
+
+	client, err := s.dial(&s.account.Credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer client.Close()
+	if err := client.SelectMailbox(mailbox); err != nil {
+		return nil, fmt.Errorf("failed to select mailbox: %w", err)
+	}
+
+	return s.runRules(client, mailbox, set, messages), nil
+}
+
+// runRules applies rules to messages of the selected mailbox and updates
+// the cache for the ones they moved, deleted or marked read
+func (s *Syncer) runRules(client mail.Store, mailbox string, set []rules.Rule, messages []rules.Message) []rules.Result {
+	account := s.account.Credentials.Email
+	runner := rules.Runner{Store: client, Sender: s.sender}
+	results := runner.Apply(set, mailbox, messages)
+	for _, result := range results {
+		switch {
+		case result.Removed:
+			s.cache.DeleteEmail(account, mailbox, result.Message.UID)
+		case result.Read:
+			s.cache.UpdateEmailFlags(account, mailbox, result.Message.UID, false)
+		}
+	}
+	return results
+}
+
 // emailToCached converts a mail.Email to cache.CachedEmail
 func emailToCached(e mail.Email) cache.CachedEmail {
 	attachments := make([]cache.Attachment, len(e.Attachments))
@@ -222,5 +418,7 @@ func emailToCached(e mail.Email) cache.CachedEmail {
 		Unread:       e.Unread,
 		References:   e.References,
 		Attachments:  attachments,
+		Calendar:     e.Calendar,
+		Headers:      e.Headers,
 	}
 }
@@ -0,0 +1,459 @@
+package sync
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+	"github.com/emersion/go-imap/v2/imapserver"
+	"github.com/emersion/go-imap/v2/imapserver/imapmemserver"
+
+	"maily/internal/auth"
+	"maily/internal/cache"
+	"maily/internal/mail"
+	"maily/internal/mail/mailtest"
+	"maily/internal/rules"
+)
+
+const (
+	testUser     = "me@example.com"
+	testPassword = "secret"
+)
+
+// testServer is an in-process IMAP server backed by imapmemserver
+type testServer struct {
+	t    *testing.T
+	addr string
+	user *imapmemserver.User
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	memServer := imapmemserver.New()
+	user := imapmemserver.NewUser(testUser, testPassword)
+	if err := user.Create("INBOX", nil); err != nil {
+		t.Fatalf("create INBOX: %v", err)
+	}
+	memServer.AddUser(user)
+
+	server := imapserver.New(&imapserver.Options{
+		NewSession: func(*imapserver.Conn) (imapserver.Session, *imapserver.GreetingData, error) {
+			return memServer.NewSession(), nil, nil
+		},
+		Caps: imap.CapSet{
+			imap.CapIMAP4rev1: {},
+			imap.CapIMAP4rev2: {},
+		},
+		InsecureAuth: true,
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+
+	return &testServer{t: t, addr: ln.Addr().String(), user: user}
+}
+
+// dial is a mail.Dialer that connects to the test server without TLS
+func (s *testServer) dial(creds *auth.Credentials) (mail.Store, error) {
+	conn, err := net.Dial("tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	client, err := mail.NewIMAPClientFromConn(conn, creds)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// admin opens a raw IMAP session for manipulating the server behind the syncer's back
+func (s *testServer) admin() *imapclient.Client {
+	s.t.Helper()
+
+	conn, err := net.Dial("tcp", s.addr)
+	if err != nil {
+		s.t.Fatalf("dial: %v", err)
+	}
+	client := imapclient.New(conn, nil)
+	if err := client.Login(testUser, testPassword).Wait(); err != nil {
+		s.t.Fatalf("login: %v", err)
+	}
+	s.t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func (s *testServer) appendMessage(mailbox, subject string, seen bool) {
+	s.t.Helper()
+
+	raw := fmt.Sprintf("From: sender@example.com\r\n"+
+		"To: %s\r\n"+
+		"Subject: %s\r\n"+
+		"Date: %s\r\n"+
+		"Message-ID: <%s@example.com>\r\n"+
+		"Content-Type: text/plain; charset=utf-8\r\n"+
+		"\r\n"+
+		"Body of %s\r\n",
+		testUser, subject, time.Now().Format(time.RFC1123Z), strings.ReplaceAll(subject, " ", "-"), subject)
+
+	var flags []imap.Flag
+	if seen {
+		flags = append(flags, imap.FlagSeen)
+	}
+	if _, err := s.user.Append(mailbox, literal(raw), &imap.AppendOptions{Flags: flags, Time: time.Now()}); err != nil {
+		s.t.Fatalf("append: %v", err)
+	}
+}
+
+type literalReader struct {
+	*strings.Reader
+	size int64
+}
+
+func (l literalReader) Size() int64 { return l.size }
+
+func literal(s string) imap.LiteralReader {
+	return literalReader{Reader: strings.NewReader(s), size: int64(len(s))}
+}
+
+func newTestSyncer(t *testing.T, dial mail.Dialer) (*Syncer, *cache.Cache) {
+	t.Helper()
+
+	c := cache.NewWithBaseDir(t.TempDir())
+	account := &auth.Account{
+		Name:     "test",
+		Provider: "imap",
+		Credentials: auth.Credentials{
+			Email:    testUser,
+			Password: testPassword,
+		},
+	}
+	return NewSyncerWithDialer(c, account, dial), c
+}
+
+func cachedSubjects(t *testing.T, c *cache.Cache) map[string]cache.CachedEmail {
+	t.Helper()
+
+	emails, err := c.LoadEmails(testUser, "INBOX")
+	if err != nil {
+		t.Fatalf("LoadEmails: %v", err)
+	}
+	bySubject := make(map[string]cache.CachedEmail)
+	for _, e := range emails {
+		bySubject[e.Subject] = e
+	}
+	return bySubject
+}
+
+func TestFullSyncCachesNewMessages(t *testing.T) {
+	server := newTestServer(t)
+	server.appendMessage("INBOX", "hello", false)
+	server.appendMessage("INBOX", "already read", true)
+
+	syncer, c := newTestSyncer(t, server.dial)
+	if err := syncer.FullSync("INBOX"); err != nil {
+		t.Fatalf("FullSync: %v", err)
+	}
+
+	cached := cachedSubjects(t, c)
+	if len(cached) != 2 {
+		t.Fatalf("cached %d emails, want 2", len(cached))
+	}
+	if !cached["hello"].Unread {
+		t.Error("hello should be unread")
+	}
+	if cached["already read"].Unread {
+		t.Error("already read should not be unread")
+	}
+	if !strings.Contains(cached["hello"].Body, "Body of hello") {
+		t.Errorf("body = %q", cached["hello"].Body)
+	}
+
+	meta, err := c.LoadMetadata(testUser, "INBOX")
+	if err != nil || meta == nil {
+		t.Fatalf("LoadMetadata = %v, %v", meta, err)
+	}
+	if meta.UIDValidity == 0 {
+		t.Error("UIDValidity not recorded")
+	}
+}
+
+func TestFullSyncRemovesDeletedMessages(t *testing.T) {
+	server := newTestServer(t)
+	server.appendMessage("INBOX", "keep", false)
+	server.appendMessage("INBOX", "drop", false)
+
+	syncer, c := newTestSyncer(t, server.dial)
+	if err := syncer.FullSync("INBOX"); err != nil {
+		t.Fatalf("FullSync: %v", err)
+	}
+	drop := cachedSubjects(t, c)["drop"].UID
+
+	admin := server.admin()
+	if _, err := admin.Select("INBOX", nil).Wait(); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	store := &imap.StoreFlags{Op: imap.StoreFlagsAdd, Flags: []imap.Flag{imap.FlagDeleted}, Silent: true}
+	if err := admin.Store(imap.UIDSetNum(drop), store, nil).Close(); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if err := admin.Expunge().Close(); err != nil {
+		t.Fatalf("expunge: %v", err)
+	}
+
+	if err := syncer.FullSync("INBOX"); err != nil {
+		t.Fatalf("second FullSync: %v", err)
+	}
+
+	cached := cachedSubjects(t, c)
+	if _, ok := cached["drop"]; ok {
+		t.Error("deleted message still cached")
+	}
+	if _, ok := cached["keep"]; !ok {
+		t.Error("kept message missing from cache")
+	}
+}
+
+func TestFullSyncUpdatesFlags(t *testing.T) {
+	server := newTestServer(t)
+	server.appendMessage("INBOX", "hello", false)
+
+	syncer, c := newTestSyncer(t, server.dial)
+	if err := syncer.FullSync("INBOX"); err != nil {
+		t.Fatalf("FullSync: %v", err)
+	}
+	uid := cachedSubjects(t, c)["hello"].UID
+
+	admin := server.admin()
+	if _, err := admin.Select("INBOX", nil).Wait(); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	store := &imap.StoreFlags{Op: imap.StoreFlagsAdd, Flags: []imap.Flag{imap.FlagSeen}, Silent: true}
+	if err := admin.Store(imap.UIDSetNum(uid), store, nil).Close(); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	if err := syncer.FullSync("INBOX"); err != nil {
+		t.Fatalf("second FullSync: %v", err)
+	}
+	if cachedSubjects(t, c)["hello"].Unread {
+		t.Error("message read on server is still unread in cache")
+	}
+}
+
+func TestFullSyncResetsOnUIDValidityChange(t *testing.T) {
+	server := newTestServer(t)
+	server.appendMessage("INBOX", "before", false)
+
+	syncer, c := newTestSyncer(t, server.dial)
+	if err := syncer.FullSync("INBOX"); err != nil {
+		t.Fatalf("FullSync: %v", err)
+	}
+	before, _ := c.LoadMetadata(testUser, "INBOX")
+
+	// Recreating the mailbox gives it a new UIDVALIDITY and restarts UIDs at 1,
+	// so the new message reuses the old message's UID
+	if err := server.user.Delete("INBOX"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := server.user.Create("INBOX", nil); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	server.appendMessage("INBOX", "after", false)
+
+	if err := syncer.FullSync("INBOX"); err != nil {
+		t.Fatalf("second FullSync: %v", err)
+	}
+
+	after, _ := c.LoadMetadata(testUser, "INBOX")
+	if after.UIDValidity == before.UIDValidity {
+		t.Fatalf("UIDValidity unchanged (%d)", after.UIDValidity)
+	}
+
+	cached := cachedSubjects(t, c)
+	if len(cached) != 1 {
+		t.Fatalf("cached %d emails, want 1", len(cached))
+	}
+	if _, ok := cached["after"]; !ok {
+		t.Errorf("cache was not rebuilt after UIDVALIDITY change: %v", cached)
+	}
+}
+
+func TestQuickRefreshWithMemoryStore(t *testing.T) {
+	store := mailtest.NewMemoryStore()
+	for i := 0; i < QuickRefreshLimit+5; i++ {
+		store.Add(mail.INBOX, mail.Email{Subject: fmt.Sprintf("message %d", i), Unread: true})
+	}
+
+	syncer, c := newTestSyncer(t, func(*auth.Credentials) (mail.Store, error) {
+		return store, nil
+	})
+	if err := syncer.QuickRefresh(mail.INBOX); err != nil {
+		t.Fatalf("QuickRefresh: %v", err)
+	}
+
+	if !store.Closed() {
+		t.Error("QuickRefresh did not close the store")
+	}
+
+	uids, err := c.GetCachedUIDs(testUser, mail.INBOX)
+	if err != nil {
+		t.Fatalf("GetCachedUIDs: %v", err)
+	}
+	if len(uids) != QuickRefreshLimit {
+		t.Errorf("cached %d emails, want %d", len(uids), QuickRefreshLimit)
+	}
+	// The newest messages are the ones with the highest UIDs
+	if !uids[imap.UID(QuickRefreshLimit+5)] || uids[1] {
+		t.Error("QuickRefresh did not cache the newest messages")
+	}
+}
+
+func TestSyncFailsWhenLocked(t *testing.T) {
+	syncer, c := newTestSyncer(t, func(*auth.Credentials) (mail.Store, error) {
+		return mailtest.NewMemoryStore(), nil
+	})
+
+	if acquired, err := c.AcquireLock(testUser); err != nil || !acquired {
+		t.Fatalf("AcquireLock = %v, %v", acquired, err)
+	}
+	defer c.ReleaseLock(testUser)
+
+	if err := syncer.FullSync(mail.INBOX); err == nil {
+		t.Error("FullSync succeeded while another sync holds the lock")
+	}
+}
+
+// trackingStore adds JMAP-style change tracking to a MemoryStore
+type trackingStore struct {
+	*mailtest.MemoryStore
+	state   string
+	changes *mail.MailboxChanges
+	since   []mail.SyncState
+}
+
+func (s *trackingStore) State(mailbox string) (*mail.SyncState, error) {
+	return &mail.SyncState{Email: s.state, Mailbox: s.state}, nil
+}
+
+func (s *trackingStore) Changes(mailbox string, since mail.SyncState) (*mail.MailboxChanges, error) {
+	s.since = append(s.since, since)
+	if s.changes == nil {
+		return nil, mail.ErrStateReset
+	}
+	return s.changes, nil
+}
+
+func TestFullSyncAppliesServerChanges(t *testing.T) {
+	store := &trackingStore{MemoryStore: mailtest.NewMemoryStore(), state: "s1"}
+	kept := store.Add(mail.INBOX, mail.Email{Subject: "kept", Unread: true})
+	gone := store.Add(mail.INBOX, mail.Email{Subject: "gone", Unread: true})
+
+	syncer, c := newTestSyncer(t, func(*auth.Credentials) (mail.Store, error) {
+		return store, nil
+	})
+
+	// The first sync lists everything and records the state
+	if err := syncer.FullSync(mail.INBOX); err != nil {
+		t.Fatalf("FullSync: %v", err)
+	}
+	if len(store.since) != 0 {
+		t.Fatal("Changes called without a saved state")
+	}
+	meta, _ := c.LoadMetadata(testUser, mail.INBOX)
+	if meta.EmailState != "s1" {
+		t.Fatalf("EmailState = %q, want s1", meta.EmailState)
+	}
+
+	added := store.Add(mail.INBOX, mail.Email{Subject: "added", Unread: true})
+	store.changes = &mail.MailboxChanges{
+		State:   mail.SyncState{Email: "s2", Mailbox: "s2"},
+		Flags:   map[imap.UID]bool{kept: false, added: true},
+		Removed: []imap.UID{gone},
+	}
+
+	if err := syncer.FullSync(mail.INBOX); err != nil {
+		t.Fatalf("second FullSync: %v", err)
+	}
+	if len(store.since) != 1 || store.since[0].Email != "s1" {
+		t.Fatalf("Changes called with %v, want s1", store.since)
+	}
+
+	cached := cachedSubjects(t, c)
+	if _, ok := cached["gone"]; ok {
+		t.Error("removed message still cached")
+	}
+	if cached["kept"].Unread {
+		t.Error("flag change not applied")
+	}
+	if _, ok := cached["added"]; !ok {
+		t.Error("new message not fetched")
+	}
+	meta, _ = c.LoadMetadata(testUser, mail.INBOX)
+	if meta.EmailState != "s2" {
+		t.Errorf("EmailState = %q, want s2", meta.EmailState)
+	}
+
+	// When the server cannot compute changes, the cache is rebuilt
+	store.changes = nil
+	store.state = "s3"
+	if err := syncer.FullSync(mail.INBOX); err != nil {
+		t.Fatalf("third FullSync: %v", err)
+	}
+	cached = cachedSubjects(t, c)
+	if len(cached) != 3 {
+		t.Errorf("cached %d emails after reset, want 3", len(cached))
+	}
+	meta, _ = c.LoadMetadata(testUser, mail.INBOX)
+	if meta.EmailState != "s3" {
+		t.Errorf("EmailState = %q, want s3", meta.EmailState)
+	}
+}
+
+func TestFullSyncAppliesRules(t *testing.T) {
+	store := mailtest.NewMemoryStore()
+	store.CreateMailbox("News")
+	store.Add(mail.INBOX, mail.Email{From: "news@shop.com", Subject: "old news", Unread: true})
+
+	set, err := rules.Parse([]byte("rules:\n  - name: news\n    conditions:\n      - field: from\n        contains: shop.com\n    actions:\n      - mark_read: true\n      - move: News\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var reported []rules.Result
+	syncer, c := newTestSyncer(t, func(*auth.Credentials) (mail.Store, error) {
+		return store, nil
+	})
+	syncer.SetRules(set, nil, func(r rules.Result) { reported = append(reported, r) })
+
+	// Rules leave the mail of a first sync alone
+	if err := syncer.FullSync(mail.INBOX); err != nil {
+		t.Fatalf("FullSync: %v", err)
+	}
+	if len(reported) != 0 {
+		t.Fatalf("rules ran on first sync: %+v", reported)
+	}
+
+	store.Add(mail.INBOX, mail.Email{From: "news@shop.com", Subject: "new news", Unread: true})
+	store.Add(mail.INBOX, mail.Email{From: "friend@example.com", Subject: "hi", Unread: true})
+	if err := syncer.FullSync(mail.INBOX); err != nil {
+		t.Fatalf("second FullSync: %v", err)
+	}
+	if len(reported) != 1 || reported[0].Message.Subject != "new news" || reported[0].Err != nil {
+		t.Fatalf("reported = %+v", reported)
+	}
+	cached := cachedSubjects(t, c)
+	if _, ok := cached["new news"]; ok || len(cached) != 2 {
+		t.Errorf("cached %v, want the moved message gone", cached)
+	}
+	if moved := store.Messages("News"); len(moved) != 1 || moved[0].Unread {
+		t.Errorf("News = %+v, want new news, read", moved)
+	}
+}
@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"maily/internal/cache"
+)
+
+// TriageDays limits triage to mail received in the last days, so opting in
+// does not classify a whole cached mailbox at once
+const TriageDays = 3
+
+// Classifier assigns a triage to an email, e.g. with an AI prompt
+type Classifier func(email cache.CachedEmail) (*cache.Triage, error)
+
+// Triage classifies up to limit recent cached emails of mailbox that have
+// no triage yet, newest first, and saves the results so each message is
+// classified once. Accounts that have not opted in and senders their allow
+// and deny lists exclude are skipped. It returns how many emails were
+// classified; emails that failed to classify are skipped and their errors
+// returned together.
+func (s *Syncer) Triage(mailbox string, classify Classifier, limit int) (int, error) {
+	account := s.account.Credentials.Email
+	if s.account.Triage == nil || !s.account.Triage.Enabled {
+		return 0, nil
+	}
+
+	emails, err := s.cache.LoadEmails(account, mailbox)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load emails: %w", err)
+	}
+
+	since := time.Now().AddDate(0, 0, -TriageDays)
+	classified, tried := 0, 0
+	var errs []error
+	for _, email := range emails {
+		if tried == limit {
+			break
+		}
+		if email.Triage != nil || email.InternalDate.Before(since) || !s.account.Triage.Allows(email.From) {
+			continue
+		}
+
+		// A message that fails is tried again next time; it must not hold
+		// up the ones after it
+		tried++
+		triage, err := classify(email)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to classify %q: %w", email.Subject, err))
+			continue
+		}
+		email.Triage = triage
+		if err := s.cache.SaveEmail(account, mailbox, email); err != nil {
+			return classified, fmt.Errorf("failed to save triage: %w", err)
+		}
+		classified++
+	}
+	return classified, errors.Join(errs...)
+}
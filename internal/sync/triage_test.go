@@ -0,0 +1,90 @@
+package sync
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+
+	"maily/internal/auth"
+	"maily/internal/cache"
+)
+
+func TestTriage(t *testing.T) {
+	c := cache.NewWithBaseDir(t.TempDir())
+	account := &auth.Account{Credentials: auth.Credentials{Email: testUser}}
+	now := time.Now()
+	for i, from := range []string{"Boss <boss@work.com>", "Bank <alerts@mybank.com>", "news@shop.com"} {
+		email := cache.CachedEmail{UID: imap.UID(i + 1), MessageID: from, From: from, InternalDate: now.Add(-time.Duration(i) * time.Minute)}
+		if err := c.SaveEmail(testUser, "INBOX", email); err != nil {
+			t.Fatalf("SaveEmail: %v", err)
+		}
+	}
+
+	calls := 0
+	classify := func(email cache.CachedEmail) (*cache.Triage, error) {
+		calls++
+		return &cache.Triage{Category: "action", Priority: 1}, nil
+	}
+
+	// Accounts must opt in
+	syncer := NewSyncer(c, account)
+	if n, err := syncer.Triage("INBOX", classify, 10); err != nil || n != 0 {
+		t.Fatalf("Triage without opt-in = %d, %v", n, err)
+	}
+
+	account.Triage = &auth.TriageConfig{Enabled: true, Deny: []string{"mybank.com"}}
+	if n, err := syncer.Triage("INBOX", classify, 10); err != nil || n != 2 {
+		t.Fatalf("Triage = %d, %v, want 2 (bank denied)", n, err)
+	}
+	if email, _ := c.GetEmail(testUser, "INBOX", 2); email.Triage != nil {
+		t.Errorf("denied sender was classified: %+v", email.Triage)
+	}
+
+	// A sync saving the message again keeps its triage, so it is not
+	// classified twice
+	if err := c.SaveEmail(testUser, "INBOX", cache.CachedEmail{UID: 1, MessageID: "Boss <boss@work.com>", From: "Boss <boss@work.com>", InternalDate: now}); err != nil {
+		t.Fatalf("SaveEmail: %v", err)
+	}
+	if n, err := syncer.Triage("INBOX", classify, 10); err != nil || n != 0 || calls != 2 {
+		t.Errorf("second Triage = %d, %v after %d calls, want nothing new", n, err, calls)
+	}
+	if email, _ := c.GetEmail(testUser, "INBOX", 1); email.Triage == nil || email.Triage.Category != "action" {
+		t.Errorf("triage lost on save: %+v", email.Triage)
+	}
+}
+
+func TestTriageSkipsFailures(t *testing.T) {
+	c := cache.NewWithBaseDir(t.TempDir())
+	account := &auth.Account{
+		Credentials: auth.Credentials{Email: testUser},
+		Triage:      &auth.TriageConfig{Enabled: true},
+	}
+	now := time.Now()
+	for i, subject := range []string{"newest", "garbled", "oldest"} {
+		email := cache.CachedEmail{UID: imap.UID(i + 1), MessageID: subject, Subject: subject, From: "a@b.com", InternalDate: now.Add(-time.Duration(i) * time.Minute)}
+		if err := c.SaveEmail(testUser, "INBOX", email); err != nil {
+			t.Fatalf("SaveEmail: %v", err)
+		}
+	}
+
+	// The model answers one message with something that is not a triage
+	classify := func(email cache.CachedEmail) (*cache.Triage, error) {
+		if email.Subject == "garbled" {
+			return nil, errors.New("invalid JSON")
+		}
+		return &cache.Triage{Category: "fyi", Priority: 3}, nil
+	}
+	n, err := NewSyncer(c, account).Triage("INBOX", classify, 10)
+	if n != 2 || err == nil || !strings.Contains(err.Error(), "garbled") {
+		t.Fatalf("Triage = %d, %v, want 2 and the error of the garbled one", n, err)
+	}
+	if email, _ := c.GetEmail(testUser, "INBOX", 3); email.Triage == nil {
+		t.Error("email after the failing one was not classified")
+	}
+	if email, _ := c.GetEmail(testUser, "INBOX", 2); email.Triage != nil {
+		t.Errorf("failing email has triage %+v", email.Triage)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"maily/internal/ai"
 	"maily/internal/auth"
 	"maily/internal/cache"
+	"maily/internal/calendar"
 	"maily/internal/mail"
 	"maily/internal/ui/components"
 )
@@ -24,6 +25,8 @@ const (
 	listView view = iota
 	readView
 	composeView
+	chatView
+	sieveView
 )
 
 type state int
@@ -37,8 +40,8 @@ const (
 type App struct {
 	store         *auth.AccountStore
 	accountIdx    int
-	imap          *mail.IMAPClient
-	imapCache     map[int]*mail.IMAPClient
+	imap          mail.Store
+	imapCache     map[int]mail.Store
 	emailCache    map[string][]mail.Email // key: "accountIdx:label"
 	diskCache     *cache.Cache             // persistent disk cache
 	mailList      components.MailList
@@ -67,6 +70,10 @@ type App struct {
 	searchQuery    string
 	inboxCache     []mail.Email
 
+	// Triage view of the list: category filter and priority sort
+	triageCategory   string
+	triageByPriority bool
+
 	// Multi-select (search mode only)
 	selected map[imap.UID]bool
 
@@ -85,6 +92,32 @@ type App struct {
 	showSummary   bool
 	summaryText   string
 	summarySource string // which AI provider was used
+
+	// AI-drafted reply: intent input and tone (index in ai.Tones)
+	draftClient *ai.Client
+	draftMode   bool
+	draftInput  textinput.Model
+	draftTone   int
+
+	// Events extracted from the open email, nil when the dialog is closed
+	extractClient *ai.Client
+	extract       *extractDialog
+
+	// Questions about the mailbox; the panel keeps its history while the
+	// app runs
+	chat       ChatModel
+	chatOpen   bool // chat has been created
+	chatReturn view // view to return to when the chat closes
+
+	// Server-side Sieve filters of the current account
+	sieve       SieveModel
+	sieveReturn view
+
+	// Meeting invitation in the open email
+	invitation *calendar.Invitation
+
+	// Following the daemon's events: the list refreshes when it syncs
+	daemonConnected bool
 }
 
 type emailsLoadedMsg struct {
@@ -97,7 +130,7 @@ type errorMsg struct {
 }
 
 type clientReadyMsg struct {
-	imap *mail.IMAPClient
+	imap mail.Store
 }
 
 type appSearchResultsMsg struct {
@@ -161,7 +194,7 @@ func NewApp(store *auth.AccountStore) App {
 	return App{
 		store:          store,
 		accountIdx:     0,
-		imapCache:      make(map[int]*mail.IMAPClient),
+		imapCache:      make(map[int]mail.Store),
 		emailCache:     make(map[string][]mail.Email),
 		diskCache:      diskCache,
 		mailList:       components.NewMailList(),
@@ -175,7 +208,10 @@ func NewApp(store *auth.AccountStore) App {
 		searchInput:    si,
 		selected:       make(map[imap.UID]bool),
 		commandPalette: components.NewCommandPalette(),
-		aiClient:       ai.NewClient(),
+		aiClient:       ai.NewTaskClient(ai.TaskSummarize),
+		draftClient:    ai.NewTaskClient(ai.TaskDraft),
+		draftInput:     newDraftInput(),
+		extractClient:  ai.NewTaskClient(ai.TaskCalendar),
 	}
 }
 
@@ -192,6 +228,7 @@ func (a App) Init() tea.Cmd {
 		a.loadCachedEmails(),
 		a.initClient(),
 		scheduleAutoRefresh(),
+		subscribeDaemon(),
 	)
 }
 
@@ -220,6 +257,30 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return a, cmd
 		}
 
+		// Handle chat panel input
+		if a.view == chatView {
+			var cmd tea.Cmd
+			a.chat, cmd = a.chat.Update(msg)
+			return a, cmd
+		}
+
+		// Handle Sieve editor input
+		if a.view == sieveView {
+			var cmd tea.Cmd
+			a.sieve, cmd = a.sieve.Update(msg)
+			return a, cmd
+		}
+
+		// Handle extracted events dialog input
+		if a.extract != nil {
+			return a, a.updateExtractDialog(msg)
+		}
+
+		// Handle draft reply dialog input
+		if a.draftMode {
+			return a, a.updateDraftDialog(msg)
+		}
+
 		// Handle search mode input
 		if a.searchMode {
 			switch msg.String() {
@@ -373,6 +434,7 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if a.view == listView && a.state == stateReady {
 				if email := a.mailList.SelectedEmail(); email != nil {
 					a.view = readView
+					a.invitation = parseInvitation(*email)
 					a.viewport.SetContent(a.renderEmailContent(*email))
 					a.viewport.GotoTop()
 
@@ -422,11 +484,11 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 		case "R":
-			// Shift+R for refresh from IMAP server
+			// Shift+R to sync now, through the daemon if it runs
 			if a.state == stateReady && !a.isSearchResult && a.view == listView {
 				a.state = stateLoading
 				a.statusMsg = "Refreshing..."
-				return a, tea.Batch(a.spinner.Tick, a.loadEmails())
+				return a, tea.Batch(a.spinner.Tick, a.refresh())
 			}
 		case "s":
 			// Context-aware: search in list view, summarize in read view
@@ -450,11 +512,68 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 				}
 			}
+		case "S":
+			// Summarize the whole thread with AI (read view only)
+			if a.state == stateReady && a.view == readView && !a.confirmDelete && !a.showSummary {
+				if !a.aiClient.Available() {
+					a.statusMsg = "No AI CLI found (install claude, codex, gemini, vibe, or ollama)"
+					return a, nil
+				}
+				if email := a.mailList.SelectedEmail(); email != nil {
+					a.state = stateLoading
+					a.statusMsg = "Summarizing thread with " + a.aiClient.Provider() + "..."
+					return a, tea.Batch(a.spinner.Tick, a.summarizeThread(email))
+				}
+			}
+		case "T":
+			// Cycle the triage category filter (list view)
+			if a.state == stateReady && !a.confirmDelete && a.view == listView {
+				a.triageCategory = nextTriageCategory(a.triageCategory)
+				a.mailList.SetTriageView(a.triageCategory, a.triageByPriority)
+				a.statusMsg = a.triageStatus()
+				return a, nil
+			}
+		case "P":
+			// Toggle sorting by triage priority (list view)
+			if a.state == stateReady && !a.confirmDelete && a.view == listView {
+				a.triageByPriority = !a.triageByPriority
+				a.mailList.SetTriageView(a.triageCategory, a.triageByPriority)
+				a.statusMsg = a.triageStatus()
+				return a, nil
+			}
+		case "?":
+			// Ask questions about the mailbox (AI)
+			if a.state == stateReady && !a.confirmDelete && (a.view == listView || a.view == readView) {
+				return a, a.openChat()
+			}
+		case "A":
+			// Draft a reply with AI (read view only)
+			if a.state == stateReady && a.view == readView && !a.confirmDelete && !a.showSummary {
+				return a, a.openDraftDialog()
+			}
+		case "y", "t", "n":
+			// Answer a meeting invitation (read view only)
+			if a.state == stateReady && a.view == readView && !a.confirmDelete && a.invitation != nil && a.invitation.Method == calendar.MethodRequest {
+				status := map[string]string{
+					"y": calendar.StatusAccepted,
+					"t": calendar.StatusTentative,
+					"n": calendar.StatusDeclined,
+				}[msg.String()]
+				a.state = stateLoading
+				a.statusMsg = "Sending reply to " + a.invitation.Event.Organizer.Email + "..."
+				return a, tea.Batch(a.spinner.Tick, a.respondToInvitation(a.invitation, status))
+			}
+		case "x":
+			// Remove a cancelled meeting from the calendar (read view only)
+			if a.state == stateReady && a.view == readView && !a.confirmDelete && a.invitation != nil && a.invitation.Method == calendar.MethodCancel {
+				a.state = stateLoading
+				a.statusMsg = "Removing from calendar..."
+				return a, tea.Batch(a.spinner.Tick, a.removeCancelledEvent(a.invitation))
+			}
 		case "e":
 			// Extract event to calendar (read view only)
-			if a.state == stateReady && a.view == readView && !a.confirmDelete {
-				a.statusMsg = "Extract: AI not configured yet"
-				return a, nil
+			if a.state == stateReady && a.view == readView && !a.confirmDelete && !a.showSummary {
+				return a, a.openExtract()
 			}
 		case "d":
 			if a.state == stateReady && !a.confirmDelete {
@@ -609,6 +728,12 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.labelPicker.SetSize(msg.Width, msg.Height)
 		a.viewport.Width = msg.Width - 8
 		a.viewport.Height = msg.Height - 8
+		if a.chatOpen {
+			a.chat.SetSize(msg.Width, msg.Height)
+		}
+		if a.view == sieveView {
+			a.sieve.SetSize(msg.Width, msg.Height)
+		}
 		// Update compose model size
 		if a.view == composeView {
 			a.compose.width = msg.Width
@@ -649,11 +774,15 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		labelName := components.GetLabelDisplayName(a.currentLabel)
 		a.statusMsg = fmt.Sprintf("%s: %d emails", labelName, len(msg.emails))
 
+	case daemonSubscribedMsg, daemonEventMsg, daemonGoneMsg, daemonRetryMsg, daemonSyncDoneMsg:
+		return a.handleDaemonMsg(msg)
+
 	case autoRefreshTickMsg:
 		// Schedule next tick
 		cmds = append(cmds, scheduleAutoRefresh())
-		// Only refresh if in list view, ready state, and not in any dialog
-		if a.view == listView && a.state == stateReady && !a.confirmDelete && !a.searchMode && !a.showLabelPicker && !a.showCommandPalette && !a.isSearchResult {
+		// Only refresh if in list view, ready state, and not in any dialog.
+		// While the daemon runs, the list refreshes whenever it syncs.
+		if !a.daemonConnected && a.canAutoRefresh() {
 			a.state = stateLoading
 			a.statusMsg = "Auto-refreshing..."
 			cmds = append(cmds, a.spinner.Tick, a.loadEmails())
@@ -752,6 +881,17 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		a.statusMsg = "Cancelled"
 
+	case ProposeTimesMsg:
+		a.statusMsg = "Looking for free times..."
+		return a, a.proposeTimes()
+
+	case proposedTimesMsg:
+		a.statusMsg = fmt.Sprintf("Inserted %d free times", msg.count)
+		return a, a.compose.InsertText(msg.text)
+
+	case proposeTimesErrorMsg:
+		a.statusMsg = fmt.Sprintf("Could not find free times: %v", msg.err)
+
 	case summaryResultMsg:
 		a.state = stateReady
 		a.showSummary = true
@@ -762,6 +902,95 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case summaryErrorMsg:
 		a.state = stateReady
 		a.statusMsg = fmt.Sprintf("Summarize failed: %v", msg.err)
+
+	case draftReplyMsg:
+		a.state = stateReady
+		account := a.currentAccount()
+		if account == nil {
+			return a, nil
+		}
+		// Open the draft for editing; sending stays with the user
+		a.compose = NewReplyModel(account.Credentials.Email, msg.email)
+		a.compose.width = a.width
+		a.compose.height = a.height
+		a.view = composeView
+		a.statusMsg = "Drafted by " + msg.provider + " - review before sending"
+		return a, tea.Batch(a.compose.Init(), a.compose.InsertText(msg.body))
+
+	case chatAnswerMsg:
+		var cmd tea.Cmd
+		a.chat, cmd = a.chat.Update(msg)
+		return a, cmd
+
+	case CloseChatMsg:
+		a.view = a.chatReturn
+
+	case sieveResultMsg:
+		var cmd tea.Cmd
+		a.sieve, cmd = a.sieve.Update(msg)
+		return a, cmd
+
+	case CloseSieveMsg:
+		a.view = a.sieveReturn
+
+	case OpenCitationMsg:
+		// Open the cited email in the read view if it is in the current
+		// list, else show it in the chat panel
+		source := msg.source
+		account := a.currentAccount()
+		if account != nil && account.Credentials.Email == source.account && a.currentLabel == source.mailbox && !a.isSearchResult && a.mailList.SelectByUID(source.email.UID) {
+			email := a.mailList.SelectedEmail()
+			a.view = readView
+			a.invitation = parseInvitation(*email)
+			a.viewport.SetContent(a.renderEmailContent(*email))
+			a.viewport.GotoTop()
+			return a, nil
+		}
+		a.chat.ShowPreview(source)
+
+	case draftReplyErrorMsg:
+		a.state = stateReady
+		a.statusMsg = fmt.Sprintf("Draft failed: %v", msg.err)
+
+	case eventsExtractedMsg:
+		a.state = stateReady
+		a.statusMsg = ""
+		if len(msg.events) == 0 {
+			a.statusMsg = "No events found in this email"
+			return a, nil
+		}
+		a.extract = &extractDialog{events: msg.events, provider: msg.provider}
+
+	case extractErrorMsg:
+		a.state = stateReady
+		a.statusMsg = fmt.Sprintf("Add to calendar failed: %v", msg.err)
+
+	case extractedEventsCreatedMsg:
+		a.state = stateReady
+		a.statusMsg = fmt.Sprintf("Added %d events to your calendar", msg.created)
+		if msg.created == 1 {
+			a.statusMsg = "Added the event to your calendar"
+		}
+		if msg.invited {
+			a.statusMsg += " and invited the attendees"
+		}
+
+	case invitationRespondedMsg:
+		a.state = stateReady
+		switch msg.status {
+		case calendar.StatusAccepted:
+			a.statusMsg = fmt.Sprintf("Accepted %q and added it to your calendar", msg.title)
+		case calendar.StatusTentative:
+			a.statusMsg = fmt.Sprintf("Tentatively accepted %q and added it to your calendar", msg.title)
+		case calendar.StatusDeclined:
+			a.statusMsg = fmt.Sprintf("Declined %q", msg.title)
+		case calendar.MethodCancel:
+			a.statusMsg = fmt.Sprintf("Removed %q from your calendar", msg.title)
+		}
+
+	case invitationErrorMsg:
+		a.state = stateReady
+		a.statusMsg = fmt.Sprintf("Invitation failed: %v", msg.err)
 	}
 
 	if a.view == listView && a.state == stateReady {
@@ -806,6 +1035,10 @@ func (a App) View() string {
 				}
 				content = components.RenderReadView(emailData, a.width, a.viewport.View())
 			}
+		case chatView:
+			content = a.chat.View()
+		case sieveView:
+			content = a.sieve.View()
 		case composeView:
 			content = lipgloss.Place(
 				a.width,
@@ -843,6 +1076,16 @@ func (a App) View() string {
 		content = components.RenderCentered(a.width, a.height, a.commandPalette.View())
 	}
 
+	// Show draft reply dialog overlay
+	if a.draftMode {
+		content = a.renderDraftDialog()
+	}
+
+	// Show extracted events overlay
+	if a.extract != nil {
+		content = a.renderExtractDialog()
+	}
+
 	// Show summary dialog overlay
 	if a.showSummary {
 		content = components.RenderSummaryDialog(a.width, a.height, a.summaryText, a.summarySource)
@@ -862,6 +1105,11 @@ func (a App) View() string {
 		CurrentLabel:   a.currentLabel,
 	}
 
+	invitation := ""
+	if a.view == readView && a.invitation != nil {
+		invitation = a.invitation.Method
+	}
+
 	// Build status bar data
 	statusData := components.StatusBarData{
 		Width:          a.width,
@@ -870,6 +1118,7 @@ func (a App) View() string {
 		IsSearchResult: a.isSearchResult,
 		IsListView:     a.view == listView,
 		IsComposeView:  a.view == composeView,
+		Invitation:     invitation,
 		AccountCount:   len(a.store.Accounts),
 		SelectionCount: a.selectedCount(),
 	}
@@ -899,6 +1148,15 @@ func (a App) renderEmailContent(email mail.Email) string {
 		PaddingLeft(4).
 		PaddingRight(4)
 
+	if inv := parseInvitation(email); inv != nil {
+		self := ""
+		if account := a.currentAccount(); account != nil {
+			self = account.Credentials.Email
+		}
+		card := renderInvitation(inv, self, wrapWidth-2)
+		return contentStyle.Render(card + "\n\n" + body)
+	}
+
 	return contentStyle.Render(body)
 }
 
@@ -7,6 +7,7 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"maily/internal/ai"
+	"maily/internal/auth"
 	"maily/internal/calendar"
 	"maily/internal/ui/components"
 )
@@ -36,6 +37,9 @@ const (
 // CalendarApp is the main calendar TUI model
 type CalendarApp struct {
 	client       calendar.Client
+	store        *auth.AccountStore // accounts that email invitations, may be nil
+	organizer    *auth.Account      // account new meetings are sent from, may be nil
+	secondary    *time.Location     // zone shown next to local times, may be nil
 	width        int
 	height       int
 	selectedDate time.Time
@@ -53,36 +57,39 @@ type CalendarApp struct {
 	// NLP quick-add fields
 	nlpInput       textinput.Model
 	nlpParsed      *ai.ParsedEvent
+	nlpProvider    string // AI provider and model that parse the input
 	nlpCalendarIdx int
 	nlpReminderIdx int
 	nlpStartTime   time.Time
 	nlpEndTime     time.Time
 
 	// Interactive form fields (fallback when no AI CLI)
-	formTitleInput    textinput.Model
-	formDateInput     components.DatePicker
-	formStartInput    components.TimePicker
-	formEndInput      components.TimePicker
-	formLocationInput textinput.Model
-	formCalendarIdx   int
-	formReminderIdx   int
-	formFocusField    int // 0=date, 1=start, 2=end, 3=location in datetime view
+	formTitleInput     textinput.Model
+	formDateInput      components.DatePicker
+	formStartInput     components.TimePicker
+	formEndInput       components.TimePicker
+	formLocationInput  textinput.Model
+	formAttendeesInput textinput.Model
+	formCalendarIdx    int
+	formReminderIdx    int
+	formFocusField     int // 0=date, 1=start, 2=end, 3=location, 4=attendees in datetime view
 
 	// Delete confirmation
-	deleteButtonIdx int // 0=Delete, 1=Cancel
+	deleteButtonIdx int // 0=Delete, 1=Cancel; recurring: 0=This event, 1=All future, 2=Cancel
 
 	// Event detail view
 	detailButtonIdx int // 0=Edit, 1=Delete, 2=Close
 }
 
 type eventForm struct {
-	title    textinput.Model
-	date     components.DatePicker
-	start    components.TimePicker
-	end      components.TimePicker
-	location textinput.Model
-	calendar int // index into calendars slice
-	editID   string
+	title     textinput.Model
+	date      components.DatePicker
+	start     components.TimePicker
+	end       components.TimePicker
+	location  textinput.Model
+	attendees textinput.Model // comma-separated addresses
+	calendar  int             // index into calendars slice
+	editing   *calendar.Event // event being edited, nil for a new event
 }
 
 // Messages
@@ -110,10 +117,14 @@ type nlpParsedMsg struct {
 	endTime   time.Time
 }
 
-// NewCalendarApp creates a new calendar TUI
-func NewCalendarApp(client calendar.Client) *CalendarApp {
+// NewCalendarApp creates a new calendar TUI. Invitations to attendees are
+// sent from the accounts in store; organizer organizes new meetings.
+func NewCalendarApp(client calendar.Client, store *auth.AccountStore, organizer *auth.Account) *CalendarApp {
 	return &CalendarApp{
 		client:       client,
+		store:        store,
+		organizer:    organizer,
+		secondary:    loadSecondaryZone(),
 		selectedDate: time.Now(),
 		view:         viewCalendar,
 	}
@@ -300,7 +311,7 @@ func (m *CalendarApp) handleCalendarKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, m.loadEvents()
 	case "n":
 		// Check if AI CLI is available
-		aiClient := ai.NewClient()
+		aiClient := ai.NewTaskClient(ai.TaskCalendar)
 		if aiClient.Available() {
 			// NLP quick-add (AI-powered)
 			m.initNLPInput()
@@ -357,26 +368,26 @@ func (m *CalendarApp) handleFormInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case "tab":
-		m.formFocusIdx = (m.formFocusIdx + 1) % 8
+		m.formFocusIdx = (m.formFocusIdx + 1) % 9
 		m.updateFormFocus()
 		return m, nil
 
 	case "shift+tab":
-		m.formFocusIdx = (m.formFocusIdx + 7) % 8
+		m.formFocusIdx = (m.formFocusIdx + 8) % 9
 		m.updateFormFocus()
 		return m, nil
 
 	case "enter":
-		if m.formFocusIdx == 5 { // Calendar selector
+		if m.formFocusIdx == 6 { // Calendar selector
 			if len(m.calendars) > 0 {
 				m.form.calendar = (m.form.calendar + 1) % len(m.calendars)
 			}
 			return m, nil
 		}
-		if m.formFocusIdx == 6 { // Save button
+		if m.formFocusIdx == 7 { // Save button
 			return m, m.saveEvent()
 		}
-		if m.formFocusIdx == 7 { // Cancel button
+		if m.formFocusIdx == 8 { // Cancel button
 			m.view = viewCalendar
 			return m, nil
 		}
@@ -389,19 +400,19 @@ func (m *CalendarApp) handleFormInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, m.saveEvent()
 
 	case "left":
-		if m.formFocusIdx == 5 && len(m.calendars) > 0 {
+		if m.formFocusIdx == 6 && len(m.calendars) > 0 {
 			m.form.calendar = (m.form.calendar + len(m.calendars) - 1) % len(m.calendars)
 			return m, nil
 		}
 
 	case "right":
-		if m.formFocusIdx == 5 && len(m.calendars) > 0 {
+		if m.formFocusIdx == 6 && len(m.calendars) > 0 {
 			m.form.calendar = (m.form.calendar + 1) % len(m.calendars)
 			return m, nil
 		}
 	}
 
-	// Pass keystrokes to the focused text input (title, date, location only)
+	// Pass keystrokes to the focused text input (title, date, location, attendees only)
 	var cmd tea.Cmd
 	switch m.formFocusIdx {
 	case 0:
@@ -410,30 +421,45 @@ func (m *CalendarApp) handleFormInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.form.date, cmd = m.form.date.Update(msg)
 	case 4:
 		m.form.location, cmd = m.form.location.Update(msg)
+	case 5:
+		m.form.attendees, cmd = m.form.attendees.Update(msg)
 	}
 
 	return m, cmd
 }
 
 func (m *CalendarApp) handleDeleteKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	dayEvents := m.eventsForDate(m.selectedDate)
+	if m.selectedIdx >= len(dayEvents) {
+		m.view = viewCalendar
+		return m, nil
+	}
+	event := dayEvents[m.selectedIdx]
+
+	// Recurring events offer "This event" and "All future" before Cancel
+	cancelIdx := 1
+	if event.IsRecurring() {
+		cancelIdx = 2
+	}
+
 	switch msg.String() {
 	case "left", "h":
 		if m.deleteButtonIdx > 0 {
 			m.deleteButtonIdx--
 		}
 	case "right", "l", "tab":
-		if m.deleteButtonIdx < 1 {
+		if m.deleteButtonIdx < cancelIdx {
 			m.deleteButtonIdx++
 		}
 	case "enter":
-		if m.deleteButtonIdx == 0 {
-			// Delete
-			dayEvents := m.eventsForDate(m.selectedDate)
-			if m.selectedIdx < len(dayEvents) {
-				return m, m.deleteEvent(dayEvents[m.selectedIdx].ID)
-			}
+		switch {
+		case m.deleteButtonIdx == cancelIdx:
+		case m.deleteButtonIdx == 1:
+			return m, m.deleteEvent(event, calendar.SpanFutureEvents)
+		default:
+			return m, m.deleteEvent(event, calendar.SpanThisEvent)
 		}
-		// Cancel (or if delete index out of range)
+		// Cancel
 		m.view = viewCalendar
 		return m, nil
 	case "esc", "q":
@@ -502,8 +528,9 @@ func (m *CalendarApp) initEditForm(event calendar.Event) {
 		date:     components.NewDatePicker(),
 		start:    components.NewTimePicker(),
 		end:      components.NewTimePicker(),
-		location: textinput.New(),
-		editID:   event.ID,
+		location:  textinput.New(),
+		attendees: newAttendeesInput(),
+		editing:   &event,
 	}
 
 	m.form.title.SetValue(event.Title)
@@ -513,6 +540,7 @@ func (m *CalendarApp) initEditForm(event calendar.Event) {
 	m.form.start.SetTime24(event.StartTime.Format("15:04"))
 	m.form.end.SetTime24(event.EndTime.Format("15:04"))
 	m.form.location.SetValue(event.Location)
+	m.form.attendees.SetValue(formatAttendees(event.Attendees))
 
 	// Find calendar index
 	for i, cal := range m.calendars {
@@ -531,6 +559,7 @@ func (m *CalendarApp) updateFormFocus() {
 	m.form.start.Blur()
 	m.form.end.Blur()
 	m.form.location.Blur()
+	m.form.attendees.Blur()
 
 	switch m.formFocusIdx {
 	case 0:
@@ -543,6 +572,8 @@ func (m *CalendarApp) updateFormFocus() {
 		m.form.end.Focus()
 	case 4:
 		m.form.location.Focus()
+	case 5:
+		m.form.attendees.Focus()
 	}
 }
 
@@ -616,8 +647,9 @@ func (m *CalendarApp) handleNLPSelectKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m *CalendarApp) parseNLPInput() tea.Cmd {
+	aiClient := ai.NewTaskClient(ai.TaskCalendar)
+	m.nlpProvider = aiClient.Provider()
 	return func() tea.Msg {
-		aiClient := ai.NewClient()
 		if !aiClient.Available() {
 			return errMsg{fmt.Errorf("no AI CLI found (install claude, codex, gemini, or ollama)")}
 		}
@@ -643,6 +675,16 @@ func (m *CalendarApp) parseNLPInput() tea.Cmd {
 			return errMsg{fmt.Errorf("invalid end time: %v", err)}
 		}
 
+		parsed.Recurrence, err = calendar.ParseRecurrence(parsed.Recurrence)
+		if err != nil {
+			return errMsg{err}
+		}
+
+		parsed.TimeZone, startTime, endTime, err = calendar.ParsedTimeZone(m.nlpInput.Value(), parsed.TimeZone, startTime, endTime)
+		if err != nil {
+			return errMsg{err}
+		}
+
 		return nlpParsedMsg{
 			parsed:    parsed,
 			startTime: startTime,
@@ -670,9 +712,11 @@ func (m *CalendarApp) createNLPEvent() tea.Cmd {
 			Title:              m.nlpParsed.Title,
 			StartTime:          m.nlpStartTime,
 			EndTime:            m.nlpEndTime,
+			TimeZone:           m.nlpParsed.TimeZone,
 			Location:           m.nlpParsed.Location,
 			Calendar:           calendarID,
 			AlarmMinutesBefore: m.getNLPReminderMinutes(),
+			Recurrence:         m.nlpParsed.Recurrence,
 		}
 
 		id, err := m.client.CreateEvent(event)
@@ -713,34 +757,96 @@ func (m *CalendarApp) saveEvent() tea.Cmd {
 			calendarID = m.calendars[m.form.calendar].ID
 		}
 
+		var existing []calendar.Attendee
+		if m.form.editing != nil {
+			existing = m.form.editing.Attendees
+		}
+		attendees, err := parseAttendees(m.form.attendees.Value(), existing)
+		if err != nil {
+			return errMsg{err}
+		}
+
+		if original := m.form.editing; original != nil {
+			event := *original
+			event.Title = m.form.title.Value()
+			event.StartTime = start
+			event.EndTime = end
+			event.Location = m.form.location.Value()
+			event.Attendees = attendees
+			prepareMeeting(m.organizer, &event)
+
+			// Moving to another calendar is the only edit that needs a new event
+			id := event.ID
+			if m.form.calendar >= len(m.calendars) || m.calendars[m.form.calendar].Title == original.Calendar {
+				if err := m.client.UpdateEvent(event); err != nil {
+					return errMsg{err}
+				}
+			} else {
+				event.Calendar = calendarID
+				event.OccurrenceDate = time.Time{}
+				if id, err = m.client.CreateEvent(event); err != nil {
+					return errMsg{err}
+				}
+				// A recurring event moves from this occurrence on
+				if original.IsRecurring() {
+					_ = m.client.DeleteOccurrence(original.ID, original.OccurrenceDate, calendar.SpanFutureEvents)
+				} else {
+					_ = m.client.DeleteEvent(original.ID)
+				}
+			}
+
+			// Attendees hear about the change, and removed ones that they
+			// are no longer invited
+			event.Sequence++
+			if err := notifyAttendees(m.store, m.client, calendar.MethodRequest, event, calendar.SpanThisEvent); err != nil {
+				return errMsg{fmt.Errorf("event saved, but sending invitations failed: %w", err)}
+			}
+			removed := event
+			removed.Attendees = removedAttendees(original.Attendees, event.Attendees)
+			if err := notifyAttendees(m.store, m.client, calendar.MethodCancel, removed, calendar.SpanThisEvent); err != nil {
+				return errMsg{fmt.Errorf("event saved, but sending cancellations failed: %w", err)}
+			}
+			return eventCreatedMsg{id}
+		}
+
 		event := calendar.Event{
 			Title:     m.form.title.Value(),
 			StartTime: start,
 			EndTime:   end,
 			Location:  m.form.location.Value(),
 			Calendar:  calendarID,
+			Attendees: attendees,
 		}
-
-		// If editing, delete old event first (EventKit doesn't have update)
-		if m.form.editID != "" {
-			_ = m.client.DeleteEvent(m.form.editID)
-		}
+		prepareMeeting(m.organizer, &event)
 
 		id, err := m.client.CreateEvent(event)
 		if err != nil {
 			return errMsg{err}
 		}
+		if err := notifyAttendees(m.store, m.client, calendar.MethodRequest, event, calendar.SpanThisEvent); err != nil {
+			return errMsg{fmt.Errorf("event saved, but sending invitations failed: %w", err)}
+		}
 
 		return eventCreatedMsg{id}
 	}
 }
 
-func (m *CalendarApp) deleteEvent(id string) tea.Cmd {
+// deleteEvent deletes the event, or for a recurring event the occurrences
+// selected by span
+func (m *CalendarApp) deleteEvent(event calendar.Event, span calendar.Span) tea.Cmd {
 	return func() tea.Msg {
-		err := m.client.DeleteEvent(id)
+		var err error
+		if event.IsRecurring() {
+			err = m.client.DeleteOccurrence(event.ID, event.OccurrenceDate, span)
+		} else {
+			err = m.client.DeleteEvent(event.ID)
+		}
 		if err != nil {
 			return errMsg{err}
 		}
+		if err := notifyAttendees(m.store, m.client, calendar.MethodCancel, event, span); err != nil {
+			return errMsg{fmt.Errorf("event deleted, but sending cancellations failed: %w", err)}
+		}
 		return eventDeletedMsg{}
 	}
 }
@@ -819,6 +925,8 @@ func (m *CalendarApp) initInteractiveForm() {
 	m.formLocationInput.CharLimit = 100
 	m.formLocationInput.Width = 40
 
+	m.formAttendeesInput = newAttendeesInput()
+
 	m.formCalendarIdx = 0
 	m.formReminderIdx = 0
 	m.formFocusField = 0
@@ -838,6 +946,7 @@ func (m *CalendarApp) handleFormTitleKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.formStartInput.Blur()
 			m.formEndInput.Blur()
 			m.formLocationInput.Blur()
+			m.formAttendeesInput.Blur()
 		}
 		return m, nil
 	}
@@ -871,11 +980,11 @@ func (m *CalendarApp) handleFormDateTimeKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd
 		m.view = viewCalendar
 		return m, nil
 	case "tab":
-		m.formFocusField = (m.formFocusField + 1) % 4
+		m.formFocusField = (m.formFocusField + 1) % 5
 		m.updateFormDateTimeFocus()
 		return m, nil
 	case "shift+tab":
-		m.formFocusField = (m.formFocusField + 3) % 4
+		m.formFocusField = (m.formFocusField + 4) % 5
 		m.updateFormDateTimeFocus()
 		return m, nil
 	case "enter":
@@ -886,10 +995,13 @@ func (m *CalendarApp) handleFormDateTimeKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd
 		return m, nil
 	}
 
-	// Pass keystrokes to location input only
+	// Pass keystrokes to location and attendees inputs only
 	var cmd tea.Cmd
-	if m.formFocusField == 3 {
+	switch m.formFocusField {
+	case 3:
 		m.formLocationInput, cmd = m.formLocationInput.Update(msg)
+	case 4:
+		m.formAttendeesInput, cmd = m.formAttendeesInput.Update(msg)
 	}
 	return m, cmd
 }
@@ -899,6 +1011,7 @@ func (m *CalendarApp) updateFormDateTimeFocus() {
 	m.formStartInput.Blur()
 	m.formEndInput.Blur()
 	m.formLocationInput.Blur()
+	m.formAttendeesInput.Blur()
 
 	switch m.formFocusField {
 	case 0:
@@ -909,6 +1022,8 @@ func (m *CalendarApp) updateFormDateTimeFocus() {
 		m.formEndInput.Focus()
 	case 3:
 		m.formLocationInput.Focus()
+	case 4:
+		m.formAttendeesInput.Focus()
 	}
 }
 
@@ -922,6 +1037,10 @@ func (m *CalendarApp) validateFormDateTime() bool {
 		m.err = fmt.Errorf("end time must be after start time")
 		return false
 	}
+	if _, err := parseAttendees(m.formAttendeesInput.Value(), nil); err != nil {
+		m.err = err
+		return false
+	}
 
 	m.err = nil
 	return true
@@ -996,6 +1115,11 @@ func (m *CalendarApp) createFormEvent() tea.Cmd {
 			calendarID = m.calendars[m.formCalendarIdx].ID
 		}
 
+		attendees, err := parseAttendees(m.formAttendeesInput.Value(), nil)
+		if err != nil {
+			return errMsg{err}
+		}
+
 		event := calendar.Event{
 			Title:              m.formTitleInput.Value(),
 			StartTime:          m.getFormStartTime(),
@@ -1003,15 +1127,26 @@ func (m *CalendarApp) createFormEvent() tea.Cmd {
 			Location:           m.formLocationInput.Value(),
 			Calendar:           calendarID,
 			AlarmMinutesBefore: m.getFormReminderMinutes(),
+			Attendees:          attendees,
 		}
+		prepareMeeting(m.organizer, &event)
 
 		id, err := m.client.CreateEvent(event)
 		if err != nil {
 			return errMsg{err}
 		}
+		if err := notifyAttendees(m.store, m.client, calendar.MethodRequest, event, calendar.SpanThisEvent); err != nil {
+			return errMsg{fmt.Errorf("event saved, but sending invitations failed: %w", err)}
+		}
 
 		return eventCreatedMsg{id}
 	}
 }
 
-
+func newAttendeesInput() textinput.Model {
+	input := textinput.New()
+	input.Placeholder = "ann@example.com, bob@example.com"
+	input.CharLimit = 500
+	input.Width = 40
+	return input
+}
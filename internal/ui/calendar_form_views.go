@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"maily/internal/calendar"
 	"maily/internal/ui/components"
 )
 
@@ -80,8 +81,17 @@ func (m *CalendarApp) renderForm(title string) string {
 	content.WriteString(m.form.location.View())
 	content.WriteString("\n")
 
-	// Calendar selector
+	// Attendees
 	if m.formFocusIdx == 5 {
+		content.WriteString(focusedLabelStyle.Render("Attendees"))
+	} else {
+		content.WriteString(labelStyle.Render("Attendees"))
+	}
+	content.WriteString(m.form.attendees.View())
+	content.WriteString("\n")
+
+	// Calendar selector
+	if m.formFocusIdx == 6 {
 		content.WriteString(focusedLabelStyle.Render("Calendar"))
 	} else {
 		content.WriteString(labelStyle.Render("Calendar"))
@@ -92,7 +102,7 @@ func (m *CalendarApp) renderForm(title string) string {
 		calName = m.calendars[m.form.calendar].Title
 	}
 	calStyle := lipgloss.NewStyle()
-	if m.formFocusIdx == 5 {
+	if m.formFocusIdx == 6 {
 		calStyle = calStyle.Background(components.Primary).Foreground(components.Text)
 	}
 	content.WriteString(calStyle.Render(fmt.Sprintf("◀ %s ▶", calName)))
@@ -126,12 +136,12 @@ func (m *CalendarApp) renderForm(title string) string {
 		Foreground(components.Muted)
 
 	var saveBtn, cancelBtn string
-	if m.formFocusIdx == 6 {
+	if m.formFocusIdx == 7 {
 		saveBtn = selectedBtn.BorderForeground(components.Primary).Background(components.Primary).Foreground(lipgloss.Color("#FFFFFF")).Render("Save")
 	} else {
 		saveBtn = unselectedBtn.Render("Save")
 	}
-	if m.formFocusIdx == 7 {
+	if m.formFocusIdx == 8 {
 		cancelBtn = selectedBtn.BorderForeground(components.Muted).Background(components.Muted).Foreground(lipgloss.Color("#FFFFFF")).Render("Cancel")
 	} else {
 		cancelBtn = unselectedBtn.Render("Cancel")
@@ -153,9 +163,9 @@ func (m *CalendarApp) renderDeleteConfirm() string {
 	var b strings.Builder
 
 	dayEvents := m.eventsForDate(m.selectedDate)
-	var eventTitle string
+	var event calendar.Event
 	if m.selectedIdx < len(dayEvents) {
-		eventTitle = dayEvents[m.selectedIdx].Title
+		event = dayEvents[m.selectedIdx]
 	}
 
 	titleStyle := lipgloss.NewStyle().
@@ -165,7 +175,14 @@ func (m *CalendarApp) renderDeleteConfirm() string {
 	b.WriteString(titleStyle.Render("Delete Event?"))
 	b.WriteString("\n\n")
 
-	b.WriteString(fmt.Sprintf("Are you sure you want to delete \"%s\"?\n\n", eventTitle))
+	labels := []string{"Delete", "Cancel"}
+	if event.IsRecurring() {
+		b.WriteString(fmt.Sprintf("\"%s\" repeats (%s).\nDelete only this occurrence, or this and all future ones?\n\n",
+			event.Title, strings.ToLower(calendar.DescribeRecurrence(event.Recurrence))))
+		labels = []string{"This event", "All future", "Cancel"}
+	} else {
+		b.WriteString(fmt.Sprintf("Are you sure you want to delete \"%s\"?\n\n", event.Title))
+	}
 
 	// Button styles
 	selectedBtn := lipgloss.NewStyle().
@@ -177,17 +194,20 @@ func (m *CalendarApp) renderDeleteConfirm() string {
 		Foreground(components.Muted).
 		Padding(0, 2)
 
-	// Render buttons
-	var deleteBtn, cancelBtn string
-	if m.deleteButtonIdx == 0 {
-		deleteBtn = selectedBtn.Render("Delete")
-		cancelBtn = unselectedBtn.Render("Cancel")
-	} else {
-		deleteBtn = unselectedBtn.Render("Delete")
-		cancelBtn = selectedBtn.Background(components.Muted).Foreground(lipgloss.Color("#FFFFFF")).Render("Cancel")
+	// Render buttons; the last one is Cancel
+	buttons := make([]string, len(labels))
+	for i, label := range labels {
+		switch {
+		case i != m.deleteButtonIdx:
+			buttons[i] = unselectedBtn.Render(label)
+		case i == len(labels)-1:
+			buttons[i] = selectedBtn.Background(components.Muted).Foreground(lipgloss.Color("#FFFFFF")).Render(label)
+		default:
+			buttons[i] = selectedBtn.Render(label)
+		}
 	}
 
-	b.WriteString(deleteBtn + "  " + cancelBtn)
+	b.WriteString(strings.Join(buttons, "  "))
 	b.WriteString("\n\n")
 
 	hintStyle := lipgloss.NewStyle().Foreground(components.Muted)
@@ -232,11 +252,28 @@ func (m *CalendarApp) renderEventDetail() string {
 		timeStr = "All day"
 	} else {
 		timeStr = fmt.Sprintf("%s - %s", event.StartTime.Format("3:04 PM"), event.EndTime.Format("3:04 PM"))
+		if zoned := originalTimes(event); zoned != "" {
+			timeStr += " (" + zoned + ")"
+		}
 	}
 	content.WriteString(labelStyle.Render("Time"))
 	content.WriteString(valueStyle.Render(timeStr))
 	content.WriteString("\n")
 
+	// The same times in the secondary zone
+	if loc := m.secondary; loc != nil && !event.AllDay && !sameClock(loc, event.StartTime) {
+		content.WriteString(labelStyle.Render(calendar.ZoneLabel(loc, event.StartTime)))
+		content.WriteString(valueStyle.Render(zoneTimes(event, loc)))
+		content.WriteString("\n")
+	}
+
+	// Recurrence (if any)
+	if event.IsRecurring() {
+		content.WriteString(labelStyle.Render("Repeats"))
+		content.WriteString(valueStyle.Render(calendar.DescribeRecurrence(event.Recurrence)))
+		content.WriteString("\n")
+	}
+
 	// Location (if present)
 	if event.Location != "" {
 		content.WriteString(labelStyle.Render("Location"))
@@ -244,6 +281,21 @@ func (m *CalendarApp) renderEventDetail() string {
 		content.WriteString("\n")
 	}
 
+	// Attendees (if any), with their answers
+	for i, attendee := range event.Attendees {
+		label := ""
+		if i == 0 {
+			label = "Attendees"
+		}
+		mark, ok := invitationStatusMarks[attendee.Status]
+		if !ok {
+			mark = "·"
+		}
+		content.WriteString(labelStyle.Render(label))
+		content.WriteString(valueStyle.Render(mark + " " + attendee.String()))
+		content.WriteString("\n")
+	}
+
 	// Calendar
 	if event.Calendar != "" {
 		content.WriteString(labelStyle.Render("Calendar"))
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"maily/internal/calendar"
 	"maily/internal/ui/components"
 	"maily/internal/ui/utils"
 )
@@ -33,7 +34,7 @@ func (m *CalendarApp) renderNLPParsing() string {
 
 	b.WriteString(titleStyle.Render("Parsing..."))
 	b.WriteString("\n\n")
-	b.WriteString("    Using AI to parse: \"" + m.nlpInput.Value() + "\"")
+	b.WriteString("    Using " + m.nlpProvider + " to parse: \"" + m.nlpInput.Value() + "\"")
 
 	return lipgloss.NewStyle().Padding(1, 2).Render(b.String())
 }
@@ -121,6 +122,12 @@ func (m *CalendarApp) renderNLPConfirm() string {
 	b.WriteString(fmt.Sprintf("  │  Title:    %-35s│\n", utils.TruncateStr(m.nlpParsed.Title, 35)))
 	b.WriteString(fmt.Sprintf("  │  Date:     %-35s│\n", m.nlpStartTime.Format("Monday, Jan 2, 2006")))
 	b.WriteString(fmt.Sprintf("  │  Time:     %-35s│\n", fmt.Sprintf("%s - %s", m.nlpStartTime.Format("3:04 PM"), m.nlpEndTime.Format("3:04 PM"))))
+	if zoned := m.nlpOriginalTimes(); zoned != "" {
+		b.WriteString(fmt.Sprintf("  │  Their time: %-33s│\n", utils.TruncateStr(zoned, 33)))
+	}
+	if m.nlpParsed.Recurrence != "" {
+		b.WriteString(fmt.Sprintf("  │  Repeats:  %-35s│\n", utils.TruncateStr(calendar.DescribeRecurrence(m.nlpParsed.Recurrence), 35)))
+	}
 	if m.nlpParsed.Location != "" {
 		b.WriteString(fmt.Sprintf("  │  Location: %-35s│\n", utils.TruncateStr(m.nlpParsed.Location, 35)))
 	}
@@ -146,6 +153,16 @@ func (m *CalendarApp) renderNLPConfirm() string {
 	return lipgloss.NewStyle().Padding(1, 2).Render(b.String())
 }
 
+// nlpOriginalTimes returns the parsed event's times in the zone named in
+// the input, "" if none was
+func (m *CalendarApp) nlpOriginalTimes() string {
+	return originalTimes(calendar.Event{
+		StartTime: m.nlpStartTime,
+		EndTime:   m.nlpEndTime,
+		TimeZone:  m.nlpParsed.TimeZone,
+	})
+}
+
 func (m *CalendarApp) renderNLPEventBox() string {
 	var b strings.Builder
 
@@ -153,10 +170,17 @@ func (m *CalendarApp) renderNLPEventBox() string {
 	b.WriteString(fmt.Sprintf("  │  Title:    %-37s│\n", utils.TruncateStr(m.nlpParsed.Title, 37)))
 	b.WriteString(fmt.Sprintf("  │  Date:     %-37s│\n", m.nlpStartTime.Format("Monday, Jan 2, 2006")))
 	b.WriteString(fmt.Sprintf("  │  Time:     %-37s│\n", fmt.Sprintf("%s - %s", m.nlpStartTime.Format("3:04 PM"), m.nlpEndTime.Format("3:04 PM"))))
+	if zoned := m.nlpOriginalTimes(); zoned != "" {
+		b.WriteString(fmt.Sprintf("  │  Their time: %-35s│\n", utils.TruncateStr(zoned, 35)))
+	}
+	if m.nlpParsed.Recurrence != "" {
+		b.WriteString(fmt.Sprintf("  │  Repeats:  %-37s│\n", utils.TruncateStr(calendar.DescribeRecurrence(m.nlpParsed.Recurrence), 37)))
+	}
 	if m.nlpParsed.Location != "" {
 		b.WriteString(fmt.Sprintf("  │  Location: %-37s│\n", utils.TruncateStr(m.nlpParsed.Location, 37)))
 	}
-	b.WriteString("  └────────────────────────────────────────────────┘")
+	b.WriteString("  └────────────────────────────────────────────────┘\n")
+	b.WriteString(lipgloss.NewStyle().Foreground(components.Muted).Render("  Parsed by " + m.nlpProvider))
 
 	return b.String()
 }
@@ -235,6 +259,13 @@ func (m *CalendarApp) renderFormDateTime() string {
 		b.WriteString("    " + labelStyle.Render("Location:") + m.formLocationInput.View() + "\n")
 	}
 
+	// Attendees
+	if m.formFocusField == 4 {
+		b.WriteString("    " + focusedLabel.Render("Attendees:") + m.formAttendeesInput.View() + "\n")
+	} else {
+		b.WriteString("    " + labelStyle.Render("Attendees:") + m.formAttendeesInput.View() + "\n")
+	}
+
 	// Error
 	if m.err != nil {
 		errStyle := lipgloss.NewStyle().Foreground(components.Danger)
@@ -384,6 +415,9 @@ func (m *CalendarApp) renderFormEventBox() string {
 	if m.formLocationInput.Value() != "" {
 		b.WriteString(fmt.Sprintf("  │  Location: %-39s│\n", utils.TruncateStr(m.formLocationInput.Value(), 39)))
 	}
+	if m.formAttendeesInput.Value() != "" {
+		b.WriteString(fmt.Sprintf("  │  Invite:   %-39s│\n", utils.TruncateStr(m.formAttendeesInput.Value(), 39)))
+	}
 	b.WriteString("  └────────────────────────────────────────────────────┘")
 
 	return b.String()
@@ -63,6 +63,12 @@ func (m *CalendarApp) renderCalendar() string {
 			Render("  No events")
 		b.WriteString(noEvents)
 	} else {
+		if m.secondary != nil {
+			// Column headings for the secondary zone's times
+			headStyle := lipgloss.NewStyle().Foreground(components.Muted)
+			b.WriteString("  " + headStyle.Width(20).Render("Local") + headStyle.Width(12).Render(calendar.ZoneLabel(m.secondary, m.selectedDate)))
+			b.WriteString("\n")
+		}
 		for i, event := range dayEvents {
 			b.WriteString(m.renderEvent(event, i == m.selectedIdx))
 			b.WriteString("\n")
@@ -177,7 +183,22 @@ func (m *CalendarApp) renderEvent(event calendar.Event, selected bool) string {
 		prefix = "  "
 	}
 
-	line := prefix + timeStyle.Render(timeStr) + titleStyle.Render(event.Title)
+	line := prefix + timeStyle.Render(timeStr)
+	if m.secondary != nil {
+		// Start time in the secondary zone
+		secondaryStr := ""
+		if !event.AllDay {
+			secondaryStr = event.StartTime.In(m.secondary).Format("3:04 PM")
+		}
+		line += timeStyle.Width(12).Render(secondaryStr)
+	}
+	line += titleStyle.Render(event.Title)
+	if zoned := originalTimes(event); zoned != "" {
+		line += lipgloss.NewStyle().Foreground(components.Muted).Render(" (" + zoned + ")")
+	}
+	if event.IsRecurring() {
+		line += calStyle.Render(" ↻")
+	}
 	if event.Calendar != "" {
 		line += calStyle.Render(fmt.Sprintf(" [%s]", event.Calendar))
 	}
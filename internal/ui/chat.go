@@ -0,0 +1,371 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"maily/internal/ai"
+	"maily/internal/cache"
+	"maily/internal/ui/components"
+	"maily/internal/ui/utils"
+)
+
+// chatSourceLimit is how many retrieved emails go into a prompt
+const chatSourceLimit = 6
+
+// chatSourceChars caps each email's body in a prompt; the start of an
+// email usually holds what it is about
+const chatSourceChars = 3000
+
+// chatSource is a cached email retrieved for a question
+type chatSource struct {
+	number  int // [n] in the answer
+	account string
+	mailbox string
+	email   cache.CachedEmail
+}
+
+// chatTurn is a question with its answer and the emails it cites
+type chatTurn struct {
+	question string
+	answer   string
+	cited    []chatSource
+	err      error
+}
+
+type chatAnswerMsg struct {
+	answer string
+	cited  []chatSource
+	err    error
+}
+
+// OpenCitationMsg asks the app to open an email cited in the chat
+type OpenCitationMsg struct {
+	source chatSource
+}
+
+// CloseChatMsg is sent when the chat panel is closed
+type CloseChatMsg struct{}
+
+// ChatModel answers questions about the cached mailbox: it retrieves
+// matching emails with a local search, asks the AI about them and lists
+// the emails the answer cites
+type ChatModel struct {
+	input     textinput.Model
+	viewport  viewport.Model
+	turns     []chatTurn
+	cursor    int // selected citation of the last answer
+	pending   bool
+	preview   *chatSource // cited email being read in the panel
+	client    *ai.Client
+	diskCache *cache.Cache
+	accounts  []string
+	width     int
+	height    int
+}
+
+// NewChatModel creates a chat over the cached mail of accounts
+func NewChatModel(diskCache *cache.Cache, accounts []string, width, height int) ChatModel {
+	ti := textinput.New()
+	ti.Placeholder = "Ask about your email, e.g. when is the contractor's invoice due?"
+	ti.CharLimit = 300
+	ti.Focus()
+
+	m := ChatModel{
+		input:     ti,
+		viewport:  viewport.New(80, 20),
+		client:    ai.NewTaskClient(ai.TaskChat),
+		diskCache: diskCache,
+		accounts:  accounts,
+	}
+	m.SetSize(width, height)
+	return m
+}
+
+// openChat opens the chat panel, creating it the first time
+func (a *App) openChat() tea.Cmd {
+	if !a.chatOpen {
+		var accounts []string
+		for _, account := range a.store.Accounts {
+			accounts = append(accounts, account.Credentials.Email)
+		}
+		a.chat = NewChatModel(a.diskCache, accounts, a.width, a.height)
+		a.chatOpen = true
+	}
+	a.chatReturn = a.view
+	a.view = chatView
+	return a.chat.Init()
+}
+
+func (m ChatModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// SetSize resizes the panel
+func (m *ChatModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+	m.input.Width = max(20, width-16)
+	m.viewport.Width = max(20, width-8)
+	m.viewport.Height = max(5, height-14)
+	m.refresh()
+}
+
+// ShowPreview shows a cited email in the panel
+func (m *ChatModel) ShowPreview(source chatSource) {
+	m.preview = &source
+	m.refresh()
+	m.viewport.GotoTop()
+}
+
+func (m ChatModel) Update(msg tea.Msg) (ChatModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case chatAnswerMsg:
+		m.pending = false
+		turn := &m.turns[len(m.turns)-1]
+		turn.answer, turn.cited, turn.err = msg.answer, msg.cited, msg.err
+		m.cursor = 0
+		m.refresh()
+		m.viewport.GotoBottom()
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.preview != nil {
+			switch msg.String() {
+			case "esc", "q":
+				m.preview = nil
+				m.refresh()
+				m.viewport.GotoBottom()
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		}
+
+		switch msg.String() {
+		case "esc":
+			return m, func() tea.Msg { return CloseChatMsg{} }
+		case "up", "ctrl+p":
+			if m.cursor > 0 {
+				m.cursor--
+				m.refresh()
+			}
+			return m, nil
+		case "down", "ctrl+n":
+			if cited := m.lastCited(); m.cursor < len(cited)-1 {
+				m.cursor++
+				m.refresh()
+			}
+			return m, nil
+		case "pgup", "pgdown":
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		case "enter":
+			question := strings.TrimSpace(m.input.Value())
+			if question == "" {
+				// Jump to the selected citation
+				if cited := m.lastCited(); m.cursor < len(cited) {
+					source := cited[m.cursor]
+					return m, func() tea.Msg { return OpenCitationMsg{source: source} }
+				}
+				return m, nil
+			}
+			if m.pending {
+				return m, nil
+			}
+			if !m.client.Available() {
+				m.turns = append(m.turns, chatTurn{question: question, err: fmt.Errorf("no AI CLI found (install claude, codex, gemini, vibe, or ollama)")})
+				m.input.SetValue("")
+				m.refresh()
+				return m, nil
+			}
+			m.turns = append(m.turns, chatTurn{question: question})
+			m.pending = true
+			m.input.SetValue("")
+			m.refresh()
+			m.viewport.GotoBottom()
+			return m, m.ask(question)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// lastCited returns the emails cited by the last answer
+func (m ChatModel) lastCited() []chatSource {
+	if len(m.turns) == 0 {
+		return nil
+	}
+	return m.turns[len(m.turns)-1].cited
+}
+
+// ask retrieves the cached emails that best match question and asks the AI
+// to answer from them
+func (m ChatModel) ask(question string) tea.Cmd {
+	client := m.client
+	diskCache := m.diskCache
+	accounts := m.accounts
+
+	return func() tea.Msg {
+		sources := retrieveChatSources(diskCache, accounts, question)
+		if len(sources) == 0 {
+			return chatAnswerMsg{answer: "No cached emails match that question. Try other words, or sync more mail."}
+		}
+
+		messages := make([]ai.ThreadMessage, len(sources))
+		for i, s := range sources {
+			body := s.email.Body
+			if body == "" {
+				body = s.email.Snippet
+			}
+			if len(body) > chatSourceChars {
+				body = body[:chatSourceChars] + "..."
+			}
+			messages[i] = ai.ThreadMessage{From: s.email.From, Subject: s.email.Subject, Date: s.email.Date, Body: body}
+		}
+
+		answer, err := client.Call(ai.AskPrompt(question, messages, time.Now()))
+		if err != nil {
+			return chatAnswerMsg{err: err}
+		}
+		var cited []chatSource
+		for _, n := range ai.Citations(answer, len(sources)) {
+			cited = append(cited, sources[n-1])
+		}
+		return chatAnswerMsg{answer: answer, cited: cited}
+	}
+}
+
+// retrieveChatSources searches the cached mailboxes of accounts for
+// question, skipping copies of the same message in several mailboxes
+func retrieveChatSources(diskCache *cache.Cache, accounts []string, question string) []chatSource {
+	if diskCache == nil {
+		return nil
+	}
+	var all []chatSource
+	var emails []cache.CachedEmail
+	for _, account := range accounts {
+		mailboxes, _ := diskCache.Mailboxes(account)
+		for _, mailbox := range mailboxes {
+			cached, err := diskCache.LoadEmails(account, mailbox)
+			if err != nil {
+				continue
+			}
+			for _, e := range cached {
+				all = append(all, chatSource{account: account, mailbox: mailbox, email: e})
+				emails = append(emails, e)
+			}
+		}
+	}
+
+	var sources []chatSource
+	seen := make(map[string]bool)
+	for _, i := range cache.Search(emails, question, 3*chatSourceLimit) {
+		if id := all[i].email.MessageID; id != "" {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+		}
+		source := all[i]
+		source.number = len(sources) + 1
+		sources = append(sources, source)
+		if len(sources) == chatSourceLimit {
+			break
+		}
+	}
+	return sources
+}
+
+// refresh renders the conversation, or the previewed email, into the
+// viewport
+func (m *ChatModel) refresh() {
+	width := m.viewport.Width - 2
+	if m.preview != nil {
+		e := m.preview.email
+		body := e.Body
+		if body == "" {
+			body = e.Snippet
+		}
+		header := components.DialogTitleStyle.Render(e.Subject) + "\n" +
+			components.HelpDescStyle.Render(fmt.Sprintf("From %s · %s · %s/%s", e.From, e.Date.Local().Format("Mon Jan 2 2006 15:04"), m.preview.account, m.preview.mailbox))
+		m.viewport.SetContent(header + "\n\n" + lipgloss.NewStyle().Width(width).Render(body))
+		return
+	}
+
+	questionStyle := lipgloss.NewStyle().Bold(true).Foreground(components.Primary).Width(width)
+	answerStyle := lipgloss.NewStyle().Width(width)
+	errorStyle := lipgloss.NewStyle().Foreground(components.Warning).Width(width)
+
+	var b strings.Builder
+	for i, turn := range m.turns {
+		b.WriteString(questionStyle.Render("> "+turn.question) + "\n\n")
+		switch {
+		case turn.err != nil:
+			b.WriteString(errorStyle.Render("Error: "+turn.err.Error()) + "\n\n")
+		case turn.answer == "":
+			b.WriteString(components.HelpDescStyle.Render("Searching your mail and asking "+m.client.Provider()+"...") + "\n\n")
+		default:
+			b.WriteString(answerStyle.Render(turn.answer) + "\n\n")
+		}
+
+		last := i == len(m.turns)-1
+		for j, source := range turn.cited {
+			line := fmt.Sprintf("[%d] %s · %s · %s", source.number, source.email.Date.Local().Format("Jan 2"), utils.ExtractNameFromEmail(source.email.From), source.email.Subject)
+			line = utils.TruncateStr(line, width-2)
+			if last && j == m.cursor {
+				b.WriteString(components.HelpKeyStyle.Render("▸ "+line) + "\n")
+			} else {
+				b.WriteString(components.HelpDescStyle.Render("  "+line) + "\n")
+			}
+		}
+		if len(turn.cited) > 0 {
+			b.WriteString("\n")
+		}
+	}
+	m.viewport.SetContent(b.String())
+}
+
+func (m ChatModel) View() string {
+	title := components.DialogTitleStyle.Foreground(components.Primary).Render("Ask Your Mail")
+	privacy := "answers use " + m.client.Provider() + "; matching emails are sent to it"
+	if m.client.Local() {
+		privacy = "answers use " + m.client.Provider() + " on this machine; nothing leaves it"
+	}
+	if !m.client.Available() {
+		privacy = "no AI provider found"
+	}
+
+	var help string
+	if m.preview != nil {
+		help = components.HelpKeyStyle.Render("↑/↓") + components.HelpDescStyle.Render(" scroll  ") +
+			components.HelpKeyStyle.Render("esc") + components.HelpDescStyle.Render(" back to chat")
+	} else {
+		help = components.HelpKeyStyle.Render("enter") + components.HelpDescStyle.Render(" ask / open citation  ") +
+			components.HelpKeyStyle.Render("↑/↓") + components.HelpDescStyle.Render(" select citation  ") +
+			components.HelpKeyStyle.Render("pgup/pgdn") + components.HelpDescStyle.Render(" scroll  ") +
+			components.HelpKeyStyle.Render("esc") + components.HelpDescStyle.Render(" close")
+	}
+
+	parts := []string{
+		title + "  " + components.HelpDescStyle.Render(privacy),
+		"",
+		m.viewport.View(),
+	}
+	if m.preview == nil {
+		parts = append(parts, "", m.input.View())
+	}
+	parts = append(parts, "", help)
+
+	return lipgloss.NewStyle().Padding(1, 4).Render(lipgloss.JoinVertical(lipgloss.Left, parts...))
+}
@@ -38,7 +38,7 @@ func (a App) initClient() tea.Cmd {
 	creds := &account.Credentials
 	accountEmail := creds.Email // capture for closure
 	return func() tea.Msg {
-		client, err := mail.NewIMAPClient(creds)
+		client, err := mail.Dial(creds)
 		if err != nil {
 			return errorMsg{err: err, accountEmail: accountEmail}
 		}
@@ -53,12 +53,13 @@ func (a *App) loadEmails() tea.Cmd {
 		accountEmail = account.Credentials.Email
 	}
 	since := time.Now().AddDate(0, 0, -SyncDays)
+	diskCache := a.diskCache
 	return func() tea.Msg {
 		emails, err := a.imap.FetchMessagesSince(label, since, a.emailLimit)
 		if err != nil {
 			return errorMsg{err: err, accountEmail: accountEmail}
 		}
-		return emailsLoadedMsg{emails: emails}
+		return emailsLoadedMsg{emails: withTriage(diskCache, accountEmail, label, emails)}
 	}
 }
 
@@ -257,13 +258,13 @@ func (a *App) sendReply() tea.Cmd {
 	original := a.compose.GetOriginalEmail()
 
 	return func() tea.Msg {
-		smtp := mail.NewSMTPClient(&account.Credentials)
+		sender := mail.NewSender(&account.Credentials)
 
 		var err error
 		if original != nil {
-			err = smtp.Reply(to, subject, body, original.MessageID, original.References)
+			err = sender.Reply(to, subject, body, original.MessageID, original.References)
 		} else {
-			err = smtp.Send(to, subject, body)
+			err = sender.Send(to, subject, body)
 		}
 
 		if err != nil {
@@ -304,6 +305,51 @@ func (a *App) summarizeEmail(email *mail.Email) tea.Cmd {
 	}
 }
 
+// summarizeThread summarizes the conversation email belongs to, built from
+// the cached emails of the current mailbox
+func (a *App) summarizeThread(email *mail.Email) tea.Cmd {
+	client := a.aiClient
+	provider := client.Provider()
+	diskCache := a.diskCache
+	var account string
+	if acc := a.currentAccount(); acc != nil {
+		account = acc.Credentials.Email
+	}
+	mailbox := a.currentLabel
+	selected := cache.CachedEmail{
+		UID:        email.UID,
+		MessageID:  email.MessageID,
+		References: email.References,
+		From:       email.From,
+		Subject:    email.Subject,
+		Date:       email.Date,
+		Body:       email.Body,
+		Snippet:    email.Snippet,
+	}
+
+	return func() tea.Msg {
+		var cached []cache.CachedEmail
+		if diskCache != nil && account != "" {
+			cached, _ = diskCache.LoadEmails(account, mailbox)
+		}
+		thread := cache.Thread(cached, selected)
+
+		messages := make([]ai.ThreadMessage, len(thread))
+		for i, e := range thread {
+			body := e.Body
+			if body == "" {
+				body = e.Snippet
+			}
+			messages[i] = ai.ThreadMessage{From: e.From, Date: e.Date, Body: body}
+		}
+		summary, err := client.Call(ai.SummarizeThreadPrompt(email.Subject, messages))
+		if err != nil {
+			return summaryErrorMsg{err: err}
+		}
+		return summaryResultMsg{summary: summary, provider: fmt.Sprintf("%s, %d messages", provider, len(thread))}
+	}
+}
+
 // loadCachedEmails loads emails from disk cache for instant display
 func (a App) loadCachedEmails() tea.Cmd {
 	account := a.currentAccount()
@@ -359,6 +405,12 @@ func (a App) reloadFromCache() tea.Cmd {
 
 // cachedToGmail converts a cache.CachedEmail to mail.Email
 func cachedToGmail(c cache.CachedEmail) mail.Email {
+	var category string
+	var priority int
+	if c.Triage != nil {
+		category, priority = c.Triage.Category, c.Triage.Priority
+	}
+
 	attachments := make([]mail.Attachment, len(c.Attachments))
 	for i, a := range c.Attachments {
 		attachments[i] = mail.Attachment{
@@ -383,6 +435,10 @@ func cachedToGmail(c cache.CachedEmail) mail.Email {
 		Unread:       c.Unread,
 		References:   c.References,
 		Attachments:  attachments,
+		Calendar:     c.Calendar,
+		Headers:      c.Headers,
+		Category:     category,
+		Priority:     priority,
 	}
 }
 
@@ -426,11 +482,11 @@ func (a App) executeCommand(command string) (tea.Model, tea.Cmd) {
 		return a, textinput.Blink
 
 	case "refresh":
-		// Refresh from IMAP server
+		// Sync now, through the daemon if it runs
 		if !a.isSearchResult && a.view == listView {
 			a.state = stateLoading
 			a.statusMsg = "Refreshing..."
-			return a, tea.Batch(a.spinner.Tick, a.loadEmails())
+			return a, tea.Batch(a.spinner.Tick, a.refresh())
 		}
 
 	case "labels":
@@ -454,9 +510,43 @@ func (a App) executeCommand(command string) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case "ask":
+		// AI questions about the mailbox
+		if a.view == listView || a.view == readView {
+			return a, a.openChat()
+		}
+
+	case "draft":
+		// AI draft a reply
+		if a.view == readView {
+			return a, a.openDraftDialog()
+		}
+
+	case "thread":
+		// AI summarize the whole conversation
+		if a.view == readView {
+			if !a.aiClient.Available() {
+				a.statusMsg = "No AI CLI found (install claude, codex, gemini, vibe, or ollama)"
+				return a, nil
+			}
+			if email := a.mailList.SelectedEmail(); email != nil {
+				a.state = stateLoading
+				a.statusMsg = "Summarizing thread with " + a.aiClient.Provider() + "..."
+				return a, tea.Batch(a.spinner.Tick, a.summarizeThread(email))
+			}
+		}
+
 	case "extract":
-		// AI extract event (placeholder - will be implemented in Phase 1)
-		a.statusMsg = "Extract: AI not configured yet"
+		// AI extract events to the calendar
+		if a.view == readView {
+			return a, a.openExtract()
+		}
+
+	case "sieve":
+		// Edit the server-side filters of the account
+		if a.view == listView {
+			return a, a.openSieve()
+		}
 
 	case "add":
 		// Add calendar event (placeholder)
@@ -30,8 +30,12 @@ var AllCommands = []Command{
 	{Name: "refresh", Description: "Refresh inbox", Shortcut: "R", Views: []string{"list"}},
 	{Name: "labels", Description: "Switch label/folder", Shortcut: "f", Views: []string{"list"}},
 	{Name: "summarize", Description: "Summarize this email (AI)", Shortcut: "s", Views: []string{"read", "today"}},
-	{Name: "extract", Description: "Extract event to calendar (AI)", Shortcut: "e", Views: []string{"read", "today"}},
+	{Name: "ask", Description: "Ask about your mail (AI)", Shortcut: "?", Views: []string{"list", "read"}},
+	{Name: "draft", Description: "Draft a reply (AI)", Shortcut: "A", Views: []string{"read"}},
+	{Name: "thread", Description: "Summarize the whole thread (AI)", Shortcut: "S", Views: []string{"read"}},
+	{Name: "extract", Description: "Extract events to calendar (AI)", Shortcut: "e", Views: []string{"read", "today"}},
 	{Name: "add", Description: "Add calendar event", Shortcut: "a", Views: []string{"today"}},
+	{Name: "sieve", Description: "Edit server-side filters (Sieve)", Views: []string{"list"}},
 }
 
 // CommandPalette is the command palette component
@@ -172,6 +176,8 @@ func (c CommandPalette) View() string {
 		line := nameStyle.Render(name) + " " + descStyle.Render(desc)
 		if shortcut != "" {
 			line = shortcutStyle.Render("["+shortcut+"]") + " " + line
+		} else {
+			line = shortcutStyle.Render("") + " " + line
 		}
 
 		if i == c.cursor {
@@ -1,6 +1,7 @@
 package components
 
 import (
+	"sort"
 	"strings"
 	"time"
 
@@ -49,7 +50,10 @@ var DefaultMailListKeyMap = MailListKeyMap{
 }
 
 type MailList struct {
+	all           []mail.Email // every email; emails is the filtered, sorted view
 	emails        []mail.Email
+	category      string // show only this triage category ("" for all)
+	byPriority    bool   // sort by triage priority instead of date
 	cursor        int
 	width         int
 	height        int
@@ -67,14 +71,56 @@ func NewMailList() MailList {
 }
 
 func (m *MailList) SetEmails(emails []mail.Email) {
-	m.emails = emails
-	if m.cursor >= len(emails) {
-		m.cursor = max(0, len(emails)-1)
-	}
+	m.all = emails
+	m.applyView()
+}
+
+// Len returns the number of emails shown
+func (m MailList) Len() int {
+	return len(m.emails)
 }
 
+// Emails returns all emails, including those the triage filter hides
 func (m MailList) Emails() []mail.Email {
-	return m.emails
+	return m.all
+}
+
+// SetTriageView filters the list to a triage category ("" for all) and
+// sorts it by priority or by date
+func (m *MailList) SetTriageView(category string, byPriority bool) {
+	m.category = category
+	m.byPriority = byPriority
+	m.cursor = 0
+	m.applyView()
+}
+
+// applyView rebuilds the visible emails from all
+func (m *MailList) applyView() {
+	if m.category == "" && !m.byPriority {
+		m.emails = m.all
+	} else {
+		m.emails = nil
+		for _, e := range m.all {
+			if m.category == "" || e.Category == m.category {
+				m.emails = append(m.emails, e)
+			}
+		}
+		if m.byPriority {
+			// Unclassified emails (priority 0) go last; ties keep date order
+			rank := func(e mail.Email) int {
+				if e.Priority == 0 {
+					return 4
+				}
+				return e.Priority
+			}
+			sort.SliceStable(m.emails, func(i, j int) bool {
+				return rank(m.emails[i]) < rank(m.emails[j])
+			})
+		}
+	}
+	if m.cursor >= len(m.emails) {
+		m.cursor = max(0, len(m.emails)-1)
+	}
 }
 
 func (m *MailList) SetSize(width, height int) {
@@ -86,31 +132,39 @@ func (m *MailList) RemoveCurrent() {
 	if len(m.emails) == 0 || m.cursor < 0 || m.cursor >= len(m.emails) {
 		return
 	}
-	m.emails = append(m.emails[:m.cursor], m.emails[m.cursor+1:]...)
-	if m.cursor >= len(m.emails) && m.cursor > 0 {
-		m.cursor--
-	}
+	m.RemoveByUID(m.emails[m.cursor].UID)
 }
 
 func (m *MailList) RemoveByUID(uid imap.UID) {
-	for i, email := range m.emails {
+	for i, email := range m.all {
 		if email.UID == uid {
-			m.emails = append(m.emails[:i], m.emails[i+1:]...)
-			if m.cursor >= len(m.emails) && m.cursor > 0 {
-				m.cursor--
-			}
-			return
+			m.all = append(m.all[:i:i], m.all[i+1:]...)
+			break
 		}
 	}
+	m.applyView()
 }
 
 func (m *MailList) MarkAsRead(uid imap.UID) {
+	for _, list := range [][]mail.Email{m.all, m.emails} {
+		for i := range list {
+			if list[i].UID == uid {
+				list[i].Unread = false
+			}
+		}
+	}
+}
+
+// SelectByUID moves the cursor to the email with uid and reports whether
+// it is in the list
+func (m *MailList) SelectByUID(uid imap.UID) bool {
 	for i := range m.emails {
 		if m.emails[i].UID == uid {
-			m.emails[i].Unread = false
-			return
+			m.cursor = i
+			return true
 		}
 	}
+	return false
 }
 
 func (m *MailList) ScrollUp() {
@@ -220,7 +274,11 @@ func (m MailList) renderEmailLine(email mail.Email, isCursor bool) string {
 	}
 
 	from := truncate(extractName(email.From), fromWidth)
-	subject := truncate(email.Subject, availableWidth)
+	subject := email.Subject
+	if email.Category != "" {
+		subject = triageTag(email) + " " + subject
+	}
+	subject = truncate(subject, availableWidth)
 	date := formatDate(email.Date)
 
 	// Checkbox for selection mode
@@ -265,6 +323,16 @@ func (m MailList) renderEmailLine(email mail.Email, isCursor bool) string {
 	return checkbox + status + lineStyle.Render(line)
 }
 
+// triageTag labels an email with its triage, e.g. "[action!]" for an
+// action item of high priority
+func triageTag(email mail.Email) string {
+	tag := email.Category
+	if email.Priority == 1 {
+		tag += "!"
+	}
+	return "[" + tag + "]"
+}
+
 func extractName(from string) string {
 	if idx := strings.Index(from, "<"); idx > 0 {
 		return strings.TrimSpace(from[:idx])
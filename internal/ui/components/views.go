@@ -27,6 +27,7 @@ type StatusBarData struct {
 	IsSearchResult bool
 	IsListView     bool
 	IsComposeView    bool
+	Invitation     string // iTIP method (REQUEST, CANCEL) of an invitation in the read view
 	AccountCount   int
 	SelectionCount int
 }
@@ -116,6 +117,9 @@ func RenderStatusBar(data StatusBarData) string {
 		row2 := HelpKeyStyle.Render("d") + HelpDescStyle.Render(" delete  ") +
 			HelpKeyStyle.Render("l") + HelpDescStyle.Render(" more  ") +
 			HelpKeyStyle.Render("f") + HelpDescStyle.Render(" folders  ") +
+			HelpKeyStyle.Render("T") + HelpDescStyle.Render(" category  ") +
+			HelpKeyStyle.Render("P") + HelpDescStyle.Render(" priority  ") +
+			HelpKeyStyle.Render("?") + HelpDescStyle.Render(" ask  ") +
 			HelpKeyStyle.Render("/") + HelpDescStyle.Render(" commands")
 		help = row1 + "\n" + row2
 	} else {
@@ -123,10 +127,20 @@ func RenderStatusBar(data StatusBarData) string {
 		help = tabHint +
 			HelpKeyStyle.Render("r") + HelpDescStyle.Render(" reply  ") +
 			HelpKeyStyle.Render("s") + HelpDescStyle.Render(" summarize  ") +
-			HelpKeyStyle.Render("e") + HelpDescStyle.Render(" extract  ") +
+			HelpKeyStyle.Render("S") + HelpDescStyle.Render(" thread  ") +
+			HelpKeyStyle.Render("A") + HelpDescStyle.Render(" AI reply  ") +
+			HelpKeyStyle.Render("e") + HelpDescStyle.Render(" add events  ") +
 			HelpKeyStyle.Render("/") + HelpDescStyle.Render(" commands  ") +
 			HelpKeyStyle.Render("esc") + HelpDescStyle.Render(" back  ") +
 			HelpKeyStyle.Render("q") + HelpDescStyle.Render(" quit")
+		switch data.Invitation {
+		case "REQUEST":
+			help += "\n" + HelpKeyStyle.Render("y") + HelpDescStyle.Render(" accept  ") +
+				HelpKeyStyle.Render("t") + HelpDescStyle.Render(" tentative  ") +
+				HelpKeyStyle.Render("n") + HelpDescStyle.Render(" decline")
+		case "CANCEL":
+			help += "\n" + HelpKeyStyle.Render("x") + HelpDescStyle.Render(" remove from calendar")
+		}
 	}
 
 	status := StatusKeyStyle.Render(data.StatusMsg)
@@ -270,6 +284,40 @@ func RenderSearchInput(inputView string) string {
 	)
 }
 
+// RenderDraftDialog renders the prompt for an AI-drafted reply: the intent
+// input and the tones, with tones[selected] highlighted
+func RenderDraftDialog(inputView string, tones []string, selected int, provider string) string {
+	dialogStyle := DialogStyle.BorderForeground(Primary)
+
+	title := DialogTitleStyle.
+		Foreground(Primary).
+		Render("Draft Reply")
+
+	var toneViews []string
+	for i, tone := range tones {
+		if i == selected {
+			toneViews = append(toneViews, HelpKeyStyle.Render("["+tone+"]"))
+		} else {
+			toneViews = append(toneViews, HelpDescStyle.Render(" "+tone+" "))
+		}
+	}
+
+	hint := DialogHintStyle.Render("Enter to draft with " + provider + ", Tab for tone, Esc to cancel\nThe draft opens for editing; nothing is sent")
+
+	return dialogStyle.Render(
+		lipgloss.JoinVertical(
+			lipgloss.Center,
+			title,
+			"",
+			inputView,
+			"",
+			"Tone: "+strings.Join(toneViews, " "),
+			"",
+			hint,
+		),
+	)
+}
+
 func RenderLoading(width, height int, spinnerView, statusMsg string) string {
 	return lipgloss.Place(
 		width,
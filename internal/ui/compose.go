@@ -190,6 +190,9 @@ type SaveDraftMsg struct{}
 // CancelMsg is sent when user presses Enter on Cancel button
 type CancelMsg struct{}
 
+// ProposeTimesMsg is sent when user asks for free times to offer
+type ProposeTimesMsg struct{}
+
 func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 	var cmd tea.Cmd
 	var cmds []tea.Cmd
@@ -242,6 +245,8 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 			nextFocus := (m.focused + 5) % 6
 			cmd = m.focusField(nextFocus)
 			return m, cmd
+		case "ctrl+t":
+			return m, func() tea.Msg { return ProposeTimesMsg{} }
 		}
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -346,7 +351,8 @@ func (m ComposeModel) View() string {
 	buttons := lipgloss.JoinHorizontal(lipgloss.Top, sendBtn, "  ", saveDraftBtn, "  ", cancelBtn)
 
 	// Help line
-	help := components.HelpKeyStyle.Render("Tab") + components.HelpDescStyle.Render(" next field")
+	help := components.HelpKeyStyle.Render("Tab") + components.HelpDescStyle.Render(" next field  ") +
+		components.HelpKeyStyle.Render("Ctrl+T") + components.HelpDescStyle.Render(" propose free times")
 
 	// Compose everything
 	content := lipgloss.JoinVertical(
@@ -395,6 +401,13 @@ func (m ComposeModel) View() string {
 	)
 }
 
+// InsertText inserts text into the body at the cursor and focuses it
+func (m *ComposeModel) InsertText(text string) tea.Cmd {
+	cmd := m.focusField(focusBody)
+	m.body.InsertString(text)
+	return cmd
+}
+
 // GetBody returns the composed email body
 func (m ComposeModel) GetBody() string {
 	return m.body.Value()
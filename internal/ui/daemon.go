@@ -0,0 +1,127 @@
+package ui
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"maily/internal/control"
+)
+
+// daemonRetryInterval is how long the app waits before it tries again to
+// follow a daemon that was not running
+const daemonRetryInterval = time.Minute
+
+// daemonSubscribedMsg is sent once the app follows the daemon's events
+type daemonSubscribedMsg struct {
+	sub *control.Subscription
+}
+
+// daemonEventMsg is an event from the daemon
+type daemonEventMsg struct {
+	sub   *control.Subscription
+	event control.Event
+}
+
+// daemonGoneMsg is sent when the daemon is not running or has stopped
+type daemonGoneMsg struct{}
+
+// daemonRetryMsg asks the app to try following the daemon again
+type daemonRetryMsg struct{}
+
+// daemonSyncDoneMsg is the result of a sync the app asked the daemon for
+type daemonSyncDoneMsg struct {
+	accountEmail string
+	mailbox      string
+	err          error
+}
+
+// subscribeDaemon starts following the daemon's events over its control
+// socket
+func subscribeDaemon() tea.Cmd {
+	return func() tea.Msg {
+		sub, err := control.Subscribe()
+		if err != nil {
+			return daemonGoneMsg{}
+		}
+		return daemonSubscribedMsg{sub: sub}
+	}
+}
+
+// nextDaemonEvent waits for the daemon's next event
+func nextDaemonEvent(sub *control.Subscription) tea.Cmd {
+	return func() tea.Msg {
+		event, err := sub.Next()
+		if err != nil {
+			sub.Close()
+			return daemonGoneMsg{}
+		}
+		return daemonEventMsg{sub: sub, event: event}
+	}
+}
+
+// refresh brings the list up to date: through the daemon, which syncs the
+// mailbox into the disk cache, when it runs, else from the server
+func (a *App) refresh() tea.Cmd {
+	account := a.currentAccount()
+	if !a.daemonConnected || account == nil || a.diskCache == nil {
+		return a.loadEmails()
+	}
+	accountEmail := account.Credentials.Email
+	mailbox := a.currentLabel
+	return func() tea.Msg {
+		err := control.Sync(accountEmail, mailbox)
+		return daemonSyncDoneMsg{accountEmail: accountEmail, mailbox: mailbox, err: err}
+	}
+}
+
+// showsMailbox reports whether the list shows mailbox of account
+func (a App) showsMailbox(account, mailbox string) bool {
+	current := a.currentAccount()
+	return current != nil && current.Credentials.Email == account && strings.EqualFold(a.currentLabel, mailbox)
+}
+
+// canAutoRefresh reports whether the list may be refreshed without the
+// user asking: it is shown, and no dialog or search is open
+func (a App) canAutoRefresh() bool {
+	return a.view == listView && a.state == stateReady && !a.confirmDelete && !a.searchMode && !a.showLabelPicker && !a.showCommandPalette && !a.isSearchResult
+}
+
+func (a App) handleDaemonMsg(msg tea.Msg) (App, tea.Cmd) {
+	switch msg := msg.(type) {
+	case daemonSubscribedMsg:
+		a.daemonConnected = true
+		return a, nextDaemonEvent(msg.sub)
+
+	case daemonGoneMsg:
+		// The timer refreshes the list until the daemon is back
+		a.daemonConnected = false
+		return a, tea.Tick(daemonRetryInterval, func(time.Time) tea.Msg { return daemonRetryMsg{} })
+
+	case daemonRetryMsg:
+		return a, subscribeDaemon()
+
+	case daemonEventMsg:
+		cmd := nextDaemonEvent(msg.sub)
+		// The daemon has just written the mailbox to the disk cache
+		event := msg.event
+		if event.Type == control.EventSyncFinished && event.Error == "" &&
+			a.showsMailbox(event.Account, event.Mailbox) && a.canAutoRefresh() {
+			return a, tea.Batch(cmd, a.reloadFromCache())
+		}
+		return a, cmd
+
+	case daemonSyncDoneMsg:
+		if !a.showsMailbox(msg.accountEmail, msg.mailbox) || a.state != stateLoading {
+			return a, nil
+		}
+		if msg.err != nil {
+			// e.g. an account added after the daemon started
+			a.statusMsg = "Refreshing from server..."
+			return a, a.loadEmails()
+		}
+		return a, a.reloadFromCache()
+	}
+	return a, nil
+}
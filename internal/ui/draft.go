@@ -0,0 +1,120 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"maily/config"
+	"maily/internal/ai"
+	"maily/internal/mail"
+	"maily/internal/ui/components"
+)
+
+// draftReplyMsg carries an AI-drafted reply body for email
+type draftReplyMsg struct {
+	email    *mail.Email
+	body     string
+	provider string
+}
+
+type draftReplyErrorMsg struct {
+	err error
+}
+
+// newDraftInput returns the input for the intent of a drafted reply
+func newDraftInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "e.g. decline politely, propose next week (optional)"
+	ti.CharLimit = 200
+	ti.Width = 50
+	return ti
+}
+
+// defaultDraftTone returns the index in ai.Tones of draft_tone in
+// config.json, 0 if it is unset or unknown
+func defaultDraftTone() int {
+	cfg, _ := config.Load()
+	for i, tone := range ai.Tones {
+		if strings.EqualFold(cfg.AI.DraftTone, tone) {
+			return i
+		}
+	}
+	return 0
+}
+
+// openDraftDialog asks for the intent and tone of a reply to the selected
+// email
+func (a *App) openDraftDialog() tea.Cmd {
+	if !a.draftClient.Available() {
+		a.statusMsg = "No AI CLI found (install claude, codex, gemini, vibe, or ollama)"
+		return nil
+	}
+	if a.mailList.SelectedEmail() == nil {
+		return nil
+	}
+	a.draftMode = true
+	a.draftTone = defaultDraftTone()
+	a.draftInput.SetValue("")
+	return a.draftInput.Focus()
+}
+
+// updateDraftDialog handles keys while the draft dialog is open
+func (a *App) updateDraftDialog(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		a.draftMode = false
+		a.draftInput.Blur()
+		return nil
+	case "tab":
+		a.draftTone = (a.draftTone + 1) % len(ai.Tones)
+		return nil
+	case "shift+tab":
+		a.draftTone = (a.draftTone + len(ai.Tones) - 1) % len(ai.Tones)
+		return nil
+	case "enter":
+		email := a.mailList.SelectedEmail()
+		a.draftMode = false
+		a.draftInput.Blur()
+		if email == nil {
+			return nil
+		}
+		a.state = stateLoading
+		a.statusMsg = "Drafting reply with " + a.draftClient.Provider() + "..."
+		return tea.Batch(a.spinner.Tick, a.draftReply(email, strings.TrimSpace(a.draftInput.Value()), ai.Tones[a.draftTone]))
+	}
+	var cmd tea.Cmd
+	a.draftInput, cmd = a.draftInput.Update(msg)
+	return cmd
+}
+
+// draftReply asks the AI for a reply to email. The draft only ever opens
+// in the compose view; it is never sent without the user.
+func (a *App) draftReply(email *mail.Email, intent, tone string) tea.Cmd {
+	client := a.draftClient
+	provider := client.Provider()
+	var me string
+	if account := a.currentAccount(); account != nil {
+		me = account.Credentials.Email
+	}
+	body := email.Body
+	if body == "" {
+		body = email.Snippet
+	}
+	prompt := ai.DraftReplyPrompt(email.From, email.Subject, body, intent, tone, me)
+
+	return func() tea.Msg {
+		draft, err := client.Call(prompt)
+		if err != nil {
+			return draftReplyErrorMsg{err: err}
+		}
+		return draftReplyMsg{email: email, body: draft, provider: provider}
+	}
+}
+
+// renderDraftDialog renders the intent and tone prompt of a drafted reply
+func (a App) renderDraftDialog() string {
+	return components.RenderCentered(a.width, a.height,
+		components.RenderDraftDialog(a.draftInput.View(), ai.Tones, a.draftTone, a.draftClient.Provider()))
+}
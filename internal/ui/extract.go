@@ -0,0 +1,385 @@
+package ui
+
+import (
+	"fmt"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"maily/internal/ai"
+	"maily/internal/calendar"
+	"maily/internal/mail"
+	"maily/internal/ui/components"
+	"maily/internal/ui/utils"
+)
+
+// extractedEvent is an event found in an email and whether it will be
+// added to the calendar
+type extractedEvent struct {
+	event    calendar.Event
+	selected bool
+}
+
+type eventsExtractedMsg struct {
+	events   []extractedEvent
+	provider string
+}
+
+type extractErrorMsg struct {
+	err error
+}
+
+type extractedEventsCreatedMsg struct {
+	created int
+	invited bool
+}
+
+// Fields of the extracted event form
+const (
+	extractTitle = iota
+	extractDate
+	extractStart
+	extractEnd
+	extractLocation
+	extractAttendees
+	extractFieldCount
+)
+
+var extractFieldLabels = [extractFieldCount]string{"Title", "Date", "Start", "End", "Location", "Attendees"}
+
+// extractDialog lists the events extracted from the open email so they can
+// be picked, edited and added to the calendar
+type extractDialog struct {
+	events   []extractedEvent
+	cursor   int
+	invite   bool // send invitations to the attendees
+	provider string
+	editing  bool
+	fields   [extractFieldCount]textinput.Model
+	focus    int
+	err      string
+}
+
+// extractEvents asks the AI for the events in email
+func (a *App) extractEvents(email *mail.Email) tea.Cmd {
+	client := a.extractClient
+	provider := client.Provider()
+	body := email.Body
+	if body == "" {
+		body = email.Snippet
+	}
+	from, subject, sent := email.From, email.Subject, email.Date
+
+	return func() tea.Msg {
+		parsed, err := client.ExtractEvents(from, subject, body, sent, time.Now())
+		if err != nil {
+			return extractErrorMsg{err: err}
+		}
+		var events []extractedEvent
+		for _, p := range parsed {
+			if event, err := extractedToEvent(p, from, subject); err == nil {
+				events = append(events, extractedEvent{event: event, selected: true})
+			}
+		}
+		return eventsExtractedMsg{events: events, provider: provider}
+	}
+}
+
+// extractedToEvent turns an event parsed from an email into a calendar
+// event. Attendees are kept with the email's sender as organizer, so
+// adding the event invites nobody unless the user asks to.
+func extractedToEvent(p ai.ParsedEvent, from, subject string) (calendar.Event, error) {
+	start, err := p.GetStartTime()
+	if err != nil {
+		return calendar.Event{}, err
+	}
+	end, err := p.GetEndTime()
+	if err != nil {
+		return calendar.Event{}, err
+	}
+	recurrence, err := calendar.ParseRecurrence(p.Recurrence)
+	if err != nil {
+		return calendar.Event{}, err
+	}
+	zone, start, end, err := calendar.ParsedTimeZone("", p.TimeZone, start, end)
+	if err != nil {
+		return calendar.Event{}, err
+	}
+	attendees, err := parseAttendees(strings.Join(p.Attendees, ", "), nil)
+	if err != nil {
+		return calendar.Event{}, err
+	}
+
+	event := calendar.Event{
+		Title:      p.Title,
+		StartTime:  start,
+		EndTime:    end,
+		TimeZone:   zone,
+		Location:   p.Location,
+		Notes:      fmt.Sprintf("From the email %q from %s", subject, from),
+		Recurrence: recurrence,
+		Attendees:  attendees,
+	}
+	if p.AlarmSpecified {
+		event.AlarmMinutesBefore = p.AlarmMinutesBefore
+	}
+	if len(attendees) > 0 {
+		if addr, err := netmail.ParseAddress(from); err == nil {
+			event.Organizer = calendar.Attendee{Email: addr.Address, Name: addr.Name}
+		}
+	}
+	return event, nil
+}
+
+// openExtract starts extracting events from the selected email
+func (a *App) openExtract() tea.Cmd {
+	if !a.extractClient.Available() {
+		a.statusMsg = "No AI CLI found (install claude, codex, gemini, vibe, or ollama)"
+		return nil
+	}
+	email := a.mailList.SelectedEmail()
+	if email == nil {
+		return nil
+	}
+	a.state = stateLoading
+	a.statusMsg = "Finding events with " + a.extractClient.Provider() + "..."
+	return tea.Batch(a.spinner.Tick, a.extractEvents(email))
+}
+
+// updateExtractDialog handles keys while the extracted events are shown
+func (a *App) updateExtractDialog(msg tea.KeyMsg) tea.Cmd {
+	d := a.extract
+	if d.editing {
+		return d.updateForm(msg)
+	}
+
+	switch msg.String() {
+	case "esc", "q":
+		a.extract = nil
+	case "up", "k":
+		if d.cursor > 0 {
+			d.cursor--
+		}
+	case "down", "j":
+		if d.cursor < len(d.events)-1 {
+			d.cursor++
+		}
+	case " ":
+		d.events[d.cursor].selected = !d.events[d.cursor].selected
+	case "i":
+		d.invite = !d.invite
+	case "e":
+		return d.edit()
+	case "enter":
+		var events []calendar.Event
+		for _, e := range d.events {
+			if e.selected {
+				events = append(events, e.event)
+			}
+		}
+		if len(events) == 0 {
+			d.err = "No events selected"
+			return nil
+		}
+		invite := d.invite
+		a.extract = nil
+		a.state = stateLoading
+		a.statusMsg = fmt.Sprintf("Adding %d events to your calendar...", len(events))
+		return tea.Batch(a.spinner.Tick, a.createExtractedEvents(events, invite))
+	}
+	return nil
+}
+
+// edit opens the form for the event under the cursor
+func (d *extractDialog) edit() tea.Cmd {
+	event := d.events[d.cursor].event
+	values := [extractFieldCount]string{
+		extractTitle:     event.Title,
+		extractDate:      event.StartTime.Format("2006-01-02"),
+		extractStart:     event.StartTime.Format("15:04"),
+		extractEnd:       event.EndTime.Format("15:04"),
+		extractLocation:  event.Location,
+		extractAttendees: formatAttendees(event.Attendees),
+	}
+	for i := range d.fields {
+		ti := textinput.New()
+		ti.CharLimit = 200
+		ti.Width = 40
+		ti.SetValue(values[i])
+		d.fields[i] = ti
+	}
+	d.editing = true
+	d.focus = 0
+	d.err = ""
+	return d.fields[0].Focus()
+}
+
+// updateForm handles keys while an event is edited
+func (d *extractDialog) updateForm(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		d.editing = false
+		d.err = ""
+		return nil
+	case "tab", "down":
+		d.fields[d.focus].Blur()
+		d.focus = (d.focus + 1) % extractFieldCount
+		return d.fields[d.focus].Focus()
+	case "shift+tab", "up":
+		d.fields[d.focus].Blur()
+		d.focus = (d.focus + extractFieldCount - 1) % extractFieldCount
+		return d.fields[d.focus].Focus()
+	case "enter":
+		event, err := d.formEvent(d.events[d.cursor].event)
+		if err != nil {
+			d.err = err.Error()
+			return nil
+		}
+		d.events[d.cursor] = extractedEvent{event: event, selected: true}
+		d.editing = false
+		d.err = ""
+		return nil
+	}
+	var cmd tea.Cmd
+	d.fields[d.focus], cmd = d.fields[d.focus].Update(msg)
+	return cmd
+}
+
+// formEvent returns event changed by the form
+func (d *extractDialog) formEvent(event calendar.Event) (calendar.Event, error) {
+	title := strings.TrimSpace(d.fields[extractTitle].Value())
+	if title == "" {
+		return event, fmt.Errorf("title is required")
+	}
+	date, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(d.fields[extractDate].Value()), time.Local)
+	if err != nil {
+		return event, fmt.Errorf("invalid date, use YYYY-MM-DD")
+	}
+	startTime, err := time.Parse("15:04", strings.TrimSpace(d.fields[extractStart].Value()))
+	if err != nil {
+		return event, fmt.Errorf("invalid start time, use HH:MM")
+	}
+	endTime, err := time.Parse("15:04", strings.TrimSpace(d.fields[extractEnd].Value()))
+	if err != nil {
+		return event, fmt.Errorf("invalid end time, use HH:MM")
+	}
+	if !endTime.After(startTime) {
+		return event, fmt.Errorf("end time must be after start time")
+	}
+	attendees, err := parseAttendees(d.fields[extractAttendees].Value(), event.Attendees)
+	if err != nil {
+		return event, err
+	}
+
+	event.Title = title
+	event.StartTime = time.Date(date.Year(), date.Month(), date.Day(), startTime.Hour(), startTime.Minute(), 0, 0, time.Local)
+	event.EndTime = time.Date(date.Year(), date.Month(), date.Day(), endTime.Hour(), endTime.Minute(), 0, 0, time.Local)
+	event.Location = strings.TrimSpace(d.fields[extractLocation].Value())
+	event.Attendees = attendees
+	if len(attendees) == 0 {
+		event.Organizer = calendar.Attendee{}
+	}
+	return event, nil
+}
+
+// createExtractedEvents adds events to the calendar of the current account.
+// With invite, the current account organizes them and their attendees are
+// sent invitations; without it, nobody hears about them, so calendars that
+// invite attendees themselves get the events without attendees.
+func (a *App) createExtractedEvents(events []calendar.Event, invite bool) tea.Cmd {
+	account := a.currentAccount()
+	store := a.store
+
+	return func() tea.Msg {
+		if account == nil {
+			return extractErrorMsg{err: fmt.Errorf("no account configured")}
+		}
+		client, err := accountCalendar(account)
+		if err != nil {
+			return extractErrorMsg{err: err}
+		}
+
+		for i, event := range events {
+			switch {
+			case invite:
+				event.Organizer = calendar.Attendee{}
+				prepareMeeting(account, &event)
+			case calendar.SendsInvitations(client):
+				event.Attendees = nil
+				event.Organizer = calendar.Attendee{}
+			}
+			if _, err := client.CreateEvent(event); err != nil {
+				return extractErrorMsg{err: fmt.Errorf("added %d of %d events: %w", i, len(events), err)}
+			}
+			if invite {
+				if err := notifyAttendees(store, client, calendar.MethodRequest, event, calendar.SpanThisEvent); err != nil {
+					return extractErrorMsg{err: fmt.Errorf("%q added, but sending invitations failed: %w", event.Title, err)}
+				}
+			}
+		}
+		return extractedEventsCreatedMsg{created: len(events), invited: invite}
+	}
+}
+
+// renderExtractDialog renders the extracted events, or the form of the one
+// being edited
+func (a App) renderExtractDialog() string {
+	d := a.extract
+	title := components.DialogTitleStyle.Foreground(components.Primary).Render("Add to Calendar")
+	errStyle := lipgloss.NewStyle().Foreground(components.Warning)
+
+	var b strings.Builder
+	if d.editing {
+		for i, field := range d.fields {
+			b.WriteString(components.HelpDescStyle.Render(fmt.Sprintf("%-10s", extractFieldLabels[i])) + field.View() + "\n")
+		}
+		if d.err != "" {
+			b.WriteString("\n" + errStyle.Render(d.err) + "\n")
+		}
+		hint := components.DialogHintStyle.Render("Tab next field, Enter to save, Esc to go back\nDate is YYYY-MM-DD, times HH:MM; attendees are comma-separated")
+		return components.RenderCentered(a.width, a.height,
+			components.DialogStyle.BorderForeground(components.Primary).Render(
+				lipgloss.JoinVertical(lipgloss.Left, title, "", b.String(), hint)))
+	}
+
+	for i, e := range d.events {
+		check := "[ ]"
+		if e.selected {
+			check = "[x]"
+		}
+		when := e.event.StartTime.Format("Mon Jan 2 15:04") + "-" + e.event.EndTime.Format("15:04")
+		line := utils.TruncateStr(fmt.Sprintf("%s %s  %s", check, when, e.event.Title), 60)
+		if i == d.cursor {
+			b.WriteString(components.HelpKeyStyle.Render("▸ "+line) + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+		var details []string
+		if e.event.Location != "" {
+			details = append(details, e.event.Location)
+		}
+		if len(e.event.Attendees) > 0 {
+			details = append(details, formatAttendees(e.event.Attendees))
+		}
+		if len(details) > 0 {
+			b.WriteString(components.HelpDescStyle.Render("      "+utils.TruncateStr(strings.Join(details, " · "), 56)) + "\n")
+		}
+	}
+	if d.err != "" {
+		b.WriteString("\n" + errStyle.Render(d.err) + "\n")
+	}
+
+	invite := "Attendees are saved on the events; no invitations are sent (i to send them)"
+	if d.invite {
+		invite = "Invitations will be sent to the attendees (i to not send them)"
+	}
+	hint := components.DialogHintStyle.Render("Space to pick, e to edit, Enter to add, Esc to cancel\n" + invite + "\nFound by " + d.provider)
+
+	return components.RenderCentered(a.width, a.height,
+		components.DialogStyle.BorderForeground(components.Primary).Render(
+			lipgloss.JoinVertical(lipgloss.Left, title, "", b.String(), hint)))
+}
@@ -0,0 +1,344 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"maily/internal/auth"
+	"maily/internal/calendar"
+	"maily/internal/mail"
+	"maily/internal/ui/components"
+)
+
+type invitationRespondedMsg struct {
+	status string
+	title  string
+}
+
+type invitationErrorMsg struct {
+	err error
+}
+
+// parseInvitation returns the meeting invitation carried by email, nil if
+// there is none
+func parseInvitation(email mail.Email) *calendar.Invitation {
+	if email.Calendar == "" {
+		return nil
+	}
+	inv, err := calendar.ParseInvitation([]byte(email.Calendar))
+	if err != nil {
+		return nil
+	}
+	switch inv.Method {
+	case calendar.MethodRequest, calendar.MethodCancel:
+		return inv
+	}
+	return nil
+}
+
+// accountCalendar returns the calendar of account (where its invitations
+// go and its free time is looked up): the account's own calendar, or the
+// default one if it has none
+func accountCalendar(account *auth.Account) (calendar.Client, error) {
+	client, err := calendar.NewAccountClient(account)
+	if errors.Is(err, calendar.ErrNotSupported) {
+		return calendar.NewClient()
+	}
+	return client, err
+}
+
+var invitationReplySubjects = map[string]string{
+	calendar.StatusAccepted:  "Accepted",
+	calendar.StatusTentative: "Tentative",
+	calendar.StatusDeclined:  "Declined",
+}
+
+// respondToInvitation updates the calendar for the answer and sends the
+// organizer an iTIP REPLY with it
+func (a *App) respondToInvitation(inv *calendar.Invitation, status string) tea.Cmd {
+	account := a.currentAccount()
+	if account == nil {
+		return func() tea.Msg {
+			return invitationErrorMsg{err: fmt.Errorf("no account configured")}
+		}
+	}
+
+	return func() tea.Msg {
+		if inv.Event.Organizer.Email == "" {
+			return invitationErrorMsg{err: fmt.Errorf("invitation has no organizer to reply to")}
+		}
+		sender, ok := mail.NewSender(&account.Credentials).(mail.CalendarSender)
+		if !ok {
+			return invitationErrorMsg{err: fmt.Errorf("%s cannot send invitation replies", account.Credentials.Email)}
+		}
+
+		client, err := accountCalendar(account)
+		if err != nil {
+			return invitationErrorMsg{err: err}
+		}
+		if status == calendar.StatusDeclined {
+			err = calendar.CancelInvitation(client, inv)
+			if errors.Is(err, calendar.ErrNotFound) {
+				err = nil
+			}
+		} else {
+			_, err = calendar.SaveInvitation(client, inv)
+		}
+		if err != nil {
+			return invitationErrorMsg{err: err}
+		}
+
+		reply, err := inv.Reply(account.Credentials.Email, status)
+		if err != nil {
+			return invitationErrorMsg{err: err}
+		}
+		answer := invitationReplySubjects[status]
+		subject := answer + ": " + inv.Event.Title
+		body := fmt.Sprintf("%s: %s has answered %q.\n", answer, account.Credentials.Email, inv.Event.Title)
+		if err := sender.SendCalendar([]string{inv.Event.Organizer.Email}, subject, body, reply, calendar.MethodReply); err != nil {
+			return invitationErrorMsg{err: err}
+		}
+		return invitationRespondedMsg{status: status, title: inv.Event.Title}
+	}
+}
+
+// removeCancelledEvent deletes the event of a METHOD:CANCEL message
+func (a *App) removeCancelledEvent(inv *calendar.Invitation) tea.Cmd {
+	account := a.currentAccount()
+	if account == nil {
+		return func() tea.Msg {
+			return invitationErrorMsg{err: fmt.Errorf("no account configured")}
+		}
+	}
+
+	return func() tea.Msg {
+		client, err := accountCalendar(account)
+		if err != nil {
+			return invitationErrorMsg{err: err}
+		}
+		if err := calendar.CancelInvitation(client, inv); err != nil {
+			if errors.Is(err, calendar.ErrNotFound) {
+				err = fmt.Errorf("%q is not in your calendar", inv.Event.Title)
+			}
+			return invitationErrorMsg{err: err}
+		}
+		return invitationRespondedMsg{status: calendar.MethodCancel, title: inv.Event.Title}
+	}
+}
+
+var invitationStatusMarks = map[string]string{
+	calendar.StatusAccepted:  "✓",
+	calendar.StatusTentative: "?",
+	calendar.StatusDeclined:  "✗",
+}
+
+// renderInvitation renders the invitation card shown above the message body
+func renderInvitation(inv *calendar.Invitation, self string, width int) string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(components.Primary)
+	labelStyle := lipgloss.NewStyle().Width(12).Foreground(components.Muted)
+	hintStyle := lipgloss.NewStyle().Foreground(components.TextDim)
+
+	heading := "Invitation"
+	if inv.Method == calendar.MethodCancel {
+		heading = "Cancelled"
+	}
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(heading + ": " + inv.Event.Title))
+	b.WriteString("\n\n")
+
+	row := func(label, value string) {
+		if value != "" {
+			b.WriteString(labelStyle.Render(label) + value + "\n")
+		}
+	}
+
+	e := inv.Event
+	when := e.StartTime.Format("Monday, Jan 2, 2006")
+	if e.AllDay {
+		if days := int(e.EndTime.Sub(e.StartTime).Hours()/24 + 0.5); days > 1 {
+			when += " – " + e.StartTime.AddDate(0, 0, days-1).Format("Monday, Jan 2")
+		}
+	} else {
+		when += fmt.Sprintf("  %s - %s", e.StartTime.Format("3:04 PM"), e.EndTime.Format("3:04 PM"))
+		if zoned := originalTimes(e); zoned != "" {
+			when += " (" + zoned + ")"
+		}
+	}
+	row("When", when)
+	if e.IsRecurring() {
+		row("Repeats", calendar.DescribeRecurrence(e.Recurrence))
+	}
+	row("Where", e.Location)
+	row("Organizer", inv.Event.Organizer.String())
+	for i, attendee := range inv.Event.Attendees {
+		label := ""
+		if i == 0 {
+			label = "Attendees"
+		}
+		mark, ok := invitationStatusMarks[attendee.Status]
+		if !ok {
+			mark = "·"
+		}
+		row(label, mark+" "+attendee.String())
+	}
+
+	if me, ok := inv.Event.Attendee(self); ok && inv.Method == calendar.MethodRequest && me.Status != calendar.StatusNeedsAction {
+		b.WriteString("\n" + hintStyle.Render("Your answer: "+strings.ToLower(me.Status)))
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(components.Primary).
+		Padding(0, 1).
+		Width(width).
+		Render(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// parseAttendees parses a comma-separated list of addresses, keeping the
+// answers of people who were already invited
+func parseAttendees(input string, existing []calendar.Attendee) ([]calendar.Attendee, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+	addrs, err := netmail.ParseAddressList(input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attendees: %w", err)
+	}
+	attendees := make([]calendar.Attendee, len(addrs))
+	for i, addr := range addrs {
+		attendees[i] = calendar.Attendee{Email: addr.Address, Name: addr.Name}
+		for _, a := range existing {
+			if strings.EqualFold(a.Email, addr.Address) {
+				attendees[i].Status = a.Status
+			}
+		}
+	}
+	return attendees, nil
+}
+
+// formatAttendees is the inverse of parseAttendees
+func formatAttendees(attendees []calendar.Attendee) string {
+	list := make([]string, len(attendees))
+	for i, a := range attendees {
+		list[i] = a.String()
+	}
+	return strings.Join(list, ", ")
+}
+
+// removedAttendees returns the attendees of before that are not in after
+func removedAttendees(before, after []calendar.Attendee) []calendar.Attendee {
+	var removed []calendar.Attendee
+	for _, a := range before {
+		found := false
+		for _, b := range after {
+			if strings.EqualFold(a.Email, b.Email) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			removed = append(removed, a)
+		}
+	}
+	return removed
+}
+
+// prepareMeeting gives an event with attendees the UID and organizer its
+// invitations need: organizer is the account new meetings are sent from
+func prepareMeeting(organizer *auth.Account, event *calendar.Event) {
+	if len(event.Attendees) == 0 || organizer == nil {
+		return
+	}
+	if event.UID == "" {
+		event.UID = calendar.NewUID()
+	}
+	if event.Organizer.Email == "" {
+		event.Organizer = calendar.Attendee{Email: organizer.Credentials.Email}
+	}
+}
+
+// organizerAccount returns the account that organizes event, nil if
+// someone else does
+func organizerAccount(store *auth.AccountStore, event calendar.Event) *auth.Account {
+	if store == nil {
+		return nil
+	}
+	for i, account := range store.Accounts {
+		if strings.EqualFold(account.Credentials.Email, event.Organizer.Email) {
+			return &store.Accounts[i]
+		}
+	}
+	return nil
+}
+
+// notifyAttendees emails event's attendees an iTIP REQUEST after it was
+// created or changed, or a CANCEL after it was deleted (for an occurrence,
+// span says whether later ones went too). Events without attendees,
+// meetings organized by someone else and calendars whose server invites
+// attendees itself need no mail.
+func notifyAttendees(store *auth.AccountStore, client calendar.Client, method string, event calendar.Event, span calendar.Span) error {
+	if len(event.Attendees) == 0 || calendar.SendsInvitations(client) {
+		return nil
+	}
+	account := organizerAccount(store, event)
+	if account == nil {
+		return nil
+	}
+	sender, ok := mail.NewSender(&account.Credentials).(mail.CalendarSender)
+	if !ok {
+		return fmt.Errorf("%s cannot send invitations", account.Credentials.Email)
+	}
+
+	var ics []byte
+	var err error
+	var subject string
+	switch {
+	case method == calendar.MethodCancel:
+		ics, err = calendar.NewCancel(event, span)
+		subject = "Cancelled: "
+	case event.Sequence > 0:
+		ics, err = calendar.NewRequest(event)
+		subject = "Updated invitation: "
+	default:
+		ics, err = calendar.NewRequest(event)
+		subject = "Invitation: "
+	}
+	if err != nil {
+		return err
+	}
+	when := event.StartTime.Format("Mon Jan 2, 2006 3:04 PM")
+	if event.AllDay {
+		when = event.StartTime.Format("Mon Jan 2, 2006")
+	}
+	subject += event.Title + " @ " + when
+
+	var body strings.Builder
+	body.WriteString(event.Title + "\n\n")
+	body.WriteString("When: " + when + "\n")
+	if event.IsRecurring() && event.OccurrenceDate.IsZero() {
+		body.WriteString("Repeats: " + calendar.DescribeRecurrence(event.Recurrence) + "\n")
+	}
+	if event.Location != "" {
+		body.WriteString("Where: " + event.Location + "\n")
+	}
+	body.WriteString("Organizer: " + event.Organizer.String() + "\n")
+	if event.Notes != "" {
+		body.WriteString("\n" + event.Notes + "\n")
+	}
+
+	to := make([]string, 0, len(event.Attendees))
+	for _, a := range event.Attendees {
+		if !strings.EqualFold(a.Email, account.Credentials.Email) {
+			to = append(to, a.Email)
+		}
+	}
+	if len(to) == 0 {
+		return nil
+	}
+	return sender.SendCalendar(to, subject, body.String(), ics, method)
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"unicode"
@@ -9,8 +10,10 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/oauth2"
 
 	"maily/internal/auth"
+	"maily/internal/graph"
 	"maily/internal/mail"
 	"maily/internal/ui/components"
 )
@@ -20,6 +23,7 @@ type loginState int
 const (
 	loginStateInput loginState = iota
 	loginStateVerifying
+	loginStateDeviceCode // Outlook: waiting for the user to enter the code
 	loginStateSuccess
 	loginStateError
 )
@@ -29,12 +33,14 @@ type loginField int
 const (
 	fieldEmail loginField = iota
 	fieldPassword
+	fieldServer // JMAP only
 )
 
 type LoginApp struct {
 	provider     string
 	emailInput   textinput.Model
 	passwordInput textinput.Model
+	serverInput  textinput.Model
 	focusedField loginField
 	state        loginState
 	spinner      spinner.Model
@@ -42,6 +48,7 @@ type LoginApp struct {
 	height       int
 	err          error
 	account      *auth.Account
+	deviceCode   *oauth2.DeviceAuthResponse
 }
 
 type verifySuccessMsg struct {
@@ -52,6 +59,11 @@ type verifyErrorMsg struct {
 	err error
 }
 
+type deviceCodeMsg struct {
+	cfg  *oauth2.Config
+	resp *oauth2.DeviceAuthResponse
+}
+
 func NewLoginApp(provider string) LoginApp {
 	emailInput := textinput.New()
 	emailInput.Focus()
@@ -73,21 +85,81 @@ func NewLoginApp(provider string) LoginApp {
 	passwordInput.CharLimit = 100
 	passwordInput.Width = 40
 
+	serverInput := textinput.New()
+	serverInput.Placeholder = "Leave empty for Fastmail"
+	serverInput.CharLimit = 200
+	serverInput.Width = 40
+
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = components.SpinnerStyle
 
-	return LoginApp{
+	a := LoginApp{
 		provider:      provider,
 		emailInput:    emailInput,
 		passwordInput: passwordInput,
-		focusedField:  fieldEmail,
+		serverInput:   serverInput,
 		state:         loginStateInput,
 		spinner:       s,
 	}
+
+	switch provider {
+	case "jmap":
+		passwordInput.Placeholder = "App password or API token"
+		a.passwordInput = passwordInput
+	case "outlook":
+		// Outlook signs in through the browser, there is no form
+		a.state = loginStateVerifying
+	}
+	a.focus(a.fields()[0])
+	return a
+}
+
+// fields returns the form fields for the provider, in tab order
+func (a LoginApp) fields() []loginField {
+	if a.provider == "jmap" {
+		return []loginField{fieldServer, fieldEmail, fieldPassword}
+	}
+	return []loginField{fieldEmail, fieldPassword}
+}
+
+func (a *LoginApp) focus(field loginField) {
+	a.focusedField = field
+	a.emailInput.Blur()
+	a.passwordInput.Blur()
+	a.serverInput.Blur()
+	switch field {
+	case fieldEmail:
+		a.emailInput.Focus()
+	case fieldPassword:
+		a.passwordInput.Focus()
+	case fieldServer:
+		a.serverInput.Focus()
+	}
+}
+
+// moveFocus moves focus by delta fields, wrapping around if wrap is set
+func (a *LoginApp) moveFocus(delta int, wrap bool) {
+	fields := a.fields()
+	idx := 0
+	for i, f := range fields {
+		if f == a.focusedField {
+			idx = i
+		}
+	}
+	idx += delta
+	if wrap {
+		idx = (idx + len(fields)) % len(fields)
+	}
+	if idx >= 0 && idx < len(fields) {
+		a.focus(fields[idx])
+	}
 }
 
 func (a LoginApp) Init() tea.Cmd {
+	if a.provider == "outlook" {
+		return tea.Batch(a.spinner.Tick, requestDeviceCode())
+	}
 	return textinput.Blink
 }
 
@@ -104,44 +176,28 @@ func (a LoginApp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			case "tab":
 				// Circular tab behavior
-				if a.focusedField == fieldEmail {
-					a.focusedField = fieldPassword
-					a.emailInput.Blur()
-					a.passwordInput.Focus()
-				} else {
-					a.focusedField = fieldEmail
-					a.passwordInput.Blur()
-					a.emailInput.Focus()
-				}
+				a.moveFocus(1, true)
 
 			case "up":
-				if a.focusedField == fieldPassword {
-					a.focusedField = fieldEmail
-					a.passwordInput.Blur()
-					a.emailInput.Focus()
-				}
+				a.moveFocus(-1, false)
 
 			case "down":
-				if a.focusedField == fieldEmail {
-					a.focusedField = fieldPassword
-					a.emailInput.Blur()
-					a.passwordInput.Focus()
-				}
+				a.moveFocus(1, false)
 
 			case "enter":
-				switch a.focusedField {
-				case fieldEmail:
-					a.focusedField = fieldPassword
-					a.emailInput.Blur()
-					a.passwordInput.Focus()
-				case fieldPassword:
-					if a.emailInput.Value() != "" && a.passwordInput.Value() != "" {
-						a.state = loginStateVerifying
-						return a, tea.Batch(a.spinner.Tick, a.verifyCredentials())
-					}
+				if a.focusedField != fieldPassword {
+					a.moveFocus(1, false)
+				} else if a.emailInput.Value() != "" && a.passwordInput.Value() != "" {
+					a.state = loginStateVerifying
+					return a, tea.Batch(a.spinner.Tick, a.verifyCredentials())
 				}
 			}
 
+		case loginStateVerifying, loginStateDeviceCode:
+			if msg.String() == "ctrl+c" || msg.String() == "esc" {
+				return a, tea.Quit
+			}
+
 		case loginStateSuccess, loginStateError:
 			if msg.String() == "enter" || msg.String() == "q" || msg.String() == "esc" {
 				return a, tea.Quit
@@ -157,6 +213,11 @@ func (a LoginApp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.spinner, cmd = a.spinner.Update(msg)
 		cmds = append(cmds, cmd)
 
+	case deviceCodeMsg:
+		a.state = loginStateDeviceCode
+		a.deviceCode = msg.resp
+		return a, pollDeviceCode(msg.cfg, msg.resp)
+
 	case verifySuccessMsg:
 		a.state = loginStateSuccess
 		a.account = msg.account
@@ -170,13 +231,15 @@ func (a LoginApp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// Update text inputs
 	if a.state == loginStateInput {
 		var cmd tea.Cmd
-		if a.focusedField == fieldEmail {
+		switch a.focusedField {
+		case fieldEmail:
 			a.emailInput, cmd = a.emailInput.Update(msg)
-			cmds = append(cmds, cmd)
-		} else {
+		case fieldPassword:
 			a.passwordInput, cmd = a.passwordInput.Update(msg)
-			cmds = append(cmds, cmd)
+		case fieldServer:
+			a.serverInput, cmd = a.serverInput.Update(msg)
 		}
+		cmds = append(cmds, cmd)
 	}
 
 	return a, tea.Batch(cmds...)
@@ -185,6 +248,7 @@ func (a LoginApp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (a LoginApp) verifyCredentials() tea.Cmd {
 	email := a.emailInput.Value()
 	password := a.passwordInput.Value()
+	server := a.serverInput.Value()
 	provider := a.provider
 
 	// Clean password (remove all whitespace)
@@ -201,6 +265,8 @@ func (a LoginApp) verifyCredentials() tea.Cmd {
 		switch provider {
 		case "yahoo":
 			creds = auth.YahooCredentials(email, password)
+		case "jmap":
+			creds = auth.JMAPCredentials(email, password, server)
 		default:
 			creds = auth.GmailCredentials(email, password)
 		}
@@ -212,7 +278,7 @@ func (a LoginApp) verifyCredentials() tea.Cmd {
 		}
 
 		// Test connection
-		client, err := mail.NewIMAPClient(&creds)
+		client, err := mail.Dial(&creds)
 		if err != nil {
 			return verifyErrorMsg{err: err}
 		}
@@ -233,6 +299,63 @@ func (a LoginApp) verifyCredentials() tea.Cmd {
 	}
 }
 
+// requestDeviceCode starts the Microsoft device-code flow
+func requestDeviceCode() tea.Cmd {
+	return func() tea.Msg {
+		cfg, err := auth.OutlookOAuthConfig("")
+		if err != nil {
+			return verifyErrorMsg{err: err}
+		}
+		resp, err := cfg.DeviceAuth(context.Background())
+		if err != nil {
+			return verifyErrorMsg{err: err}
+		}
+		return deviceCodeMsg{cfg: cfg, resp: resp}
+	}
+}
+
+// pollDeviceCode waits for the user to approve the code, then looks up the
+// mailbox address and saves the account
+func pollDeviceCode(cfg *oauth2.Config, resp *oauth2.DeviceAuthResponse) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		token, err := cfg.DeviceAccessToken(ctx, resp)
+		if err != nil {
+			return verifyErrorMsg{err: err}
+		}
+
+		var me struct {
+			Mail              string `json:"mail"`
+			UserPrincipalName string `json:"userPrincipalName"`
+		}
+		api := graph.NewWithTokenSource(cfg.TokenSource(ctx, token))
+		if err := api.Get("/me?$select=mail,userPrincipalName", &me); err != nil {
+			return verifyErrorMsg{err: err}
+		}
+		email := me.Mail
+		if email == "" {
+			email = me.UserPrincipalName
+		}
+
+		account := &auth.Account{
+			Name:        email,
+			Provider:    "outlook",
+			Credentials: auth.OutlookCredentials(email, cfg.ClientID, token),
+		}
+
+		store, err := auth.LoadAccountStore()
+		if err != nil {
+			return verifyErrorMsg{err: err}
+		}
+		store.AddAccount(*account)
+		if err := store.Save(); err != nil {
+			return verifyErrorMsg{err: err}
+		}
+
+		return verifySuccessMsg{account: account}
+	}
+}
+
 func (a LoginApp) View() string {
 	if a.width == 0 {
 		return "Loading..."
@@ -245,14 +368,21 @@ func (a LoginApp) View() string {
 		content = a.renderInputForm()
 
 	case loginStateVerifying:
+		text := "Verifying credentials..."
+		if a.provider == "outlook" {
+			text = "Requesting sign-in code..."
+		}
 		content = lipgloss.Place(
 			a.width,
 			a.height-2,
 			lipgloss.Center,
 			lipgloss.Center,
-			fmt.Sprintf("%s Verifying credentials...", a.spinner.View()),
+			fmt.Sprintf("%s %s", a.spinner.View(), text),
 		)
 
+	case loginStateDeviceCode:
+		content = a.renderDeviceCode()
+
 	case loginStateSuccess:
 		successMsg := lipgloss.NewStyle().
 			Bold(true).
@@ -281,6 +411,10 @@ func (a LoginApp) View() string {
 		switch a.provider {
 		case "yahoo":
 			hintText = "\n\nMake sure you:\n• Used an App Password (not your regular password)\n• Have 2-Step Verification enabled\n\nPress Enter to exit."
+		case "outlook":
+			hintText = "\n\nMake sure you:\n• Entered the code before it expired\n• Consented to mail and calendar access\n\nPress Enter to exit."
+		case "jmap":
+			hintText = "\n\nMake sure you:\n• Entered the right server (or left it empty for Fastmail)\n• Used an app password or API token with mail access\n\nPress Enter to exit."
 		default:
 			hintText = "\n\nMake sure you:\n• Used an App Password (not your regular password)\n• Have IMAP enabled in Gmail settings\n\nPress Enter to exit."
 		}
@@ -329,6 +463,9 @@ func (a LoginApp) renderInputForm() string {
 	case "yahoo":
 		title = titleStyle.Render("Yahoo Mail Login")
 		instructions = hintStyle.Render("You need an App Password to continue.\n\n1. Go to: login.yahoo.com/account/security\n2. Click 'Generate app password'\n3. Select 'Other App' and generate\n")
+	case "jmap":
+		title = titleStyle.Render("JMAP Login")
+		instructions = hintStyle.Render("Works with Fastmail, Stalwart and other JMAP servers.\n\nFastmail: create an app password or API token under\nSettings → Privacy & Security → Integrations\n")
 	default:
 		title = titleStyle.Render("Gmail Login")
 		instructions = hintStyle.Render("You need an App Password to continue.\n\n1. Enable 2-Step Verification (if not done)\n2. Go to: myaccount.google.com/apppasswords\n3. Type a name and click Create\n")
@@ -346,30 +483,77 @@ func (a LoginApp) renderInputForm() string {
 	)
 
 	// Password field
+	passwordLabelText := "App Password:"
+	if a.provider == "jmap" {
+		passwordLabelText = "Password:"
+	}
 	passwordLabel := labelStyle
 	if a.focusedField == fieldPassword {
 		passwordLabel = focusedLabelStyle
 	}
 	passwordRow := lipgloss.JoinHorizontal(
 		lipgloss.Left,
-		passwordLabel.Render("App Password:"),
+		passwordLabel.Render(passwordLabelText),
 		a.passwordInput.View(),
 	)
 
 	// Hint
 	hint := hintStyle.Render("\nTab to switch fields • Enter to submit • Esc to cancel")
 
-	form := lipgloss.JoinVertical(
-		lipgloss.Left,
-		title,
-		"",
-		instructions,
+	rows := []string{title, "", instructions, ""}
+	if a.provider == "jmap" {
+		serverLabel := labelStyle
+		if a.focusedField == fieldServer {
+			serverLabel = focusedLabelStyle
+		}
+		serverRow := lipgloss.JoinHorizontal(
+			lipgloss.Left,
+			serverLabel.Render("Server:"),
+			a.serverInput.View(),
+		)
+		rows = append(rows, serverRow, "")
+	}
+	rows = append(rows, emailRow, "", passwordRow, "", hint)
+
+	form := lipgloss.JoinVertical(lipgloss.Left, rows...)
+
+	return lipgloss.Place(
+		a.width,
+		a.height-2,
+		lipgloss.Center,
+		lipgloss.Center,
+		boxStyle.Render(form),
+	)
+}
+
+func (a LoginApp) renderDeviceCode() string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("#7C3AED")).
+		MarginBottom(1)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#9CA3AF"))
+
+	codeStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("#F9FAFB"))
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#7C3AED")).
+		Padding(1, 3)
+
+	form := lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("Outlook / Microsoft 365 Login"),
 		"",
-		emailRow,
+		hintStyle.Render("1. Open: ")+codeStyle.Render(a.deviceCode.VerificationURI),
+		hintStyle.Render("2. Enter the code: ")+codeStyle.Render(a.deviceCode.UserCode),
+		hintStyle.Render("3. Sign in and allow mail and calendar access"),
 		"",
-		passwordRow,
+		fmt.Sprintf("%s Waiting for sign-in...", a.spinner.View()),
 		"",
-		hint,
+		hintStyle.Render("Esc to cancel"),
 	)
 
 	return lipgloss.Place(
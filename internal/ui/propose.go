@@ -0,0 +1,62 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"maily/internal/calendar"
+)
+
+// Free times offered from compose: 30-minute meetings in working hours
+// over the next week, as in `maily c free`
+const (
+	proposeDuration = 30 * time.Minute
+	proposeWithin   = "7d"
+	proposeMaxSlots = 6
+)
+
+type proposedTimesMsg struct {
+	text  string
+	count int
+}
+
+type proposeTimesErrorMsg struct {
+	err error
+}
+
+// proposeTimes looks up the current account's free slots and formats them
+// for the message body
+func (a *App) proposeTimes() tea.Cmd {
+	account := a.currentAccount()
+	if account == nil {
+		return func() tea.Msg {
+			return proposeTimesErrorMsg{err: fmt.Errorf("no account configured")}
+		}
+	}
+
+	return func() tea.Msg {
+		start, end, err := calendar.ParseWithin(proposeWithin, time.Now())
+		if err != nil {
+			return proposeTimesErrorMsg{err: err}
+		}
+		client, err := accountCalendar(account)
+		if err != nil {
+			return proposeTimesErrorMsg{err: err}
+		}
+		events, err := client.ListEvents(start, end)
+		if err != nil {
+			return proposeTimesErrorMsg{err: err}
+		}
+
+		slots := calendar.FreeSlots(events, start, end, proposeDuration, calendar.DefaultWorkingHours)
+		if len(slots) == 0 {
+			return proposeTimesErrorMsg{err: fmt.Errorf("no free slots in the next week")}
+		}
+		if len(slots) > proposeMaxSlots {
+			slots = slots[:proposeMaxSlots]
+		}
+		text := "Would any of these times work for you?\n\n" + calendar.FormatSlots(slots)
+		return proposedTimesMsg{text: text, count: len(slots)}
+	}
+}
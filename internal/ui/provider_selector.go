@@ -16,6 +16,8 @@ type provider struct {
 var providers = []provider{
 	{id: "gmail", name: "Gmail", desc: "Google Mail"},
 	{id: "yahoo", name: "Yahoo Mail", desc: "Yahoo Mail"},
+	{id: "outlook", name: "Outlook", desc: "Outlook.com and Microsoft 365"},
+	{id: "jmap", name: "JMAP", desc: "Fastmail, Stalwart and other JMAP servers"},
 }
 
 type ProviderSelector struct {
@@ -46,7 +46,7 @@ const (
 type SearchApp struct {
 	account           *auth.Account
 	query             string
-	imap              *mail.IMAPClient
+	imap              mail.Store
 	emails            []mail.Email
 	selected          map[int]bool
 	cursor            int
@@ -103,7 +103,7 @@ func (a SearchApp) Init() tea.Cmd {
 
 func (a SearchApp) connect() tea.Cmd {
 	return func() tea.Msg {
-		client, err := mail.NewIMAPClient(&a.account.Credentials)
+		client, err := mail.Dial(&a.account.Credentials)
 		if err != nil {
 			return searchErrorMsg{err: err}
 		}
@@ -216,8 +216,8 @@ func (a SearchApp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			a.state = searchStateDone
 		}
 		// Store the IMAP client for later actions
-		client, _ := mail.NewIMAPClient(&a.account.Credentials)
-		if client != nil {
+		client, err := mail.Dial(&a.account.Credentials)
+		if err == nil {
 			client.SelectMailbox("INBOX")
 		}
 		a.imap = client
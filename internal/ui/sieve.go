@@ -0,0 +1,403 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"maily/internal/auth"
+	"maily/internal/mail"
+	"maily/internal/ui/components"
+)
+
+// newSieveScript is what a new script starts with
+const newSieveScript = "require [\"fileinto\"];\n\n"
+
+// sieveResultMsg is the result of a ManageSieve command the editor ran
+type sieveResultMsg struct {
+	op         string // list, get, put, check or activate
+	scripts    []mail.SieveScript
+	extensions []string
+	name       string
+	content    string
+	warnings   string
+	err        error
+}
+
+// CloseSieveMsg is sent when the Sieve editor is closed
+type CloseSieveMsg struct{}
+
+// SieveModel edits the filter scripts on the account's ManageSieve server:
+// a list of scripts, and an editor that checks and uploads them
+type SieveModel struct {
+	creds      *auth.Credentials
+	scripts    []mail.SieveScript
+	extensions []string
+	cursor     int
+	loaded     bool
+
+	editing  bool
+	name     string // script being edited
+	original string // its content on the server
+	editor   textarea.Model
+	discard  bool // esc pressed once on unsaved changes
+
+	naming    bool
+	nameInput textinput.Model
+
+	pending bool
+	status  string
+	err     error
+	width   int
+	height  int
+}
+
+// NewSieveModel creates an editor for the scripts of an account
+func NewSieveModel(creds *auth.Credentials, width, height int) SieveModel {
+	ta := textarea.New()
+	ta.CharLimit = 0
+	ta.ShowLineNumbers = true
+
+	ti := textinput.New()
+	ti.Placeholder = "script name"
+	ti.CharLimit = 100
+
+	m := SieveModel{creds: creds, editor: ta, nameInput: ti, pending: true}
+	m.SetSize(width, height)
+	return m
+}
+
+// openSieve opens the Sieve editor for the current account
+func (a *App) openSieve() tea.Cmd {
+	account := a.currentAccount()
+	if account == nil {
+		return nil
+	}
+	if _, err := mail.SieveAddr(&account.Credentials); err != nil {
+		a.statusMsg = "Sieve: " + err.Error()
+		return nil
+	}
+	a.sieve = NewSieveModel(&account.Credentials, a.width, a.height)
+	a.sieveReturn = a.view
+	a.view = sieveView
+	return a.sieve.list()
+}
+
+// SetSize resizes the editor
+func (m *SieveModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+	m.editor.SetWidth(max(20, width-8))
+	m.editor.SetHeight(max(5, height-12))
+	m.nameInput.Width = max(20, width/3)
+}
+
+func (m SieveModel) Update(msg tea.Msg) (SieveModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case sieveResultMsg:
+		return m.handleResult(msg)
+
+	case tea.KeyMsg:
+		switch {
+		case m.naming:
+			return m.updateNaming(msg)
+		case m.editing:
+			return m.updateEditor(msg)
+		}
+		return m.updateList(msg)
+	}
+	return m, nil
+}
+
+func (m SieveModel) handleResult(msg sieveResultMsg) (SieveModel, tea.Cmd) {
+	m.pending = false
+	m.err = msg.err
+	if msg.op != "list" {
+		m.status = ""
+	}
+	if msg.err != nil {
+		return m, nil
+	}
+
+	switch msg.op {
+	case "list":
+		m.scripts, m.extensions, m.loaded = msg.scripts, msg.extensions, true
+		m.cursor = min(m.cursor, max(0, len(m.scripts)-1))
+	case "get":
+		m.edit(msg.name, msg.content)
+		return m, textarea.Blink
+	case "check":
+		m.status = "✓ Script is valid"
+	case "put":
+		m.original = msg.content
+		m.status = "✓ Saved " + msg.name
+		if m.active() != msg.name {
+			m.status += " - a to make it active"
+		}
+		// Pick up a new script in the list
+		m = m.withWarnings(msg.warnings)
+		cmd := m.list()
+		return m, cmd
+	case "activate":
+		if msg.name == "" {
+			m.status = "✓ Filtering turned off"
+		} else {
+			m.status = "✓ " + msg.name + " is now active"
+		}
+		cmd := m.list()
+		return m, cmd
+	}
+	return m.withWarnings(msg.warnings), nil
+}
+
+func (m SieveModel) withWarnings(warnings string) SieveModel {
+	if warnings != "" {
+		m.status += " (warning: " + warnings + ")"
+	}
+	return m
+}
+
+// active returns the name of the active script, if any
+func (m SieveModel) active() string {
+	for _, s := range m.scripts {
+		if s.Active {
+			return s.Name
+		}
+	}
+	return ""
+}
+
+func (m *SieveModel) edit(name, content string) {
+	m.editing = true
+	m.discard = false
+	m.name = name
+	m.original = content
+	m.editor.SetValue(content)
+	m.editor.Focus()
+}
+
+func (m SieveModel) updateList(msg tea.KeyMsg) (SieveModel, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q":
+		return m, func() tea.Msg { return CloseSieveMsg{} }
+	case "j", "down":
+		if m.cursor < len(m.scripts)-1 {
+			m.cursor++
+		}
+	case "k", "up":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "r":
+		cmd := m.list()
+		return m, cmd
+	case "n":
+		m.naming = true
+		m.nameInput.SetValue("")
+		m.nameInput.Focus()
+		return m, textinput.Blink
+	case "enter":
+		if m.cursor < len(m.scripts) && !m.pending {
+			cmd := m.get(m.scripts[m.cursor].Name)
+			return m, cmd
+		}
+	case "a":
+		// Toggle the selected script as the active one
+		if m.cursor < len(m.scripts) && !m.pending {
+			name := m.scripts[m.cursor].Name
+			if m.scripts[m.cursor].Active {
+				name = ""
+			}
+			cmd := m.activate(name)
+			return m, cmd
+		}
+	}
+	return m, nil
+}
+
+func (m SieveModel) updateNaming(msg tea.KeyMsg) (SieveModel, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.naming = false
+		return m, nil
+	case "enter":
+		name := strings.TrimSpace(m.nameInput.Value())
+		if name == "" {
+			return m, nil
+		}
+		m.naming = false
+		for _, s := range m.scripts {
+			if s.Name == name {
+				cmd := m.get(name)
+				return m, cmd
+			}
+		}
+		m.edit(name, newSieveScript)
+		m.original = ""
+		return m, textarea.Blink
+	}
+	var cmd tea.Cmd
+	m.nameInput, cmd = m.nameInput.Update(msg)
+	return m, cmd
+}
+
+func (m SieveModel) updateEditor(msg tea.KeyMsg) (SieveModel, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		if m.editor.Value() != m.original && !m.discard {
+			m.discard = true
+			m.status = "Unsaved changes - esc again to discard, ctrl+s to save"
+			return m, nil
+		}
+		m.editing = false
+		m.editor.Blur()
+		m.status = ""
+		return m, nil
+	case "ctrl+s":
+		if !m.pending {
+			cmd := m.put(m.name, m.editor.Value())
+			return m, cmd
+		}
+		return m, nil
+	case "ctrl+k":
+		if !m.pending {
+			cmd := m.check(m.editor.Value())
+			return m, cmd
+		}
+		return m, nil
+	}
+	m.discard = false
+	var cmd tea.Cmd
+	m.editor, cmd = m.editor.Update(msg)
+	return m, cmd
+}
+
+// run connects to the server, runs fn and closes the connection
+func (m *SieveModel) run(op string, fn func(c *mail.SieveClient, result *sieveResultMsg) error) tea.Cmd {
+	m.pending = true
+	m.err = nil
+	creds := m.creds
+	return func() tea.Msg {
+		result := sieveResultMsg{op: op}
+		client, err := mail.NewSieveClient(creds)
+		if err != nil {
+			result.err = err
+			return result
+		}
+		defer client.Close()
+		result.err = fn(client, &result)
+		return result
+	}
+}
+
+func (m *SieveModel) list() tea.Cmd {
+	return m.run("list", func(c *mail.SieveClient, r *sieveResultMsg) error {
+		var err error
+		r.scripts, err = c.Scripts()
+		r.extensions = c.Extensions()
+		return err
+	})
+}
+
+func (m *SieveModel) get(name string) tea.Cmd {
+	m.status = "Opening " + name + "..."
+	return m.run("get", func(c *mail.SieveClient, r *sieveResultMsg) error {
+		var err error
+		r.name = name
+		r.content, err = c.Script(name)
+		return err
+	})
+}
+
+func (m *SieveModel) put(name, content string) tea.Cmd {
+	m.status = "Saving..."
+	return m.run("put", func(c *mail.SieveClient, r *sieveResultMsg) error {
+		var err error
+		r.name, r.content = name, content
+		r.warnings, err = c.PutScript(name, content)
+		return err
+	})
+}
+
+func (m *SieveModel) check(content string) tea.Cmd {
+	m.status = "Checking..."
+	return m.run("check", func(c *mail.SieveClient, r *sieveResultMsg) error {
+		var err error
+		r.warnings, err = c.CheckScript(content)
+		return err
+	})
+}
+
+func (m *SieveModel) activate(name string) tea.Cmd {
+	return m.run("activate", func(c *mail.SieveClient, r *sieveResultMsg) error {
+		r.name = name
+		return c.SetActive(name)
+	})
+}
+
+func (m SieveModel) View() string {
+	title := components.DialogTitleStyle.Foreground(components.Primary).Render("Sieve Filters")
+	subtitle := components.HelpDescStyle.Render(m.creds.Email + " · run by the server on delivery")
+	errorStyle := lipgloss.NewStyle().Foreground(components.Warning).Width(max(20, m.width-8))
+
+	var body, help string
+	switch {
+	case m.editing:
+		title = components.DialogTitleStyle.Foreground(components.Primary).Render("Sieve: " + m.name)
+		if m.name == m.active() {
+			subtitle = components.HelpDescStyle.Render("active")
+		} else {
+			subtitle = components.HelpDescStyle.Render("not active")
+		}
+		body = m.editor.View()
+		help = components.HelpKeyStyle.Render("ctrl+s") + components.HelpDescStyle.Render(" save  ") +
+			components.HelpKeyStyle.Render("ctrl+k") + components.HelpDescStyle.Render(" check  ") +
+			components.HelpKeyStyle.Render("esc") + components.HelpDescStyle.Render(" back")
+
+	default:
+		var b strings.Builder
+		switch {
+		case !m.loaded && m.err == nil:
+			b.WriteString(components.HelpDescStyle.Render("Loading scripts..."))
+		case m.loaded && len(m.scripts) == 0:
+			b.WriteString(components.HelpDescStyle.Render("No scripts on the server. Press n to write one."))
+		}
+		for i, s := range m.scripts {
+			line := s.Name
+			if s.Active {
+				line += components.SuccessStyle.Render("  active")
+			}
+			if i == m.cursor {
+				b.WriteString(components.HelpKeyStyle.Render("▸ ") + line + "\n")
+			} else {
+				b.WriteString("  " + line + "\n")
+			}
+		}
+		if len(m.extensions) > 0 {
+			b.WriteString("\n" + components.HelpDescStyle.Width(max(20, m.width-8)).Render("Extensions: "+strings.Join(m.extensions, " ")))
+		}
+		body = b.String()
+		if m.naming {
+			body += "\n\nNew script: " + m.nameInput.View()
+		}
+		help = components.HelpKeyStyle.Render("enter") + components.HelpDescStyle.Render(" edit  ") +
+			components.HelpKeyStyle.Render("n") + components.HelpDescStyle.Render(" new  ") +
+			components.HelpKeyStyle.Render("a") + components.HelpDescStyle.Render(" (de)activate  ") +
+			components.HelpKeyStyle.Render("r") + components.HelpDescStyle.Render(" reload  ") +
+			components.HelpKeyStyle.Render("esc") + components.HelpDescStyle.Render(" close")
+	}
+
+	parts := []string{title + "  " + subtitle, "", body, ""}
+	switch {
+	case m.err != nil:
+		parts = append(parts, errorStyle.Render("Error: "+m.err.Error()))
+	case m.status != "":
+		parts = append(parts, components.HelpDescStyle.Render(m.status))
+	}
+	parts = append(parts, "", help)
+
+	return lipgloss.NewStyle().Padding(1, 4).Render(lipgloss.JoinVertical(lipgloss.Left, parts...))
+}
@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"time"
+
+	"maily/config"
+	"maily/internal/calendar"
+)
+
+// originalTimes returns when event happens in the zone it was scheduled in,
+// e.g. "9:00 AM - 10:00 AM PDT", or "" if that zone's clock is the local one
+func originalTimes(event calendar.Event) string {
+	loc, ok := event.ForeignZone()
+	if !ok {
+		return ""
+	}
+	return zoneTimes(event, loc)
+}
+
+// zoneTimes returns when event happens in loc, e.g. "6:00 PM - 7:00 PM CEST"
+func zoneTimes(event calendar.Event, loc *time.Location) string {
+	start := event.StartTime.In(loc)
+	return start.Format("3:04 PM") + " - " + event.EndTime.In(loc).Format("3:04 PM") + " " + calendar.ZoneLabel(loc, start)
+}
+
+// sameClock reports whether loc shows the local time at t
+func sameClock(loc *time.Location, t time.Time) bool {
+	_, offset := t.In(loc).Zone()
+	_, local := t.Local().Zone()
+	return offset == local
+}
+
+// loadSecondaryZone returns the zone shown next to local times in the
+// calendar views (secondary_time_zone in config.json), nil if there is none
+func loadSecondaryZone() *time.Location {
+	cfg, err := config.Load()
+	if err != nil || cfg.SecondaryTimeZone == "" {
+		return nil
+	}
+	loc, err := calendar.ResolveTimeZone(cfg.SecondaryTimeZone)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
@@ -47,7 +47,8 @@ type AccountEmails struct {
 type TodayApp struct {
 	store        *auth.AccountStore
 	calClient    calendar.Client
-	imapClients  map[int]*mail.IMAPClient
+	secondary    *time.Location // zone shown next to local times, may be nil
+	imapClients  map[int]mail.Store
 	width        int
 	height       int
 	activePanel  panel
@@ -76,7 +77,7 @@ type TodayApp struct {
 	editFormEnd      textinput.Model
 	editFormLocation textinput.Model
 	editFormFocus    int
-	editEventID      string
+	editEvent        calendar.Event // event being edited
 }
 
 // Messages
@@ -92,7 +93,7 @@ type todayEventsLoadedMsg struct {
 
 type todayClientReadyMsg struct {
 	accountIdx int
-	imap       *mail.IMAPClient
+	imap       mail.Store
 }
 
 type todayErrMsg struct {
@@ -115,7 +116,8 @@ func NewTodayApp(store *auth.AccountStore, calClient calendar.Client) *TodayApp
 	return &TodayApp{
 		store:         store,
 		calClient:     calClient,
-		imapClients:   make(map[int]*mail.IMAPClient),
+		secondary:     loadSecondaryZone(),
+		imapClients:   make(map[int]mail.Store),
 		activePanel:   emailPanel,
 		view:          todayDashboard,
 		loading:       true,
@@ -147,7 +149,7 @@ func (m *TodayApp) initEmailClient(accountIdx int) tea.Cmd {
 		}
 
 		account := m.store.Accounts[accountIdx]
-		client, err := mail.NewIMAPClient(&account.Credentials)
+		client, err := mail.Dial(&account.Credentials)
 		if err != nil {
 			return todayErrMsg{err}
 		}
@@ -438,9 +440,15 @@ func (m *TodayApp) handleDeleteConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.removeEmailByUID(uid)
 			m.view = todayDashboard
 		} else if m.activePanel == eventPanel && len(m.events) > 0 && m.eventCursor < len(m.events) {
-			// Delete event
+			// Delete event (only this occurrence of a recurring one)
 			event := m.events[m.eventCursor]
-			return m, m.deleteEvent(event.ID)
+			return m, m.deleteEvent(event, calendar.SpanThisEvent)
+		}
+		return m, nil
+
+	case "f", "F":
+		if m.activePanel == eventPanel && m.eventCursor < len(m.events) && m.events[m.eventCursor].IsRecurring() {
+			return m, m.deleteEvent(m.events[m.eventCursor], calendar.SpanFutureEvents)
 		}
 		return m, nil
 
@@ -709,6 +717,16 @@ func (m *TodayApp) renderEventLine(event calendar.Event, isCursor bool, maxWidth
 	timeStr := event.StartTime.Format("3:04pm")
 	if event.AllDay {
 		timeStr = "All day"
+	} else {
+		if loc, ok := event.ForeignZone(); ok {
+			timeStr += " (" + event.StartTime.In(loc).Format("3:04pm") + " " + calendar.ZoneLabel(loc, event.StartTime) + ")"
+		}
+		if m.secondary != nil && !sameClock(m.secondary, event.StartTime) {
+			timeStr += " · " + event.StartTime.In(m.secondary).Format("3:04pm") + " " + calendar.ZoneLabel(m.secondary, event.StartTime)
+		}
+	}
+	if event.IsRecurring() {
+		timeStr += " ↻"
 	}
 
 	timeStyle := lipgloss.NewStyle().Foreground(components.Muted)
@@ -838,12 +856,22 @@ func (m *TodayApp) removeEmailByUID(uid imap.UID) {
 	}
 }
 
-func (m *TodayApp) deleteEvent(eventID string) tea.Cmd {
+// deleteEvent deletes the event, or for a recurring event the occurrences
+// selected by span
+func (m *TodayApp) deleteEvent(event calendar.Event, span calendar.Span) tea.Cmd {
 	return func() tea.Msg {
-		err := m.calClient.DeleteEvent(eventID)
+		var err error
+		if event.IsRecurring() {
+			err = m.calClient.DeleteOccurrence(event.ID, event.OccurrenceDate, span)
+		} else {
+			err = m.calClient.DeleteEvent(event.ID)
+		}
 		if err != nil {
 			return todayErrMsg{err}
 		}
+		if err := notifyAttendees(m.store, m.calClient, calendar.MethodCancel, event, span); err != nil {
+			return todayErrMsg{fmt.Errorf("event deleted, but sending cancellations failed: %w", err)}
+		}
 		return todayEventDeletedMsg{}
 	}
 }
@@ -870,7 +898,7 @@ func (m *TodayApp) initEditEventForm(event calendar.Event) {
 	m.editFormLocation.SetValue(event.Location)
 
 	m.editFormFocus = 0
-	m.editEventID = event.ID
+	m.editEvent = event
 }
 
 func (m *TodayApp) updateEditFormFocus() {
@@ -916,22 +944,20 @@ func (m *TodayApp) saveEditedEvent() tea.Cmd {
 		end := time.Date(date.Year(), date.Month(), date.Day(),
 			endTime.Hour(), endTime.Minute(), 0, 0, time.Local)
 
-		// Delete old event first (EventKit doesn't have update)
-		if m.editEventID != "" {
-			_ = m.calClient.DeleteEvent(m.editEventID)
-		}
-
-		event := calendar.Event{
-			Title:     m.editFormTitle.Value(),
-			StartTime: start,
-			EndTime:   end,
-			Location:  m.editFormLocation.Value(),
-		}
+		// Update in place so notes, alarms, attendees and recurrence survive
+		event := m.editEvent
+		event.Title = m.editFormTitle.Value()
+		event.StartTime = start
+		event.EndTime = end
+		event.Location = m.editFormLocation.Value()
 
-		_, err = m.calClient.CreateEvent(event)
-		if err != nil {
+		if err := m.calClient.UpdateEvent(event); err != nil {
 			return todayErrMsg{err}
 		}
+		event.Sequence++
+		if err := notifyAttendees(m.store, m.calClient, calendar.MethodRequest, event, calendar.SpanThisEvent); err != nil {
+			return todayErrMsg{fmt.Errorf("event saved, but sending invitations failed: %w", err)}
+		}
 
 		return todayEventUpdatedMsg{}
 	}
@@ -947,16 +973,20 @@ func (m *TodayApp) renderDeleteConfirm() string {
 	b.WriteString(titleStyle.Render("Delete?"))
 	b.WriteString("\n\n")
 
+	help := "y: yes  n: no"
 	if m.activePanel == emailPanel && m.emailCursor < len(m.emails) {
 		email := m.emails[m.emailCursor]
 		b.WriteString(fmt.Sprintf("Delete email: \"%s\"?\n\n", utils.TruncateStr(email.Subject, 40)))
 	} else if m.activePanel == eventPanel && m.eventCursor < len(m.events) {
 		event := m.events[m.eventCursor]
 		b.WriteString(fmt.Sprintf("Delete event: \"%s\"?\n\n", utils.TruncateStr(event.Title, 40)))
+		if event.IsRecurring() {
+			help = "y: this occurrence  f: all future  n: no"
+		}
 	}
 
 	helpStyle := lipgloss.NewStyle().Foreground(components.Muted)
-	b.WriteString(helpStyle.Render("y: yes  n: no"))
+	b.WriteString(helpStyle.Render(help))
 
 	dialogStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
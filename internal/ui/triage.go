@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"fmt"
+
+	"maily/internal/ai"
+	"maily/internal/cache"
+	"maily/internal/mail"
+)
+
+// withTriage copies the triage of cached emails onto emails fetched from
+// the server, which don't carry it
+func withTriage(diskCache *cache.Cache, account, mailbox string, emails []mail.Email) []mail.Email {
+	if diskCache == nil || account == "" {
+		return emails
+	}
+	cached, err := diskCache.LoadEmails(account, mailbox)
+	if err != nil {
+		return emails
+	}
+	triages := make(map[string]*cache.Triage)
+	for _, c := range cached {
+		if c.Triage != nil && c.MessageID != "" {
+			triages[c.MessageID] = c.Triage
+		}
+	}
+	for i := range emails {
+		if t := triages[emails[i].MessageID]; t != nil {
+			emails[i].Category, emails[i].Priority = t.Category, t.Priority
+		}
+	}
+	return emails
+}
+
+// nextTriageCategory returns the category after current in the filter
+// cycle: all, then each of ai.Categories
+func nextTriageCategory(current string) string {
+	if current == "" {
+		return ai.Categories[0]
+	}
+	for i, c := range ai.Categories {
+		if c == current && i+1 < len(ai.Categories) {
+			return ai.Categories[i+1]
+		}
+	}
+	return ""
+}
+
+// triageStatus describes the triage view of the list for the status bar
+func (a App) triageStatus() string {
+	category := "all categories"
+	if a.triageCategory != "" {
+		category = a.triageCategory
+	}
+	order := "by date"
+	if a.triageByPriority {
+		order = "by priority"
+	}
+	return fmt.Sprintf("Showing %s, %s: %d emails", category, order, a.mailList.Len())
+}